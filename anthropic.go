@@ -0,0 +1,382 @@
+package fastcommit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultAnthropicURL is the base URL of Anthropic's API.
+	DefaultAnthropicURL = "https://api.anthropic.com"
+	// DefaultAnthropicModel writes good commit messages, and
+	// DefaultAnthropicSmallModel is a cheaper one for summaries.
+	DefaultAnthropicModel      = "claude-3-5-sonnet-latest"
+	DefaultAnthropicSmallModel = "claude-3-5-haiku-latest"
+	// anthropicVersion is the version of the Messages API spoken.
+	anthropicVersion = "2023-06-01"
+	// anthropicMaxTokens bounds the response when a request does not; the
+	// Messages API requires a bound.
+	anthropicMaxTokens = 4096
+)
+
+// AnthropicProvider talks to Anthropic's Messages API, which takes the
+// system prompt apart from the messages and streams events of its own.
+type AnthropicProvider struct {
+	Key string
+	// BaseURL is the API's URL, e.g. DefaultAnthropicURL.
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewAnthropicProvider returns a provider for the API at baseURL, or at
+// DefaultAnthropicURL if it is empty. Its errors are *openai.APIError, with
+// the delay the server asks for as returned by RetryAfter.
+func NewAnthropicProvider(key, baseURL string) AnthropicProvider {
+	if baseURL == "" {
+		baseURL = DefaultAnthropicURL
+	}
+	return AnthropicProvider{Key: key, BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float32            `json:"temperature"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
+}
+
+type anthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// anthropicRequestBody maps req onto the Messages API: system messages go
+// into the system prompt, since the API has no system role, and consecutive
+// messages of the same role are joined, since roles must alternate. A
+// response format becomes an instruction, there being no such option.
+func anthropicRequestBody(req openai.ChatCompletionRequest, stream bool) anthropicRequest {
+	body := anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+	if body.MaxTokens == 0 {
+		body.MaxTokens = anthropicMaxTokens
+	}
+	var system []string
+	for _, m := range req.Messages {
+		if m.Role == openai.ChatMessageRoleSystem {
+			system = append(system, m.Content)
+			continue
+		}
+		role := openai.ChatMessageRoleUser
+		if m.Role == openai.ChatMessageRoleAssistant {
+			role = openai.ChatMessageRoleAssistant
+		}
+		if n := len(body.Messages); n > 0 && body.Messages[n-1].Role == role {
+			body.Messages[n-1].Content += "\n\n" + m.Content
+			continue
+		}
+		body.Messages = append(body.Messages, anthropicMessage{Role: role, Content: m.Content})
+	}
+	if rf := req.ResponseFormat; rf != nil {
+		instruction := "Reply with a single JSON object and nothing else."
+		if rf.JSONSchema != nil {
+			if schema, err := json.Marshal(rf.JSONSchema.Schema); err == nil {
+				instruction += " It must follow this JSON schema: " + string(schema)
+			}
+		}
+		system = append(system, instruction)
+	}
+	body.System = strings.Join(system, "\n\n")
+	return body
+}
+
+// post sends a request to the Messages API and returns the response.
+func (p AnthropicProvider) post(ctx context.Context, req openai.ChatCompletionRequest, stream bool) (*http.Response, error) {
+	b, err := json.Marshal(anthropicRequestBody(req, stream))
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/v1/messages", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return p.do(httpReq)
+}
+
+// do sends req with the API's headers, turning error statuses into
+// *openai.APIError so that they are retried and explained as OpenAI's are.
+func (p AnthropicProvider) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("x-api-key", p.Key)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var body struct {
+		Error anthropicError `json:"error"`
+	}
+	if json.Unmarshal(b, &body) != nil || body.Error.Message == "" {
+		body.Error.Message = strings.TrimSpace(string(b))
+	}
+	err = anthropicAPIError(resp.StatusCode, body.Error)
+	if d, ok := parseRetryAfter(resp.Header, time.Now()); ok {
+		err = &retryAfterError{err: err, after: d}
+	}
+	return nil, err
+}
+
+// anthropicAPIError returns e, received with the HTTP status, as an
+// *openai.APIError. A prompt too long for the model gets OpenAI's code for
+// it.
+func anthropicAPIError(status int, e anthropicError) error {
+	apiErr := &openai.APIError{
+		Type:           e.Type,
+		Message:        "anthropic: " + e.Message,
+		HTTPStatusCode: status,
+	}
+	if strings.Contains(e.Message, "prompt is too long") {
+		apiErr.Code = "context_length_exceeded"
+	}
+	return apiErr
+}
+
+// anthropicStatuses are the HTTP statuses of the errors that can arrive in
+// the middle of a stream, which has already answered with 200.
+var anthropicStatuses = map[string]int{
+	"overloaded_error":      529,
+	"api_error":             http.StatusInternalServerError,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"invalid_request_error": http.StatusBadRequest,
+}
+
+func anthropicFinishReason(stopReason string) openai.FinishReason {
+	if stopReason == "max_tokens" {
+		return openai.FinishReasonLength
+	}
+	return openai.FinishReasonStop
+}
+
+func (p AnthropicProvider) StreamCompletion(
+	ctx context.Context,
+	req openai.ChatCompletionRequest,
+) (CompletionStream, error) {
+	resp, err := p.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	return &anthropicStream{resp: resp, scanner: bufio.NewScanner(resp.Body)}, nil
+}
+
+// CreateCompletion asks for each of req.N choices separately, since the
+// Messages API generates one per request.
+func (p AnthropicProvider) CreateCompletion(
+	ctx context.Context,
+	req openai.ChatCompletionRequest,
+) (openai.ChatCompletionResponse, error) {
+	var resp openai.ChatCompletionResponse
+	for i := 0; i < max(req.N, 1); i++ {
+		httpResp, err := p.post(ctx, req, false)
+		if err != nil {
+			return resp, err
+		}
+		var r anthropicResponse
+		err = json.NewDecoder(httpResp.Body).Decode(&r)
+		httpResp.Body.Close()
+		if err != nil {
+			return resp, fmt.Errorf("anthropic: decode response: %w", err)
+		}
+		var text strings.Builder
+		for _, c := range r.Content {
+			if c.Type == "text" {
+				text.WriteString(c.Text)
+			}
+		}
+		resp.Model = r.Model
+		resp.Choices = append(resp.Choices, openai.ChatCompletionChoice{
+			Index:        i,
+			Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: text.String()},
+			FinishReason: anthropicFinishReason(r.StopReason),
+		})
+		resp.Usage.PromptTokens += r.Usage.InputTokens
+		resp.Usage.CompletionTokens += r.Usage.OutputTokens
+		resp.Usage.TotalTokens += r.Usage.InputTokens + r.Usage.OutputTokens
+	}
+	return resp, nil
+}
+
+func (p AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/v1/models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&models); err != nil {
+		return nil, fmt.Errorf("anthropic: decode models: %w", err)
+	}
+	var ids []string
+	for _, m := range models.Data {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
+// anthropicStream turns the server-sent events of the Messages API into
+// chunks: one per text delta, one with the finish reason, and a last one
+// with the usage, as OpenAI streams them with include_usage.
+type anthropicStream struct {
+	resp    *http.Response
+	scanner *bufio.Scanner
+	model   string
+	usage   anthropicUsage
+	done    bool
+}
+
+func (s *anthropicStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	resp := openai.ChatCompletionStreamResponse{Model: s.model}
+	for !s.done {
+		if !s.scanner.Scan() {
+			if err := s.scanner.Err(); err != nil {
+				return resp, err
+			}
+			// The connection closed before message_stop.
+			return resp, io.ErrUnexpectedEOF
+		}
+		data, ok := bytes.CutPrefix(s.scanner.Bytes(), []byte("data:"))
+		if !ok {
+			// Event names repeat the type in the data; blank lines end
+			// events.
+			continue
+		}
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Model string         `json:"model"`
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type       string `json:"type"`
+				Text       string `json:"text"`
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage *anthropicUsage `json:"usage"`
+			Error anthropicError  `json:"error"`
+		}
+		if err := json.Unmarshal(bytes.TrimSpace(data), &event); err != nil {
+			return resp, fmt.Errorf("anthropic: decode event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			s.model = event.Message.Model
+			s.usage = event.Message.Usage
+			resp.Model = s.model
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" {
+				continue
+			}
+			resp.Choices = []openai.ChatCompletionStreamChoice{{
+				Delta: openai.ChatCompletionStreamChoiceDelta{Content: event.Delta.Text},
+			}}
+			return resp, nil
+		case "message_delta":
+			// The usage here is cumulative.
+			if event.Usage != nil {
+				s.usage.OutputTokens = event.Usage.OutputTokens
+			}
+			if event.Delta.StopReason != "" {
+				resp.Choices = []openai.ChatCompletionStreamChoice{{
+					FinishReason: anthropicFinishReason(event.Delta.StopReason),
+				}}
+				return resp, nil
+			}
+		case "message_stop":
+			s.done = true
+			resp.Usage = &openai.Usage{
+				PromptTokens:     s.usage.InputTokens,
+				CompletionTokens: s.usage.OutputTokens,
+				TotalTokens:      s.usage.InputTokens + s.usage.OutputTokens,
+			}
+			return resp, nil
+		case "error":
+			status, ok := anthropicStatuses[event.Error.Type]
+			if !ok {
+				status = http.StatusInternalServerError
+			}
+			return resp, anthropicAPIError(status, event.Error)
+		}
+	}
+	return resp, io.EOF
+}
+
+func (s *anthropicStream) Close() error {
+	return s.resp.Body.Close()
+}
+
+// GetRateLimitHeaders returns the request and token limits the API reported.
+// Its reset times are timestamps rather than durations and are left out.
+func (s *anthropicStream) GetRateLimitHeaders() openai.RateLimitHeaders {
+	h := s.resp.Header
+	atoi := func(name string) int {
+		n, _ := strconv.Atoi(h.Get(name))
+		return n
+	}
+	return openai.RateLimitHeaders{
+		LimitRequests:     atoi("anthropic-ratelimit-requests-limit"),
+		RemainingRequests: atoi("anthropic-ratelimit-requests-remaining"),
+		LimitTokens:       atoi("anthropic-ratelimit-tokens-limit"),
+		RemainingTokens:   atoi("anthropic-ratelimit-tokens-remaining"),
+	}
+}
@@ -0,0 +1,230 @@
+package fastcommit
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Automation is a recognized mechanical change, such as a version bump made
+// by a release bot, whose commit message follows a fixed pattern.
+type Automation struct {
+	// Kind is "revert", "release", "codegen" or "deps".
+	Kind string
+	// Type is the Conventional Commits type with its scope, e.g.
+	// "chore(deps)".
+	Type string
+	// Message is the complete commit message when it follows from the change
+	// alone, and "" when only the type is known.
+	Message string
+}
+
+// dependencyFiles are dependency manifests and lock files.
+var dependencyFiles = []string{
+	"go.mod", "go.sum", "package.json", "package-lock.json", "yarn.lock", "pnpm-lock.yaml",
+	"Cargo.toml", "Cargo.lock", "requirements*.txt", "poetry.lock", "Pipfile.lock",
+	"Gemfile", "Gemfile.lock", "composer.lock",
+}
+
+// lockFiles are generated alongside manifests; their changes follow from the
+// manifest's and are not looked at.
+var lockFiles = []string{
+	"go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock",
+	"poetry.lock", "Pipfile.lock", "Gemfile.lock", "composer.lock",
+}
+
+// generatedFiles are the outputs of code generators.
+var generatedFiles = []string{"*_gen.go", "*.pb.go"}
+
+// versionLineRes match the line holding the version in each kind of release
+// manifest.
+var versionLineRes = map[string]*regexp.Regexp{
+	"package.json":   regexp.MustCompile(`^\s*"version"\s*:\s*"([^"]+)",?\s*$`),
+	"composer.json":  regexp.MustCompile(`^\s*"version"\s*:\s*"([^"]+)",?\s*$`),
+	"Cargo.toml":     regexp.MustCompile(`^\s*version\s*=\s*"([^"]+)"\s*$`),
+	"pyproject.toml": regexp.MustCompile(`^\s*version\s*=\s*"([^"]+)"\s*$`),
+	"Chart.yaml":     regexp.MustCompile(`^version:\s*"?([^"\s]+)"?\s*$`),
+	"VERSION":        regexp.MustCompile(`^\s*(v?\d[^\s]*)\s*$`),
+	"version.txt":    regexp.MustCompile(`^\s*(v?\d[^\s]*)\s*$`),
+}
+
+// dependencyLineRes match a single dependency requirement, capturing its
+// name and version, in each kind of dependency manifest.
+var dependencyLineRes = map[string]*regexp.Regexp{
+	"go.mod":           regexp.MustCompile(`^\s*(?:require\s+)?([^\s()]+)\s+(v[^\s]+)(?:\s*//.*)?$`),
+	"package.json":     regexp.MustCompile(`^\s*"([^"]+)"\s*:\s*"([^"]+)",?\s*$`),
+	"Cargo.toml":       regexp.MustCompile(`^\s*([A-Za-z0-9_-]+)\s*=\s*(?:\{.*\bversion\s*=\s*)?"([^"]+)".*$`),
+	"requirements.txt": regexp.MustCompile(`^\s*([A-Za-z0-9._\[\],-]+)\s*==\s*([^\s;#]+)`),
+}
+
+// DetectAutomation recognizes the changes described by opts as one of a few
+// well-known mechanical operations: a revert in progress, a version bump in a
+// single release manifest, regenerated code, or updated dependencies.
+func DetectAutomation(dir string, opts PromptOptions) (Automation, bool, error) {
+	if opts.CommitHash == "" && opts.Range == "" {
+		if a, ok, err := detectRevert(dir); err != nil || ok {
+			return a, ok, err
+		}
+	}
+
+	diff, err := Diff(dir, opts)
+	if err != nil {
+		return Automation{}, false, err
+	}
+	files := SplitDiff(diff)
+	if len(files) == 0 {
+		return Automation{}, false, nil
+	}
+	for _, recognize := range []func([]FileDiff) (Automation, bool){
+		recognizeRelease, recognizeCodegen, recognizeDeps,
+	} {
+		if a, ok := recognize(files); ok {
+			return a, true, nil
+		}
+	}
+	return Automation{}, false, nil
+}
+
+// detectRevert recognizes the changes left staged by git revert --no-commit
+// or a revert with conflicts.
+func detectRevert(dir string) (Automation, bool, error) {
+	var buf bytes.Buffer
+	if err := runGit(&buf, dir, "rev-parse", "--verify", "-q", "REVERT_HEAD"); err != nil {
+		return Automation{}, false, nil
+	}
+	hash := strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := runGit(&buf, dir, "log", "-1", "--format=%s", hash); err != nil {
+		return Automation{}, false, err
+	}
+	return Automation{
+		Kind:    "revert",
+		Type:    "revert",
+		Message: fmt.Sprintf("revert: %s\n\nThis reverts commit %s.", strings.TrimSpace(buf.String()), hash),
+	}, true, nil
+}
+
+// recognizeRelease recognizes a change to nothing but the version line of a
+// single release manifest, along with any lock files.
+func recognizeRelease(files []FileDiff) (Automation, bool) {
+	version := ""
+	for _, f := range files {
+		if matchesAny(lockFiles, f.Path) {
+			continue
+		}
+		re, ok := versionLineRes[path.Base(f.Path)]
+		if !ok || version != "" {
+			return Automation{}, false
+		}
+		removed, added := changedLines(f.Diff)
+		if len(removed) != 1 || len(added) != 1 {
+			return Automation{}, false
+		}
+		old, nw := re.FindStringSubmatch(removed[0]), re.FindStringSubmatch(added[0])
+		if old == nil || nw == nil || old[1] == nw[1] {
+			return Automation{}, false
+		}
+		version = nw[1]
+	}
+	if version == "" {
+		return Automation{}, false
+	}
+	return Automation{
+		Kind:    "release",
+		Type:    "chore(release)",
+		Message: "chore(release): bump version to " + version,
+	}, true
+}
+
+// recognizeCodegen recognizes a change to nothing but generated code.
+func recognizeCodegen(files []FileDiff) (Automation, bool) {
+	var dirs []string
+	for _, f := range files {
+		if !matchesAny(generatedFiles, f.Path) {
+			return Automation{}, false
+		}
+		if d := path.Dir(f.Path); !slices.Contains(dirs, d) {
+			dirs = append(dirs, d)
+		}
+	}
+	msg := "chore(codegen): regenerate code"
+	if len(dirs) == 1 && dirs[0] != "." {
+		msg += " in " + dirs[0]
+	}
+	return Automation{Kind: "codegen", Type: "chore(codegen)", Message: msg}, true
+}
+
+// recognizeDeps recognizes a change to nothing but dependency files. The
+// message is only known when a single requirement changed its version.
+func recognizeDeps(files []FileDiff) (Automation, bool) {
+	a := Automation{Kind: "deps", Type: "chore(deps)"}
+	oldVersions, newVersions := map[string]string{}, map[string]string{}
+	parsed := true
+	for _, f := range files {
+		if !matchesAny(dependencyFiles, f.Path) {
+			return Automation{}, false
+		}
+		if matchesAny(lockFiles, f.Path) {
+			continue
+		}
+		base := path.Base(f.Path)
+		if MatchGlob("requirements*.txt", base) {
+			base = "requirements.txt"
+		}
+		re, ok := dependencyLineRes[base]
+		if !ok {
+			parsed = false
+			continue
+		}
+		removed, added := changedLines(f.Diff)
+		for _, lines := range []struct {
+			lines    []string
+			versions map[string]string
+		}{{removed, oldVersions}, {added, newVersions}} {
+			for _, l := range lines.lines {
+				m := re.FindStringSubmatch(l)
+				if m == nil {
+					parsed = false
+					continue
+				}
+				lines.versions[m[1]] = m[2]
+			}
+		}
+	}
+	if !parsed || len(oldVersions) != 1 || len(newVersions) != 1 {
+		return a, true
+	}
+	for name, from := range oldVersions {
+		if to, ok := newVersions[name]; ok && to != from {
+			a.Message = fmt.Sprintf("chore(deps): bump %s from %s to %s", name, from, to)
+		}
+	}
+	return a, true
+}
+
+// changedLines returns the removed and added lines of a single file's diff,
+// without their leading - or +.
+func changedLines(diff string) (removed, added []string) {
+	inHunk := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+		case strings.HasPrefix(line, "-"):
+			removed = append(removed, line[1:])
+		case strings.HasPrefix(line, "+"):
+			added = append(added, line[1:])
+		}
+	}
+	return removed, added
+}
+
+func matchesAny(patterns []string, p string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		return MatchGlob(pattern, p)
+	})
+}
@@ -0,0 +1,135 @@
+package fastcommit
+
+import (
+	"testing"
+)
+
+func TestDetectAutomation(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after map[string]string
+		want          Automation
+		ok            bool
+	}{
+		{
+			name:   "release",
+			before: map[string]string{"package.json": "{\n  \"name\": \"app\",\n  \"version\": \"1.2.3\",\n  \"private\": true\n}\n"},
+			after: map[string]string{
+				"package.json":      "{\n  \"name\": \"app\",\n  \"version\": \"1.3.0\",\n  \"private\": true\n}\n",
+				"package-lock.json": "{\n  \"version\": \"1.3.0\"\n}\n",
+			},
+			want: Automation{Kind: "release", Type: "chore(release)", Message: "chore(release): bump version to 1.3.0"},
+			ok:   true,
+		},
+		{
+			name:   "release of a nested chart",
+			before: map[string]string{"deploy/chart/Chart.yaml": "name: app\nversion: 0.4.1\n"},
+			after:  map[string]string{"deploy/chart/Chart.yaml": "name: app\nversion: 0.5.0\n"},
+			want:   Automation{Kind: "release", Type: "chore(release)", Message: "chore(release): bump version to 0.5.0"},
+			ok:     true,
+		},
+		{
+			name:   "version bump with another change",
+			before: map[string]string{"Cargo.toml": "[package]\nname = \"app\"\nversion = \"0.1.0\"\n", "src/main.rs": "fn main() {}\n"},
+			after:  map[string]string{"Cargo.toml": "[package]\nname = \"app\"\nversion = \"0.2.0\"\n", "src/main.rs": "fn main() { run() }\n"},
+		},
+		{
+			name:   "two manifests bumped",
+			before: map[string]string{"VERSION": "1.0.0\n", "pyproject.toml": "[project]\nversion = \"1.0.0\"\n"},
+			after:  map[string]string{"VERSION": "1.0.1\n", "pyproject.toml": "[project]\nversion = \"1.0.1\"\n"},
+		},
+		{
+			name:   "codegen",
+			before: map[string]string{"api/api.pb.go": "package api\n\n// v1\n", "api/enum_gen.go": "package api\n\n// v1\n"},
+			after:  map[string]string{"api/api.pb.go": "package api\n\n// v2\n", "api/enum_gen.go": "package api\n\n// v2\n"},
+			want:   Automation{Kind: "codegen", Type: "chore(codegen)", Message: "chore(codegen): regenerate code in api"},
+			ok:     true,
+		},
+		{
+			name:   "codegen with a hand-written file",
+			before: map[string]string{"api/api.pb.go": "package api\n\n// v1\n", "api/api.go": "package api\n"},
+			after:  map[string]string{"api/api.pb.go": "package api\n\n// v2\n", "api/api.go": "package api\n\n// Doc.\n"},
+		},
+		{
+			name: "single Go dependency",
+			before: map[string]string{
+				"go.mod": "module example.com/app\n\ngo 1.21\n\nrequire (\n\tgithub.com/google/uuid v1.5.0\n\tgolang.org/x/sync v0.6.0\n)\n",
+				"go.sum": "github.com/google/uuid v1.5.0 h1:old\n",
+			},
+			after: map[string]string{
+				"go.mod": "module example.com/app\n\ngo 1.21\n\nrequire (\n\tgithub.com/google/uuid v1.6.0\n\tgolang.org/x/sync v0.6.0\n)\n",
+				"go.sum": "github.com/google/uuid v1.6.0 h1:new\n",
+			},
+			want: Automation{Kind: "deps", Type: "chore(deps)", Message: "chore(deps): bump github.com/google/uuid from v1.5.0 to v1.6.0"},
+			ok:   true,
+		},
+		{
+			name:   "single pinned Python dependency",
+			before: map[string]string{"requirements-dev.txt": "pytest==7.4.0\nruff==0.1.0\n"},
+			after:  map[string]string{"requirements-dev.txt": "pytest==8.0.0\nruff==0.1.0\n"},
+			want:   Automation{Kind: "deps", Type: "chore(deps)", Message: "chore(deps): bump pytest from 7.4.0 to 8.0.0"},
+			ok:     true,
+		},
+		{
+			name:   "several dependencies",
+			before: map[string]string{"package.json": "{\n  \"dependencies\": {\n    \"react\": \"^18.2.0\",\n    \"vite\": \"^5.0.0\"\n  }\n}\n"},
+			after:  map[string]string{"package.json": "{\n  \"dependencies\": {\n    \"react\": \"^18.3.0\",\n    \"vite\": \"^5.1.0\"\n  }\n}\n"},
+			want:   Automation{Kind: "deps", Type: "chore(deps)"},
+			ok:     true,
+		},
+		{
+			name:   "source change",
+			before: map[string]string{"main.go": "package main\n"},
+			after:  map[string]string{"main.go": "package main\n\nfunc main() {}\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newRepo(t)
+			for name, content := range tt.before {
+				writeFile(t, dir, name, content)
+			}
+			gitT(t, dir, "add", "-A")
+			gitT(t, dir, "commit", "-q", "-m", "Before")
+			for name, content := range tt.after {
+				writeFile(t, dir, name, content)
+			}
+			gitT(t, dir, "add", "-A")
+
+			got, ok, err := DetectAutomation(dir, PromptOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("DetectAutomation = %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDetectAutomationRevert(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	gitT(t, dir, "add", "-A")
+	gitT(t, dir, "commit", "-q", "-m", "Add main")
+	hash := gitT(t, dir, "rev-parse", "HEAD")
+	gitT(t, dir, "revert", "--no-commit", "HEAD")
+
+	got, ok, err := DetectAutomation(dir, PromptOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Automation{Kind: "revert", Type: "revert", Message: "revert: Add main\n\nThis reverts commit " + hash + "."}
+	if !ok || got != want {
+		t.Errorf("DetectAutomation = %+v, %v; want %+v", got, ok, want)
+	}
+
+	// Describing a commit ignores the revert in progress.
+	got, ok, err = DetectAutomation(dir, PromptOptions{CommitHash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok && got.Kind == "revert" {
+		t.Errorf("DetectAutomation of %s = %+v", hash, got)
+	}
+}
@@ -0,0 +1,55 @@
+package fastcommit
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version used unless another
+// is given. It is the first generally available version that reports usage
+// when streaming.
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// IsAzure reports whether baseURL is an Azure OpenAI resource, such as
+// https://corp.openai.azure.com, which addresses models by deployment. Its
+// OpenAI-compatible API under /openai/v1 is not, since it takes requests as
+// the OpenAI API does.
+func IsAzure(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if !strings.HasSuffix(host, ".openai.azure.com") {
+		return false
+	}
+	return !strings.HasPrefix(strings.TrimSuffix(u.Path, "/")+"/", "/openai/v1/")
+}
+
+// NewAzureProvider returns a provider for the Azure OpenAI resource at
+// baseURL, authenticating with the resource's key and sending apiVersion,
+// or DefaultAzureAPIVersion if empty, with every request. Requests go to
+// deployment, or to the deployment named like the requested model if empty.
+// Older versions refusing StreamOptions get the request again without them,
+// as other compatible servers do.
+func NewAzureProvider(key, baseURL, deployment, apiVersion string) OpenAIProvider {
+	// go-openai adds the /openai path itself.
+	baseURL = strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/openai")
+	config := openai.DefaultAzureConfig(key, baseURL)
+	if apiVersion != "" {
+		config.APIVersion = apiVersion
+	} else {
+		config.APIVersion = DefaultAzureAPIVersion
+	}
+	config.AzureModelMapperFunc = func(model string) string {
+		if deployment != "" {
+			return deployment
+		}
+		return model
+	}
+	config.HTTPClient = &http.Client{Transport: retryAfterTransport{base: http.DefaultTransport}}
+	return OpenAIProvider{Client: openai.NewClientWithConfig(config)}
+}
@@ -0,0 +1,154 @@
+package fastcommit
+
+import (
+	"bytes"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxComponentDepth bounds how far DiffByComponent descends into a single
+// directory looking for components.
+const maxComponentDepth = 3
+
+// DiffChunk is the part of a diff that touches one component of the
+// repository.
+type DiffChunk struct {
+	// Component is the directory the chunk covers, or "." for files in the
+	// repository root.
+	Component string
+	// Paths lists the changed files in the component.
+	Paths []string
+	Diff  string
+}
+
+// DiffByComponent returns the changes described by opts split by directory,
+// sorted by component. Components are the top-level directories, unless all
+// changes are under a single one, in which case its subdirectories are used,
+// and so on.
+func DiffByComponent(dir string, opts PromptOptions) ([]DiffChunk, error) {
+	diff, err := Diff(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return splitByComponent(SplitDiff(diff)), nil
+}
+
+// Diff returns the changes described by opts as a single diff, with long
+// lines truncated as in the prompt.
+func Diff(dir string, opts PromptOptions) (string, error) {
+	var buf bytes.Buffer
+	if err := generateDiff(&buf, dir, opts); err != nil {
+		return "", err
+	}
+	maxLineLength := opts.MaxLineLength
+	if maxLineLength == 0 {
+		maxLineLength = DefaultMaxLineLength
+	}
+	return truncateLongLines(DescribeLineEndingChanges(excludedNote(dir, vendoredNote(buf.String(), opts.VendorDirs), opts)), maxLineLength), nil
+}
+
+// GroupByComponent groups paths into components the same way
+// DiffByComponent splits a diff. The returned chunks carry no diff.
+func GroupByComponent(paths []string) []DiffChunk {
+	files := make([]FileDiff, len(paths))
+	for i, p := range paths {
+		files[i].Path = p
+	}
+	return splitByComponent(files)
+}
+
+// splitByComponent groups files by top-level directory, descending while
+// everything is in a single one.
+func splitByComponent(files []FileDiff) []DiffChunk {
+	for depth := 1; ; depth++ {
+		chunks := groupByComponent(files, depth)
+		if len(chunks) != 1 || depth == maxComponentDepth {
+			return chunks
+		}
+	}
+}
+
+// FileDiff is the part of a diff that touches one file.
+type FileDiff struct {
+	// Path is the path of the file after the change.
+	Path string
+	Diff string
+}
+
+// SplitDiff splits a diff in git's format into its per-file parts. Text
+// before the first file header is dropped. The parts share the memory of
+// diff.
+func SplitDiff(diff string) []FileDiff {
+	var files []FileDiff
+	start := -1
+	for i := 0; i < len(diff); {
+		end := strings.IndexByte(diff[i:], '\n')
+		if end < 0 {
+			end = len(diff)
+		} else {
+			end += i + 1
+		}
+		if strings.HasPrefix(diff[i:end], "diff --git ") {
+			if start >= 0 {
+				files[len(files)-1].Diff = diff[start:i]
+			}
+			files = append(files, FileDiff{Path: diffHeaderPath(diff[i:end])})
+			start = i
+		}
+		i = end
+	}
+	if start >= 0 {
+		files[len(files)-1].Diff = diff[start:]
+	}
+	return files
+}
+
+// diffHeaderPath extracts the new path from a "diff --git a/x b/x" line.
+// Paths git quoted for unusual characters, such as newlines, are unquoted.
+func diffHeaderPath(header string) string {
+	header = strings.TrimSpace(header)
+	i := strings.LastIndex(header, " b/")
+	if i < 0 {
+		i = strings.LastIndex(header, ` "b/`)
+		if i < 0 {
+			return ""
+		}
+		quoted := header[i+1:]
+		if p, err := strconv.Unquote(quoted); err == nil {
+			return strings.TrimPrefix(p, "b/")
+		}
+		return strings.TrimSuffix(header[i+4:], `"`)
+	}
+	return header[i+3:]
+}
+
+// componentOf returns the directory of p cut to at most depth components.
+func componentOf(p string, depth int) string {
+	parts := strings.Split(path.Dir(p), "/")
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return strings.Join(parts, "/")
+}
+
+func groupByComponent(files []FileDiff, depth int) []DiffChunk {
+	var chunks []DiffChunk
+	index := map[string]int{}
+	for _, f := range files {
+		c := componentOf(f.Path, depth)
+		i, ok := index[c]
+		if !ok {
+			i = len(chunks)
+			index[c] = i
+			chunks = append(chunks, DiffChunk{Component: c})
+		}
+		chunks[i].Paths = append(chunks[i].Paths, f.Path)
+		chunks[i].Diff += f.Diff
+	}
+	slices.SortFunc(chunks, func(a, b DiffChunk) int {
+		return strings.Compare(a.Component, b.Component)
+	})
+	return chunks
+}
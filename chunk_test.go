@@ -0,0 +1,54 @@
+package fastcommit
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzSplitDiff(f *testing.F) {
+	f.Add("diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-x\n+y\n")
+	f.Add("note\n\ndiff --git a/a b/a\nnew file mode 100644\ndiff --git \"a/new\\nline\" \"b/new\\nline\"\n+z")
+	f.Add("diff --git a/x b/x")
+	f.Add("diff --git \"a/\\x\" \"b/\\q")
+	f.Add("+diff --git a/in b/content\n")
+	f.Fuzz(func(t *testing.T, diff string) {
+		files := SplitDiff(diff)
+		var joined strings.Builder
+		for _, file := range files {
+			if !strings.HasPrefix(file.Diff, "diff --git ") {
+				t.Fatalf("part does not start with a header: %q", file.Diff)
+			}
+			if strings.Contains(file.Diff[1:], "\ndiff --git ") {
+				t.Fatalf("part holds two files: %q", file.Diff)
+			}
+			joined.WriteString(file.Diff)
+		}
+		if !strings.HasSuffix(diff, joined.String()) {
+			t.Fatalf("the parts %q are not the end of the diff %q", joined.String(), diff)
+		}
+		headers := 0
+		for _, line := range strings.SplitAfter(diff, "\n") {
+			if strings.HasPrefix(line, "diff --git ") {
+				headers++
+			}
+		}
+		if len(files) != headers {
+			t.Fatalf("%d parts for %d headers in %q", len(files), headers, diff)
+		}
+	})
+}
+
+func TestDiffHeaderPath(t *testing.T) {
+	tests := map[string]string{
+		"diff --git a/a.go b/a.go\n":                         "a.go",
+		"diff --git a/old name b/new name\n":                 "new name",
+		"diff --git \"a/new\\nline\" \"b/new\\nline\"\n":     "new\nline",
+		"diff --git \"a/caf\\303\\251\" \"b/caf\\303\\251\"": "café",
+		"diff --git a b\n":                                   "",
+	}
+	for header, want := range tests {
+		if got := diffHeaderPath(header); got != want {
+			t.Errorf("diffHeaderPath(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package fastcommit
+
+// TypeRule maps files matching any of Patterns to a Conventional Commits type,
+// which may include a scope such as "chore(deps)".
+type TypeRule struct {
+	Type     string   `toml:"type"`
+	Patterns []string `toml:"patterns"`
+}
+
+// DefaultTypeRules classifies tests, documentation, CI configuration and
+// dependency manifests. Rules are tried in order.
+var DefaultTypeRules = []TypeRule{
+	{Type: "test", Patterns: []string{
+		"*_test.go", "*_test.py", "test_*.py", "*.test.js", "*.test.ts", "*.spec.js", "*.spec.ts",
+		"**/testdata/**", "test/**", "tests/**", "**/__tests__/**",
+	}},
+	{Type: "ci", Patterns: []string{
+		".github/workflows/**", ".gitlab-ci.yml", ".circleci/**", ".travis.yml",
+		"Jenkinsfile", "azure-pipelines.yml", ".buildkite/**",
+	}},
+	{Type: "docs", Patterns: []string{
+		"*.md", "*.rst", "*.adoc", "docs/**", "doc/**", "LICENSE*",
+	}},
+	{Type: "chore(deps)", Patterns: dependencyFiles},
+}
+
+// ClassifyChange returns the type of the first rule matching each path if all
+// paths agree on it. It returns "" when the paths are mixed or any of them
+// matches no rule, leaving the choice to the model.
+func ClassifyChange(paths []string, rules []TypeRule) string {
+	typ := ""
+	for _, p := range paths {
+		t := classifyPath(p, rules)
+		if t == "" || (typ != "" && t != typ) {
+			return ""
+		}
+		typ = t
+	}
+	return typ
+}
+
+func classifyPath(p string, rules []TypeRule) string {
+	for _, r := range rules {
+		for _, pattern := range r.Patterns {
+			if MatchGlob(pattern, p) {
+				return r.Type
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package fastcommit
+
+import "testing"
+
+func TestClassifyChange(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{[]string{"parse_test.go", "internal/x/x_test.go"}, "test"},
+		{[]string{"src/app.test.ts", "pkg/testdata/in.txt"}, "test"},
+		{[]string{"README.md", "docs/guide/setup.txt"}, "docs"},
+		{[]string{".github/workflows/ci.yml"}, "ci"},
+		{[]string{"go.mod", "go.sum"}, "chore(deps)"},
+		{[]string{"package.json", "package-lock.json"}, "chore(deps)"},
+		// Mixed changes are left to the model.
+		{[]string{"parse.go", "parse_test.go"}, ""},
+		{[]string{"README.md", "parse_test.go"}, ""},
+		{[]string{"go.mod", ".github/workflows/ci.yml"}, ""},
+		{[]string{"docs/api.md", "api.go"}, ""},
+		{[]string{"main.go"}, ""},
+		{nil, ""},
+		// The first matching rule wins: a test of the docs is a test.
+		{[]string{"docs/build_test.go"}, "test"},
+	}
+	for _, tt := range tests {
+		if got := ClassifyChange(tt.paths, DefaultTypeRules); got != tt.want {
+			t.Errorf("ClassifyChange(%q) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyChangeCustomRules(t *testing.T) {
+	rules := []TypeRule{
+		{Type: "build", Patterns: []string{"Makefile", "*.mk"}},
+		{Type: "docs", Patterns: []string{"*.md"}},
+	}
+	if got := ClassifyChange([]string{"Makefile", "rules/go.mk"}, rules); got != "build" {
+		t.Errorf("ClassifyChange = %q, want build", got)
+	}
+	// Files the default rules know stay unclassified with other rules.
+	if got := ClassifyChange([]string{"main_test.go"}, rules); got != "" {
+		t.Errorf("ClassifyChange = %q, want none", got)
+	}
+}
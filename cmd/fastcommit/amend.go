@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// amendChainWindow is how recently fastcommit must have generated the
+	// commit being amended for the amend to count as polishing it.
+	amendChainWindow = time.Hour
+	// maxIncrementalTokens is the largest delta for which the incremental
+	// prompt is used instead of the full one.
+	maxIncrementalTokens = 2000
+)
+
+// incrementalPrompt returns a cheaper prompt for amending hash when it is a
+// commit whose message fastcommit generated moments ago, following the rules
+// of opts. ok is false when the full prompt should be used instead.
+func incrementalPrompt(hash string, opts fastcommit.PromptOptions) (msgs []openai.ChatCompletionMessage, ok bool) {
+	state, err := loadState()
+	if err != nil {
+		debugf("incremental prompt: load state: %v", err)
+		return nil, false
+	}
+	last := state.LastCommit
+	switch {
+	case last == nil:
+		debugf("incremental prompt: no previous generation recorded")
+		return nil, false
+	case last.Hash != hash:
+		debugf("incremental prompt: %s was not generated by fastcommit", shortHash(hash))
+		return nil, false
+	case time.Since(last.Time) > amendChainWindow:
+		debugf("incremental prompt: previous generation is older than %s", amendChainWindow)
+		return nil, false
+	}
+
+	current, err := exec.Command("git", "show", "-s", "--format=%B", hash).Output()
+	if err != nil || strings.TrimSpace(string(current)) != strings.TrimSpace(last.Message) {
+		debugf("incremental prompt: message of %s was changed outside fastcommit", shortHash(hash))
+		return nil, false
+	}
+
+	snap, err := takeSnapshot(false)
+	if err != nil {
+		debugf("incremental prompt: %v", err)
+		return nil, false
+	}
+	args := append(append(slices.Clone(fastcommit.GitConfigArgs), "diff"), fastcommit.DiffFormatArgs...)
+	args = append(args, last.Tree, snap.tree)
+	delta, err := exec.Command("git", args...).Output()
+	if err != nil {
+		debugf("incremental prompt: diff against previous tree: %v", err)
+		return nil, false
+	}
+	tokens := fastcommit.CountTokens(openai.ChatCompletionMessage{Content: string(delta)})
+	if tokens > maxIncrementalTokens {
+		debugf("incremental prompt: delta is %d tokens, using full prompt", tokens)
+		return nil, false
+	}
+
+	debugf("using incremental amend prompt (%d token delta)", tokens)
+	return fastcommit.BuildIncrementalPromptWithOptions(last.Message, string(delta), opts), true
+}
+
+// recordCommit remembers that the commit at HEAD was created with msg, so
+// that a following amend can use the incremental prompt. A message saved
+// from a failed commit is no longer needed once a commit lands.
+func recordCommit(msg string) {
+	hash, err := getLastCommitHash()
+	if err != nil {
+		debugf("record commit: %v", err)
+		return
+	}
+	tree, err := exec.Command("git", "rev-parse", hash+"^{tree}").Output()
+	if err != nil {
+		debugf("record commit: %v", err)
+		return
+	}
+
+	err = updateState(func(s *repoState) {
+		s.LastCommit = &generatedCommit{
+			Hash:    hash,
+			Tree:    strings.TrimSpace(string(tree)),
+			Message: msg,
+			Time:    time.Now(),
+		}
+		s.Unlanded = nil
+	})
+	if err != nil {
+		debugf("record commit: save state: %v", err)
+	}
+}
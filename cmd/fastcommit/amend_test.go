@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIncrementalPromptGuidance(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "parse.go", "package parse\n")
+	gitT(t, dir, "add", "-A")
+	gitT(t, dir, "commit", "-q", "-m", "Add the parser")
+	recordCommit("Add the parser")
+	writeFile(t, dir, "parse.go", "package parse\n\nfunc Parse() {}\n")
+	gitT(t, dir, "add", "-A")
+
+	f := testFlags()
+	f.amend = true
+	f.conventional = true
+	f.subjectLength = 60
+	p, err := buildPrompt(context.Background(), nil, f, config{}, dir, gitT(t, dir, "rev-parse", "HEAD"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := requestBody(t, p.msgs)
+	if !strings.Contains(body, "The commit is being amended") {
+		t.Fatalf("the amend did not use the incremental prompt:\n%s", body)
+	}
+	for _, want := range []string{
+		"Conventional Commits",
+		"Limit the subject line to 60 characters",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the incremental prompt lacks %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// annotations collects what the provider said about the responses of one
+// generation besides their content: the system fingerprint and the findings
+// of content filters, as reported by Azure OpenAI and compatible gateways,
+// and the model, upstream provider and cost OpenRouter reports. A nil
+// *annotations records nothing.
+type annotations struct {
+	mu                sync.Mutex
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	// Filters lists the content filter findings, e.g. "completion: violence
+	// (medium, filtered)". Findings rated safe are left out.
+	Filters []string `json:"filters,omitempty"`
+	// Altered is set when a filter removed or changed content, so the
+	// message may not be what the model wrote.
+	Altered bool `json:"altered"`
+	// ServedBy is the model that actually served the requests, and
+	// Upstream the provider it ran on, as OpenRouter reports them.
+	ServedBy string `json:"served_by,omitempty"`
+	Upstream string `json:"upstream_provider,omitempty"`
+	// Cost is what the requests were billed, in US dollars, as OpenRouter
+	// reports it.
+	Cost float64 `json:"cost,omitempty"`
+	// PromptTokens and CompletionTokens add up the usage the provider
+	// reported for the requests.
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+	// SpanID identifies the request the message came from, as traced by
+	// traceRun.
+	SpanID string `json:"span_id,omitempty"`
+	// Impact is the classification of --impact-label.
+	Impact *fastcommit.Impact `json:"impact,omitempty"`
+}
+
+// observeStream records the annotations of a streamed response chunk.
+func (a *annotations) observeStream(resp openai.ChatCompletionStreamResponse) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fingerprint(resp.SystemFingerprint)
+	for _, r := range resp.PromptFilterResults {
+		a.filter("prompt", r.ContentFilterResults)
+	}
+	for _, r := range resp.PromptAnnotations {
+		a.filter("prompt", r.ContentFilterResults)
+	}
+	for _, c := range resp.Choices {
+		a.filter("completion", c.ContentFilterResults)
+		a.finish(c.FinishReason)
+	}
+}
+
+// observe records the annotations of a complete response.
+func (a *annotations) observe(resp openai.ChatCompletionResponse) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fingerprint(resp.SystemFingerprint)
+	for _, c := range resp.Choices {
+		a.finish(c.FinishReason)
+	}
+}
+
+// observeUsage records the token usage of a response.
+func (a *annotations) observeUsage(u openai.Usage) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.PromptTokens += u.PromptTokens
+	a.CompletionTokens += u.CompletionTokens
+}
+
+// observeSpan records the span of the request the message came from.
+func (a *annotations) observeSpan(s *span) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.SpanID = s.id
+}
+
+// observeOpenRouter records the usage OpenRouter reported for a response.
+func (a *annotations) observeOpenRouter(u fastcommit.OpenRouterUsage) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if u.Model != "" {
+		a.ServedBy = u.Model
+	}
+	if u.Provider != "" {
+		a.Upstream = u.Provider
+	}
+	a.Cost += u.Cost
+}
+
+func (a *annotations) fingerprint(fp string) {
+	if fp != "" && a.SystemFingerprint != fp {
+		if a.SystemFingerprint != "" {
+			debugf("system fingerprint changed from %s to %s", a.SystemFingerprint, fp)
+		}
+		a.SystemFingerprint = fp
+	}
+}
+
+func (a *annotations) finish(reason openai.FinishReason) {
+	if reason == openai.FinishReasonContentFilter {
+		a.add("completion: cut short by the content filter")
+		a.Altered = true
+	}
+}
+
+func (a *annotations) filter(where string, r openai.ContentFilterResults) {
+	for _, c := range []struct {
+		name     string
+		filtered bool
+		severity string
+	}{
+		{"hate", r.Hate.Filtered, r.Hate.Severity},
+		{"self-harm", r.SelfHarm.Filtered, r.SelfHarm.Severity},
+		{"sexual", r.Sexual.Filtered, r.Sexual.Severity},
+		{"violence", r.Violence.Filtered, r.Violence.Severity},
+	} {
+		switch {
+		case c.filtered:
+			a.add(fmt.Sprintf("%s: %s (%s, filtered)", where, c.name, c.severity))
+			a.Altered = true
+		case c.severity != "" && c.severity != "safe":
+			a.add(fmt.Sprintf("%s: %s (%s)", where, c.name, c.severity))
+		}
+	}
+}
+
+func (a *annotations) add(finding string) {
+	if !slices.Contains(a.Filters, finding) {
+		a.Filters = append(a.Filters, finding)
+	}
+}
+
+// report prints the annotations in -v mode.
+func (a *annotations) report() {
+	if a == nil {
+		return
+	}
+	if a.SystemFingerprint != "" {
+		verbosef("system fingerprint: %s", a.SystemFingerprint)
+	}
+	for _, finding := range a.Filters {
+		verbosef("content filter: %s", finding)
+	}
+	if a.ServedBy != "" {
+		servedBy := a.ServedBy
+		if a.Upstream != "" {
+			servedBy += " on " + a.Upstream
+		}
+		verbosef("served by %s, cost $%.6f", servedBy, a.Cost)
+	}
+	if a.Impact != nil {
+		impact := "internal"
+		if a.Impact.UserFacing {
+			impact = "user-facing"
+		}
+		verbosef("impact: %s", impact)
+	}
+}
+
+// confirmAltered asks before committing a message the provider's content
+// filter altered. Outside a terminal, such a message is never committed.
+func (a *annotations) confirmAltered() error {
+	if a == nil || !a.Altered {
+		return nil
+	}
+	warnf("%s\n", tr("content_filtered", strings.Join(a.Filters, "; ")))
+	if !interactive() {
+		return errors.New(tr("content_filtered_blocked"))
+	}
+	if !confirm(tr("content_filtered_question")) {
+		return errors.New(tr("aborted"))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Chunks as Azure OpenAI streams them: the prompt filter results first,
+// without choices, then content with the completion's filter results.
+const (
+	promptFilterChunk = `{"id":"","object":"","created":0,"model":"","choices":[],
+		"prompt_filter_results":[{"prompt_index":0,"content_filter_results":{
+			"hate":{"filtered":false,"severity":"safe"},
+			"self_harm":{"filtered":false,"severity":"safe"},
+			"sexual":{"filtered":false,"severity":"safe"},
+			"violence":{"filtered":false,"severity":"low"}}}]}`
+	contentChunk = `{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-4o",
+		"system_fingerprint":"fp_abc123",
+		"choices":[{"index":0,"delta":{"content":"Remove the kill switch"},"content_filter_results":{
+			"hate":{"filtered":false,"severity":"safe"},
+			"violence":{"filtered":true,"severity":"medium"}}}]}`
+	cutChunk = `{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-4o",
+		"system_fingerprint":"fp_abc123",
+		"choices":[{"index":0,"delta":{},"finish_reason":"content_filter"}]}`
+	cleanChunk = `{"id":"c2","object":"chat.completion.chunk","created":1,"model":"gpt-4o",
+		"system_fingerprint":"fp_abc123",
+		"choices":[{"index":0,"delta":{"content":"Add retries"},"finish_reason":"stop","content_filter_results":{
+			"hate":{"filtered":false,"severity":"safe"}}}]}`
+	usageChunk = `{"id":"c1","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[],
+		"usage":{"prompt_tokens":120,"completion_tokens":5,"total_tokens":125}}`
+)
+
+func TestAnnotationsFlaggedStream(t *testing.T) {
+	f := testFlags()
+	f.annotations = &annotations{}
+	msg, err := streamReplay(t, f, promptFilterChunk, contentChunk, cutChunk, usageChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "Remove the kill switch" {
+		t.Errorf("message = %q", msg)
+	}
+
+	a := f.annotations
+	want := []string{
+		"prompt: violence (low)",
+		"completion: violence (medium, filtered)",
+		"completion: cut short by the content filter",
+	}
+	if !slices.Equal(a.Filters, want) {
+		t.Errorf("filters = %q, want %q", a.Filters, want)
+	}
+	if !a.Altered {
+		t.Error("a filtered completion is not marked altered")
+	}
+	if a.SystemFingerprint != "fp_abc123" {
+		t.Errorf("system fingerprint = %q", a.SystemFingerprint)
+	}
+	if a.PromptTokens != 120 || a.CompletionTokens != 5 {
+		t.Errorf("usage = %d+%d tokens", a.PromptTokens, a.CompletionTokens)
+	}
+
+	// Tests do not run in a terminal, where the user would be asked.
+	err = a.confirmAltered()
+	if err == nil || err.Error() != tr("content_filtered_blocked") {
+		t.Errorf("confirmAltered outside a terminal = %v, want the message blocked", err)
+	}
+}
+
+func TestAnnotationsCleanStream(t *testing.T) {
+	f := testFlags()
+	f.annotations = &annotations{}
+	if _, err := streamReplay(t, f, cleanChunk, usageChunk); err != nil {
+		t.Fatal(err)
+	}
+	if a := f.annotations; a.Altered || len(a.Filters) != 0 {
+		t.Errorf("a clean response is annotated %+v", a)
+	}
+	if err := f.annotations.confirmAltered(); err != nil {
+		t.Errorf("confirmAltered of a clean response = %v", err)
+	}
+}
+
+func TestAnnotationsNil(t *testing.T) {
+	f := testFlags()
+	msg, err := streamReplay(t, f, promptFilterChunk, contentChunk, cutChunk)
+	if err != nil || !strings.HasPrefix(msg, "Remove") {
+		t.Errorf("streaming without annotations = %q, %v", msg, err)
+	}
+	var a *annotations
+	if err := a.confirmAltered(); err != nil {
+		t.Errorf("confirmAltered on nil = %v", err)
+	}
+}
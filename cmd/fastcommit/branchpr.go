@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxBranchLength is the longest branch name suggested.
+const maxBranchLength = 40
+
+// runBranch suggests a branch name for the staged changes, or for all
+// changes in the working tree when nothing is staged, and with --checkout
+// creates it.
+func runBranch(f flags, cfg config, args []string) error {
+	fs := flag.NewFlagSet("branch", flag.ExitOnError)
+	checkout := fs.Bool("checkout", false, "Create the branch with git checkout -b")
+	_ = fs.Parse(args)
+
+	if staged, err := stagedPaths(""); err != nil {
+		return err
+	} else if len(staged) == 0 {
+		f.preview = true
+	}
+	instruction := fmt.Sprintf("Do not write a commit message. Instead, suggest a name for a git branch holding "+
+		"this change: one of the prefixes %s, then a few lowercase words joined by hyphens, %d characters "+
+		"at most in total, e.g. fix/login-redirect-loop. Reply with the branch name only.",
+		joinList(branchPrefixes()), maxBranchLength)
+	text, err := generateSibling(f, cfg, instruction)
+	if err != nil {
+		return err
+	}
+	name := branchName(text)
+	if name == "" || exec.Command("git", "check-ref-format", "--branch", name).Run() != nil {
+		return fmt.Errorf("the model suggested no usable branch name: %q", text)
+	}
+
+	cmd := exec.Command("git", "checkout", "-b", name)
+	switch {
+	case !*checkout:
+		fmt.Println(name)
+	case f.dryRun:
+		fmt.Println(formatShellCommand(cmd))
+	default:
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return &exitError{code: exitGit, err: err}
+		}
+	}
+	return nil
+}
+
+// branchPrefixes are the type prefixes of suggested branch names.
+func branchPrefixes() []string {
+	var prefixes []string
+	for _, t := range fastcommit.ConventionalTypes {
+		prefixes = append(prefixes, t+"/")
+	}
+	return prefixes
+}
+
+// branchName turns the model's reply into a branch name: lowercase words
+// of letters and digits joined by hyphens after a type prefix, cut at a
+// word boundary to maxBranchLength.
+func branchName(reply string) string {
+	reply = strings.Trim(strings.TrimSpace(strings.Split(strings.TrimSpace(reply), "\n")[0]), "`'\"")
+	prefix, rest, ok := strings.Cut(strings.ToLower(reply), "/")
+	if !ok || !slices.Contains(fastcommit.ConventionalTypes, prefix) {
+		prefix, rest = "chore", strings.ToLower(reply)
+	}
+	words := strings.FieldsFunc(rest, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	name := prefix + "/"
+	for _, w := range words {
+		next := name + w
+		if !strings.HasSuffix(name, "/") {
+			next = name + "-" + w
+		}
+		if len(next) > maxBranchLength {
+			break
+		}
+		name = next
+	}
+	if strings.HasSuffix(name, "/") {
+		return ""
+	}
+	return name
+}
+
+// runPR writes a pull request title and description for the commits from
+// where HEAD forked off --base to HEAD, and prints them or, with --gh,
+// opens the pull request with the GitHub CLI.
+func runPR(f flags, cfg config, args []string) error {
+	fs := flag.NewFlagSet("pr", flag.ExitOnError)
+	base := fs.String("base", "", "The branch the pull request is for (default origin/HEAD, or origin/main)")
+	gh := fs.Bool("gh", false, "Open the pull request with gh pr create")
+	_ = fs.Parse(args)
+
+	if *base == "" {
+		*base = "origin/main"
+		if ref, err := gitOutput("rev-parse", "--abbrev-ref", "origin/HEAD"); err == nil && ref != "origin/HEAD" {
+			*base = ref
+		}
+	}
+	if _, err := resolveRef(*base); err != nil {
+		return err
+	}
+	f.rangeSpec = *base + "..HEAD"
+	if _, err := rangeCount(f.rangeSpec); err != nil {
+		return err
+	}
+	if *gh && !f.dryRun {
+		if _, err := exec.LookPath("gh"); err != nil {
+			return errors.New(tr("gh_missing"))
+		}
+	}
+
+	text, err := generateSibling(f, cfg, "Do not write a commit message. Instead, write the pull request for "+
+		"all of these commits together: a title of at most 72 characters on the first line, a blank line, "+
+		"then a description in Markdown saying what the change does and why, with a short bulleted list of "+
+		"the main changes. Do not add a heading for the title.")
+	if err != nil {
+		return err
+	}
+	title, body := splitMessage(text)
+	title = strings.TrimSpace(strings.TrimLeft(title, "#"))
+	if title == "" {
+		return errors.New(tr("empty_response", apiEndpoint(f), f.model))
+	}
+
+	if !*gh {
+		fmt.Printf("%s\n\n%s\n", title, body)
+		return nil
+	}
+	cmd := exec.Command("gh", "pr", "create", "--base", strings.TrimPrefix(*base, "origin/"), "--title", title, "--body-file", "-")
+	if f.dryRun {
+		fmt.Printf("%s <<'EOF'\n%s\nEOF\n", formatShellCommand(cmd), body)
+		return nil
+	}
+	cmd.Stdin = strings.NewReader(body)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// generateSibling generates text other than a commit message from the
+// prompt for the change f describes, replacing the request for a commit
+// message with instruction.
+func generateSibling(f flags, cfg config, instruction string) (string, error) {
+	workdir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+	client := newProvider(f)
+	// A preset commit message is no answer here.
+	f.automationPresets = "off"
+	p, err := buildPrompt(ctx, client, f, cfg, workdir, "", nil)
+	if err != nil {
+		return "", err
+	}
+	msgs := append(p.msgs, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: instruction})
+	f.annotations = &annotations{}
+	f.body = false
+	text, err := generateMessage(ctx, client, f, msgs, nullDisplay{})
+	if err != nil {
+		return "", err
+	}
+	f.annotations.report()
+	return text, nil
+}
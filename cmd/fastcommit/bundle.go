@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// bundle is a self-contained record of one generation, written by
+// --bundle-report so that a bad message can be reported and replayed without
+// access to the repository. Like the doctor report, it is not localized.
+type bundle struct {
+	Request bundleRequest
+	// Messages are stored without go-openai's fields, so that any client
+	// can replay them.
+	Messages []fastcommit.PromptMessage
+	Response string
+	Meta     bundleMeta
+}
+
+type bundleRequest struct {
+	Provider    string  `json:"provider,omitempty"`
+	Model       string  `json:"model"`
+	BaseURL     string  `json:"base_url"`
+	Temperature float32 `json:"temperature"`
+}
+
+type bundleMeta struct {
+	Version    string    `json:"version"`
+	GoVersion  string    `json:"go_version"`
+	GitVersion string    `json:"git_version"`
+	Platform   string    `json:"platform"`
+	Time       time.Time `json:"time"`
+	// TraceID identifies the run in its debug output and exported traces.
+	TraceID string `json:"trace_id"`
+	// FullDiff records whether the diff was kept in the prompt.
+	FullDiff     bool          `json:"full_diff"`
+	PromptTime   time.Duration `json:"prompt_time_ns"`
+	GenerateTime time.Duration `json:"generate_time_ns"`
+	// Annotations are what the provider reported about the response
+	// besides its content.
+	Annotations *annotations `json:"annotations,omitempty"`
+}
+
+// omitDiffs replaces prompt messages carrying a raw diff with a note of
+// their size.
+func omitDiffs(msgs []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	out := make([]openai.ChatCompletionMessage, len(msgs))
+	for i, msg := range msgs {
+		if strings.HasPrefix(msg.Content, "diff --git ") || strings.Contains(msg.Content, "\ndiff --git ") {
+			msg.Content = fmt.Sprintf("[diff omitted, %d tokens; use --bundle-full to include it]",
+				fastcommit.CountTokens(msg))
+		}
+		out[i] = msg
+	}
+	return out
+}
+
+func newBundle(f flags, msgs []openai.ChatCompletionMessage, response string, promptTime, generateTime time.Duration) bundle {
+	gitVersion, _ := gitOutput("--version")
+	if !f.bundleFull {
+		msgs = omitDiffs(msgs)
+	}
+	return bundle{
+		Request: bundleRequest{
+			Provider: f.provider,
+			Model:    f.model,
+			BaseURL:  apiEndpoint(f),
+		},
+		Messages: fastcommit.FromOpenAIMessages(msgs),
+		Response: response,
+		Meta: bundleMeta{
+			Version:      Version,
+			GoVersion:    runtime.Version(),
+			GitVersion:   gitVersion,
+			Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+			Time:         time.Now().UTC(),
+			TraceID:      traceRun.traceID,
+			FullDiff:     f.bundleFull,
+			PromptTime:   promptTime,
+			GenerateTime: generateTime,
+			Annotations:  f.annotations,
+		},
+	}
+}
+
+// writeBundle writes b to path as a gzipped tarball of JSON and text files.
+func writeBundle(path string, b bundle) error {
+	var files []struct {
+		name string
+		data []byte
+	}
+	add := func(name string, v any) error {
+		data, ok := v.([]byte)
+		if !ok {
+			var err error
+			data, err = json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				return err
+			}
+		}
+		files = append(files, struct {
+			name string
+			data []byte
+		}{name, data})
+		return nil
+	}
+	for _, err := range []error{
+		add("request.json", b.Request),
+		add("prompt.json", b.Messages),
+		add("response.txt", []byte(b.Response)),
+		add("meta.json", b.Meta),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		hdr := &tar.Header{
+			Name:    file.name,
+			Mode:    0o644,
+			Size:    int64(len(file.data)),
+			ModTime: b.Meta.Time,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			out.Close()
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := errors.Join(tw.Close(), gz.Close()); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func readBundle(path string) (bundle, error) {
+	var b bundle
+	in, err := os.Open(path)
+	if err != nil {
+		return b, err
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return b, fmt.Errorf("read %s: %w", path, err)
+	}
+	r := tar.NewReader(gz)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return b, fmt.Errorf("read %s: %w", path, err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return b, fmt.Errorf("read %s: %w", path, err)
+		}
+		switch hdr.Name {
+		case "request.json":
+			err = json.Unmarshal(data, &b.Request)
+		case "prompt.json":
+			err = json.Unmarshal(data, &b.Messages)
+		case "response.txt":
+			b.Response = string(data)
+		case "meta.json":
+			err = json.Unmarshal(data, &b.Meta)
+		}
+		if err != nil {
+			return b, fmt.Errorf("%s in %s: %w", hdr.Name, path, err)
+		}
+	}
+	if len(b.Messages) == 0 {
+		return b, fmt.Errorf("%s: no prompt in bundle", path)
+	}
+	return b, nil
+}
+
+// runReplay re-runs the generation recorded in a bundle, optionally with a
+// different model, and prints the original response next to the new one.
+func runReplay(f flags, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	model := fs.String("model", "", "The model to replay with (defaults to the bundled model)")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New(tr("replay_usage"))
+	}
+
+	b, err := readBundle(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if !b.Meta.FullDiff {
+		warnf("%s\n", tr("bundle_not_full"))
+	}
+	f.model = b.Request.Model
+	if *model != "" {
+		f.model = *model
+	}
+
+	fmt.Printf("--- original (%s, fastcommit %s)\n%s\n\n--- replay (%s)\n",
+		b.Request.Model, b.Meta.Version, b.Response, f.model)
+	_, err = generateMessage(context.Background(), newProvider(f), f, fastcommit.ToOpenAIMessages(b.Messages), newDisplay(os.Stdout, f.plain))
+	fmt.Println()
+	return err
+}
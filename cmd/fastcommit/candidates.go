@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxDiffTerms caps the file and identifier names --auto-pick looks for in
+// candidate messages.
+const maxDiffTerms = 20
+
+// candidateSet records all candidates of a generation for --dry, which lists
+// them with their commands instead of asking for one.
+type candidateSet struct {
+	messages []string
+	pick     int
+}
+
+// generateCandidates asks for f.candidates messages in a single request and
+// picks one: the best scoring with --auto-pick, the user's choice in a
+// terminal, and the first otherwise. The chosen message is shown on disp.
+func generateCandidates(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	msgs []openai.ChatCompletionMessage,
+	disp display,
+) (string, error) {
+	if err := f.privacy.check(msgs); err != nil {
+		return "", err
+	}
+	choices, err := completeCandidates(ctx, client, f, msgs)
+	if err != nil {
+		return "", err
+	}
+	var candidates []string
+	for _, c := range choices {
+		if msg := sanitizers(f).Apply(c.Message.Content); msg != "" {
+			candidates = append(candidates, msg)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", errors.New(tr("empty_response", apiEndpoint(f), f.model))
+	}
+
+	pick := 0
+	switch {
+	case len(candidates) == 1:
+	case f.autoPick:
+		terms := fastcommit.DiffTerms(msgs[len(msgs)-1].Content, maxDiffTerms)
+		scores := fastcommit.ScoreCandidates(candidates, terms, f.subjectLength, fastcommit.DefaultScoreWeights)
+		pick = fastcommit.BestCandidate(scores)
+		for i, s := range scores {
+			subject, _ := splitMessage(candidates[i])
+			verbosef("candidate %d: %.2f (length %.2f, subject %.2f, coverage %.2f, denylist %.2f) %s",
+				i+1, s.Total, s.Length, s.Subject, s.Coverage, s.Denylist, subject)
+		}
+		verbosef("picked candidate %d", pick+1)
+	case f.dryCandidates != nil:
+		// All of them are listed with their commands instead.
+	case interactive():
+		for i, c := range candidates {
+			fmt.Printf("\033[1m%d)\033[0m\n%s\n\n", i+1, c)
+		}
+		if pick, err = chooseCandidate(len(candidates)); err != nil {
+			return "", err
+		}
+	}
+
+	if f.dryCandidates != nil {
+		*f.dryCandidates = candidateSet{messages: candidates, pick: pick}
+	}
+	disp.Write(candidates[pick])
+	disp.Close()
+	return candidates[pick], nil
+}
+
+// completeCandidates asks for f.candidates completions of msgs in one
+// request. Endpoints that reject n or return fewer choices, like many
+// OpenAI-compatible servers, are asked for the rest one at a time.
+func completeCandidates(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	msgs []openai.ChatCompletionMessage,
+) ([]openai.ChatCompletionChoice, error) {
+	ctx = fastcommit.WithOpenRouterUsage(ctx, f.annotations.observeOpenRouter)
+	var choices []openai.ChatCompletionChoice
+	var usage openai.Usage
+	n := f.candidates
+	for len(choices) < f.candidates {
+		resp, err := createWithRetry(ctx, client, f, openai.ChatCompletionRequest{
+			Model: f.model,
+			N:     n,
+			// Some variety is the point of asking for several.
+			Temperature: 0.7,
+			Messages:    msgs,
+		})
+		var apiErr *openai.APIError
+		if n > 1 && errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusBadRequest &&
+			apiErr.Param != nil && *apiErr.Param == "n" {
+			debugf("the endpoint does not support n, asking for the candidates one at a time: %v", err)
+			n = 1
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		f.annotations.observe(resp)
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+		if len(resp.Choices) == 0 {
+			break
+		}
+		choices = append(choices, resp.Choices...)
+		if n > 1 && len(choices) < f.candidates {
+			debugf("asked for %d candidates but got %d, asking for the rest one at a time", n, len(resp.Choices))
+			n = 1
+		}
+	}
+	debugf("total tokens: %d (prompt %d, completion %d) for %d candidates",
+		usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens, len(choices))
+	return choices, nil
+}
+
+// chooseCandidate asks for a number between 1 and n and returns its index,
+// defaulting to the first. Up to 9 candidates are picked with a single
+// keypress.
+func chooseCandidate(n int) (int, error) {
+	fmt.Printf("%s ", tr("pick_candidate", n))
+	answer, ok := "", false
+	if n <= 9 {
+		var key byte
+		if key, ok = readKey(); ok {
+			if key == 3 || key == 4 {
+				// Ctrl-C and Ctrl-D in raw mode.
+				fmt.Println()
+				return 0, &exitError{code: exitInterrupted, err: errors.New(tr("aborted"))}
+			}
+			answer = string(key)
+			fmt.Println(strings.TrimSpace(answer))
+		}
+	}
+	if !ok {
+		line, err := readLine(context.Background())
+		if err != nil {
+			fmt.Println()
+			return 0, nil
+		}
+		answer = line
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || i < 1 || i > n {
+		return 0, nil
+	}
+	return i - 1, nil
+}
+
+// printCandidateCommands prints the commands that would commit each
+// candidate of a --dry run, the picked one as completed and finished.
+func printCandidateCommands(f flags, cfg config, p prompt, set candidateSet, picked string) error {
+	fmt.Println(tr("run_to_commit_one"))
+	for i, msg := range set.messages {
+		if i == set.pick {
+			msg = picked
+		} else {
+			var err error
+			if msg, err = finishMessage(f, cfg, p, msg); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("\n\033[1m%d)\033[0m\n%s\n\n%s\n", i+1, msg, formatShellCommand(commitCommand(f, msg)))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// captureMaxTokens caps the captured output included in the prompt.
+const captureMaxTokens = 2000
+
+// failureLineRe matches lines that typically summarize a build or test
+// failure.
+var failureLineRe = regexp.MustCompile(`(?i)(^--- FAIL|^FAIL\b|^panic:|\berror\b|\bfailed\b|\bfailures?\b|^\S+:\d+(:\d+)?:|✗|✘)`)
+
+// captureOutput runs command in the shell and returns its output as a prompt
+// message labeled as build/test output. The command's exit status is
+// reported to the model but never aborts fastcommit.
+func captureOutput(command string, maxLines int) (openai.ChatCompletionMessage, error) {
+	cmd := shellCommand(context.Background(), command)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	status := "exit status 0"
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return openai.ChatCompletionMessage{}, fmt.Errorf("run %q: %w", command, err)
+		}
+		status = exitErr.String()
+	}
+	verbosef("captured %d bytes from %q (%s)", out.Len(), command, status)
+
+	output := selectLines(strings.Split(strings.TrimRight(out.String(), "\n"), "\n"), maxLines)
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: fmt.Sprintf("Build/test output of `%s` (%s), run by the user before committing. "+
+			"If the change fixes or relates to what it shows, say so:\n%s",
+			command, status, fastcommit.Ellipse(output, captureMaxTokens)),
+	}, nil
+}
+
+// shellCommand returns a command running command in the platform's shell.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// selectLines keeps at most maxLines of lines. Failure summaries and the two
+// lines after each are kept first; remaining room goes to the last lines,
+// which usually hold the overall result. The original order is preserved.
+func selectLines(lines []string, maxLines int) string {
+	if len(lines) <= maxLines {
+		return strings.Join(lines, "\n")
+	}
+
+	keep := make([]bool, len(lines))
+	kept := 0
+	mark := func(i int) {
+		if i < len(lines) && !keep[i] && kept < maxLines {
+			keep[i] = true
+			kept++
+		}
+	}
+	for i, line := range lines {
+		if failureLineRe.MatchString(line) {
+			mark(i)
+			mark(i + 1)
+			mark(i + 2)
+		}
+	}
+	for i := len(lines) - 1; i >= 0 && kept < maxLines; i-- {
+		mark(i)
+	}
+
+	var b strings.Builder
+	skipped := false
+	for i, line := range lines {
+		if !keep[i] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			b.WriteString("...\n")
+			skipped = false
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
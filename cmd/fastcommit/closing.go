@@ -0,0 +1,142 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// closingRefPattern matches a GitHub issue reference, optionally qualified
+// with its repository, or a Jira issue key.
+const closingRefPattern = `(?:[\w.-]+/[\w.-]+)?#\d+|[A-Z][A-Z0-9_]+-\d+`
+
+// closingPattern matches a closing keyword followed by one or more
+// references, e.g. "fixes #12 and #13" or "Closes: PROJ-7".
+const closingPattern = `(?i:\b(close[sd]?|fix(?:e[sd])?|resolve[sd]?))\s*:?\s+` +
+	`((?:` + closingRefPattern + `)(?:\s*(?:,|(?i:\band\b))\s*(?:` + closingRefPattern + `))*)`
+
+var (
+	closingRe     = regexp.MustCompile(closingPattern)
+	closingLineRe = regexp.MustCompile(`^\s*` + closingPattern + `\s*\.?\s*$`)
+	closingRefRe  = regexp.MustCompile(closingRefPattern)
+	trailerLineRe = regexp.MustCompile(`^[A-Za-z][\w-]*: \S`)
+)
+
+// closingRef is an issue to be closed by the commit.
+type closingRef struct {
+	keyword string
+	ref     string
+}
+
+func (c closingRef) String() string {
+	return c.keyword + " " + c.ref
+}
+
+// closingRefs extracts closing keywords and their references from texts,
+// each reference once. Only user-provided context is scanned, not the diff,
+// which is full of issue numbers that have nothing to do with the commit.
+// A non-empty keyword replaces the keyword found in the text.
+func closingRefs(texts []string, keyword string) []closingRef {
+	var refs []closingRef
+	seen := map[string]bool{}
+	for _, text := range texts {
+		for _, m := range closingRe.FindAllStringSubmatch(text, -1) {
+			kw := keyword
+			if kw == "" {
+				kw = normalizeClosingKeyword(m[1])
+			}
+			for _, ref := range closingRefRe.FindAllString(m[2], -1) {
+				if !seen[ref] {
+					seen[ref] = true
+					refs = append(refs, closingRef{kw, ref})
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// normalizeClosingKeyword turns e.g. "fixed" into "Fixes".
+func normalizeClosingKeyword(kw string) string {
+	switch strings.ToLower(kw)[0] {
+	case 'c':
+		return "Closes"
+	case 'r':
+		return "Resolves"
+	}
+	return "Fixes"
+}
+
+// applyClosingRefs makes every ref appear exactly once as a trailer at the
+// end of msg. Closing lines the model wrote for the same references are
+// removed; mentions inside sentences are left alone.
+func applyClosingRefs(msg string, refs []closingRef) string {
+	if len(refs) == 0 {
+		return msg
+	}
+	wanted := map[string]bool{}
+	for _, r := range refs {
+		wanted[r.ref] = true
+	}
+
+	subject, body := splitMessage(msg)
+	var kept []string
+	for _, line := range strings.Split(body, "\n") {
+		if m := closingLineRe.FindStringSubmatch(line); m != nil {
+			dup := true
+			for _, ref := range closingRefRe.FindAllString(m[2], -1) {
+				dup = dup && wanted[ref]
+			}
+			if dup {
+				continue
+			}
+		}
+		if len(kept) > 0 && line == "" && kept[len(kept)-1] == "" {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	body = strings.TrimSpace(strings.Join(kept, "\n"))
+
+	var trailers []string
+	for _, r := range refs {
+		trailers = append(trailers, r.String())
+	}
+	footer := strings.Join(trailers, "\n")
+
+	switch {
+	case body == "":
+		body = footer
+	case isTrailerBlock(body[strings.LastIndex(body, "\n\n")+1:]):
+		body += "\n" + footer
+	default:
+		body += "\n\n" + footer
+	}
+	return subject + "\n\n" + body
+}
+
+// appendTrailer adds trailer to the trailers at the end of msg, or starts
+// them.
+func appendTrailer(msg, trailer string) string {
+	subject, body := splitMessage(msg)
+	body = strings.TrimSpace(body)
+	switch {
+	case body == "":
+		body = trailer
+	case isTrailerBlock(body[strings.LastIndex(body, "\n\n")+1:]):
+		body += "\n" + trailer
+	default:
+		body += "\n\n" + trailer
+	}
+	return subject + "\n\n" + body
+}
+
+// isTrailerBlock reports whether every line of paragraph is a trailer, so
+// that further trailers should join it instead of starting a new paragraph.
+func isTrailerBlock(paragraph string) bool {
+	for _, line := range strings.Split(strings.TrimSpace(paragraph), "\n") {
+		if !trailerLineRe.MatchString(line) && !closingLineRe.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClosingRefs(t *testing.T) {
+	tests := []struct {
+		name    string
+		texts   []string
+		keyword string
+		want    []closingRef
+	}{
+		{
+			name:  "several references",
+			texts: []string{"fixes #12, #13 and owner/repo#14", "Closes: PROJ-7"},
+			want: []closingRef{
+				{"Fixes", "#12"}, {"Fixes", "#13"}, {"Fixes", "owner/repo#14"}, {"Closes", "PROJ-7"},
+			},
+		},
+		{
+			name:  "each reference once",
+			texts: []string{"resolved #5", "this also fixes #5"},
+			want:  []closingRef{{"Resolves", "#5"}},
+		},
+		{
+			name:    "keyword override",
+			texts:   []string{"fixes #1 and closes ABC-2"},
+			keyword: "Refs",
+			want:    []closingRef{{"Refs", "#1"}, {"Refs", "ABC-2"}},
+		},
+		{
+			name:  "mention without a keyword",
+			texts: []string{"see #482 for background"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := closingRefs(tt.texts, tt.keyword); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("closingRefs(%q) = %v, want %v", tt.texts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyClosingRefs(t *testing.T) {
+	refs := []closingRef{{"Fixes", "#482"}, {"Closes", "PROJ-123"}}
+	tests := []struct {
+		name, msg, want string
+	}{
+		{
+			name: "buried mid-sentence",
+			msg:  "Fix the parser\n\nThis fixes #482 by handling empty input.",
+			want: "Fix the parser\n\nThis fixes #482 by handling empty input.\n\nFixes #482\nCloses PROJ-123",
+		},
+		{
+			name: "footer already emitted",
+			msg:  "Fix the parser\n\nHandle empty input.\n\nFixes #482\nCloses PROJ-123",
+			want: "Fix the parser\n\nHandle empty input.\n\nFixes #482\nCloses PROJ-123",
+		},
+		{
+			name: "footer with another keyword",
+			msg:  "Fix the parser\n\nResolves #482, PROJ-123.",
+			want: "Fix the parser\n\nFixes #482\nCloses PROJ-123",
+		},
+		{
+			name: "joins existing trailers",
+			msg:  "Fix the parser\n\nHandle empty input.\n\nSigned-off-by: A <a@example.com>",
+			want: "Fix the parser\n\nHandle empty input.\n\nSigned-off-by: A <a@example.com>\nFixes #482\nCloses PROJ-123",
+		},
+		{
+			name: "subject only",
+			msg:  "Fix the parser",
+			want: "Fix the parser\n\nFixes #482\nCloses PROJ-123",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyClosingRefs(tt.msg, refs)
+			if got != tt.want {
+				t.Errorf("applyClosingRefs(%q)\n got %q\nwant %q", tt.msg, got, tt.want)
+			}
+			if again := applyClosingRefs(got, refs); again != got {
+				t.Errorf("applying twice changed the message:\n%q", again)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// codeownersMaxNames is how many owners the CODEOWNERS hint names.
+const codeownersMaxNames = 5
+
+// codeownersContext returns a prompt message naming the owners of paths
+// according to CODEOWNERS. When a single owner owns every one of them and
+// --codeowners is "scope", scope is that owner as a Conventional Commits
+// scope, e.g. "payments" for @org/payments. ok is false when no path has an
+// owner.
+func codeownersContext(
+	f flags,
+	owners fastcommit.Codeowners,
+	paths []string,
+) (msg openai.ChatCompletionMessage, scope string, ok bool) {
+	names := owners.OwnersOf(paths)
+	if len(names) == 0 {
+		return msg, "", false
+	}
+	verbosef("codeowners: %s", strings.Join(names, ", "))
+
+	if f.codeowners == "scope" && f.scope == "" && len(names) == 1 && !slices.ContainsFunc(paths, func(p string) bool {
+		return len(owners.Owners(p)) != 1
+	}) {
+		scope = ownerScope(names[0])
+	}
+
+	list := names
+	if len(list) > codeownersMaxNames {
+		list = append(list[:codeownersMaxNames:codeownersMaxNames], "others")
+	}
+	content := fmt.Sprintf("According to CODEOWNERS, the changes touch areas owned by %s. "+
+		"Use this to name the affected area correctly, but do not mention the owners.",
+		joinList(list))
+	if scope != "" {
+		content += fmt.Sprintf(" Use %q as the Conventional Commits scope.", scope)
+	}
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: content}, scope, true
+}
+
+// ownerScope turns a team such as @org/payments or a user such as @alice into
+// a scope. Owners given as email addresses yield none.
+func ownerScope(owner string) string {
+	name, ok := strings.CutPrefix(owner, "@")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(name[strings.LastIndex(name, "/")+1:])
+}
+
+// joinList joins items as in "a, b and c".
+func joinList(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+// applyScope sets scope as the Conventional Commits scope of msg's subject.
+// A subject without a type is left alone.
+func applyScope(msg, scope string) string {
+	subject, body := splitMessage(msg)
+	m := conventionalTypeRe.FindString(subject)
+	if m == "" {
+		return msg
+	}
+	typ, rest := m, subject[len(m):]
+	if i := strings.IndexAny(typ, "(!:"); i >= 0 {
+		typ = typ[:i]
+	}
+	breaking := ""
+	if strings.HasSuffix(m, "!: ") {
+		breaking = "!"
+	}
+	subject = typ + "(" + scope + ")" + breaking + ": " + rest
+	if body == "" {
+		return subject
+	}
+	return subject + "\n\n" + body
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+)
+
+func TestCodeownersContext(t *testing.T) {
+	owners := fastcommit.ParseCodeowners("* @acme/platform\n/payments/ @acme/Payments\n/payments/gen/\n")
+	tests := []struct {
+		name  string
+		paths []string
+		hint  string
+		scope string
+	}{
+		{"single owner", []string{"payments/a.go", "payments/b.go"}, "owned by @acme/Payments.", "payments"},
+		{"two owners", []string{"payments/a.go", "main.go"}, "owned by @acme/Payments and @acme/platform.", ""},
+		{"partly unowned", []string{"payments/a.go", "payments/gen/api.go"}, "owned by @acme/Payments.", ""},
+	}
+	for _, tt := range tests {
+		f := testFlags()
+		f.codeowners = "scope"
+		msg, scope, ok := codeownersContext(f, owners, tt.paths)
+		if !ok || !strings.Contains(msg.Content, tt.hint) || scope != tt.scope {
+			t.Errorf("%s: codeownersContext = %q, %q, %v; want %q and scope %q", tt.name, msg.Content, scope, ok, tt.hint, tt.scope)
+		}
+	}
+
+	if _, _, ok := codeownersContext(testFlags(), owners, []string{"payments/gen/api.go"}); ok {
+		t.Error("a hint for unowned files")
+	}
+}
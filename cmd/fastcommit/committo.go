@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+)
+
+// resolveCommitTarget returns the top-level directory of the worktree at
+// path, checking that it is another worktree of the current repository.
+func resolveCommitTarget(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	top, err := gitOutput("-C", abs, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", errors.New(tr("commit_to_not_worktree", path))
+	}
+	theirs, err1 := gitOutput("-C", abs, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	ours, err2 := gitOutput("rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err1 != nil || err2 != nil || !sameFile(theirs, ours) {
+		return "", errors.New(tr("commit_to_other_repo", path))
+	}
+	if own, err := gitOutput("rev-parse", "--show-toplevel"); err == nil && sameFile(own, top) {
+		return "", errors.New(tr("commit_to_same", path))
+	}
+	return top, nil
+}
+
+// sameFile reports whether the paths a and b name the same directory.
+func sameFile(a, b string) bool {
+	if ea, err := filepath.EvalSymlinks(a); err == nil {
+		a = ea
+	}
+	if eb, err := filepath.EvalSymlinks(b); err == nil {
+		b = eb
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// prepareCommitTarget makes sure the index of the worktree target stages
+// exactly the changes staged here, which the message describes: the same
+// paths with the same contents and modes. When nothing is staged there, the
+// same paths are staged with git add first, and unstaged again if their
+// contents do not match.
+func prepareCommitTarget(target string) error {
+	paths, err := stagedPaths("")
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	staged, err := stagedPaths(target)
+	if err != nil {
+		return err
+	}
+	restore := ""
+	if len(staged) == 0 {
+		if restore, err = gitOutput("-C", target, "write-tree"); err != nil {
+			return err
+		}
+		args := append([]string{"-C", target, "add", "-A", "--"}, fastcommit.Pathspecs(paths)...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			debugf("git %s: %s", strings.Join(args, " "), out)
+		}
+		if staged, err = stagedPaths(target); err != nil {
+			return err
+		}
+	}
+
+	mismatched := mismatchedPaths(target, paths, staged)
+	if len(mismatched) == 0 {
+		return nil
+	}
+	if restore != "" {
+		if out, err := exec.Command("git", "-C", target, "read-tree", restore).CombinedOutput(); err != nil {
+			debugf("restore the index of %s: %v: %s", target, err, out)
+		}
+	}
+	for i, p := range mismatched {
+		mismatched[i] = fastcommit.QuotePath(p)
+	}
+	return errors.New(tr("commit_to_mismatch", target, strings.Join(mismatched, ", ")))
+}
+
+// stagedPaths returns the paths whose changes are staged in the worktree
+// at dir, or the current one when dir is empty.
+func stagedPaths(dir string) ([]string, error) {
+	args := []string{"diff", "--cached", "--name-only", "--no-renames", "-z"}
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// mismatchedPaths returns the paths staged here or in target, as paths and
+// staged, whose index entries differ between the two, or that only one of
+// them stages.
+func mismatchedPaths(target string, paths, staged []string) []string {
+	all := append(slices.Clone(paths), staged...)
+	slices.Sort(all)
+	all = slices.Compact(all)
+	ours, err1 := indexEntries("", all)
+	theirs, err2 := indexEntries(target, all)
+	if err1 != nil || err2 != nil {
+		debugf("compare the indexes: %v, %v", err1, err2)
+		return all
+	}
+	var mismatched []string
+	for _, p := range all {
+		if !slices.Contains(paths, p) || !slices.Contains(staged, p) || ours[p] != theirs[p] {
+			mismatched = append(mismatched, p)
+		}
+	}
+	return mismatched
+}
+
+// indexEntries returns the mode, object and stage of each of paths in the
+// index of the worktree at dir, or the current one when dir is empty.
+// Paths not in the index are left out.
+func indexEntries(dir string, paths []string) (map[string]string, error) {
+	args := append([]string{"ls-files", "--stage", "-z", "--"}, fastcommit.Pathspecs(paths)...)
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]string{}
+	for _, e := range strings.Split(string(out), "\x00") {
+		if info, p, ok := strings.Cut(e, "\t"); ok {
+			entries[p] = info
+		}
+	}
+	return entries, nil
+}
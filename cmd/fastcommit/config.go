@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// config is the user configuration stored in config.toml in the fastcommit
+// config directory, with a repository's .fastcommit.toml applied on top.
+type config struct {
+	// Model, BaseURL, Provider, APIKey and AnthropicAPIKey are the defaults
+	// of --model, --openai-base-url, --provider, --openai-key and
+	// --anthropic-key. Flags and environment variables take precedence over
+	// them.
+	Model           string `toml:"model"`
+	BaseURL         string `toml:"base_url"`
+	Provider        string `toml:"provider"`
+	APIKey          string `toml:"api_key"`
+	AnthropicAPIKey string `toml:"anthropic_api_key"`
+	// AzureAPIKey, AzureDeployment and AzureAPIVersion are the defaults of
+	// --azure-key, --azure-deployment and --azure-api-version.
+	AzureAPIKey     string `toml:"azure_api_key"`
+	AzureDeployment string `toml:"azure_deployment"`
+	AzureAPIVersion string `toml:"azure_api_version"`
+	// MaxTokens is the token budget of the prompt.
+	MaxTokens int `toml:"max_tokens"`
+	// MemoryLimitMB is a soft limit on the memory fastcommit uses, in MiB.
+	// The diff read from git is bounded to an eighth of it.
+	MemoryLimitMB int `toml:"memory_limit_mb"`
+	// BodyMinLines turns on --body for changes of at least this many
+	// lines. Zero leaves it off.
+	BodyMinLines int `toml:"body_min_lines"`
+	// Context is added to every run, before any --context.
+	Context []string `toml:"context"`
+	// Directories holds backend settings for the repositories under a
+	// directory. The first matching entry wins.
+	Directories []directoryConfig `toml:"directories"`
+	// Branches holds settings that apply to branches matching a pattern.
+	// The first matching entry wins.
+	Branches []branchConfig `toml:"branches"`
+	// LargeCommit configures the warning for unusually large commits.
+	LargeCommit largeCommitConfig `toml:"large_commit"`
+	// TypeRules replaces the built-in table used by --type-from-paths.
+	TypeRules []fastcommit.TypeRule `toml:"type_rules"`
+	// Hooks are commands run before building the prompt and after
+	// generating the message.
+	Hooks hooksConfig `toml:"hooks"`
+	// Profanity configures the filter for unprofessional words in
+	// generated messages.
+	Profanity profanityConfig `toml:"profanity"`
+	// RateLimit paces the requests of batch modes.
+	RateLimit rateLimitConfig `toml:"rate_limit"`
+	// VendorDirs replaces the built-in list of vendor directories, whose
+	// files are summarized rather than sent.
+	VendorDirs []string `toml:"vendor_dirs"`
+	// Privacy lists strings scrubbed from every prompt.
+	Privacy privacyConfig `toml:"privacy"`
+	// OpenRouter holds the routing preferences and attribution sent when
+	// --openai-base-url points at OpenRouter.
+	OpenRouter fastcommit.OpenRouterOptions `toml:"openrouter"`
+	// Conventional turns on --conventional and sets the allowed types.
+	Conventional conventionalConfig `toml:"conventional"`
+	// History chooses the recent commits shown as examples of the
+	// repository's style.
+	History historyConfig `toml:"history"`
+	// Lint holds generated messages to the repository's standards before
+	// they are committed.
+	Lint lintConfig `toml:"lint"`
+}
+
+type historyConfig struct {
+	// Count is the default of --history. Zero means
+	// fastcommit.DefaultHistory.
+	Count int `toml:"count"`
+	// MaxTokens caps the tokens of the examples, apart from the diff's
+	// budget; negative for no cap.
+	MaxTokens int `toml:"max_tokens"`
+	// MinLength leaves out messages shorter than this many characters;
+	// negative to keep them all.
+	MinLength int `toml:"min_length"`
+	// BotPattern is a regular expression matching the authors, as
+	// "Name <email>", whose commits are left out.
+	BotPattern string `toml:"bot_pattern"`
+}
+
+type conventionalConfig struct {
+	// Enabled acts as --conventional unless the flag is given.
+	Enabled bool `toml:"enabled"`
+	// Types replaces fastcommit.ConventionalTypes.
+	Types []string `toml:"types"`
+}
+
+type directoryConfig struct {
+	// Path is the directory, e.g. "~/work/", matched against the
+	// repository's top-level directory.
+	Path string `toml:"path"`
+	// The other settings replace the top-level ones of the same name.
+	Provider        string `toml:"provider"`
+	BaseURL         string `toml:"base_url"`
+	APIKey          string `toml:"api_key"`
+	AnthropicAPIKey string `toml:"anthropic_api_key"`
+	AzureAPIKey     string `toml:"azure_api_key"`
+	AzureDeployment string `toml:"azure_deployment"`
+	Model           string `toml:"model"`
+}
+
+type branchConfig struct {
+	// Pattern is matched against the short branch name, e.g. "release/*".
+	Pattern string `toml:"pattern"`
+	// Prefix is prepended verbatim to the subject line.
+	Prefix string `toml:"prefix"`
+	// Suffix is appended verbatim to the end of the body.
+	Suffix string `toml:"suffix"`
+}
+
+// applyConfig sets up f with the settings of cfg that apply to every
+// command generating messages.
+func applyConfig(f *flags, cfg config) error {
+	applyDefault(&f.openAIKey, "openai-key", "OPENAI_API_KEY", cfg.APIKey)
+	applyDefault(&f.anthropicKey, "anthropic-key", "ANTHROPIC_API_KEY", cfg.AnthropicAPIKey)
+	applyDefault(&f.azureKey, "azure-key", "AZURE_OPENAI_API_KEY", cfg.AzureAPIKey)
+	applyDefault(&f.azureDeployment, "azure-deployment", "AZURE_OPENAI_DEPLOYMENT", cfg.AzureDeployment)
+	applyDefault(&f.azureAPIVersion, "azure-api-version", "AZURE_OPENAI_API_VERSION", cfg.AzureAPIVersion)
+	baseURLSet := applyDefault(&f.openAIBaseURL, "openai-base-url", "OPENAI_BASE_URL", cfg.BaseURL)
+	providerSet := applyDefault(&f.provider, "provider", "FASTCOMMIT_PROVIDER", cfg.Provider)
+	if !providerSet && fastcommit.IsAzure(f.openAIBaseURL) {
+		f.provider = "azure"
+	}
+	modelSet := applyDefault(&f.model, "model", "FASTCOMMIT_MODEL", cfg.Model)
+	switch f.provider {
+	case "openai":
+	case "azure":
+		// The endpoint is a base URL like any other, but Azure's tools know
+		// it as $AZURE_OPENAI_ENDPOINT.
+		if !baseURLSet {
+			f.openAIBaseURL = os.Getenv("AZURE_OPENAI_ENDPOINT")
+		}
+		if f.openAIBaseURL == "" {
+			return &exitError{code: 2, err: errors.New(tr("azure_endpoint_missing"))}
+		}
+	case "ollama":
+		if f.ollamaURL == "" {
+			f.ollamaURL = os.Getenv("OLLAMA_HOST")
+		}
+		// The OpenAI model defaults mean nothing to Ollama.
+		if !modelSet {
+			f.model = fastcommit.DefaultOllamaModel
+		}
+		if !flagPassed("deep-model") {
+			f.deepModel = fastcommit.DefaultOllamaModel
+		}
+	case "anthropic":
+		if !modelSet {
+			f.model = fastcommit.DefaultAnthropicModel
+		}
+		if !flagPassed("deep-model") {
+			f.deepModel = fastcommit.DefaultAnthropicSmallModel
+		}
+	default:
+		return &exitError{code: 2, err: errors.New(tr("invalid_provider", f.provider))}
+	}
+	if cfg.MaxTokens != 0 && !flagPassed("max-tokens") {
+		f.maxTokens = cfg.MaxTokens
+	}
+	f.bodyMinLines = cfg.BodyMinLines
+	if cfg.MemoryLimitMB > 0 {
+		debug.SetMemoryLimit(int64(cfg.MemoryLimitMB) << 20)
+		f.maxDiffBytes = cfg.MemoryLimitMB << 20 / 8
+	}
+	if !flagPassed("history") {
+		f.history = cfg.History.Count
+	} else if f.history == 0 {
+		f.noHistory = true
+	}
+	if f.history < 0 {
+		return &exitError{code: 2, err: errors.New(tr("invalid_history", f.history))}
+	}
+	f.historyConfig = cfg.History
+	f.context = append(append(arrayFlags{}, cfg.Context...), f.context...)
+
+	var err error
+	if f.privacy, err = newPrivacyGuard(cfg); err != nil {
+		return err
+	}
+	f.openRouter = cfg.OpenRouter
+	if err := cfg.Lint.validate(); err != nil {
+		return err
+	}
+	f.conventionalTypes = cfg.Conventional.Types
+	if cfg.Conventional.Enabled && !flagPassed("conventional") {
+		if f.prefix != "" {
+			return errors.New(tr("prefix_conventional"))
+		}
+		f.conventional = true
+	}
+	// Conventional Commits need a type, which the paths hint at strongly.
+	if f.conventional && !flagPassed("type-from-paths") {
+		f.typeFromPaths = "hint"
+	}
+	return nil
+}
+
+func configPath() (string, error) {
+	cdir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cdir, "config.toml"), nil
+}
+
+// repoConfigName is the file with a repository's own settings, found in the
+// working directory or one of its parents within the repository.
+const repoConfigName = ".fastcommit.toml"
+
+// repoConfigDenied are the keys a repository's config may not set: a cloned
+// repository must not be able to send the key or the prompt elsewhere or run
+// commands. Its [privacy] settings are added to the user's instead, so that
+// it can only redact more.
+var repoConfigDenied = []string{"api_key", "anthropic_api_key", "azure_api_key", "base_url", "provider", "openrouter", "directories", "hooks"}
+
+// loadConfig reads the user configuration and applies the repository's
+// .fastcommit.toml on top, key by key. Missing files yield the zero config.
+// Unknown keys, usually typos, are an error, or only a warning when lenient
+// is set.
+func loadConfig(lenient bool) (config, error) {
+	var c config
+	cp, err := configPath()
+	if err != nil {
+		return c, err
+	}
+	if c, _, err = readConfig(cp, lenient); err != nil {
+		return c, err
+	}
+	if root, err := gitOutput("rev-parse", "--show-toplevel"); err == nil {
+		if i, ok := c.directorySettings(root); ok {
+			debugf("applying [[directories]] entry %d (path %q) to %s", i+1, c.Directories[i].Path, root)
+			c.applyDirectory(c.Directories[i])
+		}
+	}
+
+	rp, ok := findRepoConfig()
+	if !ok {
+		return c, nil
+	}
+	rc, keys, err := readConfig(rp, lenient)
+	if err != nil {
+		return c, err
+	}
+	debugf("applying %s", rp)
+	for _, key := range keys {
+		if slices.Contains(repoConfigDenied, key) {
+			warnf("%s\n", tr("repo_config_denied", rp, key))
+			continue
+		}
+		if key == "privacy" {
+			c.Privacy.add(rc.Privacy)
+			continue
+		}
+		copyConfigKey(&c, rc, key)
+	}
+	return c, nil
+}
+
+// readConfig reads the config file at path, returning the top-level keys it
+// sets. A missing file yields the zero config.
+func readConfig(path string, lenient bool) (c config, keys []string, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil, nil
+		}
+		return c, nil, err
+	}
+	c, unknown, err := decodeConfig(path, b)
+	if err != nil {
+		return c, nil, err
+	}
+	if len(unknown) > 0 {
+		if !lenient {
+			return c, nil, fmt.Errorf("%s\n%s", strings.Join(unknown, "\n"), tr("lenient_config_hint"))
+		}
+		for _, u := range unknown {
+			warnf("%s\n", u)
+		}
+	}
+	var m map[string]any
+	if err := toml.Unmarshal(b, &m); err != nil {
+		return c, nil, err
+	}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return c, keys, nil
+}
+
+// findRepoConfig looks for .fastcommit.toml from the working directory up to
+// the root of the repository.
+func findRepoConfig() (string, bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	for {
+		p := filepath.Join(dir, repoConfigName)
+		if _, err := os.Stat(p); err == nil {
+			return p, true
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// copyConfigKey sets the setting with the top-level key in dst to its value
+// in src.
+func copyConfigKey(dst *config, src config, key string) {
+	dv, sv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src)
+	for i := 0; i < dv.NumField(); i++ {
+		if name, _, _ := strings.Cut(dv.Type().Field(i).Tag.Get("toml"), ","); name == key {
+			dv.Field(i).Set(sv.Field(i))
+			return
+		}
+	}
+}
+
+// applyDefault sets *dst to the value of a setting that is not given by the
+// flag named flagName: the environment variable env, or else configured. It
+// reports whether any of the three set it.
+func applyDefault(dst *string, flagName, env, configured string) bool {
+	if flagPassed(flagName) {
+		return true
+	}
+	if v := os.Getenv(env); env != "" && v != "" {
+		*dst = v
+		return true
+	}
+	if configured != "" {
+		*dst = configured
+		return true
+	}
+	return false
+}
+
+// decodeConfig decodes the config file at path. Keys that match no setting
+// are returned as unknown, each as "file:line:column: unknown key name",
+// while the rest of the file still applies.
+func decodeConfig(path string, data []byte) (c config, unknown []string, err error) {
+	d := toml.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	err = d.Decode(&c)
+	var strict *toml.StrictMissingError
+	var decodeErr *toml.DecodeError
+	switch {
+	case errors.As(err, &strict):
+		for _, e := range strict.Errors {
+			row, col := e.Position()
+			unknown = append(unknown, fmt.Sprintf("%s:%d:%d: unknown key %s", path, row, col, strings.Join(e.Key(), ".")))
+		}
+		c = config{}
+		return c, unknown, toml.Unmarshal(data, &c)
+	case errors.As(err, &decodeErr):
+		row, col := decodeErr.Position()
+		return c, nil, fmt.Errorf("%s:%d:%d: %s", path, row, col, strings.TrimPrefix(decodeErr.Error(), "toml: "))
+	case err != nil:
+		return c, nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return c, nil, nil
+}
+
+// directorySettings returns the index of the first [[directories]] entry
+// whose path contains the repository at root.
+func (c config) directorySettings(root string) (int, bool) {
+	for i, dc := range c.Directories {
+		if dc.Path != "" && inDirectory(root, dc.Path) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// applyDirectory replaces the settings dc sets.
+func (c *config) applyDirectory(dc directoryConfig) {
+	for _, s := range []struct {
+		dst *string
+		v   string
+	}{
+		{&c.Provider, dc.Provider},
+		{&c.BaseURL, dc.BaseURL},
+		{&c.APIKey, dc.APIKey},
+		{&c.AnthropicAPIKey, dc.AnthropicAPIKey},
+		{&c.AzureAPIKey, dc.AzureAPIKey},
+		{&c.AzureDeployment, dc.AzureDeployment},
+		{&c.Model, dc.Model},
+	} {
+		if s.v != "" {
+			*s.dst = s.v
+		}
+	}
+}
+
+// inDirectory reports whether path is dir or lies within it. A leading ~ in
+// dir is the home directory, and a symlinked dir also matches where it
+// points. Windows paths are compared case-insensitively.
+func inDirectory(path, dir string) bool {
+	if rest, ok := strings.CutPrefix(dir, "~"); ok && (rest == "" || rest[0] == '/' || rest[0] == filepath.Separator) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return false
+		}
+		dir = home + rest
+	}
+	path = filepath.Clean(path)
+	dirs := []string{filepath.Clean(dir)}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dirs = append(dirs, real)
+	}
+	for _, d := range dirs {
+		p := path
+		if runtime.GOOS == "windows" {
+			p, d = strings.ToLower(p), strings.ToLower(d)
+		}
+		if p == d || strings.HasPrefix(p, strings.TrimSuffix(d, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// branchSettings returns the settings for branch, if any entry matches.
+func (c config) branchSettings(branch string) (branchConfig, bool) {
+	if branch == "" {
+		return branchConfig{}, false
+	}
+	for _, bc := range c.Branches {
+		if ok, _ := path.Match(bc.Pattern, branch); ok {
+			return bc, true
+		}
+	}
+	return branchConfig{}, false
+}
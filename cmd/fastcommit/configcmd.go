@@ -0,0 +1,449 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// runConfig runs the config subcommands. Their messages are localized, but
+// not the settings, TOML and JSON they print.
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return errors.New(tr("config_usage"))
+	}
+	switch args[0] {
+	case "validate":
+		return validateConfig()
+	case "list":
+		return listConfig()
+	case "which":
+		return whichConfig()
+	case "get":
+		if len(args) != 2 {
+			return errors.New(tr("config_get_usage"))
+		}
+		return getConfig(args[1])
+	case "set":
+		if len(args) < 2 {
+			return errors.New(tr("config_set_usage"))
+		}
+		if err := setConfigValue(args[1], args[2:]); err != nil {
+			return err
+		}
+		cp, err := configPath()
+		if err != nil {
+			return err
+		}
+		fmt.Println(tr("config_set", cp, args[1]))
+		return nil
+	case "schema":
+		b, err := json.MarshalIndent(configSchema(), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	default:
+		return errors.New(tr("config_unknown_command", args[0]))
+	}
+}
+
+// whichConfig prints the config files that apply in the working directory
+// and which [[directories]] entry matches its repository.
+func whichConfig() error {
+	cp, err := configPath()
+	if err != nil {
+		return err
+	}
+	c, _, err := readConfig(cp, true)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(cp); err == nil {
+		fmt.Println(tr("config_which_user", cp))
+	} else {
+		fmt.Println(tr("config_which_user_none", cp))
+	}
+
+	root, err := gitOutput("rev-parse", "--show-toplevel")
+	switch i, ok := c.directorySettings(root); {
+	case err != nil:
+		fmt.Println(tr("config_which_no_repo"))
+	case ok:
+		dc := c.Directories[i]
+		var sets []string
+		for _, s := range []struct{ key, v string }{
+			{"provider", dc.Provider},
+			{"base_url", dc.BaseURL},
+			{"api_key", dc.APIKey},
+			{"anthropic_api_key", dc.AnthropicAPIKey},
+			{"azure_api_key", dc.AzureAPIKey},
+			{"azure_deployment", dc.AzureDeployment},
+			{"model", dc.Model},
+		} {
+			if s.v != "" {
+				sets = append(sets, s.key)
+			}
+		}
+		if len(sets) == 0 {
+			sets = []string{tr("config_which_nothing")}
+		}
+		fmt.Println(tr("config_which_match", i+1, dc.Path, root, strings.Join(sets, ", ")))
+	case len(c.Directories) == 0:
+		fmt.Println(tr("config_which_no_entries"))
+	default:
+		fmt.Println(tr("config_which_no_match", len(c.Directories), root))
+	}
+
+	if rp, ok := findRepoConfig(); ok {
+		fmt.Println(tr("config_which_repo", rp))
+	} else {
+		fmt.Println(tr("config_which_repo_none"))
+	}
+	return nil
+}
+
+// validateConfig checks the config file strictly and prints it normalized:
+// with the keys fastcommit understands, in its canonical form.
+func validateConfig() error {
+	cp, err := configPath()
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(cp)
+	if os.IsNotExist(err) {
+		fmt.Println(tr("config_validate_none", cp))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c, unknown, err := decodeConfig(cp, b)
+	if err != nil {
+		return err
+	}
+	for _, u := range unknown {
+		fmt.Println(u)
+	}
+	normalized, err := toml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("# %s\n%s", tr("config_validate_normalized", cp), normalized)
+	if len(unknown) > 0 {
+		return errors.New(tr("config_unknown_keys", cp, len(unknown)))
+	}
+	return nil
+}
+
+// configSchema returns a JSON Schema for config.toml, derived from the
+// config struct so that it cannot fall out of date.
+func configSchema() map[string]any {
+	s := typeSchema(reflect.TypeOf(config{}))
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "fastcommit config.toml"
+	return s
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			props[name] = typeSchema(field.Type)
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+// listConfig prints the settings made in config.toml and in the repository's
+// .fastcommit.toml, the latter taking precedence. The key is masked.
+func listConfig() error {
+	cp, err := configPath()
+	if err != nil {
+		return err
+	}
+	files := []string{cp}
+	if rp, ok := findRepoConfig(); ok {
+		files = append(files, rp)
+	}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var m map[string]any
+		if err := toml.Unmarshal(b, &m); err != nil {
+			return fmt.Errorf("parse %s: %w", file, err)
+		}
+		fmt.Printf("# %s\n", file)
+		printSettings("", m)
+	}
+	return nil
+}
+
+func printSettings(prefix string, m map[string]any) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		v := m[k]
+		if (k == "api_key" || k == "anthropic_api_key" || k == "azure_api_key") && prefix == "" {
+			if key, ok := v.(string); ok {
+				v = maskKey(key)
+			}
+		}
+		if sub, ok := v.(map[string]any); ok {
+			printSettings(prefix+k+".", sub)
+			continue
+		}
+		fmt.Printf("%s%s = %s\n", prefix, k, tomlValue(v))
+	}
+}
+
+// getConfig prints the setting at the dotted key as it applies here, with
+// .fastcommit.toml applied on top of config.toml. Strings are printed as
+// they are, other values in TOML syntax.
+func getConfig(key string) error {
+	if _, err := configField(key); err != nil {
+		return err
+	}
+	c, err := loadConfig(true)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(c)
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		f, _ := fieldByTag(v.Type(), part)
+		v = v.FieldByIndex(f.Index)
+	}
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil
+	}
+	if v.Kind() == reflect.String {
+		fmt.Println(v.String())
+		return nil
+	}
+	fmt.Println(tomlValue(v.Interface()))
+	return nil
+}
+
+// fieldByTag returns the field of the struct type t with the TOML name name.
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if tag, _, _ := strings.Cut(f.Tag.Get("toml"), ","); tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// configField returns the type of the setting at the dotted key, such as
+// "model" or "conventional.enabled". Only settings that hold a value and are
+// at most one table deep can be set on the command line.
+func configField(key string) (reflect.Type, error) {
+	parts := strings.Split(key, ".")
+	if len(parts) > 2 {
+		return nil, errors.New(tr("config_unknown_setting", key))
+	}
+	t := reflect.TypeOf(config{})
+	for _, part := range parts {
+		if t.Kind() != reflect.Struct {
+			return nil, errors.New(tr("config_unknown_setting", key))
+		}
+		f, ok := fieldByTag(t, part)
+		if !ok {
+			return nil, errors.New(tr("config_unknown_setting", key))
+		}
+		t = f.Type
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct:
+		return nil, errors.New(tr("config_set_table", key))
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+		return nil, errors.New(tr("config_set_edit_only", key))
+	}
+	return t, nil
+}
+
+// parseConfigValue converts the command line values to the type of a
+// setting. Lists take any number of values, everything else exactly one.
+func parseConfigValue(t reflect.Type, key string, args []string) (any, error) {
+	if t.Kind() == reflect.Slice {
+		return append([]string{}, args...), nil
+	}
+	if len(args) != 1 {
+		return nil, errors.New(tr("config_set_single", key))
+	}
+	arg := args[0]
+	switch t.Kind() {
+	case reflect.String:
+		return arg, nil
+	case reflect.Bool:
+		return strconv.ParseBool(arg)
+	case reflect.Int, reflect.Int64:
+		return strconv.Atoi(arg)
+	case reflect.Float64:
+		return strconv.ParseFloat(arg, 64)
+	}
+	return nil, errors.New(tr("config_set_unsupported", key))
+}
+
+// tomlValue formats v as a TOML value.
+func tomlValue(v any) string {
+	b, err := toml.Marshal(map[string]any{"v": v})
+	if s, ok := strings.CutPrefix(string(b), "v = "); ok && err == nil {
+		return strings.TrimSpace(s)
+	}
+	if list, ok := v.([]any); ok {
+		return tr("config_entries", len(list))
+	}
+	return fmt.Sprint(v)
+}
+
+var (
+	tomlTableRe = regexp.MustCompile(`^\s*\[\[?\s*([\w.\-]+)\s*\]\]?\s*(#.*)?$`)
+	tomlKeyRe   = regexp.MustCompile(`^\s*([\w\-]+)\s*=`)
+)
+
+// setConfigValue sets the setting at the dotted key in config.toml to args,
+// editing the file in place so that comments and the order of the other
+// settings are kept.
+func setConfigValue(key string, args []string) error {
+	t, err := configField(key)
+	if err != nil {
+		return err
+	}
+	v, err := parseConfigValue(t, key, args)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	cp, err := configPath()
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(cp)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	table, name := "", key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		table, name = key[:i], key[i+1:]
+	}
+	line := name + " = " + tomlValue(v)
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	if len(b) == 0 {
+		lines = nil
+	}
+
+	// Find the lines of the table: the top level up to the first table
+	// header, or a table from its header to the next.
+	start, end, found := 0, len(lines), table == ""
+	for i, l := range lines {
+		m := tomlTableRe.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		if found {
+			end = i
+			break
+		}
+		if m[1] == table && !strings.Contains(l, "[[") {
+			start, found = i+1, true
+		}
+	}
+	if !found {
+		lines = append(lines, "", "["+table+"]", line)
+		return writeConfig(cp, lines)
+	}
+
+	for i := start; i < end; i++ {
+		m := tomlKeyRe.FindStringSubmatch(lines[i])
+		if m == nil || m[1] != name {
+			continue
+		}
+		// A list may span several lines.
+		last, depth := i, 0
+		for j := i; j < end; j++ {
+			depth += strings.Count(lines[j], "[") - strings.Count(lines[j], "]")
+			last = j
+			if depth <= 0 {
+				break
+			}
+		}
+		lines = append(lines[:i], append([]string{line}, lines[last+1:]...)...)
+		return writeConfig(cp, lines)
+	}
+
+	// Add it after the last setting of the table.
+	at := start
+	for i := start; i < end; i++ {
+		if l := strings.TrimSpace(lines[i]); l != "" && !strings.HasPrefix(l, "#") {
+			at = i + 1
+		}
+	}
+	lines = append(lines[:at], append([]string{line}, lines[at:]...)...)
+	return writeConfig(cp, lines)
+}
+
+// writeConfig checks the edited config and writes it, readable only by the
+// user since it may hold the key.
+func writeConfig(path string, lines []string) error {
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	if _, unknown, err := decodeConfig(path, data); err != nil || len(unknown) > 0 {
+		return errors.New(tr("config_set_would_break", path, err, unknown))
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0o600)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// contextLabelRe matches a label such as "bug: " at the start of a --context
+// value. Labels are single words so that ordinary sentences with a colon are
+// not taken apart.
+var contextLabelRe = regexp.MustCompile(`^([A-Za-z][\w-]{0,23}):\s+`)
+
+// contextItem is a --context value with its optional label.
+type contextItem struct {
+	label string
+	text  string
+}
+
+func parseContextItem(s string) contextItem {
+	s = strings.TrimSpace(s)
+	if m := contextLabelRe.FindStringSubmatch(s); m != nil {
+		return contextItem{label: m[1], text: strings.TrimSpace(s[len(m[0]):])}
+	}
+	return contextItem{text: s}
+}
+
+// relatedMessages returns the full messages of the --context-ref commits as
+// context items, failing for refs that do not name a commit.
+func relatedMessages(refs []string) ([]string, error) {
+	var items []string
+	for _, ref := range refs {
+		hash, err := resolveRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := gitOutput("show", "-s", "--format=%B", hash)
+		if err != nil {
+			return nil, err
+		}
+		label := shortHash(hash)
+		if !strings.HasPrefix(hash, ref) {
+			label += ", " + ref
+		}
+		items = append(items, fmt.Sprintf("Message of a related change (commit %s):\n%s", label, msg))
+	}
+	return items, nil
+}
+
+// contextMessages renders the --context values as one numbered list in
+// their order, preceded by the instruction to reflect them. A single message
+// is followed better than one per value, where the model tends to fixate on
+// the first, and costs fewer tokens.
+func contextMessages(values []string) []openai.ChatCompletionMessage {
+	var b strings.Builder
+	b.WriteString("Additional context, in order:\n")
+	var labels []string
+	for i, v := range values {
+		item := parseContextItem(v)
+		prefix := fmt.Sprintf("%d. ", i+1)
+		if item.label != "" {
+			prefix += "[" + item.label + "] "
+			labels = append(labels, fmt.Sprintf("%q", item.label))
+		}
+		// Indent continuation lines, e.g. of hook output, under the item.
+		text := strings.ReplaceAll(item.text, "\n", "\n"+strings.Repeat(" ", len(fmt.Sprint(i+1))+2))
+		b.WriteString(prefix + text + "\n")
+	}
+
+	instruction := "The user has provided additional context that MUST be included in the commit message."
+	if len(values) > 1 {
+		instruction += " Reflect every item of the list, not only the first."
+	}
+	if len(labels) > 0 {
+		instruction += fmt.Sprintf(" In particular, the message must reflect the items labeled %s.", joinList(labels))
+	}
+	return []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: instruction},
+		{Role: openai.ChatMessageRoleUser, Content: strings.TrimSuffix(b.String(), "\n")},
+	}
+}
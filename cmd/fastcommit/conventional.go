@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// conventionalRules returns the rules of --conventional, or nil without it.
+func conventionalRules(f flags) *fastcommit.ConventionalRules {
+	if !f.conventional {
+		return nil
+	}
+	return &fastcommit.ConventionalRules{Types: f.conventionalTypes, Scope: f.scope}
+}
+
+// checkConventional makes sure msg follows the --conventional rules. A
+// message breaking them is regenerated once, told which rules it broke; one
+// that still breaks them is an error, so that it never reaches a commit hook
+// that would reject it.
+func checkConventional(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	p prompt,
+	msg string,
+) (string, error) {
+	rules := conventionalRules(f)
+	if rules == nil {
+		return msg, nil
+	}
+	// The type the tool sets itself, e.g. "revert" for a revert, is allowed
+	// whatever the list says.
+	if typ, _, _ := strings.Cut(p.typeHint, "("); typ != "" && !slices.Contains(rules.Types, typ) {
+		if rules.Types == nil {
+			rules.Types = fastcommit.ConventionalTypes
+		}
+		rules.Types = append(rules.Types[:len(rules.Types):len(rules.Types)], typ)
+	}
+	broken := rules.Check(msg)
+	if len(broken) == 0 {
+		return msg, nil
+	}
+	verbosef("message breaks Conventional Commits rules %q, regenerating", broken)
+	retry := append(p.msgs[:len(p.msgs):len(p.msgs)],
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: msg},
+		openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleSystem,
+			Content: "The message breaks the Conventional Commits rules: " + strings.Join(broken, "; ") +
+				". Write the whole message again, fixing the subject line.",
+		},
+	)
+	// Not streamed; the display is updated with the final message afterwards.
+	again, err := generateMessage(ctx, client, f, retry, &rawDisplay{w: io.Discard})
+	if err != nil {
+		return "", err
+	}
+	if broken := rules.Check(again); len(broken) > 0 {
+		return "", errors.New(tr("conventional_invalid", strings.Join(broken, "; "), again))
+	}
+	return again, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// conventionsMaxTokens caps the documented conventions in the prompt.
+const conventionsMaxTokens = 1500
+
+// cachedConventions is the extracted, capped commit section of a conventions
+// document, kept until the document changes.
+type cachedConventions struct {
+	Hash   string `json:"hash"`
+	Text   string `json:"text"`
+	Tokens int    `json:"tokens"`
+}
+
+// conventions returns the commit conventions documented in the file given
+// with --conventions-from or, with --auto-conventions, the first of
+// fastcommit.ConventionFiles that exists. The extracted section is cached
+// per file in the repository's state, keyed by the file's hash.
+func conventions(f flags) (string, error) {
+	path := f.conventionsFrom
+	if path == "" {
+		if !f.autoConventions {
+			return "", nil
+		}
+		root, err := gitOutput("rev-parse", "--show-toplevel")
+		if err != nil {
+			return "", err
+		}
+		for _, name := range fastcommit.ConventionFiles {
+			if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+				path = filepath.Join(root, name)
+				break
+			}
+		}
+		if path == "" {
+			verbosef("conventions: none of the usual files found")
+			return "", nil
+		}
+	}
+
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read conventions: %w", err)
+	}
+	sum := sha256.Sum256(doc)
+	hash := hex.EncodeToString(sum[:])
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	var cache map[string]cachedConventions
+	if err := readStateFile(repoScope, "conventions.json", &cache); err != nil {
+		debugf("conventions cache: %v", err)
+	}
+	c, ok := cache[key]
+	if !ok || c.Hash != hash {
+		text := fastcommit.ExtractCommitSection(string(doc))
+		if text == "" && strings.Contains(strings.ToLower(filepath.Base(path)), "commit") {
+			// The whole file is about commits.
+			text = strings.TrimSpace(string(doc))
+		}
+		text = fastcommit.Ellipse(text, conventionsMaxTokens)
+		c = cachedConventions{
+			Hash:   hash,
+			Text:   text,
+			Tokens: fastcommit.CountTokens(openai.ChatCompletionMessage{Content: text}),
+		}
+		err := updateStateFile(repoScope, "conventions.json", &cache, func() {
+			if cache == nil {
+				cache = map[string]cachedConventions{}
+			}
+			cache[key] = c
+		})
+		if err != nil {
+			debugf("conventions cache: %v", err)
+		}
+	}
+	if c.Text == "" {
+		warnf("%s\n", tr("no_commit_section", path))
+		return "", nil
+	}
+	verbosef("conventions: %d tokens from %s", c.Tokens, path)
+	for _, conflict := range conventionConflicts(f, c.Text) {
+		warnf("%s\n", tr("conventions_conflict", path, conflict))
+	}
+	return c.Text, nil
+}
+
+var (
+	forbidsConventionalRe = regexp.MustCompile(
+		`(?i)\b(?:do not|don't|never|avoid|no)\b[^.\n]{0,40}\b(?:conventional commits?|type prefix(?:es)?)`)
+	subjectLimitRe = regexp.MustCompile(
+		`(?i)\b(?:subject|summary|title|first line)\b[^\n]{0,60}?\b(\d{2,3})\s*(?:characters|chars|columns)`)
+	bodyLimitRe = regexp.MustCompile(
+		`(?i)\b(?:body|wrap)\b[^\n]{0,60}?\b(\d{2,3})\s*(?:characters|chars|columns)`)
+)
+
+// conventionConflicts describes where the documented conventions contradict
+// explicitly passed flags. The flags win: they come later in the prompt or
+// are enforced after generation.
+func conventionConflicts(f flags, doc string) []string {
+	var conflicts []string
+	if forbidsConventionalRe.MatchString(doc) {
+		switch {
+		case f.conventional:
+			conflicts = append(conflicts, tr("conflict_conventional_flag"))
+		case f.typeFromPaths == "strict":
+			conflicts = append(conflicts, tr("conflict_conventional"))
+		}
+	}
+	check := func(re *regexp.Regexp, name string, value int) {
+		if !flagPassed(name) {
+			return
+		}
+		if m := re.FindStringSubmatch(doc); m != nil {
+			if n, _ := strconv.Atoi(m[1]); n != value {
+				conflicts = append(conflicts, tr("conflict_length", n, name, value))
+			}
+		}
+	}
+	check(subjectLimitRe, "subject-length", f.subjectLength)
+	check(bodyLimitRe, "body-width", f.bodyWidth)
+	return conflicts
+}
+
+// flagPassed reports whether the flag name was given on the command line.
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// deepMinTokens is the diff size below which --deep falls back to the
+	// normal prompt, since the diff fits comfortably anyway.
+	deepMinTokens = 30000
+	// deepChunkMaxTokens caps the diff of a single component sent to be
+	// summarized.
+	deepChunkMaxTokens = 30000
+	// deepStatMaxTokens caps the diffstat in the final prompt.
+	deepStatMaxTokens = 4000
+)
+
+// deepOverview summarizes each component touched by a very large diff with
+// the cheaper --deep-model, and returns the summaries and a diffstat to stand
+// in for the diff in the final prompt. ok is false when the diff is small
+// enough for the normal prompt.
+func deepOverview(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	workdir string,
+	hash string,
+	redactor *fastcommit.PathRedactor,
+) (overview string, ok bool, err error) {
+	opts := promptOptions(f, hash)
+	chunks, err := fastcommit.DiffByComponent(workdir, opts)
+	if err != nil {
+		return "", false, fmt.Errorf("split diff: %w", err)
+	}
+	tokens := 0
+	for _, c := range chunks {
+		tokens += fastcommit.CountTokens(openai.ChatCompletionMessage{Content: c.Diff})
+	}
+	if tokens < deepMinTokens || len(chunks) < 2 {
+		verbosef("deep: diff is %d tokens in %d components, using the normal prompt", tokens, len(chunks))
+		return "", false, nil
+	}
+
+	stats, err := fastcommit.DiffStats(workdir, opts)
+	if err != nil {
+		return "", false, fmt.Errorf("diff stats: %w", err)
+	}
+	lines := map[string]int{}
+	for _, s := range stats {
+		lines[s.Path] = s.Lines()
+	}
+
+	var sb strings.Builder
+	sb.WriteString("The diff is too large to show. Here is a summary of the changes to each component:\n\n")
+	for i, c := range chunks {
+		infof("%s\n", tr("deep_progress", i+1, len(chunks), c.Component))
+		summary, err := summarizeChunk(ctx, client, f, c, redactor)
+		if err != nil {
+			return "", false, fmt.Errorf("summarize %s: %w", c.Component, err)
+		}
+		n := 0
+		for _, p := range c.Paths {
+			n += lines[p]
+		}
+		fmt.Fprintf(&sb, "- %s (%d files, %d changed lines): %s\n", c.Component, len(c.Paths), n, summary)
+	}
+
+	var stat strings.Builder
+	for _, s := range stats {
+		if s.Binary {
+			fmt.Fprintf(&stat, "%s | binary\n", s.Path)
+			continue
+		}
+		fmt.Fprintf(&stat, "%s | +%d -%d\n", s.Path, s.Added, s.Deleted)
+	}
+	sb.WriteString("\nDiffstat:\n")
+	sb.WriteString(fastcommit.Ellipse(stat.String(), deepStatMaxTokens))
+	sb.WriteString("\nWrite a subject for the change as a whole, and a body with one bullet per component.")
+	return sb.String(), true, nil
+}
+
+// summarizeChunk asks for a one or two sentence summary of chunk.
+func summarizeChunk(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	chunk fastcommit.DiffChunk,
+	redactor *fastcommit.PathRedactor,
+) (string, error) {
+	msgs := []openai.ChatCompletionMessage{
+		{
+			Role: openai.ChatMessageRoleSystem,
+			Content: fmt.Sprintf("The next message is the part of a large diff that touches %q. "+
+				"Summarize what it changes and why in one or two sentences. "+
+				"Describe the intent, not individual lines. Output only the summary.", chunk.Component),
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: fastcommit.Ellipse(chunk.Diff, deepChunkMaxTokens),
+		},
+	}
+	msgs, err := scanSecrets(f, msgs)
+	if err != nil {
+		return "", err
+	}
+	if redactor != nil {
+		msgs = redactor.RedactMessages(msgs)
+	}
+	msgs = f.privacy.scrub(msgs)
+	if err := f.privacy.check(msgs); err != nil {
+		return "", err
+	}
+	resp, err := createWithRetry(ctx, client, f, openai.ChatCompletionRequest{
+		Model:       f.deepModel,
+		Temperature: 0,
+		Messages:    msgs,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("empty response")
+	}
+	return strings.Join(strings.Fields(resp.Choices[0].Message.Content), " "), nil
+}
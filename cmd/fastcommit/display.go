@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"golang.org/x/term"
+)
+
+// display renders streamed message deltas for the user. It is only concerned
+// with presentation; the message itself is accumulated separately.
+type display interface {
+	Write(delta string)
+	// Close flushes any buffered output and ends the line.
+	Close()
+	// Replace shows msg in place of what was displayed, unless they are
+	// the same, so that the message shown is exactly the one committed
+	// after cleanup and post-processing.
+	Replace(msg string)
+}
+
+// newDisplay returns the display for w, wrapping at the terminal width when w
+// is a terminal. Plain output is neither wrapped nor colored.
+func newDisplay(w *os.File, plain bool) display {
+	if plain || !isTerminal(w) {
+		return &rawDisplay{w: w, color: !plain}
+	}
+	d := &wrapDisplay{w: w}
+	d.width.Store(int64(terminalWidth(w)))
+	d.stopResize = watchResize(func() {
+		width := int64(terminalWidth(w))
+		if d.width.Swap(width) != width {
+			d.resized.Store(true)
+		}
+	})
+	return d
+}
+
+func terminalHeight(f *os.File) int {
+	_, height, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return height
+}
+
+func terminalWidth(f *os.File) int {
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil || width <= 0 {
+		return 80
+	}
+	return width
+}
+
+// nullDisplay shows nothing, for --output json.
+type nullDisplay struct{}
+
+func (nullDisplay) Write(string)   {}
+func (nullDisplay) Close()         {}
+func (nullDisplay) Replace(string) {}
+
+// rawDisplay writes deltas exactly as received.
+type rawDisplay struct {
+	w     io.Writer
+	color bool
+	text  strings.Builder
+}
+
+func (d *rawDisplay) Write(delta string) {
+	delta = printable(delta)
+	d.text.WriteString(delta)
+	if d.color {
+		fmt.Fprintf(d.w, "\033[34m%s\033[0m", delta)
+		return
+	}
+	fmt.Fprint(d.w, delta)
+}
+
+func (d *rawDisplay) Close() {
+	fmt.Fprintln(d.w)
+}
+
+// Replace prints msg again below a separator, since the text already written
+// cannot be taken back.
+func (d *rawDisplay) Replace(msg string) {
+	if d.text.String() == msg {
+		return
+	}
+	fmt.Fprintf(d.w, "-- %s\n", tr("final_message"))
+	d.text.Reset()
+	d.Write(msg)
+	d.Close()
+}
+
+// wrapDisplay buffers deltas into words and wraps them at the terminal width
+// so that words are never split at the edge of the screen.
+type wrapDisplay struct {
+	w io.Writer
+	// width is updated from the resize handler until the first Close,
+	// which calls stopResize.
+	width      atomic.Int64
+	stopResize func()
+
+	// resized is set when the width changed, after which the rows the
+	// terminal shows can no longer be told.
+	resized atomic.Bool
+
+	// col is the columns taken on the current line, beyond the width for a
+	// word too long to wrap, which the terminal breaks itself.
+	col   int
+	word  strings.Builder
+	space strings.Builder
+	// text is everything written, and rows the screen rows taken by the
+	// lines ended so far.
+	text strings.Builder
+	rows int
+}
+
+func (d *wrapDisplay) Write(delta string) {
+	delta = printable(delta)
+	d.text.WriteString(delta)
+	for _, r := range delta {
+		switch {
+		case r == '\n':
+			d.flushWord()
+			d.space.Reset()
+			fmt.Fprint(d.w, "\n")
+			d.endLine()
+		case unicode.IsSpace(r):
+			d.flushWord()
+			d.space.WriteRune(r)
+		default:
+			d.word.WriteRune(r)
+		}
+	}
+}
+
+func (d *wrapDisplay) flushWord() {
+	if d.word.Len() == 0 {
+		return
+	}
+	word := d.word.String()
+	d.word.Reset()
+
+	// Spaces are held back until the next word so that a wrapped line never
+	// ends in trailing whitespace.
+	space := d.space.String()
+	d.space.Reset()
+
+	end := advance(advance(d.col, space), word)
+	if d.col > 0 && end > int(d.width.Load()) {
+		fmt.Fprint(d.w, "\n")
+		d.endLine()
+		space = ""
+		end = advance(0, word)
+	}
+	d.print(space + word)
+	d.col = end
+}
+
+// endLine counts the rows the line just ended took on the screen.
+func (d *wrapDisplay) endLine() {
+	width := max(int(d.width.Load()), 1)
+	d.rows += max(1, (d.col+width-1)/width)
+	d.col = 0
+}
+
+func (d *wrapDisplay) print(s string) {
+	fmt.Fprintf(d.w, "\033[34m%s\033[0m", s)
+}
+
+func (d *wrapDisplay) Close() {
+	d.stopResize()
+	d.flushWord()
+	fmt.Fprintln(d.w)
+	d.endLine()
+}
+
+// Replace moves the cursor back to where the message started, clears the
+// screen below it and renders msg there. When part of the message has
+// already scrolled off the screen, or the terminal was resized so that its
+// rows are unknown, msg is printed below a separator instead.
+func (d *wrapDisplay) Replace(msg string) {
+	if d.text.String() == msg {
+		return
+	}
+	if f, ok := d.w.(*os.File); ok && os.Getenv("TERM") != "dumb" && !d.resized.Load() &&
+		terminalWidth(f) == int(d.width.Load()) && d.rows < terminalHeight(f) {
+		fmt.Fprintf(d.w, "\033[%dA\r\033[J", d.rows)
+	} else {
+		fmt.Fprintf(d.w, "-- %s\n", tr("final_message"))
+	}
+	d.text.Reset()
+	d.col, d.rows = 0, 0
+	d.word.Reset()
+	d.space.Reset()
+	d.Write(msg)
+	d.Close()
+}
+
+// advance returns the column the cursor reaches printing s from col, with
+// tab stops every 8 columns.
+func advance(col int, s string) int {
+	for _, r := range s {
+		if r == '\t' {
+			col = col/8*8 + 8
+		} else {
+			col += runeWidth(r)
+		}
+	}
+	return col
+}
+
+// runeWidth returns the columns r takes on a terminal: none for combining
+// marks and joiners, two for East Asian wide characters and emoji.
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r < wide[0] {
+			break
+		}
+		if r <= wide[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// wideRanges are the main ranges of characters terminals show two columns
+// wide, in order.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo
+	{0x2E80, 0x303E},   // CJK radicals and punctuation
+	{0x3041, 0x33FF},   // Kana and CJK symbols
+	{0x3400, 0x4DBF},   // CJK Extension A
+	{0x4E00, 0x9FFF},   // CJK Unified Ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x1F300, 0x1F64F}, // Emoji and pictographs
+	{0x1F900, 0x1F9FF}, // Supplemental pictographs
+	{0x20000, 0x3FFFD}, // CJK Extensions B and later
+}
+
+// printable removes control characters other than newlines and tabs from
+// streamed text, so that escape sequences in a response cannot reach the
+// terminal.
+func printable(s string) string {
+	return string(fastcommit.StripControl.Fn(fastcommit.Message(s)))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrapDisplayRows(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		rows int
+	}{
+		{"short", "Add caching", 1},
+		{"wrapped", "Add caching to the parser", 3},
+		{"lines", "Add caching\n\nKeep results", 3},
+		{"word longer than the width", "Rename parseConfigurationFile", 3},
+		{"wide runes", "修复解析器的缓存问题", 2},
+		{"combining marks", "Cafe\u0301 cre\u0300me", 1},
+		{"tabs", "a\tb\tc", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			d := &wrapDisplay{w: &out, stopResize: func() {}}
+			d.width.Store(12)
+			d.Write(tt.text)
+			d.Close()
+			if d.rows != tt.rows {
+				t.Errorf("rows = %d, want %d:\n%s", d.rows, tt.rows, out.String())
+			}
+		})
+	}
+}
+
+func TestWrapDisplayReplaceFallback(t *testing.T) {
+	var out strings.Builder
+	d := &wrapDisplay{w: &out, stopResize: func() {}}
+	d.width.Store(80)
+	d.Write("Add caching")
+	d.Close()
+	d.Replace("Add response caching")
+	if got := out.String(); strings.Contains(got, "A\r\033[J") {
+		t.Errorf("Replace moved the cursor on a writer of unknown height:\n%q", got)
+	}
+	if !strings.Contains(out.String(), "-- "+tr("final_message")) {
+		t.Errorf("Replace printed no separator:\n%q", out.String())
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// doctorCheck is a single line of the doctor report. The report is meant to
+// be attached to bug reports, so it is deliberately not localized.
+type doctorCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Critical bool   `json:"critical"`
+	Detail   string `json:"detail"`
+}
+
+// maskKey hides all but the edges of an API key.
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:3] + "..." + key[len(key)-4:]
+}
+
+func gitOutput(args ...string) (string, error) {
+	output, err := exec.Command("git", args...).Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+func runDoctor(f flags, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print the report as JSON")
+	_ = fs.Parse(args)
+
+	var checks []doctorCheck
+	add := func(name string, ok, critical bool, format string, args ...any) {
+		checks = append(checks, doctorCheck{
+			Name:     name,
+			OK:       ok,
+			Critical: critical,
+			Detail:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	add("version", true, false, "fastcommit %s (%s, %s/%s)", Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		add("git", false, true, "git not found in $PATH")
+	} else {
+		v, err := gitOutput("--version")
+		if err != nil {
+			add("git", false, true, "%s: %v", gitPath, err)
+		} else {
+			add("git", true, true, "%s (%s)", v, gitPath)
+		}
+	}
+
+	if root, err := gitOutput("rev-parse", "--show-toplevel"); err != nil {
+		add("repository", false, true, "not inside a git repository")
+	} else {
+		add("repository", true, true, "%s", root)
+	}
+
+	var gitEnv []string
+	for _, k := range []string{"GIT_DIR", "GIT_WORK_TREE", "GIT_INDEX_FILE", "GIT_OBJECT_DIRECTORY", "GIT_ALTERNATE_OBJECT_DIRECTORIES"} {
+		if v, ok := os.LookupEnv(k); ok {
+			gitEnv = append(gitEnv, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	if len(gitEnv) > 0 {
+		// Not a problem, but it changes what is described and committed.
+		add("git env", true, false, "%s", strings.Join(gitEnv, " "))
+	}
+
+	name, _ := gitOutput("config", "user.name")
+	email, _ := gitOutput("config", "user.email")
+	add("identity", name != "" && email != "", true, "%s <%s>", name, email)
+
+	cp, err := configPath()
+	if err != nil {
+		add("config", false, false, "%v", err)
+	} else if cfg, _, err := readConfig(cp, true); err != nil {
+		add("config", false, false, "%v", err)
+	} else {
+		var files []string
+		if _, err := os.Stat(cp); err == nil {
+			files = append(files, cp)
+		}
+		if root, err := gitOutput("rev-parse", "--show-toplevel"); err == nil {
+			if i, ok := cfg.directorySettings(root); ok {
+				files = append(files, fmt.Sprintf("[[directories]] %q", cfg.Directories[i].Path))
+			}
+		}
+		if rp, ok := findRepoConfig(); ok {
+			files = append(files, rp)
+		}
+		if len(files) == 0 {
+			files = []string{"none, using the defaults"}
+		}
+		add("config", true, false, "%s", strings.Join(files, ", "))
+	}
+
+	if err != nil {
+		add("key storage", false, false, "%v", err)
+	} else if cfg, _, _ := readConfig(cp, true); f.provider == "anthropic" && cfg.AnthropicAPIKey != "" {
+		add("key storage", true, false, "anthropic_api_key in %s", cp)
+	} else if f.provider == "azure" && cfg.AzureAPIKey != "" {
+		add("key storage", true, false, "azure_api_key in %s", cp)
+	} else if f.provider != "anthropic" && f.provider != "azure" && cfg.APIKey != "" {
+		add("key storage", true, false, "api_key in %s", cp)
+	} else {
+		add("key storage", true, false, "no key in %s", cp)
+	}
+
+	keySource := "--openai-key"
+	switch {
+	case f.openAIKey == "":
+		keySource = "none"
+	case f.openAIKey == os.Getenv("OPENAI_API_KEY"):
+		keySource = "$OPENAI_API_KEY"
+	case !flagPassed("openai-key"):
+		keySource = "config.toml"
+	}
+	endpoint := apiEndpoint(f)
+	switch f.provider {
+	case "ollama":
+		add("settings", true, true, "provider=ollama model=%s url=%s", f.model, endpoint)
+	case "anthropic":
+		keySource = "--anthropic-key"
+		switch {
+		case f.anthropicKey == "":
+			keySource = "none"
+		case f.anthropicKey == os.Getenv("ANTHROPIC_API_KEY"):
+			keySource = "$ANTHROPIC_API_KEY"
+		case !flagPassed("anthropic-key"):
+			keySource = "config.toml"
+		}
+		add("settings", f.anthropicKey != "", true, "provider=anthropic model=%s url=%s key=%s (from %s)",
+			f.model, endpoint, maskKey(f.anthropicKey), keySource)
+	case "azure":
+		keySource = "--azure-key"
+		switch {
+		case f.azureKey == "":
+			keySource = "none"
+		case f.azureKey == os.Getenv("AZURE_OPENAI_API_KEY"):
+			keySource = "$AZURE_OPENAI_API_KEY"
+		case !flagPassed("azure-key"):
+			keySource = "config.toml"
+		}
+		deployment := f.azureDeployment
+		if deployment == "" {
+			deployment = f.model
+		}
+		add("settings", f.azureKey != "", true, "provider=azure deployment=%s api-version=%s url=%s key=%s (from %s)",
+			deployment, f.azureAPIVersion, endpoint, maskKey(f.azureKey), keySource)
+	default:
+		add("settings", f.openAIKey != "", true, "model=%s base_url=%s key=%s (from %s)",
+			f.model, f.openAIBaseURL, maskKey(f.openAIKey), keySource)
+	}
+
+	if canGenerate(f) {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		start := time.Now()
+		models, err := newProvider(f).ListModels(ctx)
+		latency := time.Since(start).Round(time.Millisecond)
+		cancel()
+		if err != nil {
+			add("api", false, true, "%s: %v", endpoint, err)
+		} else {
+			add("api", true, true, "%s reachable in %s", endpoint, latency)
+			// Ollama lists models with their tag; "latest" is implied.
+			if slices.Contains(models, f.model) || slices.Contains(models, f.model+":latest") {
+				add("model", true, false, "%s is available", f.model)
+			} else {
+				add("model", false, false, "%s is not listed by the API", f.model)
+			}
+		}
+	}
+
+	width := "n/a"
+	if isTerminal(os.Stdout) {
+		width = fmt.Sprint(terminalWidth(os.Stdout))
+	}
+	add("terminal", true, false, "stdin tty=%t stdout tty=%t width=%s TERM=%q NO_COLOR=%t",
+		isTerminal(os.Stdin), isTerminal(os.Stdout), width, os.Getenv("TERM"), os.Getenv("NO_COLOR") != "")
+
+	failed := slices.ContainsFunc(checks, func(c doctorCheck) bool {
+		return c.Critical && !c.OK
+	})
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			OK     bool          `json:"ok"`
+			Checks []doctorCheck `json:"checks"`
+		}{!failed, checks}); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range checks {
+			mark := "\033[32m✓\033[0m"
+			if !c.OK {
+				mark = "\033[31m✗\033[0m"
+				if !c.Critical {
+					mark = "\033[33m✗\033[0m"
+				}
+			}
+			fmt.Printf("%s %-12s %s\n", mark, c.Name, c.Detail)
+		}
+	}
+
+	if failed {
+		return errors.New("doctor: critical checks failed")
+	}
+	return nil
+}
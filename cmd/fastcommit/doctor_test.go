@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// doctorChecks runs fastcommit doctor --json and returns its checks by name.
+func doctorChecks(t *testing.T, f flags) map[string]doctorCheck {
+	t.Helper()
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	old := os.Stdout
+	os.Stdout = out
+	// Without a key the settings check fails, which only sets the status.
+	_ = runDoctor(f, []string{"--json"})
+	os.Stdout = old
+
+	b, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct{ Checks []doctorCheck }
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatalf("doctor --json printed %q: %v", b, err)
+	}
+	checks := make(map[string]doctorCheck)
+	for _, c := range report.Checks {
+		checks[c.Name] = c
+	}
+	return checks
+}
+
+func TestDoctorConfigFiles(t *testing.T) {
+	dir := newRepo(t)
+	cp := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "fastcommit", "config.toml")
+
+	if c := doctorChecks(t, testFlags())["config"]; !c.OK || c.Detail != "none, using the defaults" {
+		t.Errorf("config check without config files = %+v", c)
+	}
+
+	writeFile(t, filepath.Dir(cp), "config.toml", fmt.Sprintf("model = \"gpt-4o\"\n\n[[directories]]\npath = %q\nmodel = \"gpt-4o-mini\"\n", dir))
+	writeFile(t, dir, ".fastcommit.toml", "max_tokens = 5000\n")
+	c := doctorChecks(t, testFlags())["config"]
+	for _, want := range []string{cp, "[[directories]]", filepath.Join(dir, ".fastcommit.toml")} {
+		if !strings.Contains(c.Detail, want) {
+			t.Errorf("config check lacks %q: %+v", want, c)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"unicode"
+	"unicode/utf8"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// generateMessage streams a completion for msgs to disp and returns the
+// cleaned commit message. If the stream drops part way through, the rest of
+// the message is requested as a continuation of what already arrived. Other
+// transient failures are retried from scratch, as backoff decides.
+func generateMessage(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	msgs []openai.ChatCompletionMessage,
+	disp display,
+) (string, error) {
+	var text string
+	var err error
+	for attempt := 0; ; attempt++ {
+		text, err = streamCompletion(ctx, client, f, msgs, disp)
+		if err != nil && text != "" && isNetworkError(err) {
+			debugf("stream dropped after %d bytes: %v", len(text), err)
+			text, err = continueCompletion(ctx, client, f, msgs, text, disp)
+		}
+		if err == nil || !backoff(ctx, f, err, attempt) {
+			break
+		}
+		if text != "" {
+			// The partial message shown is abandoned; the final one
+			// replaces it on display once complete.
+			disp.Write("\n")
+		}
+	}
+	disp.Close()
+	if err != nil {
+		return "", explainAPIError(f, err)
+	}
+	msg := sanitizers(f).Apply(text)
+	if msg == "" {
+		return "", errors.New(tr("empty_response", apiEndpoint(f), f.model))
+	}
+	return msg, nil
+}
+
+// sanitizers returns the cleanup applied to generated messages. With --body,
+// the body's bullets are made plain and it is wrapped at the body width.
+func sanitizers(f flags) fastcommit.Sanitizers {
+	s := fastcommit.DefaultSanitizers()
+	if f.body {
+		s = append(s, fastcommit.NormalizeWhitespace, fastcommit.PlainBullets, fastcommit.WrapBody(bodyWidth(f)))
+	}
+	return s
+}
+
+// completeMessage returns the preset message of p, displayed as a generated
+// one would be, or generates a message for it and filters it for profanity.
+func completeMessage(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	cfg config,
+	p prompt,
+	disp display,
+) (string, error) {
+	if p.preset != "" {
+		disp.Write(p.preset)
+		disp.Close()
+		return p.preset, nil
+	}
+	var msg string
+	var err error
+	if f.candidates > 1 {
+		msg, err = generateCandidates(ctx, client, f, p.msgs, disp)
+	} else {
+		msg, err = generateMessage(ctx, client, f, p.msgs, disp)
+	}
+	if err != nil {
+		return "", err
+	}
+	if msg, err = checkBodySections(ctx, client, f, p.msgs, msg); err != nil {
+		return "", err
+	}
+	if msg, err = checkConventional(ctx, client, f, p, msg); err != nil {
+		return "", err
+	}
+	if msg, err = lintMessage(ctx, client, f, cfg, p.msgs, msg); err != nil {
+		return "", err
+	}
+	if msg, err = moderateMessage(ctx, client, f, cfg, p.msgs, msg); err != nil {
+		return "", err
+	}
+	return labelImpact(ctx, client, f, p, msg)
+}
+
+// continueCompletion completes partial, the text received before the stream
+// dropped. It falls back to regenerating from scratch when the continuation
+// does not fit onto the partial text.
+func continueCompletion(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	msgs []openai.ChatCompletionMessage,
+	partial string,
+	disp display,
+) (string, error) {
+	cont := append(msgs[:len(msgs):len(msgs)],
+		openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: partial,
+		},
+		openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleUser,
+			Content: "Your response was cut off. Continue it exactly where it stopped. " +
+				"Output only the remaining text, without repeating anything.",
+		},
+	)
+	// The continuation is buffered rather than displayed so that any overlap
+	// with the partial text is not shown twice.
+	rest, err := streamCompletion(ctx, client, f, cont, nil)
+	if err == nil {
+		if joined, ok := splice(partial, rest); ok {
+			debugf("used a continuation after the stream dropped")
+			disp.Write(joined[len(partial):])
+			return joined, nil
+		}
+		debugf("continuation does not fit the partial message, regenerating")
+	} else {
+		debugf("continuation failed, regenerating: %v", err)
+	}
+
+	disp.Write("\n")
+	return streamCompletion(ctx, client, f, msgs, disp)
+}
+
+// streamCompletion streams a completion for msgs, writing deltas to disp if it
+// is not nil. On error, the text received so far is returned with it.
+func streamCompletion(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	msgs []openai.ChatCompletionMessage,
+	disp display,
+) (_ string, err error) {
+	if err := f.privacy.check(msgs); err != nil {
+		return "", err
+	}
+	sp := traceRun.startSpan("completion", map[string]any{"model": f.model, "provider": f.provider, "stream": true})
+	defer func() {
+		traceRun.endSpan(sp, err)
+		if err == nil {
+			f.annotations.observeSpan(sp)
+		}
+	}()
+	if err := f.pacer.wait(ctx, fastcommit.CountTokens(msgs...)); err != nil {
+		return "", err
+	}
+	ctx = fastcommit.WithOpenRouterUsage(ctx, f.annotations.observeOpenRouter)
+	// Until the first delta arrives, the spinner shows the request is alive.
+	var spin *spinner
+	if disp != nil {
+		spin = startSpinner(f.model)
+	}
+	defer spin.Stop()
+	stream, err := client.StreamCompletion(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model:       f.model,
+			Stream:      true,
+			Temperature: 0,
+			StreamOptions: &openai.StreamOptions{
+				IncludeUsage: true,
+			},
+			Messages: msgs,
+		})
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	f.pacer.observe(stream.GetRateLimitHeaders())
+
+	var msg strings.Builder
+	// last is the last chunk received, shown with -v when there is no
+	// content to help tell what the server sent instead.
+	var last *openai.ChatCompletionStreamResponse
+	// finished records whether the stream ended properly. go-openai reports
+	// a connection closed mid-stream as a plain io.EOF.
+	finished := false
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				debugf("stream EOF")
+				if !finished && msg.Len() > 0 {
+					return msg.String(), io.ErrUnexpectedEOF
+				}
+				break
+			}
+			return msg.String(), err
+		}
+		last = &resp
+		f.annotations.observeStream(resp)
+		if resp.Usage != nil {
+			debugf("total tokens: %d", resp.Usage.TotalTokens)
+			f.annotations.observeUsage(*resp.Usage)
+			break
+		}
+		if len(resp.Choices) == 0 {
+			// E.g. the prompt filter results Azure sends first.
+			continue
+		}
+		if resp.Choices[0].FinishReason != "" {
+			finished = true
+		}
+		c := resp.Choices[0].Delta.Content
+		msg.WriteString(c)
+		if disp != nil && c != "" {
+			spin.Stop()
+			disp.Write(c)
+		}
+	}
+	if strings.TrimSpace(msg.String()) == "" {
+		if last != nil {
+			raw, _ := json.Marshal(last)
+			verbosef("last chunk: %s", raw)
+		}
+		return "", errors.New(tr("empty_response", apiEndpoint(f), f.model))
+	}
+	return msg.String(), nil
+}
+
+// isNetworkError reports whether err is a connection failure rather than an
+// error returned by the API.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// maxSpliceOverlap bounds how much repeated text is looked for when splicing
+// a continuation onto partial text.
+const maxSpliceOverlap = 200
+
+// splice joins a continuation onto the partial text it continues, dropping
+// any text the continuation repeats. ok is false when there is no overlap and
+// the joint falls in the middle of a word, which suggests the model did not
+// continue where the text stopped.
+func splice(partial, cont string) (joined string, ok bool) {
+	trimmed := strings.TrimLeftFunc(cont, unicode.IsSpace)
+	for n := min(len(trimmed), len(partial), maxSpliceOverlap); n > 0; n-- {
+		if !strings.HasSuffix(partial, trimmed[:n]) {
+			continue
+		}
+		// Only accept overlaps that start on a word boundary of the
+		// partial text, so that a single shared letter does not count.
+		start := len(partial) - n
+		if start == 0 || !isWordByteBefore(partial, start) {
+			return partial + trimmed[n:], true
+		}
+	}
+
+	last, _ := utf8.DecodeLastRuneInString(partial)
+	first, _ := utf8.DecodeRuneInString(cont)
+	if isWordRune(last) && isWordRune(first) {
+		return "", false
+	}
+	return partial + cont, true
+}
+
+func isWordByteBefore(s string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return isWordRune(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// replayProvider streams the same recorded chunks, in the JSON the API
+// sends, for every request.
+type replayProvider struct {
+	t      *testing.T
+	chunks []string
+}
+
+func (p replayProvider) StreamCompletion(context.Context, openai.ChatCompletionRequest) (fastcommit.CompletionStream, error) {
+	s := &replayStream{}
+	for _, c := range p.chunks {
+		var resp openai.ChatCompletionStreamResponse
+		if err := json.Unmarshal([]byte(c), &resp); err != nil {
+			p.t.Fatalf("bad chunk %s: %v", c, err)
+		}
+		s.chunks = append(s.chunks, resp)
+	}
+	return s, nil
+}
+
+func (replayProvider) CreateCompletion(context.Context, openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	return openai.ChatCompletionResponse{}, errors.New("not recorded")
+}
+
+func (replayProvider) ListModels(context.Context) ([]string, error) {
+	return nil, errors.New("not recorded")
+}
+
+type replayStream struct {
+	chunks []openai.ChatCompletionStreamResponse
+}
+
+func (s *replayStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if len(s.chunks) == 0 {
+		return openai.ChatCompletionStreamResponse{}, io.EOF
+	}
+	resp := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return resp, nil
+}
+
+func (s *replayStream) Close() error { return nil }
+
+func (s *replayStream) GetRateLimitHeaders() openai.RateLimitHeaders {
+	return openai.RateLimitHeaders{}
+}
+
+// streamReplay runs streamCompletion against chunks.
+func streamReplay(t *testing.T, f flags, chunks ...string) (string, error) {
+	t.Helper()
+	msgs := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "diff"}}
+	return streamCompletion(context.Background(), replayProvider{t, chunks}, f, msgs, nil)
+}
+
+// captureStderr returns what fn writes to stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	old := os.Stderr
+	os.Stderr = f
+	defer func() { os.Stderr = old }()
+	fn()
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+const emptyChoicesChunk = `{"id":"proxy-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[]}`
+
+func TestStreamEmptyChoices(t *testing.T) {
+	msg, err := streamReplay(t, testFlags(),
+		emptyChoicesChunk,
+		`{"id":"c","choices":[{"index":0,"delta":{"content":"Add "}}]}`,
+		emptyChoicesChunk,
+		`{"id":"c","choices":[{"index":0,"delta":{"content":"retries"},"finish_reason":"stop"}]}`,
+		emptyChoicesChunk,
+	)
+	if err != nil || msg != "Add retries" {
+		t.Errorf("streamCompletion = %q, %v; want the content around the empty chunks", msg, err)
+	}
+}
+
+func TestStreamOnlyEmptyChoices(t *testing.T) {
+	old := verbose
+	verbose = true
+	defer func() { verbose = old }()
+
+	f := testFlags()
+	f.model = "gpt-4o"
+	f.openAIBaseURL = "https://proxy.example.com/v1"
+	var msg string
+	var err error
+	stderr := captureStderr(t, func() {
+		msg, err = streamReplay(t, f, emptyChoicesChunk, emptyChoicesChunk)
+	})
+	if msg != "" || err == nil || err.Error() != tr("empty_response", f.openAIBaseURL, f.model) {
+		t.Errorf("streamCompletion = %q, %v; want the empty response error", msg, err)
+	}
+	if !strings.Contains(stderr, "last chunk: ") || !strings.Contains(stderr, `"id":"proxy-1"`) {
+		t.Errorf("-v does not show the last chunk:\n%s", stderr)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitHookMarker marks a prepare-commit-msg hook written by "fastcommit hook
+// install", so that uninstall never removes someone else's hook.
+const gitHookMarker = "# Installed by \"fastcommit hook install\"."
+
+// gitHookScript is the prepare-commit-msg hook. It does nothing if
+// fastcommit is not installed, so that committing never breaks.
+const gitHookScript = `#!/bin/sh
+` + gitHookMarker + `
+# Remove it with "fastcommit hook uninstall".
+command -v fastcommit >/dev/null 2>&1 || exit 0
+exec fastcommit hook run "$@"
+`
+
+// runGitHook runs "fastcommit hook install|uninstall|run".
+func runGitHook(f flags, cfg config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(tr("hook_usage"))
+	}
+	switch args[0] {
+	case "install":
+		return installGitHook()
+	case "uninstall":
+		return uninstallGitHook()
+	case "run":
+		if len(args) < 2 || len(args) > 4 {
+			return errors.New(tr("hook_run_usage"))
+		}
+		source := ""
+		if len(args) > 2 {
+			source = args[2]
+		}
+		runPrepareCommitMsg(f, cfg, args[1], source)
+		return nil
+	}
+	return errors.New(tr("unknown_hook_command", args[0]))
+}
+
+// gitHookPath returns where git looks for the prepare-commit-msg hook,
+// following core.hooksPath.
+func gitHookPath() (string, error) {
+	p, err := gitOutput("rev-parse", "--git-path", "hooks/prepare-commit-msg")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(p)
+}
+
+func installGitHook() error {
+	p, err := gitHookPath()
+	if err != nil {
+		return err
+	}
+	if b, err := os.ReadFile(p); err == nil && !strings.Contains(string(b), gitHookMarker) {
+		return errors.New(tr("githook_exists", p))
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p, []byte(gitHookScript), 0o755); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(p, 0o755); err != nil {
+		return err
+	}
+	infof("%s\n", tr("githook_installed", p))
+	return nil
+}
+
+func uninstallGitHook() error {
+	p, err := gitHookPath()
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		infof("%s\n", tr("githook_not_installed", p))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(b), gitHookMarker) {
+		return errors.New(tr("githook_not_ours", p))
+	}
+	if err := os.Remove(p); err != nil {
+		return err
+	}
+	infof("%s\n", tr("githook_uninstalled", p))
+	return nil
+}
+
+// runPrepareCommitMsg writes a generated message into file, the message file
+// of a commit being made with plain git commit. Commits that come with a
+// message, given with -m, taken from a template, a merge, a squash or the
+// commit being amended, as source tells, are left alone. Failures are only
+// warned about: the file stays as it is, for the message to be written by
+// hand.
+func runPrepareCommitMsg(f flags, cfg config, file, source string) {
+	if source != "" || !canGenerate(f) {
+		debugf("hook: not generating for source %q", source)
+		return
+	}
+	orig, err := os.ReadFile(file)
+	if err != nil {
+		warnf("%s\n", tr("githook_failed", err))
+		return
+	}
+	msg, err := hookMessage(f, cfg)
+	if err != nil {
+		warnf("%s\n", tr("githook_failed", err))
+		return
+	}
+	// Keep what git wrote, its comments and any diff of --verbose, below the
+	// message.
+	if err := os.WriteFile(file, []byte(msg+"\n"+string(orig)), 0o644); err != nil {
+		warnf("%s\n", tr("githook_failed", err))
+	}
+}
+
+// hookMessage generates a message for the staged changes without
+// displaying it; git opens the editor on it next, or commits it as it is.
+func hookMessage(f flags, cfg config) (string, error) {
+	workdir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+	client := newProvider(f)
+	p, err := buildPrompt(ctx, client, f, cfg, workdir, "", nil)
+	if err != nil {
+		return "", err
+	}
+	f.annotations = &annotations{}
+	msg, err := completeMessage(ctx, client, f, cfg, p, &rawDisplay{w: io.Discard})
+	if err != nil {
+		return "", err
+	}
+	f.annotations.report()
+	if f.annotations.Altered {
+		warnf("%s\n", tr("content_filtered", strings.Join(f.annotations.Filters, "; ")))
+	}
+	return finishMessage(f, cfg, p, msg)
+}
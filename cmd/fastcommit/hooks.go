@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// hookTimeout bounds how long a hook may run.
+const hookTimeout = 30 * time.Second
+
+// hooksConfig holds user commands run at fixed points of a run.
+type hooksConfig struct {
+	// PrePrompt is run before the prompt is built. Each line it prints is
+	// added as if passed with --context.
+	PrePrompt string `toml:"pre_prompt_hook"`
+	// PostGenerate receives the generated message on stdin and prints the
+	// message to commit, e.g. after fixing its style. A nonzero exit aborts
+	// the commit.
+	PostGenerate string `toml:"post_generate_hook"`
+}
+
+// runHook runs command with input on stdin and returns its stdout. A nonzero
+// exit is reported with the command's stderr.
+func runHook(name, command, input string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	start := time.Now()
+	err := cmd.Run()
+	verbosef("%s %q took %s", name, command, time.Since(start).Round(time.Millisecond))
+	if ctx.Err() != nil {
+		err = errors.New(tr("hook_timeout", hookTimeout))
+	}
+	if err != nil {
+		return "", errors.New(tr("hook_failed", name, err, strings.TrimSpace(stderr.String())))
+	}
+	return stdout.String(), nil
+}
+
+// prePromptContext runs the pre_prompt_hook, if any, and returns the context
+// lines it printed.
+func prePromptContext(cfg config) ([]string, error) {
+	if cfg.Hooks.PrePrompt == "" {
+		return nil, nil
+	}
+	out, err := runHook("pre_prompt_hook", cfg.Hooks.PrePrompt, "")
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// postGenerate passes msg through the post_generate_hook, if any. When the
+// hook changes the message, the result is shown, since what was streamed is
+// no longer what will be committed.
+func postGenerate(cfg config, msg string) (string, error) {
+	if cfg.Hooks.PostGenerate == "" {
+		return msg, nil
+	}
+	out, err := runHook("post_generate_hook", cfg.Hooks.PostGenerate, msg+"\n")
+	if err != nil {
+		return "", err
+	}
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return "", errors.New(tr("hook_empty", "post_generate_hook"))
+	}
+	if out != msg {
+		// The message shown is replaced with out, so only note why.
+		debugf("post_generate_hook changed the message")
+	}
+	return out, nil
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// uiLang is the language used for user-facing CLI output. It does not affect
+// the language of generated commit messages.
+var uiLang = detectUILang()
+
+// catalog maps a language to its translated format strings. English is the
+// reference and must contain every key.
+var catalog = map[string]map[string]string{
+	"en": {
+		"usage":                      "Usage: %s [options] [ref]",
+		"ref_and_amend":              "cannot use both [ref] and --amend",
+		"no_key":                     "no API key: set $OPENAI_API_KEY, pass --openai-key or run \"fastcommit setup\"",
+		"empty_key":                  "key is empty",
+		"saved_key":                  "Saved the API key to %s",
+		"run_to_commit":              "Run the following command to commit:",
+		"files_changed":              "%d files changed",
+		"file_changed":               "%d file changed",
+		"amends":                     "amends %s",
+		"unknown_ui_lang":            "unknown UI language %q, using English",
+		"repo_changed":               "the repository changed while the message was being generated",
+		"index_changed":              "staged changes differ:",
+		"stale_message":              "refusing to commit a message that no longer matches the staged changes",
+		"regenerate_question":        "Regenerate the message?",
+		"large_commit":               "this commit is unusually large: %d files, %d changed lines. Largest changes:",
+		"large_commit_blocked":       "refusing to describe an unusually large commit, pass --force-large to proceed",
+		"large_commit_question":      "Generate a message for it anyway?",
+		"aborted":                    "aborted, nothing was committed",
+		"preview_conflict":           "--preview cannot be combined with [ref] or --amend",
+		"untracked_needs_preview":    "--include-untracked requires --preview",
+		"untracked_included":         "The preview includes these untracked files; remember to git add them:",
+		"untracked_excluded":         "These untracked files are not part of the preview (see --include-untracked):",
+		"unknown_ref":                "unknown revision %q",
+		"ref_is_head_question":       "%s is the current commit. Amend it with the generated message?",
+		"ref_preview_head":           "%s is the current commit; showing a preview only. Use --amend to replace its message.",
+		"ref_preview_history":        "%s is an older commit; showing a preview only. Rewording older commits is not supported, use git rebase -i to apply it.",
+		"ref_preview_other":          "%s is not on the current branch; showing a preview only.",
+		"amend_pushed":               "%s is already on %s; amending it will require a force push",
+		"amend_pushed_blocked":       "refusing to amend a pushed commit, pass --allow-pushed-amend to proceed",
+		"amend_pushed_question":      "Amend it anyway?",
+		"deep_progress":              "Summarizing component %[1]d/%[2]d: %[3]s",
+		"hook_failed":                "%s failed: %v\n%s",
+		"hook_empty":                 "%s printed an empty message",
+		"all_conflict":               "--all cannot be combined with [ref], --amend or --preview",
+		"split_groups":               "These changes fall into %d separate areas:",
+		"split_question":             "Create %d separate commits, one per area?",
+		"split_commit":               "Commit %d/%d: %s",
+		"run_to_commit_split":        "Run the following commands, in order, to commit:",
+		"shallow_history":            "this shallow clone does not contain the parent commit needed; run \"git fetch --deepen=1\" and try again",
+		"unknown_range":              "invalid commit range %q",
+		"empty_range":                "%s contains no commits",
+		"range_preview":              "Proposed message for squashing the %d commits in %s; nothing will be committed.",
+		"learning_cleared":           "Forgot the edited messages recorded for this repository.",
+		"final_message":              "Final message:",
+		"shim_failed":                "could not generate a message, opening the editor instead: %v",
+		"profanity_blocked":          "the generated message contains unprofessional language (%s); pass --no-profanity-filter to keep it",
+		"sections_missing":           "the message still lacks these body sections: %s",
+		"pick_candidate":             "Use which message? [1-%d, default 1]",
+		"wip_deferred":               "No message arrived within %s, so the commit has a placeholder. Run `fastcommit reword HEAD` to replace it.",
+		"reword_not_head":            "%s is not the current commit. Only HEAD can be reworded; for older commits, use git rebase -i with --editor-shim.",
+		"no_pending":                 "No commits are awaiting a better message.",
+		"pending_header":             "Commits awaiting a better message (fastcommit reword HEAD):",
+		"pending_missing":            "(no longer exists)",
+		"pending_off_branch":         "(not on the current branch)",
+		"no_commit_section":          "%s has no section about commits; ignoring it.",
+		"conventions_conflict":       "%s conflicts with the flags, which take precedence: %s.",
+		"conflict_conventional":      "it forbids Conventional Commits types, but --type-from-paths strict adds them",
+		"conflict_length":            "it asks for %d characters, but --%s is %d",
+		"content_filtered":           "The provider's content filter altered the response (%s), so the message may not be what the model wrote.",
+		"content_filtered_blocked":   "refusing to commit a filtered message without a terminal to confirm it",
+		"content_filtered_question":  "Commit it anyway?",
+		"lenient_config_hint":        "Fix or remove these keys, or pass --lenient-config to ignore them.",
+		"output_closed":              "output was closed before committing; nothing was committed",
+		"privacy_leak":               "refusing to send the prompt: %s contains a string listed in the privacy config",
+		"review_question":            "[a]ccept, [r]egenerate, [e]dit or [q]uit?",
+		"empty_message":              "empty message, nothing was committed",
+		"edit_message_help":          "# Lines starting with '#' are ignored. An empty message aborts the commit.",
+		"unlanded_saved":             "The message was saved; run fastcommit --use-saved to commit it once the problem is fixed.",
+		"unlanded_amend":             "the commit of the previous run (%s, \"%s\") never landed, so --amend would change an unrelated commit",
+		"unlanded_blocked":           "pass --use-saved to commit the saved message instead, or --discard-saved to amend anyway",
+		"unlanded_question":          "Create a new commit with the saved message instead?",
+		"no_unlanded":                "no saved message; it is only kept when a commit fails",
+		"unlanded_changed":           "HEAD or the staged changes differ from when the saved message was generated",
+		"conventional_invalid":       "the regenerated message still breaks the Conventional Commits rules (%s), not committing it:\n%s",
+		"conflict_conventional_flag": "it forbids Conventional Commits types, but --conventional requires them",
+		"nothing_staged":             "nothing staged to commit; stage changes with git add, or pass --all to commit every change to tracked files",
+		"repo_config_denied":         "%s: ignoring %s, which only your own config.toml may set",
+		"key_migrated":               "Moved the saved key from %s into %s",
+		"githook_exists":             "%s already exists and was not installed by fastcommit; remove it or call \"fastcommit hook run\" from it",
+		"githook_installed":          "Installed the prepare-commit-msg hook at %s",
+		"githook_not_installed":      "No prepare-commit-msg hook at %s",
+		"githook_not_ours":           "%s was not installed by fastcommit; leaving it alone",
+		"githook_uninstalled":        "Removed the prepare-commit-msg hook at %s",
+		"githook_failed":             "could not generate a commit message, write one yourself: %v",
+		"empty_response":             "%s returned no message content for model %s",
+		"diff_packed":                "The diff exceeds the token budget: %d files included verbatim, %d summarized (budget %d; see --max-tokens)",
+		"since_conflict":             "--since cannot be combined with [ref], --amend, --preview or --all",
+		"unknown_since":              "%s has no commit in common with HEAD, or is not a valid ref",
+		"since_preview":              "Proposed message for squash-merging the %d commits since %s and any staged changes; nothing will be committed.",
+		"run_to_commit_one":          "Run one of the following commands to commit:",
+		"retrying":                   "%s, retrying in %s...",
+		"retry_rate_limited":         "rate limited",
+		"retry_server_error":         "server error (%d)",
+		"retry_connection":           "connection lost",
+		"key_rejected":               "the API key was rejected by %s; check $OPENAI_API_KEY or --openai-key, or save a new one with --save-key",
+		"context_too_long":           "the prompt is longer than %s accepts; lower --max-tokens, or leave files out with --exclude",
+		"rewrite_range_invalid":      "--range needs a range such as origin/main..HEAD, not %q",
+		"rewrite_not_head":           "%s is not the current HEAD; check out the branch to rewrite first",
+		"rewrite_dirty":              "the working tree has uncommitted changes; commit or stash them before rewriting history",
+		"rewrite_merges":             "%s contains merge commits, which --range cannot rewrite",
+		"rewrite_pushed":             "%s is already on the upstream %s; refusing to rewrite published history",
+		"rewrite_question":           "Use the new message? [Y]es, [n]o (keep the old one) or [q]uit",
+		"rewrite_dry":                "Messages that would be rewritten:",
+		"rewrite_nothing":            "No messages to rewrite.",
+		"rewrite_rebase_failed":      "rewriting the messages failed; run git rebase --abort to restore the branch",
+		"rewrite_done":               "Rewrote %d of %d commit messages.",
+		"rewrite_conflict":           "--range cannot be combined with [ref], --amend, --all, --preview or --since",
+		"setup_not_interactive":      "fastcommit setup asks questions and needs a terminal",
+		"setup_intro":                "No API key or config.toml yet. Let's set up fastcommit; press Enter to skip any question.",
+		"setup_provider":             "Which backend should write your commit messages?",
+		"setup_other":                "Another OpenAI-compatible API",
+		"setup_choice":               "Choose 1-%d:",
+		"setup_base_url":             "Base URL, e.g. %s:",
+		"setup_key":                  "API key (input is hidden):",
+		"setup_model":                "Default model:",
+		"setup_model_prompt":         "Number or model name:",
+		"setup_conventional":         "Require Conventional Commits subjects such as \"feat(api): add login\"? [y/n]",
+		"setup_nothing":              "Nothing was saved; run \"fastcommit setup\" to start over.",
+		"setup_saved":                "Saved to %s. Change it later with \"fastcommit config set\" or \"fastcommit setup\".",
+		"no_anthropic_key":           "no Anthropic API key: set $ANTHROPIC_API_KEY, pass --anthropic-key or run \"fastcommit setup\"",
+		"anthropic_key_rejected":     "the API key was rejected by %s; check $ANTHROPIC_API_KEY or --anthropic-key, or save a new one with --save-key",
+		"commit_to_conflict":         "--commit-to cannot be combined with [ref], --amend, --preview or --all",
+		"commit_to_not_worktree":     "%s is not a git worktree",
+		"commit_to_other_repo":       "%s is a worktree of another repository",
+		"commit_to_same":             "%s is the current worktree; commit without --commit-to",
+		"commit_to_mismatch":         "refusing to commit in %s: its staged changes differ from the ones the message describes: %s",
+		"waiting_for_model":          "waiting for %s… %ds",
+		"lint_warning":               "the message breaks the lint rules:\n  - %s",
+		"lint_failed":                "the message breaks the lint rules, not committing it:\n  - %s\n\n%s",
+		"crashed":                    "fastcommit crashed: %v\nA report was written to %s; please attach it when filing a bug.",
+		"no_azure_key":               "no Azure OpenAI API key: set $AZURE_OPENAI_API_KEY, pass --azure-key or run \"fastcommit setup\"",
+		"azure_key_rejected":         "the API key was rejected by %s; check $AZURE_OPENAI_API_KEY or --azure-key, or save a new one with --save-key",
+		"secrets_redacted":           "likely secrets were redacted from the prompt; check that they are not committed by mistake:\n  - %s",
+		"secrets_blocked":            "refusing to send the prompt, which holds likely secrets (--block-secrets):\n  - %s",
+		"privacy_too_short":          "not redacting %q: strings shorter than %d characters would match parts of other words",
+		"config_usage":               "usage: fastcommit config validate|schema|list|which|get <key>|set <key> <value>...",
+		"config_get_usage":           "usage: fastcommit config get <key>",
+		"config_set_usage":           "usage: fastcommit config set <key> <value>...",
+		"config_set":                 "%s: set %s",
+		"config_unknown_command":     "unknown config command %q",
+		"config_which_user":          "user config: %s",
+		"config_which_user_none":     "user config: %s (none)",
+		"config_which_no_repo":       "directories: not in a repository",
+		"config_which_nothing":       "nothing",
+		"config_which_match":         "directories: entry %d (path = %q) matches %s, setting %s",
+		"config_which_no_entries":    "directories: no [[directories]] entries",
+		"config_which_no_match":      "directories: none of %d entries matches %s",
+		"config_which_repo":          "repository config: %s",
+		"config_which_repo_none":     "repository config: none",
+		"config_validate_none":       "%s: no config file, using defaults",
+		"config_validate_normalized": "%s, normalized",
+		"config_unknown_keys":        "%s: %d unknown keys",
+		"config_unknown_setting":     "unknown setting %q",
+		"config_set_table":           "%[1]s is a table; set one of its keys, e.g. %[1]s.<key>",
+		"config_set_edit_only":       "%s can only be changed by editing config.toml",
+		"config_set_single":          "%s takes a single value",
+		"config_set_unsupported":     "%s cannot be set on the command line",
+		"config_entries":             "(%d entries)",
+		"config_set_would_break":     "editing %s would break it (%v %v); edit it by hand",
+		"cache_usage":                "usage: fastcommit cache clear",
+		"cache_cleared":              "%s: cleared",
+		"flags_conflict":             "%s and %s cannot be used together",
+		"invalid_flag":               "invalid %s %q",
+		"output_json_conflict":       "--output json cannot be used with --range, --editor-shim or reword",
+		"key_file_invalid":           "%s does not hold a key and is ignored; remove it, or save the key again with --save-key",
+		"key_superseded":             "Ignored the key saved in %s, since %s has one; the old file was renamed",
+		"guidance_too_long":          "the guidance alone, such as COMMITS.md, conventions and recent commits, exceeds --max-tokens %d; raise it or shorten the guidance",
+		"gh_missing":                 "--gh needs the GitHub CLI, gh, which is not installed",
+		"azure_endpoint_missing":     "--provider azure needs the resource's endpoint in --openai-base-url or $AZURE_OPENAI_ENDPOINT",
+		"invalid_provider":           "invalid provider %q",
+		"invalid_history":            "invalid history count %d",
+		"prefix_conventional":        "--prefix cannot be used with conventional.enabled in config.toml",
+		"invalid_trailer":            "invalid --trailer %q, want key=value",
+		"invalid_body_section":       "invalid --body-sections entry %q",
+		"invalid_lint_policy":        "invalid lint policy %q, want retry, fail, warn or off",
+		"invalid_profanity_policy":   "invalid profanity policy %q, want mask, rephrase or block",
+		"hook_timeout":               "timed out after %s",
+		"replay_usage":               "usage: fastcommit replay [--model model] <bundle>",
+		"bundle_not_full":            "the bundle was written without --bundle-full, so the diff is missing from the prompt",
+		"hook_usage":                 "usage: fastcommit hook install|uninstall|run <msg-file> [<source> [<commit>]]",
+		"hook_run_usage":             "usage: fastcommit hook run <msg-file> [<source> [<commit>]]",
+		"unknown_hook_command":       "unknown hook command %q",
+		"shim_usage":                 "usage: fastcommit --editor-shim [--then-edit] <file>",
+		"tokens_usage":               "usage: fastcommit tokens [--model m] [--json] [path|ref|range|-]",
+		"worktree_changed":           "changes to tracked files differ:",
+	},
+	"es": {
+		"usage":                      "Uso: %s [opciones] [ref]",
+		"ref_and_amend":              "no se puede usar [ref] junto con --amend",
+		"no_key":                     "no hay clave de API: define $OPENAI_API_KEY, usa --openai-key o ejecuta \"fastcommit setup\"",
+		"empty_key":                  "la clave está vacía",
+		"saved_key":                  "Clave de API guardada en %s",
+		"run_to_commit":              "Ejecuta el siguiente comando para hacer el commit:",
+		"files_changed":              "%d archivos modificados",
+		"file_changed":               "%d archivo modificado",
+		"amends":                     "corrige %s",
+		"unknown_ui_lang":            "idioma de interfaz %q desconocido, se usará inglés",
+		"repo_changed":               "el repositorio cambió mientras se generaba el mensaje",
+		"index_changed":              "los cambios preparados son distintos:",
+		"stale_message":              "no se hará commit de un mensaje que ya no coincide con los cambios preparados",
+		"regenerate_question":        "¿Generar el mensaje de nuevo?",
+		"aborted":                    "cancelado, no se hizo ningún commit",
+		"large_commit":               "este commit es inusualmente grande: %d archivos, %d líneas modificadas. Cambios más grandes:",
+		"large_commit_blocked":       "no se describirá un commit inusualmente grande, usa --force-large para continuar",
+		"large_commit_question":      "¿Generar un mensaje de todos modos?",
+		"preview_conflict":           "--preview no se puede combinar con [ref] ni con --amend",
+		"untracked_needs_preview":    "--include-untracked requiere --preview",
+		"untracked_included":         "La vista previa incluye estos archivos sin seguimiento; recuerda hacer git add:",
+		"untracked_excluded":         "Estos archivos sin seguimiento no forman parte de la vista previa (ver --include-untracked):",
+		"unknown_ref":                "revisión desconocida %q",
+		"ref_is_head_question":       "%s es el commit actual. ¿Corregirlo con el mensaje generado?",
+		"ref_preview_head":           "%s es el commit actual; solo se muestra una vista previa. Usa --amend para reemplazar su mensaje.",
+		"ref_preview_history":        "%s es un commit anterior; solo se muestra una vista previa. No se admite reescribir commits anteriores, usa git rebase -i para aplicarlo.",
+		"ref_preview_other":          "%s no está en la rama actual; solo se muestra una vista previa.",
+		"amend_pushed":               "%s ya está en %s; corregirlo requerirá un force push",
+		"amend_pushed_blocked":       "no se corregirá un commit ya publicado, usa --allow-pushed-amend para continuar",
+		"amend_pushed_question":      "¿Corregirlo de todos modos?",
+		"deep_progress":              "Resumiendo componente %[1]d/%[2]d: %[3]s",
+		"hook_failed":                "%s falló: %v\n%s",
+		"hook_empty":                 "%s imprimió un mensaje vacío",
+		"all_conflict":               "--all no se puede combinar con [ref], --amend ni --preview",
+		"split_groups":               "Estos cambios se reparten en %d áreas independientes:",
+		"split_question":             "¿Crear %d commits independientes, uno por área?",
+		"split_commit":               "Commit %d/%d: %s",
+		"run_to_commit_split":        "Ejecuta los siguientes comandos, en orden, para hacer los commits:",
+		"shallow_history":            "este clon superficial no contiene el commit padre necesario; ejecuta \"git fetch --deepen=1\" e inténtalo de nuevo",
+		"unknown_range":              "rango de commits %q no válido",
+		"empty_range":                "%s no contiene ningún commit",
+		"range_preview":              "Mensaje propuesto para combinar los %d commits de %s; no se hará ningún commit.",
+		"learning_cleared":           "Se olvidaron los mensajes editados registrados para este repositorio.",
+		"final_message":              "Mensaje final:",
+		"shim_failed":                "no se pudo generar un mensaje, se abre el editor: %v",
+		"profanity_blocked":          "el mensaje generado contiene lenguaje poco profesional (%s); usa --no-profanity-filter para mantenerlo",
+		"sections_missing":           "al mensaje todavía le faltan estas secciones del cuerpo: %s",
+		"pick_candidate":             "¿Qué mensaje usar? [1-%d, por defecto 1]",
+		"wip_deferred":               "No llegó ningún mensaje en %s, así que el commit tiene uno provisional. Ejecuta `fastcommit reword HEAD` para reemplazarlo.",
+		"reword_not_head":            "%s no es el commit actual. Solo se puede reformular HEAD; para commits anteriores, usa git rebase -i con --editor-shim.",
+		"no_pending":                 "Ningún commit espera un mensaje mejor.",
+		"pending_header":             "Commits que esperan un mensaje mejor (fastcommit reword HEAD):",
+		"pending_missing":            "(ya no existe)",
+		"pending_off_branch":         "(no está en la rama actual)",
+		"no_commit_section":          "%s no tiene ninguna sección sobre commits; se ignora.",
+		"conventions_conflict":       "%s contradice las opciones, que tienen prioridad: %s.",
+		"conflict_conventional":      "prohíbe los tipos de Conventional Commits, pero --type-from-paths strict los añade",
+		"conflict_length":            "pide %d caracteres, pero --%s es %d",
+		"content_filtered":           "El filtro de contenido del proveedor alteró la respuesta (%s), así que el mensaje puede no ser el que escribió el modelo.",
+		"content_filtered_blocked":   "no se hace commit de un mensaje filtrado sin una terminal para confirmarlo",
+		"content_filtered_question":  "¿Hacer el commit de todos modos?",
+		"lenient_config_hint":        "Corrige o elimina estas claves, o usa --lenient-config para ignorarlas.",
+		"output_closed":              "la salida se cerró antes de confirmar; no se creó ningún commit",
+		"privacy_leak":               "no se envía el prompt: %s contiene una cadena de la configuración de privacidad",
+		"review_question":            "¿[a]ceptar, [r]egenerar, [e]ditar o [q] salir?",
+		"empty_message":              "mensaje vacío, no se hizo ningún commit",
+		"edit_message_help":          "# Las líneas que empiezan por '#' se ignoran. Un mensaje vacío cancela el commit.",
+		"unlanded_saved":             "El mensaje se guardó; ejecuta fastcommit --use-saved para hacer el commit cuando se resuelva el problema.",
+		"unlanded_amend":             "el commit de la ejecución anterior (%s, \"%s\") no llegó a crearse, así que --amend cambiaría un commit no relacionado",
+		"unlanded_blocked":           "usa --use-saved para hacer commit del mensaje guardado, o --discard-saved para modificar el commit de todos modos",
+		"unlanded_question":          "¿Crear un commit nuevo con el mensaje guardado en su lugar?",
+		"no_unlanded":                "no hay mensaje guardado; solo se guarda cuando falla un commit",
+		"unlanded_changed":           "HEAD o los cambios preparados no son los mismos que cuando se generó el mensaje guardado",
+		"conventional_invalid":       "el mensaje regenerado todavía incumple las reglas de Conventional Commits (%s), no se confirma:\n%s",
+		"conflict_conventional_flag": "prohíbe los tipos de Conventional Commits, pero --conventional los exige",
+		"nothing_staged":             "no hay nada preparado para confirmar; prepara cambios con git add, o usa --all para confirmar todos los cambios en archivos versionados",
+		"repo_config_denied":         "%s: se ignora %s, que solo tu propio config.toml puede definir",
+		"key_migrated":               "Se movió la clave guardada de %s a %s",
+		"githook_exists":             "%s ya existe y no lo instaló fastcommit; elimínalo o llama a \"fastcommit hook run\" desde él",
+		"githook_installed":          "Se instaló el hook prepare-commit-msg en %s",
+		"githook_not_installed":      "No hay hook prepare-commit-msg en %s",
+		"githook_not_ours":           "%s no lo instaló fastcommit; no se toca",
+		"githook_uninstalled":        "Se eliminó el hook prepare-commit-msg en %s",
+		"githook_failed":             "no se pudo generar un mensaje de commit, escríbelo tú: %v",
+		"empty_response":             "%s no devolvió contenido para el modelo %s",
+		"diff_packed":                "El diff supera el presupuesto de tokens: %d archivos incluidos completos, %d resumidos (presupuesto %d; ver --max-tokens)",
+		"since_conflict":             "--since no se puede combinar con [ref], --amend, --preview ni --all",
+		"unknown_since":              "%s no tiene ningún commit en común con HEAD o no es una referencia válida",
+		"since_preview":              "Mensaje propuesto para fusionar los %d commits desde %s y los cambios preparados; no se hará ningún commit.",
+		"run_to_commit_one":          "Ejecuta uno de los siguientes comandos para hacer el commit:",
+		"retrying":                   "%s, reintentando en %s...",
+		"retry_rate_limited":         "límite de peticiones alcanzado",
+		"retry_server_error":         "error del servidor (%d)",
+		"retry_connection":           "conexión perdida",
+		"key_rejected":               "%s rechazó la clave de API; revisa $OPENAI_API_KEY o --openai-key, o guarda una nueva con --save-key",
+		"context_too_long":           "el prompt es más largo de lo que admite %s; reduce --max-tokens o excluye archivos con --exclude",
+		"rewrite_range_invalid":      "--range necesita un rango como origin/main..HEAD, no %q",
+		"rewrite_not_head":           "%s no es el HEAD actual; cambia primero a la rama que quieres reescribir",
+		"rewrite_dirty":              "hay cambios sin confirmar; haz commit o guárdalos con stash antes de reescribir el historial",
+		"rewrite_merges":             "%s contiene commits de merge, que --range no puede reescribir",
+		"rewrite_pushed":             "%s ya está en %s; no se reescribe historial publicado",
+		"rewrite_question":           "¿Usar el mensaje nuevo? [S]í ([y]), [n]o (conservar el anterior) o [q] salir",
+		"rewrite_dry":                "Mensajes que se reescribirían:",
+		"rewrite_nothing":            "No hay mensajes que reescribir.",
+		"rewrite_rebase_failed":      "no se pudieron reescribir los mensajes; ejecuta git rebase --abort para restaurar la rama",
+		"rewrite_done":               "Se reescribieron %d de %d mensajes de commit.",
+		"rewrite_conflict":           "--range no se puede combinar con [ref], --amend, --all, --preview ni --since",
+		"setup_not_interactive":      "fastcommit setup hace preguntas y necesita una terminal",
+		"setup_intro":                "Aún no hay clave de API ni config.toml. Configuremos fastcommit; pulsa Enter para omitir cualquier pregunta.",
+		"setup_provider":             "¿Qué backend debe escribir tus mensajes de commit?",
+		"setup_other":                "Otra API compatible con OpenAI",
+		"setup_choice":               "Elige 1-%d:",
+		"setup_base_url":             "URL base, p. ej. %s:",
+		"setup_key":                  "Clave de API (no se muestra al escribir):",
+		"setup_model":                "Modelo por defecto:",
+		"setup_model_prompt":         "Número o nombre del modelo:",
+		"setup_conventional":         "¿Exigir asuntos de Conventional Commits como \"feat(api): add login\"? [y/n]",
+		"setup_nothing":              "No se guardó nada; ejecuta \"fastcommit setup\" para empezar de nuevo.",
+		"setup_saved":                "Guardado en %s. Cámbialo más tarde con \"fastcommit config set\" o \"fastcommit setup\".",
+		"no_anthropic_key":           "no hay clave de API de Anthropic: define $ANTHROPIC_API_KEY, usa --anthropic-key o ejecuta \"fastcommit setup\"",
+		"anthropic_key_rejected":     "%s rechazó la clave de API; revisa $ANTHROPIC_API_KEY o --anthropic-key, o guarda una nueva con --save-key",
+		"commit_to_conflict":         "--commit-to no se puede combinar con [ref], --amend, --preview ni --all",
+		"commit_to_not_worktree":     "%s no es un worktree de git",
+		"commit_to_other_repo":       "%s es un worktree de otro repositorio",
+		"commit_to_same":             "%s es el worktree actual; haz el commit sin --commit-to",
+		"commit_to_mismatch":         "no se hace el commit en %s: sus cambios preparados difieren de los que describe el mensaje: %s",
+		"waiting_for_model":          "esperando a %s… %ds",
+		"lint_warning":               "el mensaje incumple las reglas de estilo:\n  - %s",
+		"lint_failed":                "el mensaje incumple las reglas de estilo, no se confirma:\n  - %s\n\n%s",
+		"crashed":                    "fastcommit falló: %v\nSe escribió un informe en %s; adjúntalo al informar del error.",
+		"no_azure_key":               "no hay clave de API de Azure OpenAI: define $AZURE_OPENAI_API_KEY, usa --azure-key o ejecuta \"fastcommit setup\"",
+		"azure_key_rejected":         "%s rechazó la clave de API; revisa $AZURE_OPENAI_API_KEY o --azure-key, o guarda una nueva con --save-key",
+		"secrets_redacted":           "se ocultaron probables secretos del prompt; comprueba que no se confirman por error:\n  - %s",
+		"secrets_blocked":            "no se envía el prompt, que contiene probables secretos (--block-secrets):\n  - %s",
+		"privacy_too_short":          "no se oculta %q: las cadenas de menos de %d caracteres coincidirían con partes de otras palabras",
+		"config_usage":               "uso: fastcommit config validate|schema|list|which|get <clave>|set <clave> <valor>...",
+		"config_get_usage":           "uso: fastcommit config get <clave>",
+		"config_set_usage":           "uso: fastcommit config set <clave> <valor>...",
+		"config_set":                 "%s: %s establecido",
+		"config_unknown_command":     "comando de config desconocido %q",
+		"config_which_user":          "configuración de usuario: %s",
+		"config_which_user_none":     "configuración de usuario: %s (no existe)",
+		"config_which_no_repo":       "directories: fuera de un repositorio",
+		"config_which_nothing":       "nada",
+		"config_which_match":         "directories: la entrada %d (path = %q) coincide con %s y establece %s",
+		"config_which_no_entries":    "directories: no hay entradas [[directories]]",
+		"config_which_no_match":      "directories: ninguna de las %d entradas coincide con %s",
+		"config_which_repo":          "configuración del repositorio: %s",
+		"config_which_repo_none":     "configuración del repositorio: ninguna",
+		"config_validate_none":       "%s: no hay archivo de configuración, se usan los valores predeterminados",
+		"config_validate_normalized": "%s, normalizado",
+		"config_unknown_keys":        "%s: %d claves desconocidas",
+		"config_unknown_setting":     "ajuste desconocido %q",
+		"config_set_table":           "%[1]s es una tabla; establece una de sus claves, p. ej. %[1]s.<clave>",
+		"config_set_edit_only":       "%s solo se puede cambiar editando config.toml",
+		"config_set_single":          "%s admite un solo valor",
+		"config_set_unsupported":     "%s no se puede establecer desde la línea de comandos",
+		"config_entries":             "(%d entradas)",
+		"config_set_would_break":     "editar %s lo rompería (%v %v); edítalo a mano",
+		"cache_usage":                "uso: fastcommit cache clear",
+		"cache_cleared":              "%s: vaciada",
+		"flags_conflict":             "%s y %s no se pueden usar juntas",
+		"invalid_flag":               "%s no válido: %q",
+		"output_json_conflict":       "--output json no se puede usar con --range, --editor-shim ni reword",
+		"key_file_invalid":           "%s no contiene una clave y se ignora; elimínalo o vuelve a guardar la clave con --save-key",
+		"key_superseded":             "Se ignoró la clave guardada en %s, ya que %s tiene una; se renombró el archivo antiguo",
+		"guidance_too_long":          "las indicaciones por sí solas, como COMMITS.md, las convenciones y los commits recientes, superan --max-tokens %d; auméntalo o acorta las indicaciones",
+		"gh_missing":                 "--gh necesita la CLI de GitHub, gh, que no está instalada",
+		"azure_endpoint_missing":     "--provider azure necesita el endpoint del recurso en --openai-base-url o $AZURE_OPENAI_ENDPOINT",
+		"invalid_provider":           "proveedor %q no válido",
+		"invalid_history":            "número de commits del historial %d no válido",
+		"prefix_conventional":        "--prefix no se puede usar con conventional.enabled en config.toml",
+		"invalid_trailer":            "--trailer %q no válido, se espera clave=valor",
+		"invalid_body_section":       "entrada de --body-sections %q no válida",
+		"invalid_lint_policy":        "política de lint %q no válida, se espera retry, fail, warn u off",
+		"invalid_profanity_policy":   "política de lenguaje ofensivo %q no válida, se espera mask, rephrase o block",
+		"hook_timeout":               "se agotó el tiempo tras %s",
+		"replay_usage":               "uso: fastcommit replay [--model modelo] <paquete>",
+		"bundle_not_full":            "el paquete se escribió sin --bundle-full, así que falta el diff en el prompt",
+		"hook_usage":                 "uso: fastcommit hook install|uninstall|run <archivo-mensaje> [<origen> [<commit>]]",
+		"hook_run_usage":             "uso: fastcommit hook run <archivo-mensaje> [<origen> [<commit>]]",
+		"unknown_hook_command":       "comando de hook %q desconocido",
+		"shim_usage":                 "uso: fastcommit --editor-shim [--then-edit] <archivo>",
+		"tokens_usage":               "uso: fastcommit tokens [--model m] [--json] [ruta|ref|rango|-]",
+		"worktree_changed":           "los cambios en archivos con seguimiento son distintos:",
+	},
+}
+
+// detectUILang picks the UI language from the standard locale environment
+// variables, falling back to English.
+func detectUILang() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		if lang := normalizeLang(v); lang != "" {
+			if _, ok := catalog[lang]; ok {
+				return lang
+			}
+		}
+		// The first set variable wins, as in POSIX locale resolution.
+		break
+	}
+	return "en"
+}
+
+// normalizeLang turns a locale such as "es_ES.UTF-8" into "es".
+func normalizeLang(locale string) string {
+	locale = strings.ToLower(locale)
+	if i := strings.IndexAny(locale, "_.@-"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "c" || locale == "posix" {
+		return "en"
+	}
+	return locale
+}
+
+// tr returns the localized string for key formatted with args. Keys missing
+// from the active language fall back to English.
+func tr(key string, args ...any) string {
+	format, ok := catalog[uiLang][key]
+	if !ok {
+		format, ok = catalog["en"][key]
+		if !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+)
+
+// labelImpact classifies the change of msg with --impact-label and adds a
+// Changelog trailer to a user-facing one, for changelog tooling to pick up.
+func labelImpact(ctx context.Context, client fastcommit.Provider, f flags, p prompt, msg string) (string, error) {
+	if !f.impactLabel {
+		return msg, nil
+	}
+	ctx = fastcommit.WithOpenRouterUsage(ctx, f.annotations.observeOpenRouter)
+	impact, err := fastcommit.ClassifyImpact(ctx, client, f.model, p.msgs, msg)
+	if err != nil {
+		return "", fmt.Errorf("classify impact: %w", err)
+	}
+	if f.annotations != nil {
+		f.annotations.Impact = &impact
+	}
+	if !impact.UserFacing {
+		return msg, nil
+	}
+	return appendTrailer(msg, "Changelog: "+impact.Changelog), nil
+}
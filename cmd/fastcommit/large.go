@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+)
+
+// largeCommitConfig configures the warning for unusually large commits.
+type largeCommitConfig struct {
+	// MaxFiles is the number of changed files above which a commit is large.
+	MaxFiles int `toml:"max_files"`
+	// MaxLines is the number of changed lines above which a commit is large.
+	MaxLines int `toml:"max_lines"`
+	// Action is "warn" to only print a warning, or "block" to require
+	// confirmation (or --force-large) before generating.
+	Action string `toml:"action"`
+}
+
+var defaultLargeCommitConfig = largeCommitConfig{
+	MaxFiles: 100,
+	MaxLines: 5000,
+	Action:   "block",
+}
+
+// inCI reports whether fastcommit runs in a CI environment, where it must
+// never wait for confirmation.
+func inCI() bool {
+	return os.Getenv("CI") != ""
+}
+
+// checkLargeCommit warns when the changes to describe exceed the configured
+// thresholds, and depending on the configured action asks for confirmation
+// before any tokens are spent on them.
+func checkLargeCommit(f flags, cfg config, workdir, hash string) error {
+	lc := cfg.LargeCommit
+	if lc.MaxFiles == 0 {
+		lc.MaxFiles = defaultLargeCommitConfig.MaxFiles
+	}
+	if lc.MaxLines == 0 {
+		lc.MaxLines = defaultLargeCommitConfig.MaxLines
+	}
+	if lc.Action == "" {
+		lc.Action = defaultLargeCommitConfig.Action
+	}
+
+	stats, err := fastcommit.DiffStats(workdir, promptOptions(f, hash))
+	if err != nil {
+		return fmt.Errorf("diff stats: %w", err)
+	}
+	lines := 0
+	for _, s := range stats {
+		lines += s.Lines()
+	}
+	if len(stats) <= lc.MaxFiles && lines <= lc.MaxLines {
+		return nil
+	}
+
+	warnf("%s\n", tr("large_commit", len(stats), lines))
+	slices.SortFunc(stats, func(a, b fastcommit.FileStat) int {
+		return b.Lines() - a.Lines()
+	})
+	for _, s := range stats[:min(5, len(stats))] {
+		if s.Binary {
+			fmt.Fprintf(os.Stderr, "  %6s        %s\n", "binary", fastcommit.QuotePath(s.Path))
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  %6d lines  %s\n", s.Lines(), fastcommit.QuotePath(s.Path))
+	}
+
+	if lc.Action == "warn" || f.forceLarge || inCI() {
+		return nil
+	}
+	if !interactive() {
+		return errors.New(tr("large_commit_blocked"))
+	}
+	if !confirm(tr("large_commit_question")) {
+		return errors.New(tr("aborted"))
+	}
+	return nil
+}
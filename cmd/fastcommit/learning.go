@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"time"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// learningFile holds the edited messages of a repository. It is shared
+	// by all its worktrees, since style is a property of the repository.
+	learningFile = "learning.json"
+	// minEditExamples is how many edited messages must be recorded before
+	// they are used, so that a single odd edit does not steer every prompt.
+	minEditExamples = 2
+	// maxEditsKept is how many edited messages are kept.
+	maxEditsKept = 20
+	// maxEditExamples is how many edited messages a prompt includes.
+	maxEditExamples = 2
+	// editExamplesMaxTokens caps the edited messages in a prompt.
+	editExamplesMaxTokens = 1000
+)
+
+// learningState records how the user edited generated messages. Only
+// messages are stored, never diffs.
+type learningState struct {
+	Edits []editedMessage `json:"edits"`
+}
+
+type editedMessage struct {
+	Generated string    `json:"generated"`
+	Edited    string    `json:"edited"`
+	Time      time.Time `json:"time"`
+}
+
+// learnFromEdit records the committed message at HEAD if the user changed
+// generated in the editor before committing.
+func learnFromEdit(f flags, generated string) {
+	if !f.edit || f.noLearning {
+		return
+	}
+	output, err := exec.Command("git", "show", "-s", "--format=%B", "HEAD").Output()
+	if err != nil {
+		debugf("learning: %v", err)
+		return
+	}
+	recordEdit(f, generated, string(output))
+}
+
+// recordEdit records edited as the user's edit of generated, unless it is the
+// same message or learning is off.
+func recordEdit(f flags, generated, edited string) {
+	if f.noLearning {
+		return
+	}
+	edited = strings.TrimSpace(edited)
+	if edited == strings.TrimSpace(generated) {
+		return
+	}
+	var s learningState
+	err := updateStateFile(repoScope, learningFile, &s, func() {
+		s.Edits = append(s.Edits, editedMessage{
+			Generated: generated,
+			Edited:    edited,
+			Time:      time.Now(),
+		})
+		if len(s.Edits) > maxEditsKept {
+			s.Edits = s.Edits[len(s.Edits)-maxEditsKept:]
+		}
+	})
+	if err != nil {
+		debugf("learning: %v", err)
+		return
+	}
+	verbosef("recorded your edit of the generated message as a style example")
+}
+
+// editExamples returns the most recent edits to include in the prompt, within
+// the example token budget.
+func editExamples(f flags) []fastcommit.StyleExample {
+	if f.noLearning || f.minimal {
+		return nil
+	}
+	var s learningState
+	if err := readStateFile(repoScope, learningFile, &s); err != nil {
+		debugf("learning: %v", err)
+		return nil
+	}
+	if len(s.Edits) < minEditExamples {
+		return nil
+	}
+	var examples []fastcommit.StyleExample
+	tokens := 0
+	for i := len(s.Edits) - 1; i >= 0 && len(examples) < maxEditExamples; i-- {
+		e := s.Edits[i]
+		n := fastcommit.CountTokens(
+			openai.ChatCompletionMessage{Content: e.Generated},
+			openai.ChatCompletionMessage{Content: e.Edited},
+		)
+		if tokens+n > editExamplesMaxTokens {
+			break
+		}
+		tokens += n
+		examples = append(examples, fastcommit.StyleExample{Generated: e.Generated, Edited: e.Edited})
+	}
+	return examples
+}
+
+// clearLearning forgets all recorded edits of the current repository.
+func clearLearning() error {
+	var s learningState
+	return updateStateFile(repoScope, learningFile, &s, func() {
+		s.Edits = nil
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// lintRetries is how often a message breaking the lint rules is regenerated
+// before giving up on it.
+const lintRetries = 2
+
+type lintConfig struct {
+	// Policy is what happens to a message breaking the rules: "retry" (the
+	// default) regenerates it with the violations pointed out, "fail"
+	// refuses it as --lint-only does, "warn" only warns, and "off" skips
+	// the check.
+	Policy string `toml:"policy"`
+	// The other settings are those of fastcommit.LintRules.
+	Disable          []string `toml:"disable"`
+	SubjectCase      string   `toml:"subject_case"`
+	MaxSubjectLength int      `toml:"max_subject_length"`
+	BodyWidth        int      `toml:"body_width"`
+	UselessSubjects  []string `toml:"useless_subjects"`
+}
+
+// validate reports settings that do not exist.
+func (c lintConfig) validate() error {
+	switch c.Policy {
+	case "", "retry", "fail", "warn", "off":
+	default:
+		return errors.New(tr("invalid_lint_policy", c.Policy))
+	}
+	return c.rules(flags{}).Validate()
+}
+
+// rules returns the lint rules of the config. The body is held to the
+// width it is wrapped at unless the config sets one.
+func (c lintConfig) rules(f flags) fastcommit.LintRules {
+	r := fastcommit.LintRules{
+		Disable:          c.Disable,
+		SubjectCase:      c.SubjectCase,
+		MaxSubjectLength: c.MaxSubjectLength,
+		BodyWidth:        c.BodyWidth,
+		UselessSubjects:  c.UselessSubjects,
+	}
+	if r.BodyWidth == 0 {
+		r.BodyWidth = f.bodyWidth
+	}
+	return r
+}
+
+// lintMessage holds msg to the lint rules of the config. Depending on the
+// policy, a message breaking them is regenerated from msgs with the
+// violations pointed out, refused, or kept with a warning.
+func lintMessage(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	cfg config,
+	msgs []openai.ChatCompletionMessage,
+	msg string,
+) (string, error) {
+	policy := cfg.Lint.Policy
+	if f.lintOnly {
+		policy = "fail"
+	}
+	if policy == "off" {
+		return msg, nil
+	}
+	rules := cfg.Lint.rules(f)
+	found := fastcommit.Lint(msg, rules)
+	for attempt := 0; len(found) > 0 && (policy == "" || policy == "retry") && attempt < lintRetries; attempt++ {
+		verbosef("message breaks lint rules %q, regenerating", lintList(found, "; "))
+		retry := append(msgs[:len(msgs):len(msgs)],
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: msg},
+			openai.ChatCompletionMessage{
+				Role: openai.ChatMessageRoleSystem,
+				Content: "The message breaks the repository's rules for commit messages: " +
+					lintList(found, "; ") + ". Write the whole message again, fixing these.",
+			},
+		)
+		// Not streamed; the display is updated with the final message
+		// afterwards.
+		again, err := generateMessage(ctx, client, f, retry, &rawDisplay{w: io.Discard})
+		if err != nil {
+			return "", err
+		}
+		msg, found = again, fastcommit.Lint(again, rules)
+	}
+	if len(found) == 0 {
+		return msg, nil
+	}
+	if policy == "warn" {
+		warnf("%s\n", tr("lint_warning", lintList(found, "\n  - ")))
+		return msg, nil
+	}
+	return "", errors.New(tr("lint_failed", lintList(found, "\n  - "), msg))
+}
+
+func lintList(found []fastcommit.LintViolation, sep string) string {
+	s := make([]string, len(found))
+	for i, v := range found {
+		s[i] = v.String()
+	}
+	return strings.Join(s, sep)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// lockTimeout is how long to wait for another fastcommit process to
+	// release a state file before giving up.
+	lockTimeout = 5 * time.Second
+	// lockPollInterval is how often a busy lock is retried.
+	lockPollInterval = 25 * time.Millisecond
+)
+
+// errLockBusy is returned by tryLock when another process holds a
+// conflicting lock.
+var errLockBusy = errors.New("lock is held by another process")
+
+// lockFile locks path+".lock", creating it if needed, and returns a function
+// that releases the lock. Exclusive locks are for writers; any number of
+// shared locks can be held at once.
+//
+// The lock is an advisory OS lock rather than the existence of the file, so
+// the kernel drops it when a process exits or crashes and a leftover .lock
+// file never blocks a later run.
+func lockFile(path string, exclusive bool) (unlock func(), err error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := tryLock(f, exclusive)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLockBusy) {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", f.Name(), err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: timed out after %s waiting for another fastcommit process", f.Name(), lockTimeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+	return func() {
+		if err := unlockFile(f); err != nil {
+			debugf("unlock %s: %v", f.Name(), err)
+		}
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || windows)
+
+package main
+
+import "os"
+
+// Platforms without flock or LockFileEx fall back to no locking; concurrent
+// runs may then lose each other's state updates, which is harmless.
+
+func tryLock(*os.File, bool) error { return nil }
+
+func unlockFile(*os.File) error { return nil }
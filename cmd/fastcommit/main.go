@@ -1,16 +1,19 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"al.essio.dev/pkg/shellescape"
 	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
@@ -20,12 +23,145 @@ import (
 // Command line flags
 type flags struct {
 	openAIKey     string
+	anthropicKey  string
 	openAIBaseURL string
+	provider      string
+	ollamaURL     string
 	model         string
 	saveKey       bool
 	dryRun        bool
 	amend         bool
 	context       arrayFlags
+	contextRefs   arrayFlags
+	issues        arrayFlags
+	trailerValues arrayFlags
+	signoff       bool
+	exclude       arrayFlags
+	noExclude     bool
+	uiLang        string
+	plain         bool
+	describe      string
+	allowEmpty    bool
+	prefix        string
+	suffix        string
+	maxLineLength int
+	redactPaths   bool
+	blockSecrets  bool
+	noSecretScan  bool
+	forceLarge    bool
+	preview       bool
+	// azureKey, azureDeployment and azureAPIVersion configure --provider
+	// azure, which talks to the Azure OpenAI resource at openAIBaseURL.
+	azureKey        string
+	azureDeployment string
+	azureAPIVersion string
+	// includeUntracked adds untracked files to the --preview diff.
+	includeUntracked bool
+	typeFromPaths    string
+	capture          string
+	captureLines     int
+	allowPushedAmend bool
+	noStatusContext  bool
+	closingKeyword   string
+	deep             bool
+	deepModel        string
+	bundleReport     string
+	bundleFull       bool
+	subjectLength    int
+	bodyWidth        int
+	all              bool
+	// paths limits a commit to these files, relative to the repository
+	// root. It is set for each commit of a split --all.
+	paths []string
+	// shallow is set when the repository is a shallow clone.
+	shallow bool
+	// rangeSpec is set when the positional argument is a range of commits.
+	rangeSpec         string
+	since             string
+	rewriteRange      string
+	edit              bool
+	noLearning        bool
+	automationPresets string
+	// minimal sends only the diff, skipping every optional git invocation.
+	minimal bool
+	// digestOnly sends a digest of the diff without any of its content.
+	digestOnly   bool
+	editorShim   bool
+	conventional bool
+	linkFiles    bool
+	impactLabel  bool
+	// scope is the Conventional Commits scope pinned with --scope.
+	scope             string
+	thenEdit          bool
+	noProfanityFilter bool
+	bodySectionsFlag  string
+	body              bool
+	candidates        int
+	autoPick          bool
+	conventionsFrom   string
+	codeowners        string
+	lenientConfig     bool
+	yes               bool
+	useSaved          bool
+	discardSaved      bool
+	autoConventions   bool
+	output            string
+	// bodySections are the labeled sections required in the body. nil
+	// means no requirement, an empty list a subject line only.
+	bodySections []bodySection
+	// pacer spaces out the requests of batch modes. It is nil, never
+	// waiting, when a run makes a single request.
+	pacer *pacer
+	// dryCandidates receives all candidates with --dry and --candidates.
+	dryCandidates *candidateSet
+	// annotations collects the provider's annotations of the responses
+	// for the message being generated.
+	annotations *annotations
+	// result collects what --output json prints. It is nil otherwise.
+	result *jsonResult
+	// privacy scrubs the strings listed in the privacy config from prompts
+	// and refuses requests that still contain them. nil when none are.
+	privacy *privacyGuard
+	// openRouter are the OpenRouter options from the config, used when the
+	// base URL is OpenRouter's.
+	openRouter fastcommit.OpenRouterOptions
+	// maxTokens is the token budget of the prompt, from --max-tokens or the
+	// config. Zero means defaultMaxTokens.
+	maxTokens int
+	// maxRetries is how often a request failing with a transient error is
+	// retried.
+	maxRetries int
+	// trailers are added to every message, from --issue, --trailer and
+	// --signoff.
+	trailers []string
+	// commitTo is the top-level directory of the worktree to commit in,
+	// from --commit-to.
+	commitTo string
+	// noCache builds the prompt even if one was cached for the same
+	// changes, from --no-cache.
+	noCache bool
+	// maxDiffBytes bounds the diff read from git, from memory_limit_mb in
+	// the config. Zero means the library's default.
+	maxDiffBytes int
+	// bodyMinLines is the change size from which --body applies, from the
+	// config.
+	bodyMinLines int
+	// history is the most recent commits shown as examples, from --history
+	// or the config; zero means the default. noHistory is set by
+	// --history 0.
+	history   int
+	noHistory bool
+	// historyConfig filters the examples.
+	historyConfig historyConfig
+	// conventionalTypes are the Conventional Commits types allowed with
+	// --conventional; nil means the defaults.
+	conventionalTypes []string
+	// narrative is how many subjects of the commits before the change are
+	// listed for the message to follow on from, from --narrative.
+	narrative int
+	// lintOnly refuses messages breaking the lint rules instead of
+	// regenerating them, from --lint-only.
+	lintOnly bool
 }
 
 // Custom type to handle multiple --context flags
@@ -42,17 +178,76 @@ func (i *arrayFlags) Set(value string) error {
 
 var debugMode = os.Getenv("FASTCOMMIT_DEBUG") != ""
 
+// verbose enables progress notes that are less noisy than debug output.
+var verbose bool
+
+// verbosef and debugf tag their lines with the IDs of traceRun.
+func verbosef(format string, args ...any) {
+	if !verbose && !debugMode {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\033[90m[%s] "+format+"\n\033[0m", append([]any{traceRun.tag()}, args...)...)
+}
+
 func debugf(format string, args ...any) {
 	if !debugMode {
 		return
 	}
-	fmt.Fprintf(os.Stderr, "\033[90mdebug: "+format+"\n\033[0m", args...)
+	fmt.Fprintf(os.Stderr, "\033[90mdebug: [%s] "+format+"\n\033[0m", append([]any{traceRun.tag()}, args...)...)
 }
 
 func errorf(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, "\033[31merr: "+format+"\033[0m", args...)
 }
 
+func infof(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+func warnf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "\033[33mwarn: "+format+"\033[0m", args...)
+}
+
+// Exit codes other than the generic failure code 1. 2 is left to the flag
+// package for usage errors.
+const (
+	// exitStale means the repository changed while the message was generated.
+	exitStale = 3
+	// exitAPI means the API failed or could not be reached, after any
+	// retries.
+	exitAPI = 4
+	// exitGit means git failed to create the commit.
+	exitGit = 5
+	// exitOutputClosed means stdout was closed, e.g. by a pager, before
+	// anything was committed. It is what a shell reports for SIGPIPE.
+	exitOutputClosed = 141
+	// exitInterrupted means the user pressed Ctrl-C, or fastcommit got
+	// SIGTERM, before anything was committed, as a shell reports for SIGINT.
+	exitInterrupted = 130
+)
+
+// exitError makes the process exit with a specific code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitWith prints err and exits with the code it carries, or 1.
+func exitWith(err error) {
+	terminal.restore()
+	errorf("%v\n", err)
+	traceRun.flush()
+	var ee *exitError
+	if errors.As(err, &ee) {
+		os.Exit(ee.code)
+	}
+	os.Exit(1)
+}
+
 func getLastCommitHash() (string, error) {
 	cmd := exec.Command("git", "rev-parse", "HEAD")
 	output, err := cmd.Output()
@@ -62,204 +257,1318 @@ func getLastCommitHash() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// commitSummary describes a commit after it has been created.
+type commitSummary struct {
+	hash    string
+	subject string
+	files   int
+}
+
+func getCommitSummary(hash string) (commitSummary, error) {
+	cmd := exec.Command("git", "show", "-s", "--format=%s", hash)
+	output, err := cmd.Output()
+	if err != nil {
+		return commitSummary{}, err
+	}
+	cs := commitSummary{hash: hash, subject: strings.TrimSpace(string(output))}
+
+	// --root is needed so that the first commit on a freshly created branch
+	// reports its files instead of nothing.
+	cmd = exec.Command("git", "diff-tree", "--root", "--no-commit-id", "--name-only", "-r", hash)
+	output, err = cmd.Output()
+	if err != nil {
+		return commitSummary{}, err
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) != "" {
+			cs.files++
+		}
+	}
+	return cs, nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// printCommitSummary prints a single confirmation line for the commit at HEAD.
+// replaced is the hash of the commit that was amended, if any.
+func printCommitSummary(replaced string) {
+	hash, err := getLastCommitHash()
+	if err != nil {
+		// The commit itself succeeded, so failing to resolve HEAD should not
+		// turn the run into a failure.
+		debugf("resolve HEAD after commit: %v", err)
+		return
+	}
+	printSummaryOf(hash, replaced)
+}
+
+// printSummaryOf prints the confirmation line for the commit hash.
+func printSummaryOf(hash, replaced string) {
+	cs, err := getCommitSummary(hash)
+	if err != nil {
+		debugf("summarize commit %s: %v", hash, err)
+		return
+	}
+	files := tr("files_changed", cs.files)
+	if cs.files == 1 {
+		files = tr("file_changed", cs.files)
+	}
+	if replaced != "" {
+		fmt.Printf("\033[32m%s\033[0m (%s) %s (%s)\n",
+			shortHash(cs.hash), tr("amends", shortHash(replaced)), cs.subject, files)
+		return
+	}
+	fmt.Printf("\033[32m%s\033[0m %s (%s)\n", shortHash(cs.hash), cs.subject, files)
+}
+
+// resolveRef resolves ref to a commit hash.
 func resolveRef(ref string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", ref)
+	cmd := exec.Command("git", "rev-parse", "--verify", "-q", ref+"^{commit}")
 	output, err := cmd.Output()
 	if err != nil {
-		return "", err
+		return "", errors.New(tr("unknown_ref", ref))
 	}
 	return strings.TrimSpace(string(output)), nil
 }
 
+// rangeCount returns the number of commits in rng, failing for invalid or
+// empty ranges.
+func rangeCount(rng string) (int, error) {
+	output, err := exec.Command("git", "rev-list", "--count", rng, "--").Output()
+	if err != nil {
+		return 0, errors.New(tr("unknown_range", rng))
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(output)))
+	if n == 0 {
+		return 0, errors.New(tr("empty_range", rng))
+	}
+	return n, nil
+}
+
+// isAncestorOfHead reports whether hash is reachable from HEAD.
+func isAncestorOfHead(hash string) bool {
+	return exec.Command("git", "merge-base", "--is-ancestor", hash, "HEAD").Run() == nil
+}
+
+// formatShellCommand returns cmd as a line to paste into a shell. A
+// multi-line message given with -m is passed on stdin with a here-document
+// instead, since quoted newlines do not survive pasting into every shell.
 func formatShellCommand(cmd *exec.Cmd) string {
+	var paragraphs []string
+	args := cmd.Args[1:]
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-m" {
+			paragraphs = append(paragraphs, args[i+1])
+		}
+	}
+	msg := strings.Join(paragraphs, "\n\n")
+	heredoc := strings.Contains(msg, "\n")
+
 	buf := &strings.Builder{}
 	buf.WriteString(filepath.Base(cmd.Path))
-	for _, arg := range cmd.Args[1:] {
+	stdin := false
+	for i := 0; i < len(args); i++ {
+		if heredoc && args[i] == "-m" && i+1 < len(args) {
+			i++
+			if !stdin {
+				buf.WriteString(" -F -")
+				stdin = true
+			}
+			continue
+		}
 		buf.WriteString(" ")
-		buf.WriteString(shellescape.Quote(arg))
+		buf.WriteString(shellescape.Quote(args[i]))
+	}
+	if heredoc {
+		delim := "EOF"
+		for slices.Contains(strings.Split(msg, "\n"), delim) {
+			delim += "_"
+		}
+		fmt.Fprintf(buf, " <<'%s'\n%s\n%s", delim, msg, delim)
 	}
 	return buf.String()
 }
 
-func cleanAIMessage(msg string) string {
-	if strings.HasPrefix(msg, "```") {
-		msg = strings.TrimSuffix(msg, "```")
-		msg = strings.TrimPrefix(msg, "```")
+func newProvider(f flags) fastcommit.Provider {
+	switch f.provider {
+	case "ollama":
+		return fastcommit.NewOllamaProvider(f.ollamaURL)
+	case "anthropic":
+		return fastcommit.NewAnthropicProvider(f.anthropicKey, os.Getenv("ANTHROPIC_BASE_URL"))
+	case "azure":
+		return fastcommit.NewAzureProvider(f.azureKey, f.openAIBaseURL, f.azureDeployment, f.azureAPIVersion)
+	}
+	if fastcommit.IsOpenRouter(f.openAIBaseURL) {
+		return fastcommit.NewOpenRouterProvider(f.openAIKey, f.openAIBaseURL, f.openRouter)
 	}
-	msg = strings.TrimSpace(msg)
-	return msg
+	return fastcommit.NewOpenAIProvider(f.openAIKey, f.openAIBaseURL)
 }
 
-func run(f flags, ref string) error {
-	workdir, err := os.Getwd()
+// apiEndpoint returns the URL messages are generated at.
+func apiEndpoint(f flags) string {
+	switch f.provider {
+	case "ollama":
+		return fastcommit.NewOllamaProvider(f.ollamaURL).BaseURL
+	case "anthropic":
+		return fastcommit.NewAnthropicProvider("", os.Getenv("ANTHROPIC_BASE_URL")).BaseURL
+	}
+	return f.openAIBaseURL
+}
+
+// canGenerate reports whether a message can be generated: local backends need
+// no key.
+func canGenerate(f flags) bool {
+	switch f.provider {
+	case "ollama":
+		return true
+	case "anthropic":
+		return f.anthropicKey != ""
+	case "azure":
+		return f.azureKey != ""
+	}
+	return f.openAIKey != ""
+}
+
+// promptOptions returns the options selecting and shaping the changes to
+// describe, which is the commit identified by hash or the staged changes when
+// hash is empty.
+func promptOptions(f flags, hash string) fastcommit.PromptOptions {
+	return fastcommit.PromptOptions{
+		CommitHash:        hash,
+		Amend:             f.amend,
+		WorkingTree:       f.preview || f.all,
+		IncludeUntracked:  f.includeUntracked,
+		Description:       f.describe,
+		MaxLineLength:     f.maxLineLength,
+		MaxDiffBytes:      f.maxDiffBytes,
+		Paths:             f.paths,
+		SkipHistory:       f.shallow || f.noHistory,
+		History:           f.history,
+		Narrative:         f.narrative,
+		HistoryTokens:     f.historyConfig.MaxTokens,
+		HistoryMinLength:  f.historyConfig.MinLength,
+		HistoryBotPattern: f.historyConfig.BotPattern,
+		Range:             f.rangeSpec,
+		Since:             f.since,
+		SubjectLength:     f.subjectLength,
+		BodyWidth:         f.bodyWidth,
+		Minimal:           f.minimal,
+		DigestOnly:        f.digestOnly,
+		Conventional:      conventionalRules(f),
+		Exclude:           f.exclude,
+		NoExclude:         f.noExclude,
+	}
+}
+
+// debugHistory shows which recent commits the prompt uses as examples.
+func debugHistory(sel fastcommit.HistorySelection) {
+	debugf("history: %d examples of %d commits scanned (%d tokens); skipped %d merges, %d by bots, %d short, %d over the count or token cap",
+		len(sel.Examples), sel.Scanned, sel.Tokens, sel.Merges, sel.Bots, sel.Short, sel.OverBudget)
+	for _, ex := range sel.Examples {
+		same := ""
+		if ex.SameFiles {
+			same = " (same files)"
+		}
+		debugf("  %s %s%s", shortHash(ex.Hash), ex.Subject, same)
+	}
+}
+
+// defaultMaxTokens is the token budget of the prompt unless --max-tokens or
+// max_tokens is given.
+const defaultMaxTokens = 128000
+
+// prompt is a built prompt along with what post-processing of the generated
+// message needs to know about it.
+type prompt struct {
+	msgs []openai.ChatCompletionMessage
+	// redactor is set when paths were redacted from the prompt.
+	redactor *fastcommit.PathRedactor
+	// typeHint is the Conventional Commits type implied by the changed paths.
+	typeHint string
+	// pinType enforces typeHint regardless of --type-from-paths.
+	pinType bool
+	// scope is the Conventional Commits scope taken from CODEOWNERS.
+	scope string
+	// paths are the changed paths, when something needed them.
+	paths []string
+	// preset is the complete message of a recognized automation commit. The
+	// prompt is not built when it is set.
+	preset string
+	// closing holds the issues to close, taken from the user's context.
+	closing []closingRef
+}
+
+// buildPrompt builds the full prompt for the commit identified by hash, or
+// for the staged changes when hash is empty. extra holds additional context
+// messages gathered once per run, such as captured command output.
+func buildPrompt(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	cfg config,
+	workdir string,
+	hash string,
+	extra []openai.ChatCompletionMessage,
+) (prompt, error) {
+	maxTokens := f.maxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	var automation fastcommit.Automation
+	if f.automationPresets != "off" {
+		a, ok, err := fastcommit.DetectAutomation(workdir, promptOptions(f, hash))
+		if err != nil {
+			return prompt{}, fmt.Errorf("detect automation commit: %w", err)
+		}
+		if ok {
+			verbosef("recognized a %s commit", a.Kind)
+			automation = a
+		}
+	}
+	// Anything the user adds must make it into the message, which only the
+	// model can do.
+	related, err := relatedMessages(f.contextRefs)
 	if err != nil {
-		return err
+		return prompt{}, err
+	}
+	userInput := f.describe != "" || len(f.context) > 0 || len(related) > 0 || len(extra) > 0
+	if f.automationPresets == "on" && automation.Message != "" && !userInput {
+		return prompt{preset: automation.Message}, nil
 	}
 
-	if ref != "" && f.amend {
-		return errors.New("cannot use both [ref] and --amend")
+	var owners fastcommit.Codeowners
+	if f.codeowners != "off" && !f.minimal {
+		var err error
+		if owners, err = fastcommit.FindCodeowners(workdir); err != nil {
+			return prompt{}, err
+		}
 	}
 
-	hash := ""
-	if f.amend {
-		lastCommitHash, err := getLastCommitHash()
+	var paths []string
+	if f.redactPaths || f.typeFromPaths != "off" || len(owners) > 0 || f.linkFiles {
+		var err error
+		paths, err = fastcommit.ChangedPaths(workdir, promptOptions(f, hash))
 		if err != nil {
-			return err
+			return prompt{}, fmt.Errorf("list changed paths: %w", err)
+		}
+	}
+	var redactor *fastcommit.PathRedactor
+	if f.redactPaths {
+		redactor = fastcommit.NewPathRedactor(paths)
+	}
+
+	opts := promptOptions(f, hash)
+	opts.MaxTokens = maxTokens
+	if !f.minimal {
+		if opts.Conventions, err = conventions(f); err != nil {
+			return prompt{}, err
+		}
+	}
+	var msgs []openai.ChatCompletionMessage
+	ok := false
+	// The incremental prompt shows the diff added to the amended commit.
+	if f.amend && !f.digestOnly {
+		msgs, ok = incrementalPrompt(hash, opts)
+	}
+	if !ok {
+		var err error
+		opts.StyleExamples = editExamples(f)
+		opts.VendorDirs = cfg.VendorDirs
+		opts.Packed = func(p fastcommit.DiffPacking) {
+			infof("%s\n", tr("diff_packed", len(p.Verbatim), len(p.Summarized), maxTokens))
+			debugf("summarized to fit the token budget: %q", p.Summarized)
+		}
+		opts.HistorySelected = debugHistory
+		if f.deep {
+			opts.Overview, _, err = deepOverview(ctx, client, f, workdir, hash, redactor)
+			if err != nil {
+				return prompt{}, err
+			}
+		}
+		msgs, err = buildCachedPrompt(f, workdir, opts)
+		if errors.Is(err, fastcommit.ErrGuidanceTooLong) {
+			return prompt{}, errors.New(tr("guidance_too_long", maxTokens))
 		}
-		hash = lastCommitHash
-	} else if ref != "" {
-		hash, err = resolveRef(ref)
 		if err != nil {
-			return fmt.Errorf("resolve ref %q: %w", ref, err)
+			return prompt{}, err
 		}
 	}
 
-	msgs, err := fastcommit.BuildPrompt(os.Stdout, workdir, hash, f.amend, 128000)
-	if err != nil {
-		return err
+	// Related messages come after the other context but are kept out of
+	// the closing references below, which are not theirs to close.
+	if context := append(f.context[:len(f.context):len(f.context)], related...); len(context) > 0 {
+		msgs = append(msgs, contextMessages(context)...)
 	}
+	msgs = append(msgs, extra...)
+	msgs = append(msgs, trailerMessages(f)...)
 
-	if len(f.context) > 0 {
+	// The status only says something about the commit being made, not about
+	// an existing one given as ref.
+	if !f.noStatusContext && !f.minimal && f.rangeSpec == "" && (hash == "" || f.amend) {
+		msg, ok, err := statusContext(f, workdir)
+		if err != nil {
+			return prompt{}, err
+		}
+		if ok {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	if f.prefix != "" {
+		msgs = append(msgs, openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleSystem,
+			Content: fmt.Sprintf("The tool prepends %q to the subject line. Do not include it yourself, "+
+				"and make the subject %d characters shorter than usual so the combined line still fits.",
+				f.prefix, utf8.RuneCountInString(f.prefix)),
+		})
+	}
+	if f.bodySections != nil {
+		msgs = append(msgs, bodySectionsMessage(f.bodySections))
+	} else if f.body {
+		msgs = append(msgs, bodyMessage(f))
+	}
+	if f.linkFiles {
 		msgs = append(msgs, openai.ChatCompletionMessage{
 			Role: openai.ChatMessageRoleSystem,
-			Content: "The user has provided additional context that MUST be" +
-				" included in the commit message",
+			Content: "Write the body as bullets, and in each bullet name the files it refers to by their " +
+				"paths as they appear in the diff, e.g. \"- Extract retry logic into retry.go and use it " +
+				"from client.go\". Only name files the diff changes.",
+		})
+	}
+	if f.suffix != "" {
+		msgs = append(msgs, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: fmt.Sprintf("The tool appends %q to the end of the message. Do not include it yourself.", f.suffix),
 		})
-		for _, context := range f.context {
+	}
+	closing := closingRefs(append([]string{f.describe}, f.context...), f.closingKeyword)
+	if len(closing) > 0 {
+		var trailers []string
+		for _, c := range closing {
+			trailers = append(trailers, c.String())
+		}
+		msgs = append(msgs, openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleSystem,
+			Content: fmt.Sprintf("The tool adds the footer %q itself. Do not add closing keywords "+
+				"such as \"Fixes #1\" yourself.", strings.Join(trailers, "\n")),
+		})
+	}
+
+	// Before the paths are redacted, so that sensitive files are known by
+	// their names.
+	if msgs, err = scanSecrets(f, msgs); err != nil {
+		return prompt{}, err
+	}
+	if redactor != nil {
+		msgs = append(redactor.RedactMessages(msgs), openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleSystem,
+			Content: "File and directory names have been replaced with placeholders such as " +
+				"dir_01/file_01.go. Never mention file or directory names in the message.",
+		})
+	}
+
+	var typeHint string
+	if automation.Type != "" {
+		typeHint = automation.Type
+		msgs = append(msgs, openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleSystem,
+			Content: fmt.Sprintf("This is a mechanical %s commit. The tool sets the Conventional Commits "+
+				"type %q itself, so start the subject with \"%s: \" and only write the description.",
+				automation.Kind, typeHint, typeHint),
+		})
+	} else if f.typeFromPaths != "off" {
+		rules := cfg.TypeRules
+		if len(rules) == 0 {
+			rules = fastcommit.DefaultTypeRules
+		}
+		typeHint = fastcommit.ClassifyChange(paths, rules)
+		verbosef("type from paths: %q", typeHint)
+		if typeHint != "" {
 			msgs = append(msgs, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleUser,
-				Content: context,
+				Role: openai.ChatMessageRoleSystem,
+				Content: fmt.Sprintf("Every changed file is classified as %q, so start the subject "+
+					"with the Conventional Commits type %q, e.g. \"%s: ...\".", typeHint, typeHint, typeHint),
 			})
 		}
 	}
 
+	var scope string
+	if len(owners) > 0 {
+		msg, s, ok := codeownersContext(f, owners, paths)
+		if ok {
+			msgs = append(msgs, msg)
+			scope = s
+		}
+	}
+	if f.scope != "" {
+		scope = f.scope
+	}
+
+	msgs = f.privacy.scrub(msgs)
+
 	if debugMode {
 		for _, msg := range msgs {
 			debugf("%s: (%v tokens)\n %s\n\n", msg.Role, fastcommit.CountTokens(msg), msg.Content)
 		}
-		debugf("prompt includes %d commits\n", len(msgs)/2)
+		debugf("prompt includes %d commits\n", fastcommit.HistoryCommits(msgs))
 	}
+	return prompt{
+		msgs:     msgs,
+		redactor: redactor,
+		typeHint: typeHint,
+		pinType:  automation.Type != "",
+		scope:    scope,
+		closing:  closing,
+		paths:    paths,
+	}, nil
+}
 
-	oaiConfig := openai.DefaultConfig(f.openAIKey)
-	oaiConfig.BaseURL = f.openAIBaseURL
-	client := openai.NewClientWithConfig(oaiConfig)
+// splitMessage splits a commit message into its subject, the first line, and
+// its body, the rest without the separating blank lines.
+func splitMessage(msg string) (subject, body string) {
+	subject, body, _ = strings.Cut(msg, "\n")
+	subject = strings.TrimSpace(subject)
+	body = strings.TrimRight(strings.TrimLeft(body, "\r\n"), " \t\r\n")
+	return subject, body
+}
 
-	// Create context with cancel
-	ctx := context.Background()
+// messageArgs returns the git commit arguments for msg. The subject and body
+// are passed as separate -m flags, which git joins with a blank line.
+func messageArgs(msg string) []string {
+	subject, body := splitMessage(msg)
+	args := []string{"-m", subject}
+	if body != "" {
+		args = append(args, "-m", body)
+	}
+	return args
+}
 
-	stream, err := client.CreateChatCompletionStream(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:       f.model,
-			Stream:      true,
-			Temperature: 0,
-			StreamOptions: &openai.StreamOptions{
-				IncludeUsage: true,
-			},
-			Messages: msgs,
-		})
+// applyAffixes adds prefix to the subject and suffix to the end of the body
+// unless they are already present, e.g. when amending a message that already
+// carries them.
+func applyAffixes(msg, prefix, suffix string) string {
+	subject, body := splitMessage(msg)
+	if prefix != "" && !strings.HasPrefix(subject, prefix) {
+		subject = prefix + subject
+	}
+	if suffix != "" && !strings.HasSuffix(body, suffix) {
+		if body == "" {
+			body = suffix
+		} else {
+			body += "\n\n" + suffix
+		}
+	}
+	if body == "" {
+		return subject
+	}
+	return subject + "\n\n" + body
+}
+
+var conventionalTypeRe = regexp.MustCompile(`^[a-z]+(\([^)]*\))?!?: `)
+
+// enforceType makes typ the Conventional Commits type of msg's subject,
+// replacing any type the model chose. A scope in typ replaces the model's.
+func enforceType(msg, typ string) string {
+	subject, body := splitMessage(msg)
+	breaking := ""
+	if m := conventionalTypeRe.FindString(subject); m != "" {
+		if strings.HasSuffix(m, "!: ") {
+			breaking = "!"
+		}
+		if !strings.Contains(typ, "(") {
+			// Keep the model's scope when the rule has none.
+			if i := strings.Index(m, "("); i >= 0 {
+				typ += m[i : strings.Index(m, ")")+1]
+			}
+		}
+		subject = subject[len(m):]
+	}
+	subject = typ + breaking + ": " + subject
+	if body == "" {
+		return subject
+	}
+	return subject + "\n\n" + body
+}
+
+// finishMessage applies everything that happens to a generated message
+// before it is committed, in order.
+func finishMessage(f flags, cfg config, p prompt, msg string) (string, error) {
+	if len(f.trailers) > 0 && p.preset == "" {
+		msg = stripTrailers(msg)
+	}
+	if p.redactor != nil {
+		msg = p.redactor.StripPlaceholders(msg)
+	}
+	if f.linkFiles && p.preset == "" {
+		var fixes []fastcommit.PathFix
+		msg, fixes = fastcommit.LinkFiles(msg, p.paths)
+		for _, fix := range fixes {
+			if fix.New == "" {
+				verbosef("link files: dropped %s, which is not changed", fastcommit.QuotePath(fix.Old))
+			} else {
+				verbosef("link files: replaced %s with %s", fastcommit.QuotePath(fix.Old), fastcommit.QuotePath(fix.New))
+			}
+		}
+	}
+	if (f.typeFromPaths == "strict" || p.pinType) && p.typeHint != "" {
+		msg = enforceType(msg, p.typeHint)
+	}
+	if p.scope != "" {
+		msg = applyScope(msg, p.scope)
+	}
+	msg = applyAffixes(msg, f.prefix, f.suffix)
+	msg = applyClosingRefs(msg, p.closing)
+	msg = applyTrailers(msg, f.trailers)
+	return postGenerate(cfg, msg)
+}
+
+// commitCommand returns the git commit command for msg. With f.paths set,
+// only those files are committed.
+func commitCommand(f flags, msg string) *exec.Cmd {
+	cmd := exec.Command("git", append([]string{"commit"}, messageArgs(msg)...)...)
+	if f.commitTo != "" {
+		cmd.Args = append([]string{"git", "-C", f.commitTo}, cmd.Args[1:]...)
+	}
+	if f.amend {
+		cmd.Args = append(cmd.Args, "--amend")
+	}
+	if f.allowEmpty {
+		cmd.Args = append(cmd.Args, "--allow-empty")
+	}
+	if f.edit {
+		cmd.Args = append(cmd.Args, "--edit")
+	}
+	if len(f.paths) > 0 {
+		cmd.Args = append(append(cmd.Args, "--"), fastcommit.Pathspecs(f.paths)...)
+	} else if f.all {
+		cmd.Args = append(cmd.Args, "--all")
+	}
+	return cmd
+}
+
+func currentBranch() string {
+	output, err := exec.Command("git", "symbolic-ref", "--short", "-q", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func run(f flags, cfg config, ref string) error {
+	workdir, err := os.Getwd()
 	if err != nil {
 		return err
 	}
-	defer stream.Close()
 
-	msg := &bytes.Buffer{}
+	if ref != "" && f.amend {
+		return errors.New(tr("ref_and_amend"))
+	}
+	if f.preview && (ref != "" || f.amend) {
+		return errors.New(tr("preview_conflict"))
+	}
+	if f.all && (ref != "" || f.amend || f.preview) {
+		return errors.New(tr("all_conflict"))
+	}
+	if f.since != "" && (ref != "" || f.amend || f.preview || f.all) {
+		return errors.New(tr("since_conflict"))
+	}
+	if f.includeUntracked && !f.preview {
+		return errors.New(tr("untracked_needs_preview"))
+	}
+	if f.commitTo != "" {
+		if ref != "" || f.amend || f.preview || f.all {
+			return errors.New(tr("commit_to_conflict"))
+		}
+		if f.commitTo, err = resolveCommitTarget(f.commitTo); err != nil {
+			return err
+		}
+	}
+
+	if f.prefix == "" && f.suffix == "" && len(cfg.Branches) > 0 {
+		if bc, ok := cfg.branchSettings(currentBranch()); ok {
+			debugf("using prefix %q and suffix %q for branch pattern %q", bc.Prefix, bc.Suffix, bc.Pattern)
+			f.prefix, f.suffix = bc.Prefix, bc.Suffix
+		}
+	}
 
-	for {
-		resp, err := stream.Recv()
+	// With --minimal, recent commit messages are left out anyway.
+	if !f.minimal {
+		if shallow, err := fastcommit.IsShallow(workdir); err != nil {
+			debugf("shallow check: %v", err)
+		} else if shallow {
+			// The few commits a shallow clone has say little about the style.
+			verbosef("shallow clone, leaving recent commit messages out of the prompt")
+			f.shallow = true
+		}
+	}
+
+	if f.subjectLength == 0 || f.bodyWidth == 0 {
+		n, ok, err := fastcommit.EditorConfigLineLength(workdir)
+		if err != nil {
+			debugf("editorconfig: %v", err)
+		} else if ok {
+			debugf("editorconfig: max_line_length = %d", n)
+			if f.bodyWidth == 0 {
+				f.bodyWidth = n
+			}
+			if f.subjectLength == 0 && n < fastcommit.DefaultSubjectLength {
+				f.subjectLength = n
+			}
+		}
+	}
+
+	hash := ""
+	if f.since != "" {
+		// Like a range, validate the ref before doing any other work.
+		base, err := fastcommit.MergeBase(workdir, f.since)
+		if err != nil {
+			debugf("%v", err)
+			return errors.New(tr("unknown_since", f.since))
+		}
+		commits, err := fastcommit.RangeMessages(workdir, base+"..HEAD")
+		if err != nil {
+			return err
+		}
+		infof("%s\n", tr("since_preview", len(commits), f.since))
+	} else if fastcommit.IsRange(ref) {
+		// Validate the range before doing any other work.
+		n, err := rangeCount(ref)
 		if err != nil {
-			if err == io.EOF {
-				debugf("stream EOF")
-				break
+			return err
+		}
+		infof("%s\n", tr("range_preview", n, ref))
+		f.rangeSpec = ref
+	} else if ref != "" {
+		// Resolve the ref before doing any other work so that typos fail
+		// immediately.
+		hash, err = resolveRef(ref)
+		if err != nil {
+			return err
+		}
+		head, _ := getLastCommitHash()
+		switch {
+		case hash == head:
+			if interactive() && confirm(tr("ref_is_head_question", ref)) {
+				f.amend = true
+				ref, hash = "", ""
+			} else {
+				infof("%s\n", tr("ref_preview_head", ref))
 			}
+		case isAncestorOfHead(hash):
+			infof("%s\n", tr("ref_preview_history", ref))
+		default:
+			infof("%s\n", tr("ref_preview_other", ref))
+		}
+	}
+
+	if ref == "" {
+		state, err := loadState()
+		if err != nil {
 			return err
 		}
-		if resp.Usage != nil {
-			debugf("total tokens: %d", resp.Usage.TotalTokens)
-			break
+		if f.discardSaved {
+			clearUnlanded()
+			state.Unlanded = nil
+		}
+		useSaved, err := checkUnlandedAmend(f, state.Unlanded)
+		if err != nil {
+			return err
+		}
+		if useSaved || f.useSaved {
+			return commitUnlanded(f, state.Unlanded)
+		}
+	}
+
+	if ref == "" && !f.amend && !f.all && !f.preview && f.describe == "" && !f.allowEmpty {
+		// Fail before hooks, --capture or the model run for nothing.
+		staged, err := fastcommit.ChangedPaths(workdir, promptOptions(f, ""))
+		if err != nil {
+			return fmt.Errorf("list staged changes: %w", err)
+		}
+		if len(staged) == 0 {
+			return errors.New(tr("nothing_staged"))
 		}
-		c := resp.Choices[0].Delta.Content
-		msg.WriteString(c)
-		fmt.Printf("\033[34m%s\033[0m", c)
 	}
-	fmt.Println()
 
-	msg = bytes.NewBufferString(cleanAIMessage(msg.String()))
+	if !f.body && f.bodyMinLines > 0 && f.bodySections == nil {
+		stats, err := fastcommit.DiffStats(workdir, promptOptions(f, hash))
+		if err != nil {
+			return err
+		}
+		lines := 0
+		for _, s := range stats {
+			lines += s.Lines()
+		}
+		if lines >= f.bodyMinLines {
+			verbosef("the change touches %d lines (body_min_lines is %d), asking for a body", lines, f.bodyMinLines)
+			f.body = true
+		}
+	}
 
-	cmd := exec.Command("git", "commit", "-m", msg.String())
 	if f.amend {
-		cmd.Args = append(cmd.Args, "--amend")
+		head, err := getLastCommitHash()
+		if err != nil {
+			return err
+		}
+		if err := checkPushedAmend(f, head); err != nil {
+			return err
+		}
 	}
 
-	if f.dryRun {
-		fmt.Printf("Run the following command to commit:\n%s\n", formatShellCommand(cmd))
-		return nil
+	if ref == "" {
+		largeHash := ""
+		if f.amend {
+			largeHash, _ = getLastCommitHash()
+		}
+		if err := checkLargeCommit(f, cfg, workdir, largeHash); err != nil {
+			return err
+		}
 	}
-	if ref != "" {
-		debugf("targetting old ref, not committing")
-		return nil
+
+	client := newProvider(f)
+	if f.deep || f.all {
+		f.pacer = newPacer(cfg.RateLimit.RequestsPerMinute)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	hookContext, err := prePromptContext(cfg)
+	if err != nil {
+		return err
+	}
+	f.context = append(f.context, hookContext...)
+
+	var extra []openai.ChatCompletionMessage
+	if f.capture != "" {
+		msg, err := captureOutput(f.capture, f.captureLines)
+		if err != nil {
+			return err
+		}
+		extra = append(extra, msg)
+	}
+
+	if f.all && interactive() {
+		if done, err := offerSplit(ctx, client, f, cfg, workdir, extra); done || err != nil {
+			return err
+		}
 	}
 
-	fmt.Println()
+	for {
+		if f.amend {
+			// Resolved on every pass since a regeneration may be caused by
+			// HEAD moving.
+			hash, err = getLastCommitHash()
+			if err != nil {
+				return err
+			}
+		}
+
+		// Snapshot the repository before building the prompt so we can tell
+		// whether the message still describes what would be committed.
+		snap, err := takeSnapshot(f.all)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+		p, err := buildPrompt(ctx, client, f, cfg, workdir, hash, extra)
+		if err != nil {
+			return err
+		}
+		promptTime := time.Since(start)
+		verbosef("prompt: %d messages, %d tokens, built in %s",
+			len(p.msgs), fastcommit.CountTokens(p.msgs...), promptTime.Round(time.Millisecond))
+
+		start = time.Now()
+		disp := messageDisplay(f)
+		f.annotations = &annotations{}
+		if f.dryRun && f.candidates > 1 {
+			f.dryCandidates = &candidateSet{}
+		}
+		msg, err := completeMessage(ctx, client, f, cfg, p, disp)
+		if err := interrupted(ctx); err != nil {
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		f.annotations.report()
+		if f.bundleReport != "" {
+			b := newBundle(f, p.msgs, msg, promptTime, time.Since(start))
+			if err := writeBundle(f.bundleReport, b); err != nil {
+				return fmt.Errorf("write bundle: %w", err)
+			}
+			verbosef("wrote bundle report to %s", f.bundleReport)
+		}
+		if f.preview {
+			// The message was already streamed; only point out files that
+			// would need to be added before committing.
+			disp.Replace(msg)
+			f.result.generated(f, p, msg)
+			return printUntrackedNote(workdir, f.includeUntracked)
+		}
+		if f.rangeSpec != "" || f.since != "" {
+			// The message is only a proposal, to be pasted into e.g. git
+			// rebase -i or a squash-merge dialog.
+			disp.Replace(msg)
+			f.result.generated(f, p, msg)
+			return nil
+		}
+		msg, err = finishMessage(f, cfg, p, msg)
+		if err != nil {
+			return err
+		}
+		disp.Replace(msg)
+		f.result.generated(f, p, msg)
+		cmd := commitCommand(f, msg)
+
+		if f.dryRun && f.dryCandidates != nil && len(f.dryCandidates.messages) > 1 {
+			return printCandidateCommands(f, cfg, p, *f.dryCandidates, msg)
+		}
+		if f.dryRun {
+			fmt.Printf("%s\n%s\n", tr("run_to_commit"), formatShellCommand(cmd))
+			return nil
+		}
+		if ref != "" {
+			// The preview-only notice was printed when resolving the ref.
+			return nil
+		}
+
+		generated := msg
+		// Callers of --output json take the message as it is.
+		if interactive() && !f.yes && !f.edit && f.result == nil {
+			msg, generated, err = reviewMessage(msg, func(ctx context.Context, prev string) (string, error) {
+				alt := p
+				alt.msgs = alternativePrompt(p.msgs, prev)
+				disp := messageDisplay(f)
+				f.annotations = &annotations{}
+				m, err := completeMessage(ctx, client, f, cfg, alt, disp)
+				if err != nil {
+					return "", err
+				}
+				f.annotations.report()
+				if m, err = finishMessage(f, cfg, alt, m); err != nil {
+					return "", err
+				}
+				disp.Replace(m)
+				return m, nil
+			})
+			if err != nil {
+				return err
+			}
+			cmd = commitCommand(f, msg)
+		}
+
+		regenerate, err := checkSnapshot(snap)
+		if err != nil {
+			return err
+		}
+		if regenerate {
+			continue
+		}
+		if err := f.annotations.confirmAltered(); err != nil {
+			return err
+		}
+		// The last check before committing: a Ctrl-C at any point up to
+		// here, even after the message was complete, commits nothing.
+		if err := interrupted(ctx); err != nil {
+			return err
+		}
+
+		if f.commitTo != "" {
+			if err := prepareCommitTarget(f.commitTo); err != nil {
+				return err
+			}
+			if err := runCommit(cmd); err != nil {
+				return err
+			}
+			// The commit is another worktree's, whose HEAD this one does
+			// not record.
+			hash, err := gitOutput("-C", f.commitTo, "rev-parse", "HEAD")
+			if err != nil {
+				debugf("resolve HEAD of %s after commit: %v", f.commitTo, err)
+				return nil
+			}
+			if f.result != nil {
+				f.result.committed(hash)
+			}
+			printSummaryOf(hash, "")
+			return nil
+		}
+		if err := runCommit(cmd); err != nil {
+			if !f.amend {
+				saveUnlanded(msg, snap)
+			}
+			return err
+		}
+		recordCommit(msg)
+		if msg != generated {
+			recordEdit(f, generated, msg)
+		} else {
+			learnFromEdit(f, msg)
+		}
 
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
+		replaced := ""
+		if f.amend {
+			replaced = hash
+			clearPending(hash)
+		}
+		if f.result != nil {
+			hash, _ := getLastCommitHash()
+			f.result.committed(hash)
+		}
+		printCommitSummary(replaced)
+		return nil
+	}
 }
 
 func main() {
+	defer handleCrash()
+	defer traceRun.flush()
 	f := flags{}
 
-	flag.StringVar(&f.openAIKey, "openai-key", os.Getenv("OPENAI_API_KEY"), "The OpenAI API key to use")
-	flag.StringVar(&f.openAIBaseURL, "openai-base-url", "https://api.openai.com/v1", "The base URL to use for the OpenAI API")
-	flag.StringVar(&f.model, "model", "gpt-4o-2024-08-06", "The model to use, e.g. gpt-4o or gpt-4o-mini")
+	flag.StringVar(&f.openAIKey, "openai-key", "", "The OpenAI API key to use (default $OPENAI_API_KEY or api_key in config.toml)")
+	flag.StringVar(&f.openAIBaseURL, "openai-base-url", "https://api.openai.com/v1", "The base URL to use for the OpenAI API\n($OPENAI_BASE_URL or base_url in config.toml override the default)")
+	flag.StringVar(&f.anthropicKey, "anthropic-key", "", "The Anthropic API key to use with --provider anthropic (default $ANTHROPIC_API_KEY or\nanthropic_api_key in config.toml)")
+	flag.StringVar(&f.azureKey, "azure-key", "", "The Azure OpenAI API key to use with --provider azure (default $AZURE_OPENAI_API_KEY or\nazure_api_key in config.toml)")
+	flag.StringVar(&f.azureDeployment, "azure-deployment", "", "The Azure OpenAI deployment to send requests to (default $AZURE_OPENAI_DEPLOYMENT or\nazure_deployment in config.toml, or else the deployment named like --model)")
+	flag.StringVar(&f.azureAPIVersion, "azure-api-version", fastcommit.DefaultAzureAPIVersion, "The Azure OpenAI API version to use ($AZURE_OPENAI_API_VERSION or azure_api_version in\nconfig.toml override the default)")
+	flag.StringVar(&f.provider, "provider", "openai", "The backend to generate messages with: openai (or any API compatible with it, see\n--openai-base-url), azure, anthropic or ollama ($FASTCOMMIT_PROVIDER or provider in\nconfig.toml override the default, which is azure for an *.openai.azure.com base URL)")
+	flag.StringVar(&f.ollamaURL, "ollama-url", "", "The URL of the Ollama daemon (default $OLLAMA_HOST or "+fastcommit.DefaultOllamaURL+")")
+	flag.StringVar(&f.model, "model", "gpt-4o-2024-08-06", "The model to use, e.g. gpt-4o or gpt-4o-mini ($FASTCOMMIT_MODEL or model in\nconfig.toml override the default)")
 	flag.BoolVar(&f.saveKey, "save-key", false, "Save the OpenAI API key to persistent local configuration and exit")
 	flag.BoolVar(&f.dryRun, "dry", false, "Dry run the command")
 	flag.BoolVar(&f.amend, "amend", false, "Amend the last commit")
-	flag.Var(&f.context, "context", "Extra context beyond the diff to consider when generating the commit message")
+	flag.BoolVar(&f.useSaved, "use-saved", false, "Commit the message saved when the last commit failed, e.g. because signing or a hook\nfailed, as a new commit instead of generating one; with --amend, do so without asking")
+	flag.BoolVar(&f.discardSaved, "discard-saved", false, "Forget the message saved when the last commit failed; with --amend, amend without asking")
+	flag.BoolVar(&f.allowPushedAmend, "allow-pushed-amend", false, "Amend the last commit without asking even if it was already pushed")
+	flag.BoolVar(&f.signoff, "signoff", false, "Add a Signed-off-by trailer with the committer identity from git config, as\ngit commit --signoff does")
+	flag.Var(&f.trailerValues, "trailer", "Add this trailer, given as key=value, e.g. \"Reviewed-by=Jane <jane@example.com>\".\nRepeat for several")
+	flag.Var(&f.issues, "issue", "Add a \"Refs:\" trailer for this issue, e.g. JIRA-123, and let the model mention it\nin the subject line where it helps. Repeat for several")
+	flag.Var(&f.contextRefs, "context-ref", "Add the full message of this commit, e.g. one on another branch, as context\ndescribing a related change. Repeat for several")
+	flag.Var(&f.context, "context", "Extra context beyond the diff to consider when generating the commit message. Repeat\nfor several, optionally labeled, e.g. \"bug: login loops on SSO\"; all are kept in order")
+	flag.StringVar(&f.describe, "describe", "", "Describe the change in prose. With nothing staged, the message is generated from this\ndescription alone; with staged changes, it is used as additional context for the diff")
+	flag.StringVar(&f.rewriteRange, "range", "", "Rewrite the message of each commit in this range of the current branch, e.g.\norigin/main..HEAD, from its own diff, asking to accept or skip each one")
+	flag.StringVar(&f.since, "since", "", "Propose a single message for squash-merging the branch into this ref, e.g. origin/main,\nfrom everything since the branch forked off it, staged changes included. Nothing is committed")
+	flag.BoolVar(&f.allowEmpty, "allow-empty", false, "Allow creating a commit with no changes, e.g. together with --describe")
+	flag.StringVar(&f.prefix, "prefix", "", "Literal text to prepend to the subject line")
+	flag.StringVar(&f.suffix, "suffix", "", "Literal text to append to the end of the message body")
+	flag.IntVar(&f.maxLineLength, "max-line-length", fastcommit.DefaultMaxLineLength, "Truncate diff lines longer than this many characters in the prompt (negative to disable)")
+	flag.IntVar(&f.subjectLength, "subject-length", 0, "Maximum subject line length to ask for (default 50, or less if .editorconfig says so)")
+	flag.IntVar(&f.bodyWidth, "body-width", 0, "Column to wrap the body at (default max_line_length for COMMIT_EDITMSG in\n.editorconfig, or 72)")
+	flag.BoolVar(&f.redactPaths, "redact-paths", false, "Replace file and directory names in the prompt with placeholders")
+	flag.BoolVar(&f.blockSecrets, "block-secrets", false, "Refuse to send a prompt holding likely secrets, such as keys or a staged .env file,\ninstead of redacting them")
+	flag.BoolVar(&f.noSecretScan, "no-secret-scan", false, "Send the prompt without looking for secrets to redact")
+	flag.BoolVar(&f.forceLarge, "force-large", false, "Generate a message even for unusually large commits without asking")
+	flag.BoolVar(&f.all, "all", false, "Commit all changes to tracked files, staged or not, like git commit --all. When they\nfall into a few separate areas, offer to commit each area separately")
+	flag.BoolVar(&f.yes, "yes", false, "Commit the generated message without asking to accept, regenerate or edit it first\n(only asked in a terminal)")
+	flag.BoolVar(&f.edit, "edit", false, "Open the generated message in your editor before committing. Your edits are\nremembered as style examples for this repository (see --no-learning)")
+	flag.BoolVar(&f.noLearning, "no-learning", false, "Neither record edited messages nor use them as style examples; run\n\"fastcommit clear-learning\" to forget the recorded ones")
+	flag.BoolVar(&f.preview, "preview", false, "Print a message for all changes in the working tree, staged or not, without committing or staging anything")
+	flag.BoolVar(&f.includeUntracked, "include-untracked", false, "With --preview, also include untracked files")
+	flag.StringVar(&f.typeFromPaths, "type-from-paths", "off", "Derive the Conventional Commits type from the changed paths when they are all\ntests, docs, CI or dependency files: off, hint (tell the model; the default with\n--conventional) or strict (enforce it)")
+	flag.BoolVar(&verbose, "v", false, "Print verbose progress information")
+	flag.StringVar(&f.capture, "capture", "", "Run this shell command, e.g. \"go test ./...\", and include its output in the prompt as\nbuild/test output; its exit status does not stop fastcommit")
+	flag.IntVar(&f.captureLines, "capture-lines", 100, "Maximum number of --capture output lines to include, preferring failures")
+	flag.StringVar(&f.closingKeyword, "closing-keyword", "", "Keyword for the issue-closing footers taken from --context, e.g. Closes\n(defaults to the keyword used in the context)")
+	flag.BoolVar(&f.noStatusContext, "no-status-context", false, "Do not tell the model which files have unstaged changes or are untracked")
+	flag.BoolVar(&f.deep, "deep", false, "For very large changes, summarize each component with --deep-model first and write\nthe message from the summaries")
+	flag.StringVar(&f.deepModel, "deep-model", "gpt-4o-mini", "The model used by --deep to summarize components")
+	flag.StringVar(&f.bundleReport, "bundle-report", "", "Write the prompt, response, settings and timings to this .tar.gz file for bug reports;\nreplay it with \"fastcommit replay <file>\"")
+	flag.BoolVar(&f.bundleFull, "bundle-full", false, "Keep the diff in the --bundle-report prompt (it is left out by default)")
+	flag.StringVar(&f.automationPresets, "automation-presets", "off", "Recognize reverts, version bumps, regenerated code and dependency updates: on (write\na fixed message where possible), hint (pin the type and scope, the model writes the\ndescription) or off")
+	flag.BoolVar(&f.minimal, "minimal", false, "Send only the diff, without recent commits, style guides, branch or status, and skip\nthe git commands that gather them; faster, but messages follow the repository's style less")
+	flag.BoolVar(&f.conventional, "conventional", false, "Require Conventional Commits subjects, \"type(scope): description\", as commitlint checks\nthem; a message breaking the rules is regenerated once, then fastcommit fails")
+	flag.StringVar(&f.scope, "scope", "", "Use this Conventional Commits scope instead of letting the model pick one; implies\n--conventional")
+	flag.BoolVar(&f.linkFiles, "link-files", false, "Write the body as bullets naming the files each refers to, and fix or drop named\nfiles that the change does not touch")
+	flag.BoolVar(&f.impactLabel, "impact-label", false, "Also classify the change as user-facing or internal, and add a \"Changelog:\" trailer\ndescribing user-facing ones to end users")
+	flag.IntVar(&f.history, "history", 0, fmt.Sprintf("Show up to this many recent commits as examples of the repository's style, preferring\nthose touching the same files and skipping merges, bots and very short messages\n(default %d, or history.count in config.toml); 0 shows none", fastcommit.DefaultHistory))
+	flag.IntVar(&f.narrative, "narrative", 0, "List the subjects of this many commits before the change, so the message can follow\non from them without repeating them (default 0, off)")
+	flag.StringVar(&f.commitTo, "commit-to", "", "Create the commit in this other worktree of the repository, e.g. one with gh-pages\nchecked out. Its index must stage the same changes, or, when nothing is staged there,\nthe same paths are staged with git add; different contents are refused")
+	flag.BoolVar(&f.noCache, "no-cache", false, "Build the prompt again instead of reusing the one built for the same staged changes\nin the last 15 minutes; \"fastcommit cache clear\" removes them all")
+	flag.IntVar(&f.maxTokens, "max-tokens", 0, fmt.Sprintf("The token budget of the prompt; larger diffs keep their smallest files whole and\nsummarize the rest (default %d, or max_tokens in config.toml)", defaultMaxTokens))
+	flag.Var(&f.exclude, "exclude", "Leave the diffs of files matching this glob out of the prompt, as is done for lockfiles\nand generated code, mentioning only their line counts. Repeat for several")
+	flag.BoolVar(&f.noExclude, "no-exclude", false, "Show the diffs of lockfiles, generated code and files given with --exclude")
+	flag.BoolVar(&f.digestOnly, "digest-only", false, "Send no diff content at all, only the changed paths, their line counts and the names\nof the functions and types changed in them, extracted locally; messages are less detailed")
+	flag.BoolVar(&f.editorShim, strings.TrimPrefix(shimFlag, "--"), false, "Act as git's editor: write a generated message when a commit is reworded during\ngit rebase -i and open the real editor for anything else. Set GIT_EDITOR to\n\"fastcommit --editor-shim\" to use it")
+	flag.BoolVar(&f.thenEdit, "then-edit", false, "With --editor-shim, open the real editor on the generated message")
+	flag.BoolVar(&f.noProfanityFilter, "no-profanity-filter", false, "Do not filter profanity from generated messages")
+	flag.BoolVar(&f.lintOnly, "lint-only", false, "Print the lint rules a generated message breaks and exit 1, instead of regenerating it")
+	flag.BoolVar(&f.body, "body", false, "Ask for a body explaining why after the subject line, as \"- \" bullets wrapped at\n--body-width (default for changes of at least body_min_lines lines in config.toml)")
+	flag.StringVar(&f.bodySectionsFlag, "body-sections", "", "Require these labeled sections in the body, e.g. what,why or what=Change,why=Reason;\n\"none\" for a subject line only")
+	flag.IntVar(&f.maxRetries, "max-retries", 3, "Retry requests failing with rate limits, server errors or dropped connections\nthis many times, backing off exponentially")
+	flag.IntVar(&f.candidates, "candidates", 1, "Generate this many messages and choose one with a keypress (the first when not in a terminal);\nwith --dry, print them all")
+	flag.BoolVar(&f.autoPick, "auto-pick", false, "With --candidates, pick the best message by local scoring instead of asking;\n-v prints the scores")
+	flag.StringVar(&f.conventionsFrom, "conventions-from", "", "Follow the commit conventions documented in this Markdown file, e.g.\nCONTRIBUTING.md; only sections whose heading mentions commits are used")
+	flag.BoolVar(&f.autoConventions, "auto-conventions", false, "Like --conventions-from, with the first of CONTRIBUTING.md, docs/commit-style.md\nand similar files found in the repository")
+	flag.StringVar(&f.codeowners, "codeowners", "hint", "Tell the model which CODEOWNERS own the changed files: off, hint, or scope (also\nuse a single owning team as the Conventional Commits scope)")
+	flag.BoolVar(&f.lenientConfig, "lenient-config", false, "Warn about unknown keys in config.toml instead of failing")
+	flag.BoolVar(&f.plain, "plain", false, "Print the streamed message without colors or wrapping")
+	flag.StringVar(&f.output, "output", "text", "Output format: text, or json to print only a single JSON object with the message, model,\ntoken usage and any commit created on stdout, for scripts and editors; all else goes to stderr")
+	flag.StringVar(&f.uiLang, "ui-lang", "", "Language for CLI output, e.g. en or es (defaults to $LANG)")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options] [ref]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, tr("usage", os.Args[0]))
 		flag.PrintDefaults()
 	}
 
 	flag.Parse()
+	catchSIGPIPE()
+
+	switch f.typeFromPaths {
+	case "off", "hint", "strict":
+	default:
+		errorf("%s\n", tr("invalid_flag", "--type-from-paths", f.typeFromPaths))
+		os.Exit(2)
+	}
+	switch f.codeowners {
+	case "off", "hint", "scope":
+	default:
+		errorf("%s\n", tr("invalid_flag", "--codeowners", f.codeowners))
+		os.Exit(2)
+	}
+	if f.scope != "" {
+		f.conventional = true
+	}
+	if f.conventional && f.prefix != "" {
+		// The prefix would come before the type.
+		errorf("%s\n", tr("flags_conflict", "--conventional", "--prefix"))
+		os.Exit(2)
+	}
+	if f.linkFiles && f.redactPaths {
+		// The model never sees the real paths.
+		errorf("%s\n", tr("flags_conflict", "--link-files", "--redact-paths"))
+		os.Exit(2)
+	}
+	if f.blockSecrets && f.noSecretScan {
+		errorf("%s\n", tr("flags_conflict", "--block-secrets", "--no-secret-scan"))
+		os.Exit(2)
+	}
+	if f.narrative < 0 {
+		errorf("%s\n", tr("invalid_flag", "--narrative", strconv.Itoa(f.narrative)))
+		os.Exit(2)
+	}
+	if f.useSaved && f.discardSaved {
+		errorf("%s\n", tr("flags_conflict", "--use-saved", "--discard-saved"))
+		os.Exit(2)
+	}
+	if f.digestOnly && f.deep {
+		// --deep has the model summarize the diff itself.
+		errorf("%s\n", tr("flags_conflict", "--digest-only", "--deep"))
+		os.Exit(2)
+	}
+	sections, err := parseBodySections(f.bodySectionsFlag)
+	if err != nil {
+		errorf("%v\n", err)
+		os.Exit(2)
+	}
+	f.bodySections = sections
+	if f.trailers, err = messageTrailers(f); err != nil {
+		errorf("%v\n", err)
+		os.Exit(2)
+	}
+	if f.body && sections != nil && len(sections) == 0 {
+		errorf("%s\n", tr("flags_conflict", "--body", "--body-sections none"))
+		os.Exit(2)
+	}
+
+	switch f.automationPresets {
+	case "on", "hint", "off":
+	default:
+		errorf("%s\n", tr("invalid_flag", "--automation-presets", f.automationPresets))
+		os.Exit(2)
+	}
+
+	switch f.output {
+	case "text":
+	case "json":
+		if f.rewriteRange != "" || f.editorShim || flag.Arg(0) == "reword" {
+			errorf("%s\n", tr("output_json_conflict"))
+			os.Exit(2)
+		}
+		f.result = &jsonResult{}
+		redirectStdout()
+	default:
+		errorf("%s\n", tr("invalid_flag", "--output", f.output))
+		os.Exit(2)
+	}
+
+	if f.uiLang != "" {
+		lang := normalizeLang(f.uiLang)
+		if _, ok := catalog[lang]; ok {
+			uiLang = lang
+		} else {
+			errorf("%s\n", tr("unknown_ui_lang", f.uiLang))
+		}
+	}
 
 	if len(os.Args) == 2 && os.Args[1] == "version" {
 		fmt.Printf("fastcommit %s\n", Version)
 		return
 	}
 
-	savedKey, err := loadKey()
-	if err != nil && !os.IsNotExist(err) {
-		errorf("%v\n", err)
-		os.Exit(1)
+	if flag.Arg(0) == "clear-learning" {
+		if err := clearLearning(); err != nil {
+			exitWith(err)
+		}
+		fmt.Println(tr("learning_cleared"))
+		return
+	}
+
+	if flag.Arg(0) == "cache" {
+		if err := runCache(flag.Args()[1:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "config" {
+		if err := runConfig(flag.Args()[1:]); err != nil {
+			exitWith(err)
+		}
+		return
 	}
 
-	if savedKey != "" && f.openAIKey == os.Getenv("OPENAI_API_KEY") {
-		f.openAIKey = savedKey
+	// The flags as given, for applying the config again after setup.
+	given := f
+	cfg, err := loadConfig(f.lenientConfig)
+	if err == nil {
+		err = applyConfig(&f, cfg)
+	}
+	if err != nil {
+		exitWith(err)
 	}
 
-	if f.openAIKey == "" {
-		errorf("$OPENAI_API_KEY is not set\n")
+	if flag.Arg(0) == "setup" {
+		if err := runSetup(f); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "doctor" {
+		if err := runDoctor(f, flag.Args()[1:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	// Only after config and doctor, which must still work to repair a
+	// config.toml the key cannot be moved into.
+	if migrated, err := migrateKeyFile(); err != nil {
+		exitWith(err)
+	} else if migrated {
+		f = given
+		cfg, err = loadConfig(f.lenientConfig)
+		if err == nil {
+			err = applyConfig(&f, cfg)
+		}
+		if err != nil {
+			exitWith(err)
+		}
+	}
+
+	if f.editorShim {
+		if err := runEditorShim(f, cfg, flag.Args()); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "tokens" {
+		if err := runTokens(f, flag.Args()[1:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "status" {
+		if err := runStatus(); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "hook" {
+		if err := runGitHook(f, cfg, flag.Args()[1:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "wip" {
+		// Works without a key too, committing with a local message.
+		if err := runWIP(f, cfg); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if !f.saveKey && firstRun(f) {
+		if err := runSetup(f); err != nil {
+			exitWith(err)
+		}
+		f = given
+		cfg, err = loadConfig(f.lenientConfig)
+		if err == nil {
+			err = applyConfig(&f, cfg)
+		}
+		if err != nil {
+			exitWith(err)
+		}
+	}
+
+	if flag.Arg(0) == "replay" && canGenerate(f) {
+		if err := runReplay(f, flag.Args()[1:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if !canGenerate(f) {
+		switch f.provider {
+		case "anthropic":
+			errorf("%s\n", tr("no_anthropic_key"))
+		case "azure":
+			errorf("%s\n", tr("no_azure_key"))
+		default:
+			errorf("%s\n", tr("no_key"))
+		}
 		os.Exit(1)
 	}
 
 	if f.saveKey {
-		err := saveKey(f.openAIKey)
+		err := saveKey(f)
 		if err != nil {
 			errorf("%v\n", err)
 			os.Exit(1)
 		}
 
-		kp, err := keyPath()
+		cp, err := configPath()
 		if err != nil {
 			errorf("%v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("Saved OpenAI API key to %s\n", kp)
+		fmt.Println(tr("saved_key", cp))
+		return
+	}
+
+	if flag.Arg(0) == "branch" || flag.Arg(0) == "pr" {
+		run := runBranch
+		if flag.Arg(0) == "pr" {
+			run = runPR
+		}
+		if err := run(f, cfg, flag.Args()[1:]); err != nil {
+			exitWith(err)
+		}
 		return
 	}
 
@@ -268,8 +1577,33 @@ func main() {
 		ref = flag.Arg(0)
 	}
 
-	if err := run(f, ref); err != nil {
-		errorf("%v\n", err)
-		os.Exit(1)
+	if ref == "reword" {
+		if err := runReword(f, cfg, flag.Args()[1:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if f.rewriteRange != "" {
+		if ref != "" || f.amend || f.all || f.preview || f.since != "" {
+			errorf("%s\n", tr("rewrite_conflict"))
+			os.Exit(2)
+		}
+		if err := runRewriteRange(f, cfg); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	if err := run(f, cfg, ref); err != nil {
+		if errors.Is(err, fastcommit.ErrShallowHistory) {
+			err = errors.New(tr("shallow_history"))
+		}
+		exitWith(err)
+	}
+	if f.result != nil {
+		if err := f.result.write(jsonStdout); err != nil {
+			exitWith(err)
+		}
 	}
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMessageArgs(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want []string
+	}{
+		{"Fix the build", []string{"-m", "Fix the build"}},
+		{"Fix the build\n", []string{"-m", "Fix the build"}},
+		{"  Fix the build  \n\n", []string{"-m", "Fix the build"}},
+		{"Fix the build\n\nThe linker flag was misspelled.", []string{"-m", "Fix the build", "-m", "The linker flag was misspelled."}},
+		// The first line is the subject even without a blank line after it.
+		{"Fix the build\nThe linker flag was misspelled.\n", []string{"-m", "Fix the build", "-m", "The linker flag was misspelled."}},
+		{"Fix the build\r\n\r\nThe flag was wrong.\r\n", []string{"-m", "Fix the build", "-m", "The flag was wrong."}},
+		// Paragraphs of the body stay in one -m, with their blank lines.
+		{"Fix the build\n\n\nFirst.\n\nSecond.\n", []string{"-m", "Fix the build", "-m", "First.\n\nSecond."}},
+		{"Fix the build\n\n- one\n- two", []string{"-m", "Fix the build", "-m", "- one\n- two"}},
+	}
+	for _, tt := range tests {
+		if got := messageArgs(tt.msg); !slices.Equal(got, tt.want) {
+			t.Errorf("messageArgs(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+)
+
+// jsonResult is what --output json prints on stdout when a run succeeds.
+type jsonResult struct {
+	Message          string `json:"message"`
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	CommitsInPrompt  int    `json:"commits_in_prompt"`
+	Committed        bool   `json:"committed"`
+	// Commit is the hash of the commit created, if any.
+	Commit string `json:"commit,omitempty"`
+	// Impact is the classification of --impact-label.
+	Impact *fastcommit.Impact `json:"impact,omitempty"`
+	// TraceID identifies the run in debug output and exported traces, and
+	// SpanID the request the message came from.
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id,omitempty"`
+}
+
+// jsonStdout is where --output json writes its result. Everything else
+// printed on stdout goes to stderr in that mode; see redirectStdout.
+var jsonStdout = os.Stdout
+
+// redirectStdout sends all output meant for people to stderr, so that the
+// JSON result is the only thing on stdout.
+func redirectStdout() {
+	jsonStdout = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+// messageDisplay returns the display for the messages of a run, which shows
+// nothing with --output json.
+func messageDisplay(f flags) display {
+	if f.result != nil {
+		return nullDisplay{}
+	}
+	return newDisplay(os.Stdout, f.plain)
+}
+
+// generated records msg, generated for p, as the message of the result. A
+// nil *jsonResult records nothing.
+func (r *jsonResult) generated(f flags, p prompt, msg string) {
+	if r == nil {
+		return
+	}
+	r.Message = msg
+	r.Model = f.model
+	r.TraceID = traceRun.traceID
+	r.CommitsInPrompt = fastcommit.HistoryCommits(p.msgs)
+	if a := f.annotations; a != nil {
+		if a.ServedBy != "" {
+			r.Model = a.ServedBy
+		}
+		r.PromptTokens, r.CompletionTokens = a.PromptTokens, a.CompletionTokens
+		r.Impact = a.Impact
+		r.SpanID = a.SpanID
+	}
+}
+
+// committed records the commit just created, hash, with the message as
+// committed, e.g. after --edit.
+func (r *jsonResult) committed(hash string) {
+	r.Committed = true
+	r.Commit = hash
+	if msg, err := gitOutput("show", "-s", "--format=%B", hash); err == nil {
+		r.Message = msg
+	}
+}
+
+// write prints the result as a single line of JSON.
+func (r *jsonResult) write(w io.Writer) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
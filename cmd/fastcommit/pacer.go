@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// rateLimitConfig configures pacing for providers that do not report their
+// rate limits in response headers.
+type rateLimitConfig struct {
+	// RequestsPerMinute caps the requests of a batch, e.g. the summaries of
+	// --deep. Zero means no limit until the API reports one.
+	RequestsPerMinute int `toml:"requests_per_minute"`
+}
+
+// pacer spaces out the requests of a batch, such as the component summaries
+// of --deep or the commits of a split --all, so that they stay under the rate
+// limit instead of running into it and backing off. It is fed by the
+// x-ratelimit-* headers of each response. A nil pacer never waits, which is
+// what single requests use.
+type pacer struct {
+	mu       sync.Mutex
+	requests bucket
+	tokens   bucket
+}
+
+// bucket is a token bucket refilling continuously at rate per second up to
+// capacity. A zero bucket is unlimited.
+type bucket struct {
+	name     string
+	level    float64
+	capacity float64
+	rate     float64
+	updated  time.Time
+}
+
+func newPacer(requestsPerMinute int) *pacer {
+	p := &pacer{
+		requests: bucket{name: "requests"},
+		tokens:   bucket{name: "tokens"},
+	}
+	if requestsPerMinute > 0 {
+		rpm := float64(requestsPerMinute)
+		p.requests.set(rpm, rpm, rpm/60)
+	}
+	return p
+}
+
+func (b *bucket) set(level, capacity, rate float64) {
+	b.level, b.capacity, b.rate, b.updated = level, capacity, rate, time.Now()
+}
+
+// take removes n from the bucket and returns how long to wait before doing
+// so would not exceed the limit.
+func (b *bucket) take(n float64) time.Duration {
+	if b.rate == 0 {
+		return 0
+	}
+	now := time.Now()
+	b.level = min(b.capacity, b.level+b.rate*now.Sub(b.updated).Seconds())
+	b.updated = now
+	b.level -= n
+	if b.level >= 0 {
+		return 0
+	}
+	return time.Duration(-b.level / b.rate * float64(time.Second))
+}
+
+// wait blocks until a request of about tokens tokens fits under the limits.
+func (p *pacer) wait(ctx context.Context, tokens int) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	reqWait, tokWait := p.requests.take(1), p.tokens.take(float64(tokens))
+	p.mu.Unlock()
+
+	d, limit := reqWait, "requests"
+	if tokWait > d {
+		d, limit = tokWait, "tokens"
+	}
+	if d <= 0 {
+		return nil
+	}
+	verbosef("pacing: waiting %s to stay under the %s rate limit", d.Round(time.Millisecond), limit)
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// observe updates the buckets from the rate limit headers of a response.
+// Providers that send none leave the configured fallback in place.
+func (p *pacer) observe(h openai.RateLimitHeaders) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests.observe(h.LimitRequests, h.RemainingRequests, h.ResetRequests)
+	p.tokens.observe(h.LimitTokens, h.RemainingTokens, h.ResetTokens)
+}
+
+// observe sets the bucket from a limit, what remains of it, and the time
+// until it is fully replenished.
+func (b *bucket) observe(limit, remaining int, reset openai.ResetTime) {
+	if limit <= 0 {
+		return
+	}
+	// Without a usable reset time, assume the limit is per minute.
+	rate := float64(limit) / 60
+	if d, err := time.ParseDuration(reset.String()); err == nil && d > 0 && remaining < limit {
+		rate = float64(limit-remaining) / d.Seconds()
+	}
+	b.set(float64(remaining), float64(limit), rate)
+	verbosef("pacing: %d of %d %s left", remaining, limit, b.name)
+}
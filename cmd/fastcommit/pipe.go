@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync/atomic"
+)
+
+// Output may go to a pipe whose reader exits early, as in fastcommit | head -1.
+// Writes then fail and are otherwise ignored, and whether a commit is made is
+// decided in one place, runCommit: if stdout is gone before git commit starts
+// nothing is committed, and once it has started it is left to finish.
+
+// runCommit runs a git command that creates a commit, with the terminal or
+// fastcommit's own output as its output.
+func runCommit(cmd *exec.Cmd) error {
+	// Also the last write before committing, so that it doubles as the check.
+	if _, err := fmt.Println(); isBrokenPipe(err) {
+		return &exitError{code: exitOutputClosed, err: errors.New(tr("output_closed"))}
+	}
+	cmd.Stderr = guardOutput(os.Stderr)
+	cmd.Stdout = guardOutput(os.Stdout)
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		return &exitError{code: exitGit, err: err}
+	}
+	return nil
+}
+
+// guardOutput returns the writer for a command's output to f. A terminal is
+// passed on as is, since the command may need it, e.g. for an editor. Other
+// output is copied, so that a reader going away discards the rest of it
+// instead of killing the command with SIGPIPE halfway through.
+func guardOutput(f *os.File) io.Writer {
+	if isTerminal(f) {
+		return f
+	}
+	return &pipeGuard{w: f}
+}
+
+// pipeGuard drops writes once its writer reports a broken pipe.
+type pipeGuard struct {
+	w      io.Writer
+	closed atomic.Bool
+}
+
+func (g *pipeGuard) Write(p []byte) (int, error) {
+	if g.closed.Load() {
+		return len(p), nil
+	}
+	n, err := g.w.Write(p)
+	if isBrokenPipe(err) {
+		g.closed.Store(true)
+		return len(p), nil
+	}
+	return n, err
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// closedPipe returns the write end of a pipe whose reader has gone away.
+func closedPipe(t *testing.T) *os.File {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+func TestRunCommitOutputClosed(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "a.txt", "a\n")
+	gitT(t, dir, "add", "-A")
+
+	old := os.Stdout
+	os.Stdout = closedPipe(t)
+	defer func() { os.Stdout = old }()
+
+	err := runCommit(exec.Command("git", "commit", "-q", "-m", "Add a"))
+	var ee *exitError
+	if !errors.As(err, &ee) || ee.code != exitOutputClosed {
+		t.Errorf("runCommit with stdout closed = %v, want exit code %d", err, exitOutputClosed)
+	}
+	if n := gitT(t, dir, "rev-list", "--count", "HEAD"); n != "1" {
+		t.Errorf("committed with stdout closed: %s commits", n)
+	}
+}
+
+func TestRunCommitOutputClosedDuringCommit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	dir := newRepo(t)
+	writeFile(t, dir, "a.txt", "a\n")
+	gitT(t, dir, "add", "-A")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	old := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = old }()
+
+	// The commit waits for the reader to go away before printing and
+	// committing.
+	ready := filepath.Join(t.TempDir(), "ready")
+	go func() {
+		buf := make([]byte, 1)
+		r.Read(buf)
+		r.Close()
+		os.WriteFile(ready, nil, 0o644)
+	}()
+	cmd := exec.Command("sh", "-c", `while [ ! -e "$1" ]; do sleep 0.01; done; echo one; echo two; git commit -q -m "Add a"`, "sh", ready)
+	if err := runCommit(cmd); err != nil {
+		t.Errorf("runCommit with stdout closed during the commit = %v", err)
+	}
+	if n := gitT(t, dir, "rev-list", "--count", "HEAD"); n != "2" {
+		t.Errorf("the commit did not finish after stdout closed: %s commits", n)
+	}
+}
+
+func TestPipeGuard(t *testing.T) {
+	g := &pipeGuard{w: closedPipe(t)}
+	for i := 0; i < 2; i++ {
+		if n, err := g.Write([]byte("hello\n")); n != 6 || err != nil {
+			t.Errorf("write %d to a closed pipe = %d, %v; want it dropped", i+1, n, err)
+		}
+	}
+	if !g.closed.Load() {
+		t.Error("the guard did not notice the pipe closing")
+	}
+}
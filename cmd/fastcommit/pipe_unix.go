@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// catchSIGPIPE makes writes to a closed stdout or stderr fail with EPIPE
+// rather than kill the process. Unlike ignoring the signal, this is not
+// inherited by the commands fastcommit runs.
+func catchSIGPIPE() {
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGPIPE)
+}
+
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// catchSIGPIPE is a no-op on Windows, where writing to a closed pipe fails
+// instead of raising a signal.
+func catchSIGPIPE() {}
+
+// errorNoData is ERROR_NO_DATA, returned when writing to a pipe whose reader
+// has closed it.
+const errorNoData = syscall.Errno(232)
+
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.ERROR_BROKEN_PIPE) || errors.Is(err, errorNoData)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+)
+
+// printUntrackedNote lists untracked files after a --preview so the user
+// remembers to add them. included says whether they were part of the preview.
+func printUntrackedNote(workdir string, included bool) error {
+	untracked, err := fastcommit.UntrackedFiles(workdir)
+	if err != nil {
+		return fmt.Errorf("list untracked files: %w", err)
+	}
+	if len(untracked) == 0 {
+		return nil
+	}
+	fmt.Println()
+	if included {
+		fmt.Println(tr("untracked_included"))
+	} else {
+		fmt.Println(tr("untracked_excluded"))
+	}
+	for _, path := range untracked {
+		fmt.Printf("  %s\n", fastcommit.QuotePath(path))
+	}
+	return nil
+}
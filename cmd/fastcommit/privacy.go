@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// privacyConfig lists strings that must never be sent to the model.
+type privacyConfig struct {
+	// RedactSelf adds git's user.name and user.email.
+	RedactSelf bool `toml:"redact_self"`
+	// Redact lists further strings, e.g. other email addresses or a
+	// customer name.
+	Redact []string `toml:"redact"`
+}
+
+// add adds the redactions of other to p, keeping p's own.
+func (p *privacyConfig) add(other privacyConfig) {
+	p.RedactSelf = p.RedactSelf || other.RedactSelf
+	for _, s := range other.Redact {
+		if !slices.Contains(p.Redact, s) {
+			p.Redact = append(p.Redact, s)
+		}
+	}
+}
+
+// redactedPlaceholder replaces the redacted strings in the prompt.
+const redactedPlaceholder = "[redacted]"
+
+// minRedactLength is the length below which a string is not redacted, since
+// it would also match parts of unrelated words and identifiers.
+const minRedactLength = 3
+
+// privacyGuard scrubs the configured strings from prompts and, as a safety
+// net for prompt sources that do not go through the scrubbing, refuses to
+// send any request that still contains one. A nil guard does neither.
+type privacyGuard struct {
+	re *regexp.Regexp
+}
+
+// newPrivacyGuard returns the guard for cfg, or nil if nothing is to be
+// redacted.
+func newPrivacyGuard(cfg config) (*privacyGuard, error) {
+	strs := cfg.Privacy.Redact
+	if cfg.Privacy.RedactSelf {
+		for _, key := range []string{"user.name", "user.email"} {
+			// Unset is fine; there is nothing to leak then.
+			if v, _ := gitOutput("config", key); v != "" {
+				strs = append(strs, v)
+			}
+		}
+	}
+	var quoted []string
+	for _, s := range strs {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if utf8.RuneCountInString(s) < minRedactLength {
+			warnf("%s\n", tr("privacy_too_short", s, minRedactLength))
+			continue
+		}
+		quoted = append(quoted, wordPattern(s))
+	}
+	if len(quoted) == 0 {
+		return nil, nil
+	}
+	// Email addresses and names are matched regardless of case.
+	re, err := regexp.Compile("(?i)" + strings.Join(quoted, "|"))
+	if err != nil {
+		return nil, fmt.Errorf("privacy.redact: %w", err)
+	}
+	return &privacyGuard{re: re}, nil
+}
+
+// wordPattern returns a pattern matching s as a whole word, so that "Ann"
+// is not found in "planning". The boundary is only required where s starts
+// or ends with a word character: "@corp.example" still matches after a
+// user name.
+func wordPattern(s string) string {
+	p := regexp.QuoteMeta(s)
+	if isWordByte(s[0]) {
+		p = `\b` + p
+	}
+	if isWordByte(s[len(s)-1]) {
+		p += `\b`
+	}
+	return p
+}
+
+// isWordByte reports whether c is matched by \w.
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// scrub returns msgs with the redacted strings replaced by a placeholder.
+func (g *privacyGuard) scrub(msgs []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	if g == nil {
+		return msgs
+	}
+	scrubbed := make([]openai.ChatCompletionMessage, len(msgs))
+	for i, msg := range msgs {
+		msg.Content = g.re.ReplaceAllLiteralString(msg.Content, redactedPlaceholder)
+		scrubbed[i] = msg
+	}
+	return scrubbed
+}
+
+// check returns an error naming the first message of a request that
+// contains a redacted string. It is called right before each request.
+func (g *privacyGuard) check(msgs []openai.ChatCompletionMessage) error {
+	if g == nil {
+		return nil
+	}
+	for i, msg := range msgs {
+		if g.re.MatchString(msg.Content) {
+			// The preview shows where the string is, without repeating it.
+			return errors.New(tr("privacy_leak", describeMessage(i, g.scrub(msgs[i : i+1])[0])))
+		}
+	}
+	return nil
+}
+
+// describeMessage identifies a prompt message by its position, role and
+// the start of its content, e.g. `message 3 (user: "diff --git a/...")`.
+func describeMessage(i int, msg openai.ChatCompletionMessage) string {
+	const maxPreview = 40
+	first, _, _ := strings.Cut(strings.TrimSpace(msg.Content), "\n")
+	if r := []rune(first); len(r) > maxPreview {
+		first = string(r[:maxPreview]) + "..."
+	}
+	return fmt.Sprintf("message %d (%s: %q)", i+1, msg.Role, first)
+}
+
+// scanSecrets redacts the likely secrets in msgs, such as keys or the
+// contents of a staged .env file, with a warning naming where they are, or
+// refuses to send msgs with --block-secrets.
+func scanSecrets(f flags, msgs []openai.ChatCompletionMessage) ([]openai.ChatCompletionMessage, error) {
+	if f.noSecretScan {
+		return msgs, nil
+	}
+	msgs, found := fastcommit.RedactSecrets(msgs)
+	if len(found) == 0 {
+		return msgs, nil
+	}
+	where := make([]string, len(found))
+	for i, s := range found {
+		where[i] = s.String()
+	}
+	if f.blockSecrets {
+		return nil, errors.New(tr("secrets_blocked", strings.Join(where, "\n  - ")))
+	}
+	warnf("%s\n", tr("secrets_redacted", strings.Join(where, "\n  - ")))
+	return msgs, nil
+}
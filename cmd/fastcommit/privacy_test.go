@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestPrivacyGuardWholeWords(t *testing.T) {
+	var cfg config
+	cfg.Privacy.Redact = []string{"Ann", "ops@acme.example", "@corp.example", "Jo"}
+	g, err := newPrivacyGuard(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ in, want string }{
+		{"Reviewed-by: Ann", "Reviewed-by: [redacted]"},
+		{"ANN was here", "[redacted] was here"},
+		{"planning the annual release", "planning the annual release"},
+		{"mail OPS@acme.example today", "mail [redacted] today"},
+		{"mail devops@acme.example", "mail devops@acme.example"},
+		{"bob@corp.example", "bob[redacted]"},
+		// Too short to redact without matching other words.
+		{"Jo and John", "Jo and John"},
+	}
+	for _, tt := range tests {
+		msgs := g.scrub([]openai.ChatCompletionMessage{{Content: tt.in}})
+		if got := msgs[0].Content; got != tt.want {
+			t.Errorf("scrub(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if err := g.check(msgs); err != nil {
+			t.Errorf("check(scrub(%q)): %v", tt.in, err)
+		}
+	}
+}
+
+func TestPrivacyGuardOnlyShortStrings(t *testing.T) {
+	var cfg config
+	cfg.Privacy.Redact = []string{"a", " ", "ab"}
+	if g, err := newPrivacyGuard(cfg); g != nil || err != nil {
+		t.Errorf("newPrivacyGuard = %v, %v, want nil, nil", g, err)
+	}
+}
+
+func TestRepoConfigPrivacy(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "fastcommit"), "config.toml", strings.Join([]string{
+		"[privacy]",
+		"redact_self = true",
+		`redact = ["Acme Corp"]`,
+		"",
+		"[openrouter]",
+		`referer = "https://mine.example"`,
+	}, "\n"))
+	writeFile(t, dir, repoConfigName, strings.Join([]string{
+		"[privacy]",
+		"redact_self = false",
+		`redact = ["Project Falcon"]`,
+		"",
+		"[openrouter]",
+		`referer = "https://theirs.example"`,
+	}, "\n"))
+
+	cfg, err := loadConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Privacy.RedactSelf || !slices.Equal(cfg.Privacy.Redact, []string{"Acme Corp", "Project Falcon"}) {
+		t.Errorf("privacy = %+v, want the user's settings with the repository's strings added", cfg.Privacy)
+	}
+	if cfg.OpenRouter.Referer != "https://mine.example" {
+		t.Errorf("openrouter.referer = %q, set by the repository", cfg.OpenRouter.Referer)
+	}
+
+	// Emptying the list in the repository still redacts the user's strings.
+	writeFile(t, dir, repoConfigName, "[privacy]\nredact = []\n")
+	if cfg, err = loadConfig(false); err != nil {
+		t.Fatal(err)
+	}
+	g, err := newPrivacyGuard(cfg)
+	if err != nil || g == nil {
+		t.Fatalf("newPrivacyGuard = %v, %v", g, err)
+	}
+	msgs := g.scrub([]openai.ChatCompletionMessage{{Content: "Bill Acme Corp monthly"}})
+	if strings.Contains(msgs[0].Content, "Acme Corp") {
+		t.Errorf("the repository's config dropped a redaction: %q", msgs[0].Content)
+	}
+}
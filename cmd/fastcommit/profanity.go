@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// profanityConfig configures the filter for unprofessional words that the
+// model sometimes copies from the diff into the message.
+type profanityConfig struct {
+	// Words replaces the built-in list.
+	Words []string `toml:"words"`
+	// Policy is what happens to a message using any of the words: "mask"
+	// (the default) masks them, "rephrase" asks the model once for another
+	// message and masks what remains, and "block" refuses the message.
+	Policy string `toml:"policy"`
+}
+
+// moderateMessage applies the profanity filter to the generated msg. msgs is
+// the prompt msg was generated from, used to ask for a rephrased message.
+func moderateMessage(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	cfg config,
+	msgs []openai.ChatCompletionMessage,
+	msg string,
+) (string, error) {
+	if f.noProfanityFilter {
+		return msg, nil
+	}
+	words := cfg.Profanity.Words
+	if words == nil {
+		words = fastcommit.DefaultProfanity
+	}
+	filter := fastcommit.NewWordFilter(words)
+	found := filter.Find(msg)
+	if len(found) == 0 {
+		return msg, nil
+	}
+	verbosef("message contains %s", strings.Join(found, ", "))
+
+	switch cfg.Profanity.Policy {
+	case "", "mask":
+	case "block":
+		return "", errors.New(tr("profanity_blocked", strings.Join(found, ", ")))
+	case "rephrase":
+		retry := append(msgs[:len(msgs):len(msgs)], openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleSystem,
+			Content: fmt.Sprintf("Keep a professional tone. Never use the words %q, even when "+
+				"they appear in the diff; describe the change without them.", found),
+		})
+		// The rephrased message is not streamed; the display is updated
+		// with the final message afterwards.
+		rephrased, err := generateMessage(ctx, client, f, retry, &rawDisplay{w: io.Discard})
+		if err != nil {
+			return "", err
+		}
+		msg = rephrased
+	default:
+		return "", errors.New(tr("invalid_profanity_policy", cfg.Profanity.Policy))
+	}
+	return filter.Mask(msg), nil
+}
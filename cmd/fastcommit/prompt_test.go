@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// testFlags returns flags as the command line parser leaves them without
+// any options.
+func testFlags() flags {
+	return flags{
+		typeFromPaths:     "off",
+		automationPresets: "off",
+		codeowners:        "hint",
+		output:            "text",
+	}
+}
+
+// requestBody returns the JSON of the chat completion request for msgs, as
+// it would be sent.
+func requestBody(t *testing.T, msgs []openai.ChatCompletionMessage) string {
+	t.Helper()
+	b, err := json.Marshal(openai.ChatCompletionRequest{Model: "gpt-4o", Messages: msgs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRedactPathsRequestBody(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, ".github/CODEOWNERS", "/internal/project-falcon/ @acme/payments\n")
+	writeFile(t, dir, "internal/project-falcon/notes.txt", "draft\n")
+	gitT(t, dir, "add", "-A")
+	gitT(t, dir, "commit", "-q", "-m", "Add CODEOWNERS")
+
+	writeFile(t, dir, "internal/project-falcon/billing_engine.go", "package falcon\n\n// Charge bills a customer.\nfunc Charge() {}\n")
+	writeFile(t, dir, "docs/falcon-launch-plan.md", "# Launch\n\nSee internal/project-falcon/billing_engine.go.\n")
+	writeFile(t, dir, "internal/project-falcon/notes.txt", "draft 2\n")
+	// git quotes non-ASCII paths by default, as "M\303\274ller/...".
+	writeFile(t, dir, "Müller/contract.go", "package müller\n")
+	gitT(t, dir, "add", "internal/project-falcon/billing_engine.go", "docs", "Müller")
+
+	f := testFlags()
+	f.redactPaths = true
+	f.typeFromPaths = "hint"
+	f.describe = "Wire billing_engine.go into the launch plan"
+	f.context = []string{"Mentioned in docs/falcon-launch-plan.md"}
+	p, err := buildPrompt(context.Background(), nil, f, config{}, dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := requestBody(t, p.msgs)
+	for _, name := range []string{"project-falcon", "billing_engine", "falcon-launch-plan", "internal/", "docs/", "notes.txt", "Müller", `\\303`, "contract"} {
+		if strings.Contains(body, name) {
+			t.Errorf("the request body contains %q:\n%s", name, body)
+		}
+	}
+	if !strings.Contains(body, "dir_01") {
+		t.Errorf("the request body has no placeholders:\n%s", body)
+	}
+}
+
+func TestConventionalTypeFromPaths(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "docs/usage.md", "# Usage\n")
+	gitT(t, dir, "add", "-A")
+
+	for _, conventional := range []bool{false, true} {
+		f := testFlags()
+		f.provider = "openai"
+		f.conventional = conventional
+		if err := applyConfig(&f, config{}); err != nil {
+			t.Fatal(err)
+		}
+		p, err := buildPrompt(context.Background(), nil, f, config{}, dir, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hinted := strings.Contains(requestBody(t, p.msgs), `classified as \"docs\"`)
+		if hinted != conventional || (p.typeHint == "docs") != conventional {
+			t.Errorf("with conventional %v: type hinted %v (%q), want %v", conventional, hinted, p.typeHint, conventional)
+		}
+	}
+}
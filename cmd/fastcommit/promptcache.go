@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// promptCacheTTL is how long a built prompt is reused, e.g. to regenerate
+// a message or retry a failed request without building it again.
+const promptCacheTTL = 15 * time.Minute
+
+// promptCacheEntry is a prompt built by fastcommit.BuildPromptWithOptions,
+// with what its callbacks reported, so that a cached prompt shows the same
+// notices.
+type promptCacheEntry struct {
+	Time     time.Time                      `json:"time"`
+	Messages []openai.ChatCompletionMessage `json:"messages"`
+	// Tokens are the token counts of Messages.
+	Tokens  []int                        `json:"tokens"`
+	Packing *fastcommit.DiffPacking      `json:"packing,omitempty"`
+	History *fastcommit.HistorySelection `json:"history,omitempty"`
+}
+
+// promptCacheDir returns the directory of cached prompts in the user cache
+// directory.
+func promptCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "fastcommit", "prompts"), nil
+}
+
+// promptCacheKey identifies the prompt opts builds in the repository at
+// its current HEAD and index, or reports false when the prompt cannot be
+// cached: when it depends on the working tree or on refs other than HEAD,
+// or the index has conflicts. Writing the index's tree makes staging any
+// change, even without a new commit, a different key.
+func promptCacheKey(opts fastcommit.PromptOptions) (string, bool) {
+	if opts.WorkingTree || opts.Range != "" || opts.Since != "" {
+		return "", false
+	}
+	root, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", false
+	}
+	index, err := gitOutput("write-tree")
+	if err != nil {
+		return "", false
+	}
+	// A repository without commits has no HEAD, which is part of the key
+	// as such.
+	head, _ := gitOutput("rev-parse", "--verify", "-q", "HEAD")
+	o, err := json.Marshal(opts)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00", Version, root, head, index, o)
+	// The style guides and .gitattributes are read from the working tree.
+	home, _ := os.UserHomeDir()
+	for _, p := range []string{
+		filepath.Join(root, "COMMITS.md"),
+		filepath.Join(home, "COMMITS.md"),
+		filepath.Join(root, ".gitattributes"),
+	} {
+		if fi, err := os.Stat(p); err == nil {
+			fmt.Fprintf(h, "%s %d %d\x00", p, fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// cachedPrompt returns the prompt cached under key if it was built within
+// promptCacheTTL, reporting its packing and examples to opts' callbacks as
+// building it did.
+func cachedPrompt(key string, opts fastcommit.PromptOptions) ([]openai.ChatCompletionMessage, bool) {
+	dir, err := promptCacheDir()
+	if err != nil {
+		return nil, false
+	}
+	b, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var e promptCacheEntry
+	if err := json.Unmarshal(b, &e); err != nil || len(e.Tokens) != len(e.Messages) {
+		debugf("prompt cache: %s: %v", key, err)
+		return nil, false
+	}
+	if time.Since(e.Time) > promptCacheTTL {
+		return nil, false
+	}
+	for i, msg := range e.Messages {
+		fastcommit.RememberTokens(msg.Content, e.Tokens[i])
+	}
+	if e.History != nil && opts.HistorySelected != nil {
+		opts.HistorySelected(*e.History)
+	}
+	if e.Packing != nil && opts.Packed != nil {
+		opts.Packed(*e.Packing)
+	}
+	debugf("prompt cache: reusing the prompt built %s ago", time.Since(e.Time).Round(time.Second))
+	return e.Messages, true
+}
+
+// buildCachedPrompt builds the prompt of opts, reusing the one cached for
+// the same repository state unless disabled, and caches it.
+func buildCachedPrompt(f flags, workdir string, opts fastcommit.PromptOptions) ([]openai.ChatCompletionMessage, error) {
+	key, ok := "", false
+	if !f.noCache {
+		key, ok = promptCacheKey(opts)
+	}
+	if !ok {
+		return fastcommit.BuildPromptWithOptions(os.Stdout, workdir, opts)
+	}
+	if msgs, ok := cachedPrompt(key, opts); ok {
+		return msgs, nil
+	}
+
+	e := promptCacheEntry{Time: time.Now()}
+	packed, selected := opts.Packed, opts.HistorySelected
+	opts.Packed = func(p fastcommit.DiffPacking) {
+		e.Packing = &p
+		if packed != nil {
+			packed(p)
+		}
+	}
+	opts.HistorySelected = func(sel fastcommit.HistorySelection) {
+		e.History = &sel
+		if selected != nil {
+			selected(sel)
+		}
+	}
+	msgs, err := fastcommit.BuildPromptWithOptions(os.Stdout, workdir, opts)
+	if err != nil {
+		return nil, err
+	}
+	e.Messages = msgs
+	for _, msg := range msgs {
+		e.Tokens = append(e.Tokens, fastcommit.CountTokens(msg))
+	}
+	if err := writePromptCache(key, e); err != nil {
+		debugf("prompt cache: %v", err)
+	}
+	return msgs, nil
+}
+
+// writePromptCache stores e under key and removes the entries that expired.
+// The entries hold diffs, so only the user can read them.
+func writePromptCache(key string, e promptCacheEntry) error {
+	dir, err := promptCacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > promptCacheTTL {
+				os.Remove(filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a concurrent run never reads
+	// a truncated entry.
+	p := filepath.Join(dir, key+".json")
+	tmp, err := os.CreateTemp(dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// runCache runs the cache subcommand.
+func runCache(args []string) error {
+	if len(args) != 1 || args[0] != "clear" {
+		return errors.New(tr("cache_usage"))
+	}
+	dir, err := promptCacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	fmt.Println(tr("cache_cleared", dir))
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// maxRemoteRefs bounds how many remote-tracking branches are searched for
+// the commit being amended, so the check stays fast on repositories with
+// many remotes. The most recently updated branches are checked first since
+// they are the likeliest to contain HEAD.
+const maxRemoteRefs = 100
+
+// pushedBranches returns the remote-tracking branches that contain hash.
+func pushedBranches(hash string) ([]string, error) {
+	output, err := exec.Command("git", "for-each-ref", "--sort=-committerdate",
+		"--count="+fmt.Sprint(maxRemoteRefs), "--format=%(refname)", "refs/remotes").Output()
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	for _, r := range strings.Fields(string(output)) {
+		// Skip symbolic refs such as origin/HEAD, which duplicate a branch.
+		if !strings.HasSuffix(r, "/HEAD") {
+			refs = append(refs, r)
+		}
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"for-each-ref", "--contains", hash, "--format=%(refname:short)"}, refs...)
+	output, err = exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// checkPushedAmend warns when the commit about to be amended has already
+// been pushed, since replacing it will require a force push. Unless
+// --allow-pushed-amend is given, the user has to confirm.
+func checkPushedAmend(f flags, hash string) error {
+	branches, err := pushedBranches(hash)
+	if err != nil {
+		debugf("pushed check: %v", err)
+		return nil
+	}
+	if len(branches) == 0 {
+		return nil
+	}
+
+	warnf("%s\n", tr("amend_pushed", shortHash(hash), strings.Join(branches, ", ")))
+	if f.allowPushedAmend || f.dryRun {
+		return nil
+	}
+	if !interactive() {
+		return errors.New(tr("amend_pushed_blocked"))
+	}
+	if !confirm(tr("amend_pushed_question")) {
+		return errors.New(tr("aborted"))
+	}
+	return nil
+}
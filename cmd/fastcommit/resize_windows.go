@@ -0,0 +1,5 @@
+package main
+
+// watchResize is a no-op on Windows, which has no SIGWINCH. The width is
+// queried once when the display is created.
+func watchResize(fn func()) (stop func()) { return func() {} }
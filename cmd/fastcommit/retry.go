@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// retryBaseDelay is the wait before the first retry, doubled for each
+	// further one.
+	retryBaseDelay = time.Second
+	// maxRetryDelay caps the wait before a retry, including what the
+	// server asks for.
+	maxRetryDelay = 2 * time.Minute
+)
+
+// backoff waits before retrying a request that failed with err, saying why
+// in a status line, and reports whether to retry at all: only rate limits,
+// server errors and dropped connections are retried, at most f.maxRetries
+// times.
+func backoff(ctx context.Context, f flags, err error, attempt int) bool {
+	if attempt >= f.maxRetries || ctx.Err() != nil {
+		return false
+	}
+	var reason string
+	switch status := apiStatus(err); {
+	case status == http.StatusTooManyRequests:
+		reason = tr("retry_rate_limited")
+	case status >= http.StatusInternalServerError:
+		reason = tr("retry_server_error", status)
+	case status == 0 && isNetworkError(err) && !errors.Is(err, syscall.ECONNREFUSED):
+		// A refused connection means nothing is listening, which waiting
+		// rarely fixes.
+		reason = tr("retry_connection")
+	default:
+		return false
+	}
+	debugf("retrying after: %v", err)
+
+	delay, ok := fastcommit.RetryAfter(err)
+	if !ok {
+		// Full jitter keeps several clients from retrying in lockstep.
+		d := retryBaseDelay << attempt
+		delay = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	delay = min(delay, maxRetryDelay)
+	fmt.Fprintf(os.Stderr, "\033[90m%s\n\033[0m", tr("retrying", reason, delay.Round(100*time.Millisecond)))
+	select {
+	case <-time.After(delay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// createWithRetry creates a completion, paced by f.pacer, retrying transient
+// failures as backoff decides.
+func createWithRetry(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	req openai.ChatCompletionRequest,
+) (openai.ChatCompletionResponse, error) {
+	for attempt := 0; ; attempt++ {
+		if err := f.pacer.wait(ctx, fastcommit.CountTokens(req.Messages...)); err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+		sp := traceRun.startSpan("completion", map[string]any{"model": req.Model, "provider": f.provider, "attempt": attempt})
+		resp, err := client.CreateCompletion(ctx, req)
+		traceRun.endSpan(sp, err)
+		if err == nil {
+			f.annotations.observeSpan(sp)
+			f.pacer.observe(resp.GetRateLimitHeaders())
+			f.annotations.observeUsage(resp.Usage)
+			return resp, nil
+		}
+		if !backoff(ctx, f, err, attempt) {
+			return resp, explainAPIError(f, err)
+		}
+	}
+}
+
+// explainAPIError replaces errors that retrying cannot fix with what to do
+// about them: a rejected API key, and a prompt too long for the model. API
+// and connection errors make fastcommit exit with exitAPI.
+func explainAPIError(f flags, err error) error {
+	status := apiStatus(err)
+	switch status {
+	case http.StatusUnauthorized:
+		debugf("%v", err)
+		switch f.provider {
+		case "anthropic":
+			err = errors.New(tr("anthropic_key_rejected", apiEndpoint(f)))
+		case "azure":
+			err = errors.New(tr("azure_key_rejected", apiEndpoint(f)))
+		default:
+			err = errors.New(tr("key_rejected", apiEndpoint(f)))
+		}
+	case http.StatusBadRequest:
+		var apiErr *openai.APIError
+		if errors.As(err, &apiErr) && (apiErr.Code == "context_length_exceeded" ||
+			strings.Contains(apiErr.Message, "maximum context length")) {
+			debugf("%v", err)
+			err = errors.New(tr("context_too_long", f.model))
+		}
+	}
+	if status != 0 || isNetworkError(err) {
+		return &exitError{code: exitAPI, err: err}
+	}
+	return err
+}
+
+// apiStatus returns the HTTP status of an API error, or 0 for other errors.
+func apiStatus(err error) int {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// reviewMessage asks whether to commit msg, generate another one or edit it,
+// until the user accepts one or quits. regenerate is called with the current
+// message and ctx, which is cancelled by Ctrl-C. generated is the last
+// generated message, which differs from accepted if the user edited it.
+func reviewMessage(
+	msg string,
+	regenerate func(ctx context.Context, prev string) (string, error),
+) (accepted, generated string, err error) {
+	// Ctrl-C cancels ctx rather than killing fastcommit, so that temporary
+	// files are removed and nothing is committed; an editor in the
+	// foreground still gets it too.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	defer func() {
+		if ctx.Err() != nil {
+			fmt.Println()
+			accepted, generated = "", ""
+			err = &exitError{code: exitInterrupted, err: errors.New(tr("aborted"))}
+		}
+	}()
+
+	generated = msg
+	for ctx.Err() == nil {
+		fmt.Printf("%s ", tr("review_question"))
+		line, err := readLine(ctx)
+		if ctx.Err() != nil {
+			return "", "", ctx.Err()
+		}
+		if err != nil {
+			fmt.Println()
+			return "", "", errors.New(tr("aborted"))
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "a", "accept", "y", "yes":
+			return msg, generated, nil
+		case "r", "regenerate":
+			alt, err := regenerate(ctx, msg)
+			if err != nil {
+				return "", "", err
+			}
+			msg, generated = alt, alt
+		case "e", "edit":
+			text, err := editMessage(msg)
+			if err != nil {
+				return "", "", err
+			}
+			if text == "" {
+				return "", "", errors.New(tr("empty_message"))
+			}
+			msg = text
+			fmt.Printf("\033[34m%s\033[0m\n", msg)
+		case "q", "quit", "n", "no":
+			return "", "", errors.New(tr("aborted"))
+		}
+	}
+	return "", "", ctx.Err()
+}
+
+// messageEditor returns the editor git uses for commit messages, following
+// $GIT_EDITOR, core.editor, $VISUAL and $EDITOR, unless that is the shim.
+func messageEditor() string {
+	editor, err := gitOutput("var", "GIT_EDITOR")
+	if err != nil || editor == "" || strings.Contains(editor, shimFlag) {
+		return realEditor()
+	}
+	return editor
+}
+
+// alternativePrompt asks for a differently phrased message than prev.
+func alternativePrompt(msgs []openai.ChatCompletionMessage, prev string) []openai.ChatCompletionMessage {
+	return append(msgs[:len(msgs):len(msgs)],
+		openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: prev,
+		},
+		openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleUser,
+			Content: "Produce an alternative phrasing of this commit message. " +
+				"Follow the same instructions, but do not reuse its wording.",
+		},
+	)
+}
+
+// editMessage opens msg in the user's editor and returns the saved message
+// without comment lines and surrounding whitespace.
+func editMessage(msg string) (string, error) {
+	tmp, err := os.CreateTemp("", "fastcommit-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = fmt.Fprintf(tmp, "%s\n\n%s\n", msg, tr("edit_message_help"))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := runEditor(messageEditor(), tmp.Name()); err != nil {
+		return "", fmt.Errorf("run editor: %w", err)
+	}
+	b, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", err
+	}
+	var kept []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			kept = append(kept, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n")), nil
+}
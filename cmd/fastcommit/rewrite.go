@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"al.essio.dev/pkg/shellescape"
+)
+
+// rewrite is a commit of a --range run and the message it gets.
+type rewrite struct {
+	hash    string
+	oldMsg  string
+	newMsg  string
+	skipped bool
+}
+
+// runRewriteRange generates a message for each commit of f.rewriteRange from
+// its own diff and rewrites their messages with git rebase -i, after the user
+// accepted or skipped each one. With --dry, it only prints the old and new
+// messages.
+func runRewriteRange(f flags, cfg config) error {
+	base, tip, ok := strings.Cut(f.rewriteRange, "..")
+	if !ok || base == "" || strings.HasPrefix(tip, ".") {
+		return errors.New(tr("rewrite_range_invalid", f.rewriteRange))
+	}
+	if tip == "" {
+		tip = "HEAD"
+	}
+	head, err := getLastCommitHash()
+	if err != nil {
+		return err
+	}
+	if tipHash, err := resolveRef(tip); err != nil {
+		return err
+	} else if tipHash != head {
+		// Only the current branch can be rewritten in place.
+		return errors.New(tr("rewrite_not_head", tip))
+	}
+	if dirty, err := gitOutput("status", "--porcelain", "--untracked-files=no"); err != nil {
+		return err
+	} else if dirty != "" && !f.dryRun {
+		return errors.New(tr("rewrite_dirty"))
+	}
+
+	rng := base + "..HEAD"
+	if merges, _ := gitOutput("rev-list", "--min-parents=2", rng); merges != "" {
+		return errors.New(tr("rewrite_merges", f.rewriteRange))
+	}
+	out, err := gitOutput("rev-list", "--reverse", "--topo-order", rng)
+	if err != nil {
+		return errors.New(tr("unknown_range", f.rewriteRange))
+	}
+	hashes := strings.Fields(out)
+	if len(hashes) == 0 {
+		return errors.New(tr("empty_range", f.rewriteRange))
+	}
+	if upstream, err := gitOutput("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"); err == nil {
+		for _, hash := range hashes {
+			if exec.Command("git", "merge-base", "--is-ancestor", hash, upstream).Run() == nil {
+				return errors.New(tr("rewrite_pushed", shortHash(hash), upstream))
+			}
+		}
+	}
+
+	workdir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	client := newProvider(f)
+	ask := interactive() && !f.yes && !f.dryRun
+	var rewrites []rewrite
+	for i, hash := range hashes {
+		oldMsg, err := gitOutput("show", "-s", "--format=%B", hash)
+		if err != nil {
+			return err
+		}
+		subject, _ := splitMessage(oldMsg)
+		infof("\n\033[33m%s\033[0m %s (%d/%d)\n", shortHash(hash), subject, i+1, len(hashes))
+
+		p, err := buildPrompt(ctx, client, f, cfg, workdir, hash, nil)
+		if err != nil {
+			return err
+		}
+		disp := newDisplay(os.Stdout, f.plain)
+		f.annotations = &annotations{}
+		msg, err := completeMessage(ctx, client, f, cfg, p, disp)
+		if err := interrupted(ctx); err != nil {
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		f.annotations.report()
+		if msg, err = finishMessage(f, cfg, p, msg); err != nil {
+			return err
+		}
+		disp.Replace(msg)
+
+		rw := rewrite{hash: hash, oldMsg: oldMsg, newMsg: msg}
+		if ask {
+			switch askRewrite() {
+			case 'n':
+				rw.skipped = true
+			case 'q':
+				return errors.New(tr("aborted"))
+			}
+		}
+		rewrites = append(rewrites, rw)
+	}
+
+	if f.dryRun {
+		fmt.Printf("\n%s\n", tr("rewrite_dry"))
+		for _, rw := range rewrites {
+			oldSubject, _ := splitMessage(rw.oldMsg)
+			newSubject, _ := splitMessage(rw.newMsg)
+			fmt.Printf("  \033[33m%s\033[0m %s\n          → %s\n", shortHash(rw.hash), oldSubject, newSubject)
+		}
+		return nil
+	}
+	if err := interrupted(ctx); err != nil {
+		return err
+	}
+	return rebaseMessages(base, rewrites)
+}
+
+// askRewrite asks whether to use a new message, returning 'y', 'n' or 'q'.
+func askRewrite() byte {
+	fmt.Printf("%s ", tr("rewrite_question"))
+	line, err := readLine(context.Background())
+	if err != nil {
+		fmt.Println()
+		return 'q'
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "", "y", "yes":
+		return 'y'
+	case "n", "no", "s", "skip":
+		return 'n'
+	}
+	return 'q'
+}
+
+// rebaseMessages replaces the messages of the accepted rewrites with an
+// interactive rebase onto the commit the range forked off, so that only the
+// messages change. Its todo list amends each accepted commit's message right
+// after picking it.
+func rebaseMessages(base string, rewrites []rewrite) error {
+	tmp, err := os.MkdirTemp("", "fastcommit-range-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	var todo strings.Builder
+	changed := 0
+	for i, rw := range rewrites {
+		fmt.Fprintf(&todo, "pick %s\n", rw.hash)
+		if rw.skipped || rw.newMsg == strings.TrimSpace(rw.oldMsg) {
+			continue
+		}
+		file := filepath.Join(tmp, fmt.Sprintf("msg%d", i))
+		if err := os.WriteFile(file, []byte(rw.newMsg+"\n"), 0o600); err != nil {
+			return err
+		}
+		fmt.Fprintf(&todo, "exec git commit --amend --only --allow-empty --quiet -F %s\n", shellescape.Quote(file))
+		changed++
+	}
+	if changed == 0 {
+		fmt.Println(tr("rewrite_nothing"))
+		return nil
+	}
+	todoFile := filepath.Join(tmp, "todo")
+	if err := os.WriteFile(todoFile, []byte(todo.String()), 0o600); err != nil {
+		return err
+	}
+
+	forkPoint, err := gitOutput("merge-base", base, "HEAD")
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("git", "rebase", "--interactive", "--keep-empty", forkPoint)
+	// git runs the sequence editor with the todo list's path appended, so
+	// copying ours over it replaces the list.
+	cmd.Env = append(os.Environ(), "GIT_SEQUENCE_EDITOR=cp "+shellescape.Quote(todoFile))
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return &exitError{code: exitGit, err: fmt.Errorf("%s: %w", tr("rewrite_rebase_failed"), err)}
+	}
+	fmt.Println(tr("rewrite_done", changed, len(rewrites)))
+	return nil
+}
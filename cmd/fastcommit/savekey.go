@@ -2,8 +2,10 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 func configDir() (string, error) {
@@ -18,6 +20,8 @@ func configDir() (string, error) {
 	return filepath.Join(cdir, "fastcommit"), nil
 }
 
+// keyPath is where --save-key used to store the key, before it went into
+// config.toml.
 func keyPath() (string, error) {
 	cdir, err := configDir()
 	if err != nil {
@@ -26,25 +30,82 @@ func keyPath() (string, error) {
 	return filepath.Join(cdir, "openai.key"), nil
 }
 
-func saveKey(key string) error {
-	if key == "" {
-		return errors.New("key is empty")
+// saveKey stores the key of f's provider in config.toml: as
+// anthropic_api_key for Anthropic, as azure_api_key for Azure OpenAI and as
+// api_key otherwise.
+func saveKey(f flags) error {
+	name, key := "api_key", f.openAIKey
+	switch f.provider {
+	case "anthropic":
+		name, key = "anthropic_api_key", f.anthropicKey
+	case "azure":
+		name, key = "azure_api_key", f.azureKey
 	}
-	kp, err := keyPath()
-	if err != nil {
-		return err
+	if key == "" {
+		return errors.New(tr("empty_key"))
 	}
-	return os.WriteFile(kp, []byte(key), 0o600)
+	return setConfigValue(name, []string{key})
 }
 
-func loadKey() (string, error) {
+// migrateKeyFile moves a key saved by an older version into config.toml,
+// unless config.toml has a key already, and renames the old file to
+// openai.key.migrated so that it is not read again. It reports whether
+// config.toml changed. A file not holding a key is left alone with a
+// warning.
+func migrateKeyFile() (bool, error) {
 	kp, err := keyPath()
 	if err != nil {
-		return "", err
+		return false, err
 	}
 	b, err := os.ReadFile(kp)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
 	if err != nil {
-		return "", err
+		return false, err
+	}
+	key := strings.TrimSpace(string(b))
+	if !validKey(key) {
+		warnf("%s\n", tr("key_file_invalid", kp))
+		return false, nil
+	}
+	cp, err := configPath()
+	if err != nil {
+		return false, err
+	}
+	// Leniently: an unknown key elsewhere in the file is no reason to keep
+	// the old file around.
+	c, _, err := readConfig(cp, true)
+	if err != nil {
+		return false, err
+	}
+	changed := c.APIKey == ""
+	if changed {
+		if err := setConfigValue("api_key", []string{key}); err != nil {
+			return false, fmt.Errorf("migrate %s: %w", kp, err)
+		}
+	}
+	if err := os.Rename(kp, kp+".migrated"); err != nil {
+		return changed, err
+	}
+	if changed {
+		infof("%s\n", tr("key_migrated", kp, cp))
+	} else {
+		infof("%s\n", tr("key_superseded", kp, cp))
+	}
+	return changed, nil
+}
+
+// validKey reports whether key could be an API key: a single word of
+// printable ASCII.
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r <= ' ' || r > '~' {
+			return false
+		}
 	}
-	return string(b), nil
+	return true
 }
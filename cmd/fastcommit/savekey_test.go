@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// configHome points the user config directory at a new temporary one and
+// returns its fastcommit directory.
+func configHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	dir := filepath.Join(home, "fastcommit")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestMigrateKeyFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		legacy     string // the content of openai.key, if any
+		config     string // the content of config.toml, if any
+		wantKey    string
+		migrated   bool
+		wantLegacy bool // whether openai.key is left in place
+	}{
+		{name: "legacy only", legacy: "sk-legacy\n", wantKey: "sk-legacy", migrated: true},
+		{name: "new only", config: "api_key = \"sk-new\"\n", wantKey: "sk-new"},
+		{name: "both", legacy: "sk-legacy\n", config: "api_key = \"sk-new\"\n", wantKey: "sk-new"},
+		{name: "corrupted legacy", legacy: "sk-\x00\x01 garbage\n", wantLegacy: true},
+		{name: "empty legacy", legacy: "\n", config: "api_key = \"sk-new\"\n", wantKey: "sk-new", wantLegacy: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := configHome(t)
+			kp := filepath.Join(dir, "openai.key")
+			if tt.legacy != "" {
+				if err := os.WriteFile(kp, []byte(tt.legacy), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cp := filepath.Join(dir, "config.toml")
+			if tt.config != "" {
+				if err := os.WriteFile(cp, []byte(tt.config), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			migrated, err := migrateKeyFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrated != tt.migrated {
+				t.Errorf("migrated = %v, want %v", migrated, tt.migrated)
+			}
+			c, _, err := readConfig(cp, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.APIKey != tt.wantKey {
+				t.Errorf("api_key = %q, want %q", c.APIKey, tt.wantKey)
+			}
+			_, err = os.Stat(kp)
+			if legacy := err == nil; legacy != tt.wantLegacy {
+				t.Errorf("openai.key present = %v, want %v", legacy, tt.wantLegacy)
+			}
+			if tt.legacy != "" && !tt.wantLegacy {
+				if _, err := os.Stat(kp + ".migrated"); err != nil {
+					t.Errorf("openai.key.migrated: %v", err)
+				}
+			}
+
+			// Migrating again changes nothing.
+			if migrated, err := migrateKeyFile(); migrated || err != nil {
+				t.Errorf("second migrateKeyFile = %v, %v", migrated, err)
+			}
+		})
+	}
+}
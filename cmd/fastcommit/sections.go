@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// bodySection is a labeled paragraph required in the message body.
+type bodySection struct {
+	Key   string
+	Label string
+}
+
+// parseBodySections parses the --body-sections value: a comma-separated list
+// of section names, each optionally followed by "=Label". "none" asks for a
+// subject line only and yields an empty, non-nil list.
+func parseBodySections(v string) ([]bodySection, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if v == "none" {
+		return []bodySection{}, nil
+	}
+	var sections []bodySection
+	for _, item := range strings.Split(v, ",") {
+		key, label, ok := strings.Cut(strings.TrimSpace(item), "=")
+		key, label = strings.TrimSpace(key), strings.TrimSpace(label)
+		if key == "" || (ok && label == "") {
+			return nil, errors.New(tr("invalid_body_section", item))
+		}
+		if !ok {
+			label = strings.ToUpper(key[:1]) + key[1:]
+		}
+		sections = append(sections, bodySection{Key: key, Label: strings.TrimSuffix(label, ":")})
+	}
+	return sections, nil
+}
+
+// bodySectionsMessage tells the model how to structure the body.
+func bodySectionsMessage(sections []bodySection) openai.ChatCompletionMessage {
+	if len(sections) == 0 {
+		return openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "Write only the subject line, without a body.",
+		}
+	}
+	var labels []string
+	for _, s := range sections {
+		labels = append(labels, fmt.Sprintf("%q", s.Label+":"))
+	}
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: "Structure the body as the following sections, in this order: " +
+			strings.Join(labels, ", ") + ". Start each section with its label alone on a line, " +
+			"followed by its paragraph, and separate sections with a blank line. " +
+			"Write the labels as plain text exactly as given, not as Markdown headings.",
+	}
+}
+
+// bodyMessage asks for a body of bullets explaining the change, which the
+// generic style guide only wants for complex changes.
+func bodyMessage(f flags) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: fmt.Sprintf("Always write a body, even if the style guide says otherwise: the subject line, "+
+			"a blank line, then bullet points starting with \"- \" that explain why the change was made. "+
+			"Wrap them at %d characters and use no other Markdown.", bodyWidth(f)),
+	}
+}
+
+// bodyWidth returns the column the body is wrapped at.
+func bodyWidth(f flags) int {
+	if f.bodyWidth == 0 {
+		return fastcommit.DefaultBodyWidth
+	}
+	return f.bodyWidth
+}
+
+// missingSections returns the labels of the sections msg's body lacks.
+func missingSections(msg string, sections []bodySection) []string {
+	_, body := splitMessage(msg)
+	var missing []string
+	for _, s := range sections {
+		prefix := strings.ToLower(s.Label + ":")
+		found := false
+		for _, line := range strings.Split(body, "\n") {
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, s.Label)
+		}
+	}
+	return missing
+}
+
+// checkBodySections makes msg follow f.bodySections. A message lacking
+// sections is regenerated once; one that still lacks them is used as is, with
+// a warning. For "none", the body is dropped.
+func checkBodySections(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	msgs []openai.ChatCompletionMessage,
+	msg string,
+) (string, error) {
+	if f.bodySections == nil {
+		return msg, nil
+	}
+	if len(f.bodySections) == 0 {
+		subject, _ := splitMessage(msg)
+		return subject, nil
+	}
+	missing := missingSections(msg, f.bodySections)
+	if len(missing) == 0 {
+		return msg, nil
+	}
+	verbosef("message lacks the sections %q, regenerating", missing)
+	retry := append(msgs[:len(msgs):len(msgs)],
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: msg},
+		openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: fmt.Sprintf("The body lacks the sections %q. Write the whole message again with every section.", missing),
+		},
+	)
+	// Not streamed; the display is updated with the final message afterwards.
+	again, err := generateMessage(ctx, client, f, retry, &rawDisplay{w: io.Discard})
+	if err != nil {
+		return "", err
+	}
+	if missing := missingSections(again, f.bodySections); len(missing) > 0 {
+		warnf("%s\n", tr("sections_missing", strings.Join(missing, ", ")))
+	}
+	return again, nil
+}
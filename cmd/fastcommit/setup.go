@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"golang.org/x/term"
+)
+
+// setupProviders are the backends the setup wizard offers. Other compatible
+// APIs use the openai provider with their own base URL.
+var setupProviders = []struct {
+	name, provider, baseURL string
+}{
+	{"OpenAI", "openai", ""},
+	{"Azure OpenAI", "azure", "https://<resource>.openai.azure.com"},
+	{"Anthropic", "anthropic", ""},
+	{"Ollama", "ollama", ""},
+	{"other", "openai", "https://llm.example.com/v1"},
+}
+
+// setupModels are offered when the backend's models cannot be listed.
+var setupModels = map[string][]string{
+	"openai":    {"gpt-4o-2024-08-06", "gpt-4o-mini", "gpt-4.1", "gpt-4.1-mini"},
+	"anthropic": {fastcommit.DefaultAnthropicModel, fastcommit.DefaultAnthropicSmallModel},
+	"ollama":    {fastcommit.DefaultOllamaModel, "qwen2.5-coder", "mistral"},
+}
+
+// maxSetupModels is the number of listed models the wizard offers.
+const maxSetupModels = 12
+
+// firstRun reports whether to offer the setup wizard before a command: when
+// there is no key and no config file, and the user can be asked.
+func firstRun(f flags) bool {
+	if canGenerate(f) || !interactive() || inCI() {
+		return false
+	}
+	cp, err := configPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(cp)
+	return os.IsNotExist(err)
+}
+
+// runSetup asks for the provider, key, model and whether to use
+// Conventional Commits, and saves the answers to config.toml. Every question
+// can be skipped with Enter, which leaves its setting out. The models are
+// listed from the chosen backend, reached as f would reach it otherwise.
+func runSetup(f flags) error {
+	if !interactive() {
+		return errors.New(tr("setup_not_interactive"))
+	}
+	var settings [][2]string
+	set := func(key, value string) { settings = append(settings, [2]string{key, value}) }
+
+	fmt.Println(tr("setup_intro"))
+
+	fmt.Printf("\n%s\n", tr("setup_provider"))
+	for i, p := range setupProviders {
+		name := p.name
+		if p.name == "other" {
+			name = tr("setup_other")
+		}
+		fmt.Printf("  %d) %s\n", i+1, name)
+	}
+	provider, baseURL := "openai", ""
+	if n, ok := askChoice(len(setupProviders)); ok {
+		p := setupProviders[n]
+		provider = p.provider
+		set("provider", provider)
+		if p.baseURL != "" {
+			if url := askLine(tr("setup_base_url", p.baseURL)); url != "" {
+				baseURL = url
+				set("base_url", url)
+			}
+		}
+	}
+
+	key := ""
+	if provider != "ollama" {
+		fmt.Printf("\n%s ", tr("setup_key"))
+		b, err := readPassword()
+		fmt.Println()
+		if err != nil {
+			return err
+		}
+		switch key = strings.TrimSpace(string(b)); {
+		case key == "":
+		case provider == "anthropic":
+			set("anthropic_api_key", key)
+		case provider == "azure":
+			set("azure_api_key", key)
+		default:
+			set("api_key", key)
+		}
+	}
+
+	if baseURL != "" {
+		f.openAIBaseURL = baseURL
+	}
+	f.provider, f.openAIKey, f.anthropicKey, f.azureKey = provider, key, key, key
+	models := listSetupModels(f)
+	fmt.Printf("\n%s\n", tr("setup_model"))
+	for i, m := range models {
+		fmt.Printf("  %d) %s\n", i+1, m)
+	}
+	for {
+		line := askLine(tr("setup_model_prompt"))
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			if line != "" {
+				set("model", line)
+			}
+			break
+		}
+		if n >= 1 && n <= len(models) {
+			set("model", models[n-1])
+			break
+		}
+	}
+
+	fmt.Println()
+	switch strings.ToLower(askLine(tr("setup_conventional"))) {
+	case "y", "yes":
+		set("conventional.enabled", "true")
+	case "n", "no":
+		set("conventional.enabled", "false")
+	}
+
+	if len(settings) == 0 {
+		fmt.Printf("\n%s\n", tr("setup_nothing"))
+		return nil
+	}
+	for _, s := range settings {
+		if err := setConfigValue(s[0], []string{s[1]}); err != nil {
+			return err
+		}
+	}
+	cp, err := configPath()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%s\n\n", tr("setup_saved", cp))
+	return nil
+}
+
+// askLine prints question and returns the trimmed answer, empty if skipped.
+func askLine(question string) string {
+	fmt.Printf("%s ", question)
+	line, err := readLine(context.Background())
+	if err != nil {
+		fmt.Println()
+	}
+	return strings.TrimSpace(line)
+}
+
+// askChoice asks for one of n numbered options and returns its index, or
+// false if the question was skipped.
+func askChoice(n int) (int, bool) {
+	for {
+		line := askLine(tr("setup_choice", n))
+		if line == "" {
+			return 0, false
+		}
+		if i, err := strconv.Atoi(line); err == nil && i >= 1 && i <= n {
+			return i - 1, true
+		}
+	}
+}
+
+// listSetupModels returns the chat models the backend serves, or the
+// built-in suggestions when they cannot be listed, e.g. without a key. Azure
+// OpenAI lists its base models rather than the deployments requests go to,
+// so for it the deployment's name is asked for instead.
+func listSetupModels(f flags) []string {
+	if !canGenerate(f) || f.provider == "azure" {
+		return setupModels[f.provider]
+	}
+	if f.provider == "ollama" && f.ollamaURL == "" {
+		f.ollamaURL = os.Getenv("OLLAMA_HOST")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ids, err := newProvider(f).ListModels(ctx)
+	if err != nil {
+		debugf("listing models: %v", err)
+		return setupModels[f.provider]
+	}
+	var models []string
+	for _, id := range ids {
+		if chatModel(id) {
+			models = append(models, id)
+		}
+	}
+	if len(models) == 0 {
+		return setupModels[f.provider]
+	}
+	slices.Sort(models)
+	return models[:min(len(models), maxSetupModels)]
+}
+
+// chatModel reports whether the model id can write messages, leaving out
+// the embedding, speech, image and moderation models OpenAI lists too.
+func chatModel(id string) bool {
+	for _, s := range []string{"embed", "whisper", "tts", "dall-e", "audio", "realtime",
+		"moderation", "transcribe", "image", "search", "babbage", "davinci"} {
+		if strings.Contains(id, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// readPassword reads a line from the terminal without echoing it.
+func readPassword() ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	restore, err := terminal.save(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	return term.ReadPassword(fd)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// shimFlag is the flag that makes fastcommit act as git's editor.
+const shimFlag = "--editor-shim"
+
+// runEditorShim is run when git invokes fastcommit as its editor, e.g. with
+// GIT_EDITOR="fastcommit --editor-shim". For the message of a commit being
+// reworded during an interactive rebase, it writes a generated message to
+// file. Every other file, such as the rebase todo list or the message of an
+// ordinary commit, goes to the real editor untouched, so the shim is safe to
+// set globally.
+func runEditorShim(f flags, cfg config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(tr("shim_usage"))
+	}
+	file := args[0]
+
+	hash, ok := rewordCommit(file)
+	if !ok || !canGenerate(f) {
+		return runRealEditor(file)
+	}
+	f.annotations = &annotations{}
+	if err := writeRewordMessage(f, cfg, file, hash); err != nil {
+		// Leave the original message in place rather than failing the
+		// rebase; the user can still write one by hand.
+		warnf("%s\n", tr("shim_failed", err))
+		return runRealEditor(file)
+	}
+	if f.annotations.Altered {
+		// Have the user review what the content filter left of it.
+		warnf("%s\n", tr("content_filtered", strings.Join(f.annotations.Filters, "; ")))
+		return runRealEditor(file)
+	}
+	if f.thenEdit {
+		return runRealEditor(file)
+	}
+	return nil
+}
+
+// rewordCommit returns the commit being reworded if file is the commit
+// message git asks for while rewording during an interactive rebase.
+func rewordCommit(file string) (string, bool) {
+	if filepath.Base(file) != "COMMIT_EDITMSG" {
+		return "", false
+	}
+	donePath, err := gitOutput("rev-parse", "--git-path", "rebase-merge/done")
+	if err != nil {
+		return "", false
+	}
+	done, err := os.ReadFile(donePath)
+	if err != nil {
+		return "", false
+	}
+	// The last line of the done list is the command being carried out.
+	lines := strings.Split(strings.TrimSpace(string(done)), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 2 || (fields[0] != "reword" && fields[0] != "r") {
+		return "", false
+	}
+	hash, err := resolveRef(fields[1])
+	if err != nil {
+		return "", false
+	}
+	return hash, true
+}
+
+// writeRewordMessage generates a message for the changes of hash and writes
+// it to file, keeping git's comment lines below it.
+func writeRewordMessage(f flags, cfg config, file, hash string) error {
+	workdir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	orig, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client := newProvider(f)
+	p, err := buildPrompt(ctx, client, f, cfg, workdir, hash, nil)
+	if err != nil {
+		return err
+	}
+	disp := newDisplay(os.Stdout, f.plain)
+	msg, err := completeMessage(ctx, client, f, cfg, p, disp)
+	if err != nil {
+		return err
+	}
+	f.annotations.report()
+	msg, err = finishMessage(f, cfg, p, msg)
+	if err != nil {
+		return err
+	}
+	disp.Replace(msg)
+
+	var comments []string
+	for _, line := range strings.Split(string(orig), "\n") {
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, line)
+		}
+	}
+	out := msg + "\n"
+	if len(comments) > 0 {
+		out += "\n" + strings.Join(comments, "\n") + "\n"
+	}
+	return os.WriteFile(file, []byte(out), 0o644)
+}
+
+// runRealEditor opens file in the editor git would use if fastcommit were not
+// set as the editor.
+func runRealEditor(file string) error {
+	return runEditor(realEditor(), file)
+}
+
+// runEditor opens file in editor, in the same way git runs editors.
+func runEditor(editor, file string) error {
+	if editor == ":" {
+		// git's way of saying "do not edit".
+		return nil
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = shellCommand(context.Background(), editor+` "`+file+`"`)
+	} else {
+		cmd = shellCommand(context.Background(), editor+` "$@"`)
+		cmd.Args = append(cmd.Args, editor, file)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// realEditor returns the first editor setting that is not the shim itself,
+// following git's order of precedence after $GIT_EDITOR.
+func realEditor() string {
+	coreEditor, _ := gitOutput("config", "core.editor")
+	for _, editor := range []string{coreEditor, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if editor != "" && !strings.Contains(editor, shimFlag) {
+			return editor
+		}
+	}
+	if _, err := exec.LookPath("editor"); err == nil {
+		return "editor"
+	}
+	return "vi"
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// repoSnapshot records the state that a generated message describes.
+type repoSnapshot struct {
+	// head is empty on an unborn branch.
+	head string
+	// tree is the tree object of the index, as written by git write-tree,
+	// or with all, of what git commit --all would commit.
+	tree string
+	all  bool
+}
+
+func takeSnapshot(all bool) (repoSnapshot, error) {
+	snap := repoSnapshot{all: all}
+	// An error here means there are no commits yet.
+	snap.head, _ = getLastCommitHash()
+
+	cmd := exec.Command("git", "write-tree")
+	if all {
+		// The changes to tracked files are added to a copy of the index,
+		// leaving the index itself alone.
+		tmp, err := os.MkdirTemp("", "fastcommit-index-")
+		if err != nil {
+			return snap, err
+		}
+		defer os.RemoveAll(tmp)
+		index := filepath.Join(tmp, "index")
+		if err := copyIndex(index); err != nil {
+			return snap, err
+		}
+		cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+index)
+		add := exec.Command("git", "add", "--update")
+		add.Env = cmd.Env
+		if output, err := add.CombinedOutput(); err != nil {
+			return snap, fmt.Errorf("add tracked changes: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return snap, fmt.Errorf("write index tree: %w", err)
+	}
+	snap.tree = strings.TrimSpace(string(output))
+	return snap, nil
+}
+
+// copyIndex copies the index to path. An unborn repository may have no
+// index yet, which leaves path absent for git to start from empty.
+func copyIndex(path string) error {
+	index, err := gitOutput("rev-parse", "--git-path", "index")
+	if err != nil {
+		return fmt.Errorf("locate the index: %w", err)
+	}
+	b, err := os.ReadFile(index)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// checkSnapshot verifies that HEAD and the index, or with --all the tracked
+// files, still match snap. If they do not, it describes the change and, when
+// interactive, offers to regenerate the message. It returns true if the
+// caller should regenerate.
+func checkSnapshot(snap repoSnapshot) (bool, error) {
+	now, err := takeSnapshot(snap.all)
+	if err != nil {
+		return false, err
+	}
+	if now == snap {
+		return false, nil
+	}
+
+	warnf("%s\n", tr("repo_changed"))
+	if now.head != snap.head {
+		fmt.Fprintf(os.Stderr, "  HEAD: %s -> %s\n", shortHash(snap.head), shortHash(now.head))
+	}
+	if now.tree != snap.tree {
+		if snap.all {
+			fmt.Fprintln(os.Stderr, "  "+tr("worktree_changed"))
+		} else {
+			fmt.Fprintln(os.Stderr, "  "+tr("index_changed"))
+		}
+		cmd := exec.Command("git", "diff", "--stat", snap.tree, now.tree)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		_ = cmd.Run()
+	}
+
+	if !interactive() {
+		return false, &exitError{code: exitStale, err: errors.New(tr("stale_message"))}
+	}
+	if confirm(tr("regenerate_question")) {
+		return true, nil
+	}
+	return false, errors.New(tr("aborted"))
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestSnapshotAll(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "README.md", "# test\n\nStaged.\n")
+	gitT(t, dir, "add", "README.md")
+
+	take := func(all bool) repoSnapshot {
+		t.Helper()
+		snap, err := takeSnapshot(all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snap
+	}
+	index, all := take(false), take(true)
+	if index.tree != all.tree {
+		t.Errorf("with nothing unstaged, --all snapshots %s rather than the index tree %s", all.tree, index.tree)
+	}
+
+	// An edit to a tracked file changes what --all commits, not the index.
+	writeFile(t, dir, "README.md", "# test\n\nStaged.\nUnstaged.\n")
+	if now := take(true); now.tree == all.tree {
+		t.Error("the --all snapshot missed an unstaged change")
+	}
+	if now := take(false); now.tree != index.tree {
+		t.Error("the index snapshot changed with the working tree")
+	}
+	if out := gitT(t, dir, "diff", "--cached", "--name-only"); out != "README.md" {
+		t.Errorf("taking the snapshot changed the index: staged %q", out)
+	}
+	if out := gitT(t, dir, "diff", "--name-only"); out != "README.md" {
+		t.Errorf("taking the snapshot staged the working tree: unstaged %q", out)
+	}
+
+	// Untracked files are left out, as git commit --all leaves them.
+	writeFile(t, dir, "notes.txt", "todo\n")
+	before := take(true)
+	writeFile(t, dir, "notes.txt", "done\n")
+	if now := take(true); now.tree != before.tree {
+		t.Error("the --all snapshot covers untracked files")
+	}
+}
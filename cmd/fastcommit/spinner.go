@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// spinnerDelay is how long a response may take before the spinner shows, so
+// that fast responses do not flicker.
+const spinnerDelay = 300 * time.Millisecond
+
+// spinner shows on stderr that fastcommit is waiting for the model, and for
+// how long so far, until it is stopped. A nil *spinner shows nothing.
+type spinner struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// startSpinner starts a spinner for a request to model, unless stderr is not
+// a terminal or -v and --debug write their lines there.
+func startSpinner(model string) *spinner {
+	if !isTerminal(os.Stderr) || verbose || debugMode || os.Getenv("TERM") == "dumb" {
+		return nil
+	}
+	s := &spinner{stop: make(chan struct{}), done: make(chan struct{})}
+	go s.run(model)
+	return s
+}
+
+func (s *spinner) run(model string) {
+	defer close(s.done)
+	start := time.Now()
+	select {
+	case <-s.stop:
+		return
+	case <-time.After(spinnerDelay):
+	}
+	frames := []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	terminal.hideCursor()
+	for i := 0; ; i++ {
+		terminal.writeLine(fmt.Sprintf("\033[90m%c %s",
+			frames[i%len(frames)], tr("waiting_for_model", model, int(time.Since(start).Seconds()))))
+		select {
+		case <-s.stop:
+			terminal.clearLine()
+			terminal.showCursor()
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// Stop removes the spinner, returning once its line is cleared.
+func (s *spinner) Stop() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxSplitGroups is the largest number of separate commits --all offers.
+// Changes spread over more areas than that are rarely unrelated.
+const maxSplitGroups = 4
+
+// offerSplit asks whether changes that fall into a few separate components
+// should be committed separately, and does so if the user agrees. done is
+// false when the changes should be committed together as usual.
+//
+// Files are never split between commits; a file with several unrelated
+// hunks stays in a single group.
+func offerSplit(
+	ctx context.Context,
+	client fastcommit.Provider,
+	f flags,
+	cfg config,
+	workdir string,
+	extra []openai.ChatCompletionMessage,
+) (done bool, err error) {
+	paths, err := fastcommit.ChangedPaths(workdir, promptOptions(f, ""))
+	if err != nil {
+		return false, fmt.Errorf("list changed paths: %w", err)
+	}
+	groups := fastcommit.GroupByComponent(paths)
+	if len(groups) < 2 || len(groups) > maxSplitGroups {
+		return false, nil
+	}
+
+	infof("%s\n", tr("split_groups", len(groups)))
+	for _, g := range groups {
+		quoted := make([]string, len(g.Paths))
+		for i, p := range g.Paths {
+			quoted[i] = fastcommit.QuotePath(p)
+		}
+		infof("  %s: %s\n", fastcommit.QuotePath(g.Component), strings.Join(quoted, ", "))
+	}
+	if !confirm(tr("split_question", len(groups))) {
+		return false, nil
+	}
+
+	var planned []*exec.Cmd
+	for i, g := range groups {
+		infof("\n%s\n", tr("split_commit", i+1, len(groups), g.Component))
+		gf := f
+		gf.paths = g.Paths
+		p, err := buildPrompt(ctx, client, gf, cfg, workdir, "", extra)
+		if err != nil {
+			return true, err
+		}
+		disp := messageDisplay(f)
+		gf.annotations = &annotations{}
+		msg, err := completeMessage(ctx, client, gf, cfg, p, disp)
+		if err != nil {
+			return true, err
+		}
+		gf.annotations.report()
+		msg, err = finishMessage(gf, cfg, p, msg)
+		if err != nil {
+			return true, err
+		}
+		disp.Replace(msg)
+		cmd := commitCommand(gf, msg)
+		if f.dryRun {
+			planned = append(planned, cmd)
+			continue
+		}
+		if err := gf.annotations.confirmAltered(); err != nil {
+			return true, err
+		}
+		if err := runCommit(cmd); err != nil {
+			return true, err
+		}
+		recordCommit(msg)
+		learnFromEdit(gf, msg)
+		printCommitSummary("")
+	}
+
+	if f.dryRun {
+		fmt.Println(tr("run_to_commit_split"))
+		for _, cmd := range planned {
+			fmt.Println(formatShellCommand(cmd))
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stateScope selects which git dir a state file lives in.
+type stateScope int
+
+const (
+	// worktreeScope is for state tied to one worktree, such as anything that
+	// refers to its HEAD. It lives in the worktree's private git dir.
+	worktreeScope stateScope = iota
+	// repoScope is for state shared by all worktrees of a repository. It
+	// lives in the common git dir.
+	repoScope
+)
+
+// repoState is fastcommit's per-worktree state. It lives in the git dir so it
+// never shows up in the working tree.
+type repoState struct {
+	// LastCommit records the most recent commit created by fastcommit.
+	LastCommit *generatedCommit `json:"last_commit,omitempty"`
+	// Pending lists the commits made by `fastcommit wip` with a placeholder
+	// message, oldest first.
+	Pending []pendingCommit `json:"pending,omitempty"`
+	// Unlanded is the message of the last new commit that git commit
+	// failed to create, until the next commit is made.
+	Unlanded *unlandedCommit `json:"unlanded,omitempty"`
+}
+
+// generatedCommit is a commit whose message fastcommit generated.
+type generatedCommit struct {
+	Hash    string    `json:"hash"`
+	Tree    string    `json:"tree"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// stateDir returns the directory holding fastcommit's state files for scope.
+func stateDir(scope stateScope) (string, error) {
+	arg := "--absolute-git-dir"
+	if scope == repoScope {
+		arg = "--git-common-dir"
+	}
+	output, err := exec.Command("git", "rev-parse", arg).Output()
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.Abs(strings.TrimSpace(string(output)))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "fastcommit"), nil
+}
+
+// readStateFile decodes the state file name into v while holding a shared
+// lock, so it never observes a half-finished update. A missing file leaves v
+// unchanged.
+func readStateFile(scope stateScope, name string, v any) error {
+	dir, err := stateDir(scope)
+	if err != nil {
+		return err
+	}
+	sp := filepath.Join(dir, name)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	unlock, err := lockFile(sp, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return decodeStateFile(sp, v)
+}
+
+// updateStateFile decodes the state file name into v, calls fn to modify it
+// and writes the result back. An exclusive lock is held throughout, so
+// concurrent runs never lose each other's updates.
+func updateStateFile(scope stateScope, name string, v any, fn func()) error {
+	dir, err := stateDir(scope)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	sp := filepath.Join(dir, name)
+	unlock, err := lockFile(sp, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := decodeStateFile(sp, v); err != nil {
+		// A corrupt file is replaced rather than blocking every future run.
+		debugf("%s: %v", sp, err)
+	}
+	fn()
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a truncated
+	// state file behind.
+	tmp := sp + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, sp)
+}
+
+func decodeStateFile(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// loadState reads the per-worktree state. A missing file yields the zero
+// state.
+func loadState() (repoState, error) {
+	var s repoState
+	err := readStateFile(worktreeScope, "state.json", &s)
+	return s, err
+}
+
+// updateState applies fn to the per-worktree state under an exclusive lock.
+func updateState(fn func(*repoState)) error {
+	var s repoState
+	return updateStateFile(worktreeScope, "state.json", &s, func() { fn(&s) })
+}
@@ -0,0 +1,109 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const (
+	stressProcesses  = 4
+	stressGoroutines = 4
+	stressUpdates    = 25
+)
+
+type counterState struct {
+	N int `json:"n"`
+}
+
+// stressState increments the counter in the repository's state directory
+// from several goroutines, and checks that readers only ever see a whole
+// file.
+func stressState() error {
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*stressGoroutines)
+	for i := 0; i < stressGoroutines; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < stressUpdates; j++ {
+				var s counterState
+				if err := updateStateFile(repoScope, "counter.json", &s, func() { s.N++ }); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < stressUpdates; j++ {
+				var s counterState
+				if err := readStateFile(repoScope, "counter.json", &s); err != nil {
+					errs <- fmt.Errorf("read: %w", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// TestStateHelperProcess is run by TestStateConcurrentWriters in other
+// processes.
+func TestStateHelperProcess(t *testing.T) {
+	if os.Getenv("FASTCOMMIT_STATE_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	if err := stressState(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStateConcurrentWriters(t *testing.T) {
+	dir := newRepo(t)
+	var wg sync.WaitGroup
+	out := make([][]byte, stressProcesses)
+	errs := make([]error, stressProcesses)
+	for i := range out {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestStateHelperProcess$")
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "FASTCOMMIT_STATE_HELPER=1")
+			out[i], errs[i] = cmd.CombinedOutput()
+		}(i)
+	}
+	// This process writes too.
+	if err := stressState(); err != nil {
+		t.Error(err)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("helper %d: %v\n%s", i, err, out[i])
+		}
+	}
+
+	var s counterState
+	if err := readStateFile(repoScope, "counter.json", &s); err != nil {
+		t.Fatal(err)
+	}
+	if want := (stressProcesses + 1) * stressGoroutines * stressUpdates; s.N != want {
+		t.Errorf("counter = %d after %d updates; updates were lost", s.N, want)
+	}
+	sd, err := stateDir(repoScope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(sd, "*.tmp")); len(tmp) > 0 {
+		t.Errorf("temporary files left behind: %q", tmp)
+	}
+}
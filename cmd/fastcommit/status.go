@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// statusMaxNames is how many files of each kind the status context names.
+	statusMaxNames = 5
+	// statusMaxTokens caps the status context in the prompt.
+	statusMaxTokens = 150
+)
+
+// statusContext returns a prompt message summarizing the working tree
+// changes that are not part of the commit, so the model does not present a
+// partial change as complete. ok is false when there is nothing to report.
+func statusContext(f flags, workdir string) (msg openai.ChatCompletionMessage, ok bool, err error) {
+	st, err := fastcommit.Status(workdir)
+	if err != nil {
+		return msg, false, fmt.Errorf("git status: %w", err)
+	}
+	if f.preview || f.all {
+		// These are part of the diff already.
+		st.Unstaged = nil
+		if f.includeUntracked {
+			st.Untracked = nil
+		}
+	}
+	if st.Empty() {
+		return msg, false, nil
+	}
+
+	names := statusMaxNames
+	if f.redactPaths {
+		// Only the changed paths get placeholders, so other names would leak.
+		names = 0
+	}
+	summary := st.Summary(names)
+	verbosef("status context:\n%s", summary)
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: "The working tree has further changes that are NOT part of this commit (contents not shown). " +
+			"Do not describe them, but if they look related to the commit, do not present the change as " +
+			"complete, e.g. say it is a first part with the rest to follow:\n" +
+			fastcommit.Ellipse(summary, statusMaxTokens),
+	}, true, nil
+}
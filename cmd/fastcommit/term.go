@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// interactive reports whether the user can be asked questions.
+func interactive() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// stdin buffers what the user types for every question asked. A reader per
+// question would lose whatever it buffered past its own answer, such as the
+// answers to the next questions when they are piped in.
+var stdin = bufio.NewReader(os.Stdin)
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+var stdinMu sync.Mutex
+
+// pendingLine is the read of a readLine call that was cancelled, whose line
+// is the answer to the next question.
+var pendingLine chan lineResult
+
+// readLine reads a line from stdin, including its newline, or returns
+// ctx.Err() when ctx is cancelled first.
+func readLine(ctx context.Context) (string, error) {
+	stdinMu.Lock()
+	defer stdinMu.Unlock()
+	ch := pendingLine
+	pendingLine = nil
+	if ch == nil {
+		ch = make(chan lineResult, 1)
+		go func() {
+			line, err := stdin.ReadString('\n')
+			ch <- lineResult{line, err}
+		}()
+	}
+	select {
+	case r := <-ch:
+		return r.line, r.err
+	case <-ctx.Done():
+		pendingLine = ch
+		return "", ctx.Err()
+	}
+}
+
+// confirm asks a yes/no question on stdout and defaults to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	line, err := readLine(context.Background())
+	if err != nil {
+		fmt.Println()
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// readKey reads a single keypress from stdin without waiting for Enter. It
+// reports false if stdin cannot be put into raw mode.
+func readKey() (byte, bool) {
+	fd := int(os.Stdin.Fd())
+	restore, err := terminal.save(fd)
+	if err != nil {
+		return 0, false
+	}
+	defer restore()
+	if _, err := term.MakeRaw(fd); err != nil {
+		return 0, false
+	}
+	var b [1]byte
+	if _, err := os.Stdin.Read(b[:]); err != nil {
+		return 0, false
+	}
+	return b[0], true
+}
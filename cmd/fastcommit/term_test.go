@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// setStdin makes the questions asked during the test read r.
+func setStdin(t *testing.T, r io.Reader) {
+	t.Helper()
+	old := stdin
+	stdin = bufio.NewReader(r)
+	t.Cleanup(func() { stdin = old })
+}
+
+func TestConfirmPipedAnswers(t *testing.T) {
+	setStdin(t, strings.NewReader("yes\nno\ny\n"))
+	for i, want := range []bool{true, false, true, false} {
+		if got := confirm("?"); got != want {
+			t.Errorf("answer %d: confirm = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestReadLineCancelled(t *testing.T) {
+	r, w := io.Pipe()
+	setStdin(t, r)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := readLine(ctx); err != context.Canceled {
+		t.Fatalf("readLine = %v, want %v", err, context.Canceled)
+	}
+	// The line typed after the cancelled question answers the next one.
+	go io.WriteString(w, "answer\n")
+	line, err := readLine(context.Background())
+	if err != nil || line != "answer\n" {
+		t.Errorf("readLine = %q, %v, want %q", line, err, "answer\n")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// terminal tracks what fastcommit changed about the terminal, so that it is
+// put back however fastcommit exits: normally, through exitWith, on a second
+// Ctrl-C, or after a panic.
+var terminal = &terminalState{}
+
+type terminalState struct {
+	mu sync.Mutex
+	// saved are the modes to restore terminals to, by file descriptor, while
+	// in raw mode or with echo off.
+	saved map[int]*term.State
+	// cursorHidden is set while the spinner hides the cursor.
+	cursorHidden bool
+	// lineDirty is set while the spinner's line is on stderr.
+	lineDirty bool
+}
+
+// save records the mode of the terminal fd before it is changed, e.g. to
+// raw mode or with echo off, and returns the function putting it back. Until
+// then, Ctrl-C or SIGTERM restores it before exiting, since the default
+// handling would leave the shell without echo.
+func (t *terminalState) save(fd int) (restore func(), err error) {
+	state, err := term.GetState(fd)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	if t.saved == nil {
+		t.saved = map[int]*term.State{}
+	}
+	t.saved[fd] = state
+	t.mu.Unlock()
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ch:
+			t.restore()
+			fmt.Fprintln(os.Stderr)
+			os.Exit(exitInterrupted)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if s, ok := t.saved[fd]; ok {
+			term.Restore(fd, s)
+			delete(t.saved, fd)
+		}
+	}, nil
+}
+
+// hideCursor hides the cursor on stderr until showCursor.
+func (t *terminalState) hideCursor() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprint(os.Stderr, "\033[?25l")
+	t.cursorHidden = true
+}
+
+func (t *terminalState) showCursor() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.showCursorLocked()
+}
+
+func (t *terminalState) showCursorLocked() {
+	if t.cursorHidden {
+		fmt.Fprint(os.Stderr, "\033[?25h")
+		t.cursorHidden = false
+	}
+}
+
+// writeLine writes a status line over the current line of stderr, to be
+// cleared by clearLine.
+func (t *terminalState) writeLine(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "\r%s\033[0m\033[K", s)
+	t.lineDirty = true
+}
+
+func (t *terminalState) clearLine() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clearLineLocked()
+}
+
+func (t *terminalState) clearLineLocked() {
+	if t.lineDirty {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		t.lineDirty = false
+	}
+}
+
+// restore undoes every change still in effect: terminal modes, the hidden
+// cursor and the status line.
+func (t *terminalState) restore() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for fd, s := range t.saved {
+		term.Restore(fd, s)
+		delete(t.saved, fd)
+	}
+	t.clearLineLocked()
+	t.showCursorLocked()
+}
+
+// handleCrash turns a panic into a crash report in the config directory,
+// after restoring the terminal, and exits as an unrecovered panic does. It
+// must be deferred first in main.
+func handleCrash() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	terminal.restore()
+	// Output may have stopped in the middle of a color.
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if isTerminal(f) {
+			fmt.Fprint(f, "\033[0m\n")
+		}
+	}
+	traceRun.flush()
+	stack := debug.Stack()
+	if path, err := writeCrashReport(r, stack); err == nil {
+		errorf("%s\n", tr("crashed", r, path))
+	} else {
+		errorf("%v\n%s\n", r, stack)
+	}
+	os.Exit(2)
+}
+
+// writeCrashReport writes what is needed to file a bug about the panic r to
+// a new file in the config directory and returns its path. Only the names of
+// the flags given are recorded, since their values may hold keys.
+func writeCrashReport(r any, stack []byte) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	var args []string
+	for _, a := range os.Args[1:] {
+		if name, _, _ := strings.Cut(a, "="); strings.HasPrefix(name, "-") {
+			args = append(args, name)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "fastcommit %s crashed at %s\n\n", Version, time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "flags: %s\n", strings.Join(args, " "))
+	fmt.Fprintf(&b, "trace: %s\n\n", traceRun.traceID)
+	fmt.Fprintf(&b, "panic: %v\n\n%s", r, stack)
+	path := filepath.Join(dir, "crash-"+time.Now().Format("20060102-150405")+".txt")
+	return path, os.WriteFile(path, []byte(b.String()), 0o600)
+}
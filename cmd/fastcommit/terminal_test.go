@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// panicProvider replays its chunks and then panics, as a bug in handling a
+// response would mid-stream.
+type panicProvider struct{ replayProvider }
+
+func (p panicProvider) StreamCompletion(ctx context.Context, req openai.ChatCompletionRequest) (fastcommit.CompletionStream, error) {
+	s, err := p.replayProvider.StreamCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return panicStream{s.(*replayStream)}, nil
+}
+
+type panicStream struct{ *replayStream }
+
+func (s panicStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if len(s.chunks) == 0 {
+		panic("boom")
+	}
+	return s.replayStream.Recv()
+}
+
+// TestCrashHelperProcess is run by TestCrashRestoresTerminal in another
+// process, since handleCrash exits.
+func TestCrashHelperProcess(t *testing.T) {
+	if os.Getenv("FASTCOMMIT_CRASH_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	defer handleCrash()
+	// Leave the terminal as the spinner does while it is shown.
+	terminal.hideCursor()
+	terminal.writeLine("\033[90mwaiting")
+	msgs := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "diff"}}
+	streamCompletion(context.Background(), panicProvider{replayProvider{t, []string{
+		`{"id":"c","choices":[{"index":0,"delta":{"content":"Add "}}]}`,
+	}}}, testFlags(), msgs, nil)
+	t.Fatal("streamCompletion returned instead of panicking")
+}
+
+func TestCrashRestoresTerminal(t *testing.T) {
+	dir := configHome(t)
+	// The arguments after "--" are left to the helper, as fastcommit's own.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCrashHelperProcess$", "--", "--api-key=sk-secret")
+	cmd.Env = append(os.Environ(), "FASTCOMMIT_CRASH_HELPER=1")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 2 {
+		t.Fatalf("helper exited with %v, want status 2\n%s", err, stderr.String())
+	}
+
+	out := stderr.String()
+	status := strings.Index(out, "waiting")
+	clear := strings.LastIndex(out, "\r\033[K")
+	show := strings.LastIndex(out, "\033[?25h")
+	crashed := strings.Index(out, "fastcommit crashed: boom")
+	if status < 0 || crashed < 0 {
+		t.Fatalf("stderr lacks the status line or the crash message:\n%q", out)
+	}
+	if clear < status || show < status || clear > crashed || show > crashed {
+		t.Errorf("the status line was not cleared and the cursor shown before the crash message:\n%q", out)
+	}
+
+	reports, err := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("crash reports = %v, %v; want one", reports, err)
+	}
+	if !strings.Contains(out, reports[0]) {
+		t.Errorf("the crash message does not point to %s:\n%s", reports[0], out)
+	}
+	b, err := os.ReadFile(reports[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(b)
+	for _, want := range []string{"panic: boom", "flags: -test.run -- --api-key\n", "streamCompletion"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("the crash report lacks %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "sk-secret") {
+		t.Errorf("the crash report holds a flag value:\n%s", report)
+	}
+}
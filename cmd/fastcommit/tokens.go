@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+// replyReserve is the room left in the context window for the generated
+// message when deciding whether a prompt fits.
+const replyReserve = 1000
+
+type tokenCount struct {
+	Path   string `json:"path"`
+	Tokens int    `json:"tokens"`
+}
+
+// tokenReport is the output of the tokens subcommand. Like the doctor
+// report, it is deliberately not localized.
+type tokenReport struct {
+	Model         string       `json:"model"`
+	Encoding      string       `json:"encoding"`
+	ContextWindow int          `json:"context_window"`
+	KnownWindow   bool         `json:"known_window"`
+	Files         []tokenCount `json:"files"`
+	Diff          int          `json:"diff_tokens"`
+	// Overhead is the rest of the standard prompt: instructions, recent
+	// commit messages and style guide. It is -1 outside a repository.
+	Overhead int  `json:"overhead_tokens"`
+	Total    int  `json:"total_tokens"`
+	Fits     bool `json:"fits"`
+}
+
+// runTokens counts the tokens of the staged diff, a commit, a range, a file
+// or stdin for a model, without calling the API.
+func runTokens(f flags, args []string) error {
+	fs := flag.NewFlagSet("tokens", flag.ExitOnError)
+	model := fs.String("model", f.model, "The model whose encoding and context window to use")
+	jsonOutput := fs.Bool("json", false, "Print the report as JSON")
+	_ = fs.Parse(args)
+	if fs.NArg() > 1 {
+		return errors.New(tr("tokens_usage"))
+	}
+
+	workdir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	opts := fastcommit.PromptOptions{MaxTokens: math.MaxInt32}
+	content, name := "", ""
+	switch arg := fs.Arg(0); {
+	case arg == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+		content, name = string(b), "(stdin)"
+	case arg != "" && isRegularFile(arg):
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			return err
+		}
+		content, name = string(b), arg
+	default:
+		if fastcommit.IsRange(arg) {
+			if _, err := rangeCount(arg); err != nil {
+				return err
+			}
+			opts.Range = arg
+		} else if arg != "" {
+			if opts.CommitHash, err = resolveRef(arg); err != nil {
+				return err
+			}
+		}
+		if content, err = fastcommit.Diff(workdir, opts); err != nil {
+			return err
+		}
+	}
+
+	r := tokenReport{Model: *model, Encoding: fastcommit.Encoding(*model)}
+	r.ContextWindow, r.KnownWindow = fastcommit.ContextWindow(*model)
+
+	count := func(s string) int {
+		return fastcommit.CountTokensForModel(*model, openai.ChatCompletionMessage{Content: s})
+	}
+	files := fastcommit.SplitDiff(content)
+	if len(files) == 0 && content != "" {
+		// Not a diff, e.g. a plain file: count it as a whole.
+		files = []fastcommit.FileDiff{{Path: name, Diff: content}}
+	}
+	for _, fd := range files {
+		r.Files = append(r.Files, tokenCount{fd.Path, count(fd.Diff)})
+	}
+	slices.SortStableFunc(r.Files, func(a, b tokenCount) int {
+		return b.Tokens - a.Tokens
+	})
+	r.Diff = count(content)
+
+	// The content stands in for the diff, so the rest of the prompt is
+	// built exactly as it would be for it.
+	r.Overhead = -1
+	if content != "" {
+		opts.Overview = content
+		if msgs, err := fastcommit.BuildPromptWithOptions(io.Discard, workdir, opts); err == nil {
+			r.Overhead = fastcommit.CountTokensForModel(*model, msgs[:len(msgs)-1]...)
+		} else {
+			debugf("build prompt: %v\n", err)
+		}
+	}
+	r.Total = r.Diff + max(r.Overhead, 0)
+	r.Fits = r.Total+replyReserve <= r.ContextWindow
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	printTokenReport(r)
+	return nil
+}
+
+func printTokenReport(r tokenReport) {
+	width := len("prompt overhead")
+	for _, c := range r.Files {
+		width = max(width, len(c.Path))
+	}
+	row := func(label string, n int) {
+		fmt.Printf("%-*s %9d\n", width, label, n)
+	}
+	for _, c := range r.Files {
+		row(c.Path, c.Tokens)
+	}
+	if len(r.Files) > 0 {
+		fmt.Println(strings.Repeat("-", width+10))
+	}
+	row("diff", r.Diff)
+	if r.Overhead >= 0 {
+		row("prompt overhead", r.Overhead)
+	} else {
+		fmt.Printf("%-*s %9s\n", width, "prompt overhead", "n/a")
+	}
+	row("total", r.Total)
+
+	window := fmt.Sprint(r.ContextWindow)
+	if !r.KnownWindow {
+		window += " (assumed)"
+	}
+	verdict := "\033[32mfits\033[0m"
+	if !r.Fits {
+		verdict = "\033[31mdoes not fit\033[0m"
+	}
+	fmt.Printf("\n%s (%s): %s in a context window of %s tokens, leaving %d for the reply\n",
+		r.Model, r.Encoding, verdict, window, replyReserve)
+}
+
+func isRegularFile(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.Mode().IsRegular()
+}
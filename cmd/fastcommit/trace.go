@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tracer identifies a run of fastcommit by a trace ID and each request to
+// the provider by a span ID, in the W3C format, so that debug lines, the
+// --output json result and bundle reports can be tied together. With the
+// OTEL_* variables of an OTLP endpoint set, the spans are exported there
+// when fastcommit exits; otherwise nothing but the IDs is kept.
+type tracer struct {
+	traceID string
+	root    span
+	// endpoint is the OTLP/HTTP traces URL, or empty not to export.
+	endpoint string
+	headers  map[string]string
+
+	mu      sync.Mutex
+	current *span
+	spans   []*span
+}
+
+// span is one request to the provider.
+type span struct {
+	id, parent string
+	name       string
+	start, end time.Time
+	attrs      map[string]any
+	err        string
+}
+
+// traceRun traces this run of fastcommit.
+var traceRun = newTracer()
+
+func newTracer() *tracer {
+	t := &tracer{traceID: randomID(16)}
+	t.root = span{id: randomID(8), name: "fastcommit", start: time.Now()}
+	t.endpoint, t.headers = otlpConfig()
+	return t
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// tag returns the IDs that prefix debug and verbose lines: the trace ID,
+// and the span ID during a request.
+func (t *tracer) tag() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current != nil {
+		return t.traceID + " " + t.current.id
+	}
+	return t.traceID
+}
+
+// startSpan starts the span of a request, which debug lines are tagged with
+// until it ends.
+func (t *tracer) startSpan(name string, attrs map[string]any) *span {
+	s := &span{id: randomID(8), parent: t.root.id, name: name, start: time.Now(), attrs: attrs}
+	t.mu.Lock()
+	t.current = s
+	t.mu.Unlock()
+	debugf("%s started: %v", name, attrs)
+	return s
+}
+
+// endSpan ends s, recording err as its failure.
+func (t *tracer) endSpan(s *span, err error) {
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+		debugf("%s failed after %s: %v", s.name, s.end.Sub(s.start).Round(time.Millisecond), err)
+	} else {
+		debugf("%s done in %s", s.name, s.end.Sub(s.start).Round(time.Millisecond))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current == s {
+		t.current = nil
+	}
+	if t.endpoint != "" {
+		t.spans = append(t.spans, s)
+	}
+}
+
+// otlpConfig returns the OTLP/HTTP traces endpoint and headers set by the
+// standard OTEL_* variables, or an empty endpoint when exporting is not
+// configured or disabled. Only the http/json protocol is spoken.
+func otlpConfig() (string, map[string]string) {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") || os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return "", nil
+	}
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return "", nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if protocol != "" && protocol != "http/json" {
+		warnf("OTLP protocol %s is not supported, only http/json; not exporting traces\n", protocol)
+		return "", nil
+	}
+	headers := map[string]string{}
+	for _, env := range []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TRACES_HEADERS"} {
+		for _, kv := range strings.Split(os.Getenv(env), ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				continue
+			}
+			if u, err := url.QueryUnescape(strings.TrimSpace(v)); err == nil {
+				v = u
+			}
+			headers[strings.TrimSpace(k)] = v
+		}
+	}
+	return endpoint, headers
+}
+
+// flush exports the spans of the run, if exporting is configured. Failures
+// only show in debug output; tracing must not fail a run.
+func (t *tracer) flush() {
+	if t.endpoint == "" {
+		return
+	}
+	t.mu.Lock()
+	spans := append([]*span{&t.root}, t.spans...)
+	t.mu.Unlock()
+	t.root.end = time.Now()
+
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "fastcommit"
+	}
+	var otlpSpans []map[string]any
+	for _, s := range spans {
+		otlpSpans = append(otlpSpans, t.otlpSpan(s))
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []map[string]any{{
+			"resource": map[string]any{"attributes": otlpAttributes(map[string]any{
+				"service.name":    service,
+				"service.version": Version,
+			})},
+			"scopeSpans": []map[string]any{{
+				"scope": map[string]any{"name": "fastcommit"},
+				"spans": otlpSpans,
+			}},
+		}},
+	})
+	if err != nil {
+		debugf("export traces: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		debugf("export traces: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		debugf("export traces: %v", err)
+		return
+	}
+	resp.Body.Close()
+	debugf("exported %d spans to %s: %s", len(otlpSpans), t.endpoint, resp.Status)
+}
+
+// otlpSpan returns s in OTLP's JSON encoding.
+func (t *tracer) otlpSpan(s *span) map[string]any {
+	o := map[string]any{
+		"traceId":           t.traceID,
+		"spanId":            s.id,
+		"name":              s.name,
+		"kind":              3, // client
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.attrs),
+	}
+	if s.parent != "" {
+		o["parentSpanId"] = s.parent
+	} else {
+		o["kind"] = 1 // internal
+	}
+	if s.err != "" {
+		o["status"] = map[string]any{"code": 2, "message": s.err}
+	}
+	return o
+}
+
+func otlpAttributes(attrs map[string]any) []map[string]any {
+	var out []map[string]any
+	for k, v := range attrs {
+		var value map[string]any
+		switch v := v.(type) {
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, map[string]any{"key": k, "value": value})
+	}
+	return out
+}
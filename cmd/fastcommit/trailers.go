@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// trailerKeyRe matches the keys git accepts for trailers.
+var trailerKeyRe = regexp.MustCompile(`^[A-Za-z][\w-]*$`)
+
+// parseTrailer turns a --trailer value, "key=value" or "key: value", into
+// the trailer line "key: value".
+func parseTrailer(s string) (string, error) {
+	i := strings.IndexAny(s, "=:")
+	if i < 0 {
+		return "", errors.New(tr("invalid_trailer", s))
+	}
+	key, value := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if !trailerKeyRe.MatchString(key) || value == "" || strings.Contains(value, "\n") {
+		return "", errors.New(tr("invalid_trailer", s))
+	}
+	return key + ": " + value, nil
+}
+
+// messageTrailers returns the trailers added to every message, in order:
+// a Refs trailer for each --issue, the --trailer values, and Signed-off-by
+// with the committer identity for --signoff.
+func messageTrailers(f flags) ([]string, error) {
+	var trailers []string
+	for _, issue := range f.issues {
+		trailers = append(trailers, "Refs: "+issue)
+	}
+	for _, t := range f.trailerValues {
+		trailer, err := parseTrailer(t)
+		if err != nil {
+			return nil, err
+		}
+		trailers = append(trailers, trailer)
+	}
+	if f.signoff {
+		ident, err := committerIdent()
+		if err != nil {
+			return nil, err
+		}
+		trailers = append(trailers, "Signed-off-by: "+ident)
+	}
+	return trailers, nil
+}
+
+// committerIdent returns "Name <email>" of the committer, as git commit
+// --signoff would sign off with, honoring GIT_COMMITTER_NAME and the like.
+func committerIdent() (string, error) {
+	out, err := exec.Command("git", "var", "GIT_COMMITTER_IDENT").Output()
+	if err != nil {
+		return "", fmt.Errorf("--signoff needs a committer identity; set user.name and user.email: %w", err)
+	}
+	// The identity ends with the timestamp and time zone.
+	fields := strings.Fields(strings.TrimSpace(string(out)))
+	if len(fields) < 3 {
+		return "", fmt.Errorf("unexpected committer identity %q", out)
+	}
+	return strings.Join(fields[:len(fields)-2], " "), nil
+}
+
+// trailerMessages tells the model about the issues of --issue, which it may
+// mention, and that the trailers are added for it.
+func trailerMessages(f flags) []openai.ChatCompletionMessage {
+	if len(f.trailers) == 0 {
+		return nil
+	}
+	content := "Do not write trailers such as Signed-off-by, Refs or Co-authored-by at the end of the message; " +
+		"the tool adds them."
+	if len(f.issues) > 0 {
+		content = fmt.Sprintf("The change belongs to %s. Mention it in the subject line only if that "+
+			"helps, e.g. when the subject would otherwise be vague. ", joinList(f.issues)) + content
+	}
+	return []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: content}}
+}
+
+// stripTrailers removes the trailers the model wrote at the end of msg, so
+// that they are neither invented nor duplicated. Closing references are
+// kept; applyClosingRefs deals with those.
+func stripTrailers(msg string) string {
+	subject, body := splitMessage(msg)
+	i := strings.LastIndex(body, "\n\n")
+	last := body[i+1:]
+	if strings.TrimSpace(last) == "" || !isTrailerBlock(last) {
+		return msg
+	}
+	var kept []string
+	for _, line := range strings.Split(strings.TrimSpace(last), "\n") {
+		if !trailerLineRe.MatchString(line) {
+			kept = append(kept, line)
+		}
+	}
+	body = strings.TrimSpace(body[:i+1])
+	if len(kept) > 0 {
+		body = strings.TrimSpace(body + "\n\n" + strings.Join(kept, "\n"))
+	}
+	if body == "" {
+		return subject
+	}
+	return subject + "\n\n" + body
+}
+
+// applyTrailers appends each of trailers to msg that it does not carry yet,
+// e.g. when amending a message that already has them.
+func applyTrailers(msg string, trailers []string) string {
+	for _, t := range trailers {
+		if !hasLine(msg, t) {
+			msg = appendTrailer(msg, t)
+		}
+	}
+	return msg
+}
+
+func hasLine(text, line string) bool {
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// unlandedCommit is a generated message that never became a commit because
+// git commit failed, e.g. when signing was cancelled or a hook rejected it.
+type unlandedCommit struct {
+	Message string `json:"message"`
+	// Head and Tree are HEAD and the tree the message was generated for,
+	// that of the index or with --all of the tracked files.
+	Head string    `json:"head"`
+	Tree string    `json:"tree"`
+	Time time.Time `json:"time"`
+}
+
+// saveUnlanded keeps msg after git commit failed to create a new commit with
+// it, so that it can be committed with --use-saved instead of generating
+// another.
+func saveUnlanded(msg string, snap repoSnapshot) {
+	err := updateState(func(s *repoState) {
+		s.Unlanded = &unlandedCommit{
+			Message: msg,
+			Head:    snap.head,
+			Tree:    snap.tree,
+			Time:    time.Now(),
+		}
+	})
+	if err != nil {
+		debugf("save message: %v", err)
+		return
+	}
+	infof("%s\n", tr("unlanded_saved"))
+}
+
+// clearUnlanded forgets the saved message, once a commit was made.
+func clearUnlanded() {
+	if err := updateState(func(s *repoState) { s.Unlanded = nil }); err != nil {
+		debugf("clear saved message: %v", err)
+	}
+}
+
+// checkUnlandedAmend guards --amend against rewriting an unrelated commit
+// when the previous run's commit never landed: more likely than not, the
+// user meant to retry that commit. It reports whether to commit the saved
+// message as a new commit instead.
+func checkUnlandedAmend(f flags, saved *unlandedCommit) (useSaved bool, err error) {
+	if saved == nil || !f.amend || f.discardSaved {
+		return false, nil
+	}
+	subject, _ := splitMessage(saved.Message)
+	warnf("%s\n", tr("unlanded_amend", saved.Time.Format(time.DateTime), subject))
+	if f.useSaved {
+		return true, nil
+	}
+	if !interactive() {
+		return false, errors.New(tr("unlanded_blocked"))
+	}
+	return confirm(tr("unlanded_question")), nil
+}
+
+// commitUnlanded commits the saved message as a new commit.
+func commitUnlanded(f flags, saved *unlandedCommit) error {
+	if saved == nil {
+		return errors.New(tr("no_unlanded"))
+	}
+	snap, err := takeSnapshot(f.all)
+	if err != nil {
+		return err
+	}
+	if snap.head != saved.Head || snap.tree != saved.Tree {
+		warnf("%s\n", tr("unlanded_changed"))
+	}
+	f.amend = false
+	fmt.Printf("\033[34m%s\033[0m\n", saved.Message)
+	cmd := commitCommand(f, saved.Message)
+	if f.dryRun {
+		fmt.Printf("%s\n%s\n", tr("run_to_commit"), formatShellCommand(cmd))
+		return nil
+	}
+	if err := runCommit(cmd); err != nil {
+		return err
+	}
+	recordCommit(saved.Message)
+	printCommitSummary("")
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckUnlandedAmend(t *testing.T) {
+	saved := &unlandedCommit{Message: "Add retries\n\nBody.", Time: time.Now()}
+	tests := []struct {
+		name        string
+		saved       *unlandedCommit
+		amend       bool
+		useSaved    bool
+		discard     bool
+		wantUse     bool
+		wantBlocked bool
+	}{
+		{name: "absent, new commit"},
+		{name: "absent, amend", amend: true},
+		{name: "present, new commit", saved: saved},
+		// Outside a terminal nobody can be asked, so the amend is refused.
+		{name: "present, amend", saved: saved, amend: true, wantBlocked: true},
+		{name: "present, amend, --use-saved", saved: saved, amend: true, useSaved: true, wantUse: true},
+		{name: "present, amend, --discard-saved", saved: saved, amend: true, discard: true},
+	}
+	for _, tt := range tests {
+		f := testFlags()
+		f.amend, f.useSaved, f.discardSaved = tt.amend, tt.useSaved, tt.discard
+		use, err := checkUnlandedAmend(f, tt.saved)
+		if use != tt.wantUse || (err != nil) != tt.wantBlocked {
+			t.Errorf("%s: checkUnlandedAmend = %v, %v; want %v, blocked %v", tt.name, use, err, tt.wantUse, tt.wantBlocked)
+		}
+		if tt.wantBlocked && err.Error() != tr("unlanded_blocked") {
+			t.Errorf("%s: error %q", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	fastcommit "github.com/AkhilSharma90/GenAI-Code-Committer"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// wipBudget is how long `fastcommit wip` waits for a generated message
+	// before committing with a local one.
+	wipBudget = 2 * time.Second
+	// wipMaxNames is how many files the local wip message names.
+	wipMaxNames = 3
+)
+
+// pendingCommit is a commit made by `fastcommit wip` with a local placeholder
+// message, awaiting `fastcommit reword`.
+type pendingCommit struct {
+	Hash string    `json:"hash"`
+	Time time.Time `json:"time"`
+	// Prompt is the prompt the commit was made with, so that rewording it
+	// later sees the same context.
+	Prompt []openai.ChatCompletionMessage `json:"prompt,omitempty"`
+}
+
+// runWIP commits the staged changes right away. The message is generated if
+// it arrives within wipBudget, which building the prompt counts against;
+// otherwise a local "wip:" message is used and the commit is recorded as
+// pending, for `fastcommit reword` to upgrade.
+func runWIP(f flags, cfg config) error {
+	workdir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	client := newProvider(f)
+
+	genCtx, cancel := context.WithTimeout(ctx, wipBudget)
+	defer cancel()
+	p, err := buildPrompt(genCtx, client, f, cfg, workdir, "", nil)
+	// Ctrl-C means no commit, not one with a local message.
+	if err := interrupted(ctx); err != nil {
+		return err
+	}
+	built := err == nil
+	if !built {
+		if genCtx.Err() == nil {
+			return err
+		}
+		verbosef("no prompt within %s: %v", wipBudget, err)
+	}
+
+	disp := newDisplay(os.Stdout, f.plain)
+	msg := ""
+	if built && canGenerate(f) {
+		f.annotations = &annotations{}
+		msg, err = completeMessage(genCtx, client, f, cfg, p, disp)
+		cancel()
+		if err := interrupted(ctx); err != nil {
+			return err
+		}
+		f.annotations.report()
+		switch {
+		case err != nil:
+			verbosef("no message within %s: %v", wipBudget, err)
+			msg = ""
+		case f.annotations.Altered:
+			// Asking would block; commit with a local message instead.
+			warnf("%s\n", tr("content_filtered", strings.Join(f.annotations.Filters, "; ")))
+			msg = ""
+		}
+	}
+	deferred := msg == ""
+	if deferred {
+		stats, err := fastcommit.DiffStats(workdir, promptOptions(f, ""))
+		if err != nil {
+			return err
+		}
+		msg = wipMessage(stats)
+	} else if msg, err = finishMessage(f, cfg, p, msg); err != nil {
+		return err
+	}
+	disp.Replace(msg)
+
+	cmd := commitCommand(f, msg)
+	if f.dryRun {
+		fmt.Printf("%s\n%s\n", tr("run_to_commit"), formatShellCommand(cmd))
+		return nil
+	}
+	if err := interrupted(ctx); err != nil {
+		return err
+	}
+	if err := runCommit(cmd); err != nil {
+		return err
+	}
+	recordCommit(msg)
+	printCommitSummary("")
+	if !deferred {
+		return nil
+	}
+
+	hash, err := getLastCommitHash()
+	if err != nil {
+		return err
+	}
+	pc := pendingCommit{Hash: hash, Time: time.Now()}
+	if p.redactor == nil {
+		// Placeholders could not be mapped back to paths later.
+		pc.Prompt = p.msgs
+	}
+	if err := updateState(func(s *repoState) { s.Pending = append(s.Pending, pc) }); err != nil {
+		return fmt.Errorf("record pending commit: %w", err)
+	}
+	infof("%s\n", tr("wip_deferred", wipBudget))
+	return nil
+}
+
+// wipMessage returns a message naming the most changed files.
+func wipMessage(stats []fastcommit.FileStat) string {
+	slices.SortStableFunc(stats, func(a, b fastcommit.FileStat) int {
+		return b.Lines() - a.Lines()
+	})
+	var names []string
+	for _, s := range stats[:min(len(stats), wipMaxNames)] {
+		names = append(names, path.Base(s.Path))
+	}
+	msg := "wip: " + strings.Join(names, ", ")
+	if len(stats) > wipMaxNames {
+		msg += fmt.Sprintf(" and %d more", len(stats)-wipMaxNames)
+	}
+	if len(names) == 0 {
+		msg += "checkpoint"
+	}
+	return msg
+}
+
+// runReword generates a new message for the commit at HEAD, given as args[0]
+// or implied, and amends it without touching its content. A commit made by
+// `fastcommit wip` is reworded with the prompt recorded for it.
+func runReword(f flags, cfg config, args []string) error {
+	ref := "HEAD"
+	if len(args) > 0 {
+		ref = args[0]
+	}
+	hash, err := resolveRef(ref)
+	if err != nil {
+		return err
+	}
+	if head, _ := getLastCommitHash(); hash != head {
+		return errors.New(tr("reword_not_head", ref))
+	}
+	if err := checkPushedAmend(f, hash); err != nil {
+		return err
+	}
+	workdir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	client := newProvider(f)
+
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	var p prompt
+	if i := slices.IndexFunc(state.Pending, func(pc pendingCommit) bool { return pc.Hash == hash }); i >= 0 && len(state.Pending[i].Prompt) > 0 {
+		verbosef("using the prompt recorded by fastcommit wip")
+		p = prompt{msgs: state.Pending[i].Prompt}
+	} else if p, err = buildPrompt(ctx, client, f, cfg, workdir, hash, nil); err != nil {
+		return err
+	}
+
+	disp := newDisplay(os.Stdout, f.plain)
+	f.annotations = &annotations{}
+	msg, err := completeMessage(ctx, client, f, cfg, p, disp)
+	if err := interrupted(ctx); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	f.annotations.report()
+	if msg, err = finishMessage(f, cfg, p, msg); err != nil {
+		return err
+	}
+	disp.Replace(msg)
+
+	// --only without paths leaves staged changes out of the amended commit.
+	cmd := exec.Command("git", append([]string{"commit", "--amend", "--only"}, messageArgs(msg)...)...)
+	if f.edit {
+		cmd.Args = append(cmd.Args, "--edit")
+	}
+	if f.dryRun {
+		fmt.Printf("%s\n%s\n", tr("run_to_commit"), formatShellCommand(cmd))
+		return nil
+	}
+	if err := f.annotations.confirmAltered(); err != nil {
+		return err
+	}
+	if err := interrupted(ctx); err != nil {
+		return err
+	}
+	if err := runCommit(cmd); err != nil {
+		return err
+	}
+	recordCommit(msg)
+	clearPending(hash)
+	printCommitSummary(hash)
+	return nil
+}
+
+// clearPending forgets the pending commit hash, once it has been reworded or
+// amended.
+func clearPending(hash string) {
+	err := updateState(func(s *repoState) {
+		s.Pending = slices.DeleteFunc(s.Pending, func(pc pendingCommit) bool { return pc.Hash == hash })
+	})
+	if err != nil {
+		debugf("clear pending commit: %v", err)
+	}
+}
+
+// runStatus lists the commits made by `fastcommit wip` that still carry their
+// placeholder message.
+func runStatus() error {
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	if len(state.Pending) == 0 {
+		fmt.Println(tr("no_pending"))
+		return nil
+	}
+	fmt.Println(tr("pending_header"))
+	for _, pc := range state.Pending {
+		line := fmt.Sprintf("  \033[33m%s\033[0m", shortHash(pc.Hash))
+		if cs, err := getCommitSummary(pc.Hash); err != nil {
+			line += " " + tr("pending_missing")
+		} else {
+			line += " " + cs.subject
+			if !isAncestorOfHead(pc.Hash) {
+				line += " " + tr("pending_off_branch")
+			}
+		}
+		fmt.Printf("%s (%s)\n", line, pc.Time.Format(time.DateTime))
+	}
+	return nil
+}
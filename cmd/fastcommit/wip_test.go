@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWIPBudgetCoversPrompt checks that a prompt which cannot be built in
+// time, here because --deep waits on a server that never answers, still
+// leaves wip committing with a local message within its budget.
+func TestWIPBudgetCoversPrompt(t *testing.T) {
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-stop:
+		}
+	}))
+	defer srv.Close()
+	defer close(stop)
+
+	dir := newRepo(t)
+	for _, component := range []string{"api", "web"} {
+		var b strings.Builder
+		fmt.Fprintf(&b, "package %s\n\n", component)
+		for i := 0; i < 3000; i++ {
+			fmt.Fprintf(&b, "func handler%d(w Writer, r *Request) { w.Write(render(r, %d)) }\n", i, i)
+		}
+		writeFile(t, dir, component+"/handlers.go", b.String())
+	}
+	gitT(t, dir, "add", "-A")
+
+	f := testFlags()
+	f.provider = "openai"
+	f.openAIKey = "sk-test"
+	f.openAIBaseURL = srv.URL
+	f.deep = true
+	f.dryRun = true
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- runWIP(f, config{}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > wipBudget+3*time.Second {
+			t.Errorf("wip took %s, over its budget of %s", elapsed, wipBudget)
+		}
+	case <-time.After(wipBudget + 10*time.Second):
+		t.Fatal("wip is still waiting for the prompt")
+	}
+}
@@ -0,0 +1,107 @@
+package fastcommit
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// codeownersLocations are where GitHub looks for a CODEOWNERS file, in the
+// order it does.
+var codeownersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// CodeownersRule is a line of a CODEOWNERS file. A rule without owners
+// leaves the files it matches unowned.
+type CodeownersRule struct {
+	Pattern string
+	Owners  []string
+}
+
+// Codeowners is a parsed CODEOWNERS file.
+type Codeowners []CodeownersRule
+
+// ParseCodeowners parses a CODEOWNERS file in GitHub's syntax.
+func ParseCodeowners(data string) Codeowners {
+	var c Codeowners
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		c = append(c, CodeownersRule{Pattern: fields[0], Owners: fields[1:]})
+	}
+	return c
+}
+
+// FindCodeowners reads the CODEOWNERS file of the repository containing
+// dir from any of the locations GitHub supports. It returns nil if there is
+// none.
+func FindCodeowners(dir string) (Codeowners, error) {
+	root, err := findGitRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("find git root: %w", err)
+	}
+	for _, loc := range codeownersLocations {
+		data, err := os.ReadFile(filepath.Join(root, loc))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", loc, err)
+		}
+		return ParseCodeowners(string(data)), nil
+	}
+	return nil, nil
+}
+
+// Owners returns the owners of the file name, relative to the repository
+// root. As on GitHub, the last matching rule wins.
+func (c Codeowners) Owners(name string) []string {
+	for i := len(c) - 1; i >= 0; i-- {
+		if matchCodeowners(c[i].Pattern, name) {
+			return c[i].Owners
+		}
+	}
+	return nil
+}
+
+// OwnersOf returns the distinct owners of paths in the order they are first
+// found.
+func (c Codeowners) OwnersOf(paths []string) []string {
+	var owners []string
+	for _, p := range paths {
+		for _, o := range c.Owners(p) {
+			if !slices.Contains(owners, o) {
+				owners = append(owners, o)
+			}
+		}
+	}
+	return owners
+}
+
+// matchCodeowners reports whether a CODEOWNERS pattern matches name. Unlike
+// MatchGlob, a pattern without a slash also matches directories of that
+// name anywhere, and "dir/*" matches only the files directly in dir.
+func matchCodeowners(pattern, name string) bool {
+	p := strings.TrimSuffix(pattern, "/")
+	if p == "" {
+		return false
+	}
+	if !strings.Contains(p, "/") {
+		return MatchGlob("**/"+p, name)
+	}
+	if !MatchGlob(p, name) {
+		return false
+	}
+	if strings.HasSuffix(p, "/*") {
+		// MatchGlob would match nested files as inside a matched directory.
+		return !MatchGlob(p, path.Dir(name))
+	}
+	return true
+}
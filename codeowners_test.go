@@ -0,0 +1,78 @@
+package fastcommit
+
+import (
+	"slices"
+	"testing"
+)
+
+const testCodeowners = `# Default owners
+*                       @acme/platform
+
+*.md                    @acme/docs   # docs anywhere
+/payments/              @acme/payments
+/payments/README.md     @acme/payments @acme/docs
+/payments/generated/
+/build/*                @acme/release
+docs                    @acme/docs
+apps/**/config.yml      @alice
+`
+
+func TestCodeownersLastMatchWins(t *testing.T) {
+	c := ParseCodeowners(testCodeowners)
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"main.go", []string{"@acme/platform"}},
+		{"guide/intro.md", []string{"@acme/docs"}},
+		{"payments/charge.go", []string{"@acme/payments"}},
+		// Later than the *.md rule, so payments owns its docs too.
+		{"payments/CHANGES.md", []string{"@acme/payments"}},
+		{"payments/README.md", []string{"@acme/payments", "@acme/docs"}},
+		// A rule without owners leaves the files unowned.
+		{"payments/generated/api.go", nil},
+		{"build/Makefile", []string{"@acme/release"}},
+		// "dir/*" does not reach into subdirectories.
+		{"build/scripts/release.sh", []string{"@acme/platform"}},
+		// A pattern without a slash matches directories anywhere.
+		{"services/api/docs/usage.txt", []string{"@acme/docs"}},
+		{"apps/web/prod/config.yml", []string{"@alice"}},
+	}
+	for _, tt := range tests {
+		if got := c.Owners(tt.name); !slices.Equal(got, tt.want) {
+			t.Errorf("Owners(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	got := c.OwnersOf([]string{"payments/README.md", "payments/charge.go", "payments/generated/api.go", "main.go"})
+	if want := []string{"@acme/payments", "@acme/docs", "@acme/platform"}; !slices.Equal(got, want) {
+		t.Errorf("OwnersOf = %q, want %q", got, want)
+	}
+}
+
+func TestFindCodeowners(t *testing.T) {
+	dir := newRepo(t)
+	if c, err := FindCodeowners(dir); err != nil || c != nil {
+		t.Fatalf("FindCodeowners without a file = %v, %v", c, err)
+	}
+
+	writeFile(t, dir, "CODEOWNERS", "* @root\n")
+	writeFile(t, dir, "docs/CODEOWNERS", "* @docs\n")
+	writeFile(t, dir, "sub/file.txt", "x\n")
+	c, err := FindCodeowners(dir + "/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Owners("sub/file.txt"); !slices.Equal(got, []string{"@root"}) {
+		t.Errorf("owners from the root CODEOWNERS = %q", got)
+	}
+
+	// .github/CODEOWNERS comes first, as on GitHub.
+	writeFile(t, dir, ".github/CODEOWNERS", "* @github\n")
+	if c, err = FindCodeowners(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Owners("sub/file.txt"); !slices.Equal(got, []string{"@github"}) {
+		t.Errorf("owners from .github/CODEOWNERS = %q", got)
+	}
+}
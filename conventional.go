@@ -0,0 +1,108 @@
+package fastcommit
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ConventionalTypes are the Conventional Commits types allowed by default.
+var ConventionalTypes = []string{"feat", "fix", "chore", "refactor", "docs", "test", "perf", "ci"}
+
+// ConventionalSubjectLength is the default limit of a Conventional Commits
+// subject line, type and scope included.
+const ConventionalSubjectLength = 72
+
+// ConventionalRules are the Conventional Commits rules a message must follow,
+// as commit linters such as commitlint check them.
+type ConventionalRules struct {
+	// Types are the allowed types. Nil means ConventionalTypes.
+	Types []string
+	// Scope is the scope to use. When empty, the model picks one or none.
+	Scope string
+	// MaxSubjectLength limits the subject line. Zero means
+	// ConventionalSubjectLength.
+	MaxSubjectLength int
+}
+
+func (r ConventionalRules) types() []string {
+	if r.Types == nil {
+		return ConventionalTypes
+	}
+	return r.Types
+}
+
+func (r ConventionalRules) maxSubjectLength() int {
+	if r.MaxSubjectLength == 0 {
+		return ConventionalSubjectLength
+	}
+	return r.MaxSubjectLength
+}
+
+// Instructions describes the rules to the model.
+func (r ConventionalRules) Instructions() string {
+	scope := "an optional scope naming the area changed"
+	if r.Scope != "" {
+		scope = fmt.Sprintf("the scope %q", r.Scope)
+	}
+	return fmt.Sprintf("The subject line must follow Conventional Commits as \"type(scope): description\", "+
+		"with %s. These rules are checked and take priority over all style guidance above:\n"+
+		"- The type is one of %s.\n"+
+		"- The description starts with a lowercase letter and does not end with a period.\n"+
+		"- The whole subject line is at most %d characters.",
+		scope, strings.Join(r.types(), ", "), r.maxSubjectLength())
+}
+
+// messages returns the instructions as a prompt message, or nothing for nil
+// rules.
+func (r *ConventionalRules) messages() []openai.ChatCompletionMessage {
+	if r == nil {
+		return nil
+	}
+	return []openai.ChatCompletionMessage{{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: r.Instructions(),
+	}}
+}
+
+var conventionalSubjectRe = regexp.MustCompile(`^([a-z]+)(\(([^()]*)\))?(!)?: (.*)$`)
+
+// Check returns the rules msg breaks, each described as an instruction to fix
+// it, or nil if it follows them all.
+func (r ConventionalRules) Check(msg string) []string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(msg), "\n")
+	subject = strings.TrimSpace(subject)
+	var broken []string
+	if n := utf8.RuneCountInString(subject); n > r.maxSubjectLength() {
+		broken = append(broken, fmt.Sprintf("the subject line is %d characters long; shorten it to at most %d",
+			n, r.maxSubjectLength()))
+	}
+	m := conventionalSubjectRe.FindStringSubmatch(subject)
+	if m == nil {
+		return append(broken, `the subject line is not of the form "type(scope): description" or "type: description"`)
+	}
+	typ, scoped, scope, description := m[1], m[2] != "", m[3], m[5]
+	if !slices.Contains(r.types(), typ) {
+		broken = append(broken, fmt.Sprintf("the type %q is not allowed; use one of %s",
+			typ, strings.Join(r.types(), ", ")))
+	}
+	if scoped && strings.TrimSpace(scope) == "" {
+		broken = append(broken, "the scope is empty; name one or leave out the parentheses")
+	}
+	first, _ := utf8.DecodeRuneInString(description)
+	switch {
+	case strings.TrimSpace(description) == "":
+		broken = append(broken, "the description after the colon is empty")
+	case unicode.IsUpper(first):
+		broken = append(broken, "the description starts with an uppercase letter; start it lowercase")
+	}
+	if strings.HasSuffix(description, ".") {
+		broken = append(broken, "the subject line ends with a period; remove it")
+	}
+	return broken
+}
@@ -0,0 +1,60 @@
+package fastcommit
+
+import (
+	"strings"
+)
+
+// ConventionFiles are the files, relative to the repository root, that
+// commonly document a repository's commit conventions, most specific first.
+var ConventionFiles = []string{
+	"docs/commit-style.md",
+	"docs/commits.md",
+	"docs/COMMIT_CONVENTIONS.md",
+	"COMMIT_CONVENTIONS.md",
+	"CONTRIBUTING.md",
+	".github/CONTRIBUTING.md",
+	"docs/CONTRIBUTING.md",
+	"docs/contributing.md",
+}
+
+// ExtractCommitSection returns the sections of a Markdown document whose
+// headings mention commits, each with everything up to the next heading of
+// the same or a higher level. It returns "" when no heading mentions commits.
+func ExtractCommitSection(doc string) string {
+	var out []string
+	level := 0 // of the heading being copied, or 0
+	fenced := false
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+		}
+		if n := headingLevel(line); n > 0 && !fenced {
+			switch {
+			case level > 0 && n <= level:
+				level = 0
+				fallthrough
+			case level == 0:
+				if strings.Contains(strings.ToLower(line), "commit") {
+					level = n
+					if len(out) > 0 {
+						out = append(out, "")
+					}
+				}
+			}
+		}
+		if level > 0 {
+			out = append(out, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// headingLevel returns the level of an ATX heading such as "## Commits", or
+// 0 when line is not a heading.
+func headingLevel(line string) int {
+	n := len(line) - len(strings.TrimLeft(line, "#"))
+	if n == 0 || n > 6 || (len(line) > n && line[n] != ' ' && line[n] != '\t') {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,303 @@
+package fastcommit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// DiffFormatArgs make git diff print the same diff for the same changes
+// whatever the user's configuration, such as color.diff, diff.external or
+// diff.mnemonicPrefix, so that prompts are reproducible and file headers
+// parse. GitConfigArgs pin the rest and must come before the command.
+var DiffFormatArgs = []string{"--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/"}
+
+// GitConfigArgs go before the git command, pinning configuration that has
+// no option of its own: core.quotePath would write non-ASCII paths as octal
+// escapes or not depending on the user's setting, and PathRedactor does not
+// recognize the escapes.
+var GitConfigArgs = []string{"-c", "core.quotePath=false"}
+
+// generateDiff uses the git CLI to generate the diff described by opts, up
+// to opts.MaxDiffBytes.
+func generateDiff(w io.Writer, dir string, opts PromptOptions) error {
+	limiter := newDiffLimiter(w, opts.MaxDiffBytes)
+	if err := writeDiff(limiter, dir, opts); err != nil {
+		return err
+	}
+	return limiter.Close()
+}
+
+func writeDiff(w io.Writer, dir string, opts PromptOptions) error {
+	// Use the git CLI instead of go-git for more accurate and complete diff generation
+	args, err := diffArgs(dir, opts)
+	if err != nil {
+		return err
+	}
+	args = append(append([]string{"diff"}, DiffFormatArgs...), args...)
+	if err := runGit(w, dir, args...); err != nil {
+		return err
+	}
+	if !opts.WorkingTree || !opts.IncludeUntracked {
+		return nil
+	}
+
+	untracked, err := untrackedFiles(dir, opts)
+	if err != nil || len(untracked) == 0 {
+		return err
+	}
+	// The paths are relative to the repository root, and so must the diff
+	// headers be.
+	root, err := findGitRoot(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range untracked {
+		args := append(append([]string{"-C", root}, GitConfigArgs...), "diff")
+		args = append(append(args, DiffFormatArgs...), "--no-index", "--", "/dev/null", path)
+		cmd := exec.Command("git", args...)
+		cmd.Stdout = w
+		var errBuf bytes.Buffer
+		cmd.Stderr = &errBuf
+		// git diff --no-index exits with 1 when the files differ, which they
+		// always do here, but also when it cannot read one, which it then
+		// says on stderr.
+		var exitErr *exec.ExitError
+		err := cmd.Run()
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && errBuf.Len() == 0) {
+			return fmt.Errorf("diff untracked file %q: %w\n%s", path, err, errBuf.String())
+		}
+	}
+	return nil
+}
+
+// diffArgs returns the git diff arguments selecting the changes described by
+// opts.
+func diffArgs(dir string, opts PromptOptions) ([]string, error) {
+	args, err := diffRevArgs(dir, opts)
+	if err != nil || len(opts.Paths) == 0 {
+		return args, err
+	}
+	return append(append(args, "--"), Pathspecs(opts.Paths)...), nil
+}
+
+// Pathspecs turns paths relative to the repository root into pathspecs that
+// match exactly those files from any directory of the repository.
+func Pathspecs(paths []string) []string {
+	specs := make([]string, len(paths))
+	for i, p := range paths {
+		specs[i] = ":(top,literal)" + p
+	}
+	return specs
+}
+
+func diffRevArgs(dir string, opts PromptOptions) ([]string, error) {
+	if opts.WorkingTree {
+		var buf bytes.Buffer
+		if err := runGit(&buf, dir, "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+			return []string{"HEAD"}, nil
+		}
+		// No commits yet, so compare against the empty tree.
+		tree, err := emptyTree(dir)
+		if err != nil {
+			return nil, err
+		}
+		return []string{tree}, nil
+	}
+
+	if opts.Range != "" {
+		// Like a squash, compare the end of the range with where it forked
+		// off, which is what the three-dot form selects.
+		if strings.Contains(opts.Range, "...") {
+			return []string{opts.Range}, nil
+		}
+		return []string{strings.Replace(opts.Range, "..", "...", 1)}, nil
+	}
+
+	if opts.Since != "" {
+		// The staged state against the fork point covers both the branch's
+		// commits and what is about to be added to them.
+		base, err := MergeBase(dir, opts.Since)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"--cached", base}, nil
+	}
+
+	refName := opts.CommitHash
+	if refName == "" {
+		// Case 1: No specific commit reference provided
+		// Generate diff for staged changes in the working directory
+		return []string{"--cached"}, nil
+	}
+	parent, err := parentRev(dir, refName)
+	if err != nil {
+		return nil, err
+	}
+	// Case 2: A specific commit reference is provided
+	if opts.Amend {
+		// Case 2a: Amending the specified commit
+		// Show diff of the commit being amended plus any staged changes
+		return []string{"--cached", parent}, nil
+	}
+	// Case 2b: Show changes introduced by the specific commit
+	return []string{parent, refName}, nil
+}
+
+// ErrShallowHistory is returned when a shallow clone lacks the history needed
+// to describe a commit.
+var ErrShallowHistory = errors.New("the history needed is missing from this shallow clone")
+
+// parentRev returns what the changes of commit are relative to: its first
+// parent, or the empty tree for a root commit.
+func parentRev(dir, commit string) (string, error) {
+	var buf bytes.Buffer
+	if err := runGit(&buf, dir, "rev-parse", "--verify", "-q", commit+"^"); err == nil {
+		return commit + "^", nil
+	}
+	// A commit at the boundary of a shallow clone looks like a root commit
+	// to most commands, but its object still names the missing parent.
+	buf.Reset()
+	if err := runGit(&buf, dir, "cat-file", "commit", commit); err != nil {
+		return "", err
+	}
+	header, _, _ := strings.Cut(buf.String(), "\n\n")
+	for _, line := range strings.Split(header, "\n") {
+		if strings.HasPrefix(line, "parent ") {
+			return "", fmt.Errorf("diff %s against its parent: %w", commit, ErrShallowHistory)
+		}
+	}
+	return emptyTree(dir)
+}
+
+// IsRange reports whether rev is a range of commits such as "main..HEAD" or
+// "a...b" rather than a single revision.
+func IsRange(rev string) bool {
+	return strings.Contains(rev, "..")
+}
+
+// MergeBase returns the commit HEAD forked off ref at: the best common
+// ancestor of both, which for a branch rebased onto ref is ref itself.
+func MergeBase(dir, ref string) (string, error) {
+	var buf bytes.Buffer
+	if err := runGit(&buf, dir, "merge-base", "HEAD", ref); err != nil {
+		return "", fmt.Errorf("find where HEAD forked off %s: %w", ref, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// RangeMessages returns the messages of the commits in rng, oldest first.
+func RangeMessages(dir, rng string) ([]string, error) {
+	var buf bytes.Buffer
+	if err := runGit(&buf, dir, "log", "--reverse", "--format=%B%x00", rng, "--"); err != nil {
+		return nil, err
+	}
+	var msgs []string
+	for _, m := range splitNUL(buf.String()) {
+		if m = strings.TrimSpace(m); m != "" {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs, nil
+}
+
+// IsShallow reports whether the repository containing dir is a shallow clone.
+func IsShallow(dir string) (bool, error) {
+	var buf bytes.Buffer
+	if err := runGit(&buf, dir, "rev-parse", "--is-shallow-repository"); err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(buf.String()) == "true", nil
+}
+
+// emptyTree returns the hash of the empty tree.
+func emptyTree(dir string) (string, error) {
+	var buf bytes.Buffer
+	if err := runGit(&buf, dir, "hash-object", "-t", "tree", "/dev/null"); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// runGit runs git in dir, writing its output to w. The environment is
+// deliberately inherited unchanged: tools such as pre-commit point
+// GIT_INDEX_FILE at a temporary index, and GIT_OBJECT_DIRECTORY or
+// GIT_ALTERNATE_OBJECT_DIRECTORIES at extra objects, and the changes
+// described must be the ones git commit will see under the same settings.
+func runGit(w io.Writer, dir string, args ...string) error {
+	cmd := exec.Command("git", append(append([]string{"-C", dir}, GitConfigArgs...), args...)...)
+
+	var errBuf bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &errBuf
+
+	// Run the git command and return any execution errors
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("running %s %s: %w\n%s",
+			cmd.Args[0], strings.Join(cmd.Args[1:], " "), err, errBuf.String())
+	}
+
+	return nil
+}
+
+func splitNUL(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, "\x00") {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ChangedPaths returns the paths touched by the changes described by opts.
+// Both sides of a rename are included.
+func ChangedPaths(dir string, opts PromptOptions) ([]string, error) {
+	args, err := diffArgs(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	args = append([]string{"diff", "--name-only", "--no-renames", "-z"}, args...)
+	if err := runGit(&buf, dir, args...); err != nil {
+		return nil, err
+	}
+	paths := splitNUL(buf.String())
+
+	if opts.WorkingTree && opts.IncludeUntracked {
+		untracked, err := untrackedFiles(dir, opts)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, untracked...)
+		slices.Sort(paths)
+	}
+	return paths, nil
+}
+
+// untrackedFiles returns the untracked files, limited to opts.Paths if set.
+func untrackedFiles(dir string, opts PromptOptions) ([]string, error) {
+	untracked, err := UntrackedFiles(dir)
+	if err != nil || len(opts.Paths) == 0 {
+		return untracked, err
+	}
+	return slices.DeleteFunc(untracked, func(p string) bool {
+		return !slices.Contains(opts.Paths, p)
+	}), nil
+}
+
+// UntrackedFiles returns the untracked files in the working tree of dir that
+// are not ignored, relative to the repository root.
+func UntrackedFiles(dir string) ([]string, error) {
+	var buf bytes.Buffer
+	err := runGit(&buf, dir, "ls-files", "--others", "--exclude-standard", "--full-name", "-z", ":/")
+	if err != nil {
+		return nil, err
+	}
+	return splitNUL(buf.String()), nil
+}
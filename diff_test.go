@@ -0,0 +1,52 @@
+package fastcommit
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteDiffUntrackedFromSubdirectory(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "sub/tracked.txt", "one\n")
+	gitT(t, dir, "add", "-A")
+	gitT(t, dir, "commit", "-q", "-m", "Add sub")
+	writeFile(t, dir, "sub/new.txt", "hello\n")
+	writeFile(t, dir, "top.txt", "top\n")
+
+	var b strings.Builder
+	opts := PromptOptions{WorkingTree: true, IncludeUntracked: true}
+	if err := writeDiff(&b, filepath.Join(dir, "sub"), opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"diff --git a/sub/new.txt b/sub/new.txt", "+hello", "b/top.txt"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("diff lacks %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestShallowBoundary(t *testing.T) {
+	origin := newRepo(t)
+	writeFile(t, origin, "a.txt", "a\n")
+	gitT(t, origin, "add", "-A")
+	gitT(t, origin, "commit", "-q", "-m", "Add a")
+	dir := t.TempDir()
+	gitT(t, dir, "clone", "-q", "--depth", "1", "file://"+origin, ".")
+
+	for repo, want := range map[string]bool{origin: false, dir: true} {
+		if got, err := IsShallow(repo); err != nil || got != want {
+			t.Errorf("IsShallow(%s) = %v, %v; want %v", repo, got, err, want)
+		}
+	}
+	if _, err := parentRev(dir, "HEAD"); !errors.Is(err, ErrShallowHistory) {
+		t.Errorf("parentRev at the shallow boundary: %v, want ErrShallowHistory", err)
+	}
+	// A real root commit is still diffed against the empty tree.
+	root := gitT(t, origin, "rev-list", "--max-parents=0", "HEAD")
+	tree := gitT(t, origin, "hash-object", "-t", "tree", "/dev/null")
+	if got, err := parentRev(origin, root); err != nil || got != tree {
+		t.Errorf("parentRev of the root commit = %q, %v; want the empty tree", got, err)
+	}
+}
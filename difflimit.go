@@ -0,0 +1,167 @@
+package fastcommit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxDiffBytes bounds the diff read from git, far beyond what any
+// prompt can hold, so that a pathological change cannot exhaust memory.
+const DefaultMaxDiffBytes = 32 << 20
+
+const (
+	// maxFileDiffBytes bounds the diff kept of a single file.
+	maxFileDiffBytes = 4 << 20
+	// maxDiffLine bounds the bytes kept of a single line, such as one of a
+	// minified file. The line then ends in a note of its full length, which
+	// truncateLongLines keeps when it cuts the line further.
+	maxDiffLine = 64 << 10
+)
+
+// diffLimiter passes a diff through to w, keeping up to max bytes in total
+// and maxFileDiffBytes of each file. Of the files past the total, only the
+// "diff --git" headers are kept, up to twice the total, and the rest are
+// counted. Each file cut short ends with a line saying how much was left
+// out, written like git's "\ No newline at end of file" so that it is not
+// taken for content. Close must be called after the last Write.
+type diffLimiter struct {
+	w   io.Writer
+	max int
+
+	written   int
+	fileBytes int
+	// dropped is the bytes of the current file left out, and
+	// droppedFiles the files whose headers were too.
+	dropped      int
+	droppedFiles int
+	line         []byte
+	lineLen      int
+	lineRunes    int
+}
+
+func newDiffLimiter(w io.Writer, max int) *diffLimiter {
+	if max <= 0 {
+		max = DefaultMaxDiffBytes
+	}
+	return &diffLimiter{w: w, max: max}
+}
+
+func (l *diffLimiter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		chunk := p
+		i := bytes.IndexByte(p, '\n')
+		if i >= 0 {
+			chunk = p[:i+1]
+		}
+		p = p[len(chunk):]
+		if keep := maxDiffLine - len(l.line); keep > 0 {
+			l.line = append(l.line, chunk[:min(len(chunk), keep)]...)
+		}
+		l.lineLen += len(chunk)
+		l.lineRunes += countRunes(bytes.TrimSuffix(chunk, []byte("\n")))
+		if i >= 0 {
+			if err := l.endLine(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (l *diffLimiter) endLine() error {
+	line, n := l.line, l.lineLen
+	if n > len(line) {
+		// Cut between characters, as truncateLongLines would.
+		i := len(line)
+		for i > len(line)-utf8.UTFMax && !utf8.Valid(line[:i]) {
+			i--
+		}
+		line = fmt.Appendf(line[:i], truncatedLine+"\n", l.lineRunes)
+	}
+	defer func() { l.line, l.lineLen, l.lineRunes = l.line[:0], 0, 0 }()
+
+	if bytes.HasPrefix(line, []byte("diff --git ")) {
+		if err := l.endFile(); err != nil {
+			return err
+		}
+		// Headers are kept past the total, up to twice it, so that the
+		// files left out are still named.
+		if l.written+len(line) > 2*l.max {
+			l.droppedFiles++
+			return nil
+		}
+		l.fileBytes = len(line)
+		return l.write(line)
+	}
+	if l.droppedFiles > 0 {
+		// Only counted as files.
+		return nil
+	}
+	if l.dropped > 0 || l.written+len(line) > l.max || l.fileBytes+len(line) > maxFileDiffBytes {
+		l.dropped += n
+		return nil
+	}
+	l.fileBytes += len(line)
+	return l.write(line)
+}
+
+// endFile notes the part of the current file's diff that was left out.
+func (l *diffLimiter) endFile() error {
+	if l.dropped == 0 {
+		return nil
+	}
+	note := fmt.Sprintf("\\ %d more bytes of this file's diff left out\n", l.dropped)
+	l.dropped = 0
+	return l.write([]byte(note))
+}
+
+// countRunes counts the characters starting in b, so that the counts of
+// the parts of a line add up to the characters of the whole line.
+func countRunes(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if utf8.RuneStart(c) {
+			n++
+		}
+	}
+	return n
+}
+
+func (l *diffLimiter) write(b []byte) error {
+	l.written += len(b)
+	_, err := l.w.Write(b)
+	return err
+}
+
+// Close writes what is left of the last line and file.
+func (l *diffLimiter) Close() error {
+	if l.lineLen > 0 {
+		if err := l.endLine(); err != nil {
+			return err
+		}
+	}
+	if err := l.endFile(); err != nil {
+		return err
+	}
+	if l.droppedFiles > 0 {
+		return l.write([]byte(fmt.Sprintf("\\ %d more files left out\n", l.droppedFiles)))
+	}
+	return nil
+}
+
+// QuotePath returns p safe to print on a terminal: quoted with escapes,
+// like git quotes paths, when it holds control characters such as newlines
+// or escape sequences, or is not valid UTF-8; unchanged otherwise.
+func QuotePath(p string) string {
+	for _, r := range p {
+		if r == unicode.ReplacementChar || unicode.IsControl(r) {
+			return strconv.Quote(p)
+		}
+	}
+	return p
+}
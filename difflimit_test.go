@@ -0,0 +1,94 @@
+package fastcommit
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestDiffLimiterLongLine(t *testing.T) {
+	var b strings.Builder
+	l := newDiffLimiter(&b, 0)
+	line := "+" + strings.Repeat("é", 40000) + "\n"
+	// In pieces, splitting characters, as git's output may arrive.
+	for i := 0; i < len(line); i += 4097 {
+		if _, err := l.Write([]byte(line[i:min(i+4097, len(line))])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.Write([]byte(" next\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, rest, _ := strings.Cut(b.String(), "\n")
+	if len(got) > maxDiffLine+64 {
+		t.Errorf("kept %d bytes of the line", len(got))
+	}
+	if !strings.HasSuffix(got, "é... [line truncated, 40001 characters]") {
+		t.Errorf("line ends in %q", got[len(got)-60:])
+	}
+	if rest != " next\n" {
+		t.Errorf("next line = %q", rest)
+	}
+
+	// Cutting further keeps the full length.
+	if got := truncateLongLines(got, 10); got != "+ééééééééé... [line truncated, 40001 characters]" {
+		t.Errorf("truncateLongLines = %q", got)
+	}
+}
+
+func FuzzQuotePath(f *testing.F) {
+	for _, seed := range []string{"a.go", "dir/with space.txt", "new\nline", "\x1b]0;title\x07", "café", "\xff\xfe", "tab\there", " "} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, p string) {
+		q := QuotePath(p)
+		for _, r := range q {
+			if unicode.IsControl(r) {
+				t.Fatalf("QuotePath(%q) = %q keeps a control character", p, q)
+			}
+		}
+		if !utf8.ValidString(q) {
+			t.Fatalf("QuotePath(%q) = %q is not valid UTF-8", p, q)
+		}
+		if q == p {
+			return
+		}
+		if u, err := strconv.Unquote(q); err != nil || u != p {
+			t.Fatalf("QuotePath(%q) = %q does not unquote to the path: %q, %v", p, q, u, err)
+		}
+	})
+}
+
+func FuzzDiffLimiter(f *testing.F) {
+	f.Add("diff --git a/a b/a\n+x\n", 64, 3)
+	f.Add("diff --git a/a b/a\n+"+strings.Repeat("é", 100)+"\ndiff --git a/b b/b\n-y", 10, 7)
+	f.Fuzz(func(t *testing.T, diff string, max, chunk int) {
+		if max <= 0 || max > 1<<16 {
+			max = 1 << 10
+		}
+		if chunk <= 0 {
+			chunk = 1
+		}
+		var b strings.Builder
+		l := newDiffLimiter(&b, max)
+		for i := 0; i < len(diff); i += chunk {
+			if _, err := l.Write([]byte(diff[i:min(i+chunk, len(diff))])); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// Past the total, only headers, up to twice it, and a note of what
+		// was left out of each file are written.
+		if limit := 2*max + 64*(strings.Count(diff, "diff --git ")+1); b.Len() > limit {
+			t.Fatalf("%d bytes written for a limit of %d", b.Len(), max)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package fastcommit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxDigestIdentifiers caps the identifiers listed for a single file.
+const maxDigestIdentifiers = 15
+
+// FileDigest is what a digest tells about one changed file: metadata and
+// the names of what changed in it, but none of its content.
+type FileDigest struct {
+	Path string
+	// OldPath is the path before a rename.
+	OldPath string
+	// Change is "added", "modified", "deleted" or "renamed".
+	Change  string
+	Added   int
+	Deleted int
+	Binary  bool
+	// Identifiers are the changed functions, types and similar, extracted
+	// locally.
+	Identifiers []string
+}
+
+func (d FileDigest) String() string {
+	var b strings.Builder
+	b.WriteString(d.Change + " ")
+	if d.OldPath != "" {
+		b.WriteString(d.OldPath + " -> ")
+	}
+	b.WriteString(d.Path)
+	if d.Binary {
+		b.WriteString(" (binary)")
+	} else {
+		fmt.Fprintf(&b, " (+%d -%d)", d.Added, d.Deleted)
+	}
+	if len(d.Identifiers) > 0 {
+		ids := d.Identifiers
+		if len(ids) > maxDigestIdentifiers {
+			ids = append(ids[:maxDigestIdentifiers:maxDigestIdentifiers],
+				fmt.Sprintf("%d more", len(d.Identifiers)-maxDigestIdentifiers))
+		}
+		b.WriteString(": " + strings.Join(ids, ", "))
+	}
+	return b.String()
+}
+
+// Digest describes the changes selected by opts without their content, for
+// repositories whose code must not leave the machine. Identifiers are taken
+// from each file by the first of extractors that handles it; nil means
+// DefaultIdentifierExtractors.
+func Digest(dir string, opts PromptOptions, extractors IdentifierExtractors) ([]FileDigest, error) {
+	var buf bytes.Buffer
+	if err := generateDiff(&buf, dir, opts); err != nil {
+		return nil, err
+	}
+	return digestDiff(dir, opts, buf.String(), extractors)
+}
+
+func digestDiff(dir string, opts PromptOptions, diff string, extractors IdentifierExtractors) ([]FileDigest, error) {
+	if extractors == nil {
+		extractors = DefaultIdentifierExtractors()
+	}
+	before, after, err := digestSides(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	var digests []FileDigest
+	for _, f := range SplitDiff(diff) {
+		d := FileDigest{Path: f.Path, Change: "modified"}
+		for _, line := range strings.Split(f.Diff, "\n") {
+			if strings.HasPrefix(line, "@@") {
+				break
+			}
+			switch {
+			case strings.HasPrefix(line, "new file mode"):
+				d.Change = "added"
+			case strings.HasPrefix(line, "deleted file mode"):
+				d.Change = "deleted"
+			case strings.HasPrefix(line, "rename from "):
+				d.Change = "renamed"
+				d.OldPath = strings.TrimPrefix(line, "rename from ")
+			case strings.HasPrefix(line, "Binary files "), strings.HasPrefix(line, "GIT binary patch"):
+				d.Binary = true
+			}
+		}
+		removed, added := changedLines(f.Diff)
+		d.Added, d.Deleted = len(added), len(removed)
+		if !d.Binary {
+			d.Identifiers = extractors.Extract(newFileChange(dir, f, d.OldPath, before, after))
+		}
+		digests = append(digests, d)
+	}
+	return digests, nil
+}
+
+// DigestText formats digests for the prompt.
+func DigestText(digests []FileDigest) string {
+	var b strings.Builder
+	b.WriteString("The diff itself may not be sent. This digest lists each changed file with " +
+		"the number of added and removed lines and the functions, types and other names " +
+		"changed in it. Write the message from it and do not guess at details it does not give:\n")
+	for _, d := range digests {
+		b.WriteString(d.String() + "\n")
+	}
+	return b.String()
+}
+
+// digestPrompt returns the digest standing in for diff in the prompt.
+// Vendored files are summarized as usual.
+func digestPrompt(dir string, opts PromptOptions, diff string) (string, error) {
+	dirs := opts.VendorDirs
+	if dirs == nil {
+		dirs = DefaultVendorDirs
+	}
+	rest, vendored := SummarizeVendored(diff, dirs)
+	digests, err := digestDiff(dir, opts, rest, opts.DigestExtractors)
+	if err != nil {
+		return "", err
+	}
+	text := DigestText(digests)
+	for _, v := range vendored {
+		text += "vendored " + v.String() + "\n"
+	}
+	return text, nil
+}
+
+// treeSide is one side of a diff: a revision, the index or the working tree.
+type treeSide struct {
+	rev      string
+	index    bool
+	worktree bool
+}
+
+// read returns the content of the file p on this side, or nil if it does not
+// exist there.
+func (s treeSide) read(dir, p string) []byte {
+	if s.worktree {
+		root, err := findGitRoot(dir)
+		if err != nil {
+			return nil
+		}
+		b, _ := os.ReadFile(filepath.Join(root, p))
+		return b
+	}
+	spec := s.rev + ":" + p
+	if s.index {
+		spec = ":" + p
+	}
+	var buf bytes.Buffer
+	if err := runGit(&buf, dir, "show", spec); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// digestSides returns the two sides compared by the diff opts select, to
+// read whole files from. It follows diffRevArgs.
+func digestSides(dir string, opts PromptOptions) (before, after treeSide, err error) {
+	head := func() (treeSide, error) {
+		var buf bytes.Buffer
+		if err := runGit(&buf, dir, "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+			return treeSide{rev: "HEAD"}, nil
+		}
+		tree, err := emptyTree(dir)
+		return treeSide{rev: tree}, err
+	}
+	switch {
+	case opts.WorkingTree:
+		before, err = head()
+		return before, treeSide{worktree: true}, err
+	case opts.Range != "":
+		from, to, ok := strings.Cut(opts.Range, "...")
+		if !ok {
+			from, to, _ = strings.Cut(opts.Range, "..")
+		}
+		if from == "" {
+			from = "HEAD"
+		}
+		if to == "" {
+			to = "HEAD"
+		}
+		var buf bytes.Buffer
+		if err := runGit(&buf, dir, "merge-base", from, to); err != nil {
+			return before, after, err
+		}
+		return treeSide{rev: strings.TrimSpace(buf.String())}, treeSide{rev: to}, nil
+	case opts.CommitHash == "":
+		before, err = head()
+		return before, treeSide{index: true}, err
+	}
+	parent, err := parentRev(dir, opts.CommitHash)
+	if err != nil {
+		return before, after, err
+	}
+	if opts.Amend {
+		return treeSide{rev: parent}, treeSide{index: true}, nil
+	}
+	return treeSide{rev: parent}, treeSide{rev: opts.CommitHash}, nil
+}
+
+// hunkLines returns the line numbers of the removed lines in the old file
+// and of the added lines in the new file, along with the function context
+// git gives in each hunk header.
+func hunkLines(diff string) (removed, added []int, contexts []string) {
+	oldLine, newLine := 0, 0
+	inHunk := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+			// @@ -12,5 +12,7 @@ func context
+			fields := strings.SplitN(line, "@@", 3)
+			if len(fields) < 3 {
+				continue
+			}
+			if ctx := strings.TrimSpace(fields[2]); ctx != "" {
+				contexts = append(contexts, ctx)
+			}
+			for _, r := range strings.Fields(fields[1]) {
+				start, _, _ := strings.Cut(r[1:], ",")
+				n, _ := strconv.Atoi(start)
+				switch r[0] {
+				case '-':
+					oldLine = n
+				case '+':
+					newLine = n
+				}
+			}
+		case !inHunk:
+		case strings.HasPrefix(line, "-"):
+			removed = append(removed, oldLine)
+			oldLine++
+		case strings.HasPrefix(line, "+"):
+			added = append(added, newLine)
+			newLine++
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+		default:
+			oldLine++
+			newLine++
+		}
+	}
+	return removed, added, contexts
+}
@@ -0,0 +1,74 @@
+package fastcommit
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "server.go", "package app\n\nfunc Serve() {\n\tlisten()\n}\n\nfunc listen() {}\n")
+	writeFile(t, dir, "old.txt", "obsolete\n")
+	writeFile(t, dir, "notes/todo.md", "# TODO\n\n- one\n- two\n- three\n")
+	commitAll(t, dir, "Add files")
+
+	writeFile(t, dir, "server.go", "package app\n\nfunc Serve() {\n\tlisten(secretPort)\n}\n\nfunc listen(port int) {}\n\nconst secretPort = 7\n")
+	writeFile(t, dir, "handlers.py", "def handle(req):\n    return secret_value\n")
+	writeFile(t, dir, "logo.png", "\x89PNG\r\n\x1a\n\x00\x00\x00")
+	gitT(t, dir, "rm", "-q", "old.txt")
+	gitT(t, dir, "mv", "notes/todo.md", "TODO.md")
+	gitT(t, dir, "add", "-A")
+
+	digests, err := Digest(dir, PromptOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FileDigest{
+		{Path: "TODO.md", OldPath: "notes/todo.md", Change: "renamed"},
+		{Path: "handlers.py", Change: "added", Added: 2, Identifiers: []string{"handle"}},
+		{Path: "logo.png", Change: "added", Binary: true},
+		{Path: "old.txt", Change: "deleted", Deleted: 1},
+		{Path: "server.go", Change: "modified", Added: 4, Deleted: 2,
+			Identifiers: []string{"func Serve", "func listen", "const secretPort (added)"}},
+	}
+	if !reflect.DeepEqual(digests, want) {
+		t.Errorf("Digest =\n%+v\nwant\n%+v", digests, want)
+	}
+
+	text := DigestText(digests)
+	for _, line := range []string{
+		"renamed notes/todo.md -> TODO.md (+0 -0)",
+		"added logo.png (binary)",
+		"modified server.go (+4 -2): func Serve, func listen, const secretPort (added)",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("the digest lacks %q:\n%s", line, text)
+		}
+	}
+
+	msgs, err := BuildPromptWithOptions(io.Discard, dir, PromptOptions{MaxTokens: 128000, DigestOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt := promptText(msgs)
+	for _, content := range []string{"listen(secretPort)", "secret_value", "obsolete", "- two", "@@"} {
+		if strings.Contains(prompt, content) {
+			t.Errorf("the digest-only prompt contains %q:\n%s", content, prompt)
+		}
+	}
+	if !strings.Contains(prompt, "modified server.go (+4 -2)") {
+		t.Errorf("the digest-only prompt lacks the digest:\n%s", prompt)
+	}
+}
+
+func TestFileDigestManyIdentifiers(t *testing.T) {
+	d := FileDigest{Path: "a.go", Change: "modified", Added: 1}
+	for i := 0; i < maxDigestIdentifiers+3; i++ {
+		d.Identifiers = append(d.Identifiers, "f"+strings.Repeat("x", i))
+	}
+	if s := d.String(); !strings.HasSuffix(s, ", 3 more") || strings.Count(s, ", ") != maxDigestIdentifiers {
+		t.Errorf("String = %q", s)
+	}
+}
@@ -0,0 +1,461 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AkhilSharma90/GenAI-Code-Committer/fastcommittest"
+)
+
+const message = "Add a greeting to the README"
+
+// bin is the fastcommit binary under test.
+var bin string
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	bin = os.Getenv("FASTCOMMIT_BIN")
+	if bin == "" {
+		dir, err := os.MkdirTemp("", "fastcommit-e2e-")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer os.RemoveAll(dir)
+		bin = filepath.Join(dir, "fastcommit")
+		build := exec.Command("go", "build", "-o", bin, "../cmd/fastcommit")
+		build.Stdout, build.Stderr = os.Stderr, os.Stderr
+		if err := build.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "build fastcommit: %v\n", err)
+			return 1
+		}
+	}
+	return m.Run()
+}
+
+// env is a repository with staged changes, an isolated home and a fake API.
+type env struct {
+	t    *testing.T
+	dir  string
+	vars []string
+	srv  *fastcommittest.Server
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	home := t.TempDir()
+	srv := fastcommittest.NewServer(message)
+	t.Cleanup(srv.Close)
+	e := &env{
+		t:   t,
+		dir: t.TempDir(),
+		srv: srv,
+		vars: []string{
+			"PATH=" + os.Getenv("PATH"),
+			"HOME=" + home,
+			"XDG_CONFIG_HOME=" + filepath.Join(home, ".config"),
+			"XDG_CACHE_HOME=" + filepath.Join(home, ".cache"),
+			"XDG_STATE_HOME=" + filepath.Join(home, ".local", "state"),
+			"LANG=C",
+			"GIT_CONFIG_NOSYSTEM=1",
+			"GIT_CONFIG_GLOBAL=" + filepath.Join(home, ".gitconfig"),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"OPENAI_API_KEY=sk-test",
+			"OPENAI_BASE_URL=" + srv.URL,
+		},
+	}
+	e.git("init", "-q", "-b", "main")
+	e.write("README.md", "# Project\n")
+	e.git("add", "-A")
+	e.git("commit", "-q", "-m", "Initial commit")
+	e.write("README.md", "# Project\n\nHello!\n")
+	e.git("add", "-A")
+	return e
+}
+
+func (e *env) write(name, content string) {
+	e.t.Helper()
+	if err := os.WriteFile(filepath.Join(e.dir, name), []byte(content), 0o644); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+func (e *env) git(args ...string) string {
+	e.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir, cmd.Env = e.dir, e.vars
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		e.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// result is what a run of fastcommit printed and how it exited.
+type result struct {
+	stdout, stderr string
+	code           int
+}
+
+// fastcommit runs the binary with args and --plain, so that stdout holds no
+// escape sequences.
+func (e *env) fastcommit(args ...string) result {
+	e.t.Helper()
+	cmd := exec.Command(bin, append([]string{"--plain"}, args...)...)
+	cmd.Dir, cmd.Env = e.dir, e.vars
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		e.t.Fatalf("run fastcommit: %v", err)
+	}
+	return result{stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()}
+}
+
+func (r result) String() string {
+	return fmt.Sprintf("exit code %d\nstdout:\n%s\nstderr:\n%s", r.code, r.stdout, r.stderr)
+}
+
+func TestCommit(t *testing.T) {
+	e := newEnv(t)
+	r := e.fastcommit()
+	if r.code != 0 {
+		t.Fatalf("commit failed:\n%s", r)
+	}
+	if got := e.git("log", "-1", "--format=%B"); got != message {
+		t.Errorf("committed message = %q, want %q", got, message)
+	}
+	if got := e.git("rev-list", "--count", "HEAD"); got != "2" {
+		t.Errorf("%s commits, want 2", got)
+	}
+	if !strings.HasPrefix(r.stdout, message+"\n") {
+		t.Errorf("stdout does not start with the message:\n%s", r)
+	}
+
+	reqs := e.srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("%d requests, want 1", len(reqs))
+	}
+	msgs := reqs[0].Messages
+	if last := msgs[len(msgs)-1].Content; !strings.Contains(last, "+Hello!") {
+		t.Errorf("prompt lacks the staged change:\n%s", last)
+	}
+}
+
+func TestDry(t *testing.T) {
+	e := newEnv(t)
+	r := e.fastcommit("--dry")
+	if r.code != 0 {
+		t.Fatalf("--dry failed:\n%s", r)
+	}
+	if got := e.git("rev-list", "--count", "HEAD"); got != "1" {
+		t.Errorf("--dry committed: %s commits", got)
+	}
+	if got := e.git("diff", "--cached", "--name-only"); got != "README.md" {
+		t.Errorf("staged files after --dry = %q", got)
+	}
+	want := "git commit -m '" + message + "'"
+	if !strings.Contains(r.stdout, want) {
+		t.Errorf("stdout lacks %q:\n%s", want, r)
+	}
+	if strings.Contains(r.stderr, message) {
+		t.Errorf("the message went to stderr:\n%s", r)
+	}
+}
+
+func TestAmend(t *testing.T) {
+	e := newEnv(t)
+	e.git("commit", "-q", "-m", "WIP")
+	e.write("NOTES.md", "notes\n")
+	e.git("add", "-A")
+
+	r := e.fastcommit("--amend")
+	if r.code != 0 {
+		t.Fatalf("--amend failed:\n%s", r)
+	}
+	if got := e.git("rev-list", "--count", "HEAD"); got != "2" {
+		t.Errorf("%s commits after --amend, want 2", got)
+	}
+	if got := e.git("log", "-1", "--format=%B"); got != message {
+		t.Errorf("amended message = %q, want %q", got, message)
+	}
+	if got := e.git("show", "--format=", "--name-only", "HEAD"); got != "NOTES.md\nREADME.md" {
+		t.Errorf("amended commit changes %q", got)
+	}
+}
+
+func TestRefPreview(t *testing.T) {
+	e := newEnv(t)
+	e.git("commit", "-q", "-m", "WIP")
+	head := e.git("rev-parse", "HEAD")
+
+	r := e.fastcommit("HEAD")
+	if r.code != 0 {
+		t.Fatalf("preview failed:\n%s", r)
+	}
+	if got := e.git("rev-parse", "HEAD"); got != head {
+		t.Errorf("previewing HEAD changed it from %s to %s", head, got)
+	}
+	if strings.TrimSpace(r.stdout) != message {
+		t.Errorf("stdout is not just the message:\n%s", r)
+	}
+	if !strings.Contains(r.stderr, "preview only") {
+		t.Errorf("stderr lacks the preview notice:\n%s", r)
+	}
+	msgs := e.srv.Requests()[0].Messages
+	if last := msgs[len(msgs)-1].Content; !strings.Contains(last, "+Hello!") {
+		t.Errorf("prompt lacks the change of HEAD:\n%s", last)
+	}
+}
+
+func TestOutputJSON(t *testing.T) {
+	e := newEnv(t)
+	r := e.fastcommit("--output", "json")
+	if r.code != 0 {
+		t.Fatalf("--output json failed:\n%s", r)
+	}
+	var out struct {
+		Message   string `json:"message"`
+		Committed bool   `json:"committed"`
+		Commit    string `json:"commit"`
+	}
+	dec := json.NewDecoder(strings.NewReader(r.stdout))
+	if err := dec.Decode(&out); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, r)
+	}
+	if dec.More() {
+		t.Errorf("stdout holds more than one JSON object:\n%s", r)
+	}
+	if out.Message != message || !out.Committed || out.Commit != e.git("rev-parse", "HEAD") {
+		t.Errorf("output = %+v", out)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	t.Run("usage", func(t *testing.T) {
+		e := newEnv(t)
+		if r := e.fastcommit("--no-such-flag"); r.code != 2 {
+			t.Errorf("want exit code 2:\n%s", r)
+		}
+	})
+	t.Run("nothing staged", func(t *testing.T) {
+		e := newEnv(t)
+		e.git("commit", "-q", "-m", "WIP")
+		r := e.fastcommit()
+		if r.code != 1 {
+			t.Errorf("want exit code 1:\n%s", r)
+		}
+		if r.stdout != "" || !strings.Contains(r.stderr, "nothing staged") {
+			t.Errorf("the error is not alone on stderr:\n%s", r)
+		}
+	})
+	t.Run("API error", func(t *testing.T) {
+		e := newEnv(t)
+		e.srv.FailNext(401)
+		r := e.fastcommit()
+		if r.code != 4 {
+			t.Errorf("want exit code 4:\n%s", r)
+		}
+		if got := e.git("rev-list", "--count", "HEAD"); got != "1" {
+			t.Errorf("committed despite the API error: %s commits", got)
+		}
+	})
+	t.Run("output closed", func(t *testing.T) {
+		e := newEnv(t)
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		defer w.Close()
+		cmd := exec.Command(bin, "--plain")
+		cmd.Dir, cmd.Env = e.dir, e.vars
+		cmd.Stdout = w
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		cmd.Run()
+		if code := cmd.ProcessState.ExitCode(); code != 141 {
+			t.Errorf("exit code %d with stdout closed, want 141\n%s", code, stderr.String())
+		}
+		if got := e.git("rev-list", "--count", "HEAD"); got != "1" {
+			t.Errorf("committed with stdout closed: %s commits", got)
+		}
+	})
+	t.Run("git error", func(t *testing.T) {
+		e := newEnv(t)
+		e.write(filepath.Join(".git", "hooks", "pre-commit"), "#!/bin/sh\nexit 1\n")
+		if err := os.Chmod(filepath.Join(e.dir, ".git", "hooks", "pre-commit"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if r := e.fastcommit(); r.code != 5 {
+			t.Errorf("want exit code 5:\n%s", r)
+		}
+	})
+}
+
+// shallow replaces the repository with a clone of it of depth 1, with the
+// same change staged.
+func (e *env) shallow() {
+	e.t.Helper()
+	e.git("commit", "-q", "-m", "Greet in the README")
+	e.write("NOTES.md", "notes\n")
+	e.git("add", "-A")
+	e.git("commit", "-q", "-m", "Add notes")
+	origin := e.dir
+	e.dir = e.t.TempDir()
+	e.git("clone", "-q", "--depth", "1", "file://"+origin, ".")
+	e.write("NOTES.md", "notes\nmore notes\n")
+	e.git("add", "-A")
+}
+
+func TestShallowClone(t *testing.T) {
+	t.Run("commit", func(t *testing.T) {
+		e := newEnv(t)
+		e.shallow()
+		r := e.fastcommit("-v")
+		if r.code != 0 {
+			t.Fatalf("commit failed:\n%s", r)
+		}
+		if got := e.git("log", "-1", "--format=%B"); got != message {
+			t.Errorf("committed message = %q, want %q", got, message)
+		}
+		if !strings.Contains(r.stderr, "shallow clone") {
+			t.Errorf("-v does not note the shallow clone:\n%s", r)
+		}
+		for _, m := range e.srv.Requests()[0].Messages {
+			if strings.Contains(m.Content, "Add notes") {
+				t.Errorf("the prompt shows history from the shallow clone:\n%s", m.Content)
+			}
+		}
+	})
+	t.Run("boundary commit", func(t *testing.T) {
+		e := newEnv(t)
+		e.shallow()
+		// The cloned HEAD is on origin/main, so amending it needs
+		// --allow-pushed-amend.
+		for _, args := range [][]string{{"HEAD"}, {"--amend", "--allow-pushed-amend"}} {
+			r := e.fastcommit(args...)
+			if r.code == 0 {
+				t.Errorf("%s succeeded without the parent commit:\n%s", args, r)
+			}
+			if !strings.Contains(r.stderr, "git fetch --deepen") || strings.Contains(r.stderr, "fatal:") {
+				t.Errorf("%s does not explain the missing history:\n%s", args, r)
+			}
+		}
+		if n := len(e.srv.Requests()); n != 0 {
+			t.Errorf("%d requests made without the parent commit", n)
+		}
+	})
+}
+
+// failNextCommit makes the next git commit fail in a pre-commit hook, as
+// when signing is cancelled, so that fastcommit saves the message.
+func (e *env) failNextCommit() {
+	e.t.Helper()
+	hook := filepath.Join(e.dir, ".git", "hooks", "pre-commit")
+	if err := os.MkdirAll(filepath.Dir(hook), 0o755); err != nil {
+		e.t.Fatal(err)
+	}
+	script := "#!/bin/sh\nrm \"$0\"\nexit 1\n"
+	if err := os.WriteFile(hook, []byte(script), 0o755); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+func TestSavedMessage(t *testing.T) {
+	const saved = "Add the saved change"
+	// newSaved returns an environment where the last commit failed and
+	// saved its message, with the change still staged.
+	newSaved := func(t *testing.T) *env {
+		e := newEnv(t)
+		e.git("commit", "-q", "-m", "Unrelated commit")
+		e.write("NOTES.md", "notes\n")
+		e.git("add", "-A")
+		e.srv.SetMessage(saved)
+		e.failNextCommit()
+		if r := e.fastcommit(); r.code != 5 {
+			t.Fatalf("want the commit to fail:\n%s", r)
+		}
+		e.srv.SetMessage(message)
+		return e
+	}
+	subjects := func(e *env) string {
+		return e.git("log", "--format=%s")
+	}
+
+	t.Run("absent, new commit", func(t *testing.T) {
+		e := newEnv(t)
+		if r := e.fastcommit(); r.code != 0 || strings.Contains(r.stderr, "never landed") {
+			t.Errorf("commit without a saved message:\n%s", r)
+		}
+	})
+	t.Run("absent, amend", func(t *testing.T) {
+		e := newEnv(t)
+		e.git("commit", "-q", "-m", "WIP")
+		if r := e.fastcommit("--amend"); r.code != 0 || strings.Contains(r.stderr, "never landed") {
+			t.Errorf("--amend without a saved message:\n%s", r)
+		}
+		if got := subjects(e); got != message+"\nInitial commit" {
+			t.Errorf("history after --amend:\n%s", got)
+		}
+	})
+	t.Run("present, new commit", func(t *testing.T) {
+		e := newSaved(t)
+		if r := e.fastcommit(); r.code != 0 {
+			t.Fatalf("commit with a saved message:\n%s", r)
+		}
+		if got := subjects(e); got != message+"\nUnrelated commit\nInitial commit" {
+			t.Errorf("history:\n%s", got)
+		}
+	})
+	t.Run("present, amend", func(t *testing.T) {
+		e := newSaved(t)
+		head := e.git("rev-parse", "HEAD")
+		r := e.fastcommit("--amend")
+		if r.code == 0 || !strings.Contains(r.stderr, "never landed") || !strings.Contains(r.stderr, "--use-saved") {
+			t.Errorf("--amend with a saved message outside a terminal:\n%s", r)
+		}
+		if got := e.git("rev-parse", "HEAD"); got != head {
+			t.Errorf("the unrelated commit was amended")
+		}
+		if n := len(e.srv.Requests()); n != 1 {
+			t.Errorf("%d requests, want only the one of the failed commit", n)
+		}
+	})
+	t.Run("present, amend, --use-saved", func(t *testing.T) {
+		e := newSaved(t)
+		if r := e.fastcommit("--amend", "--use-saved"); r.code != 0 {
+			t.Fatalf("--amend --use-saved:\n%s", r)
+		}
+		if got := subjects(e); got != saved+"\nUnrelated commit\nInitial commit" {
+			t.Errorf("history:\n%s", got)
+		}
+	})
+	t.Run("present, amend, --discard-saved", func(t *testing.T) {
+		e := newSaved(t)
+		if r := e.fastcommit("--amend", "--discard-saved"); r.code != 0 {
+			t.Fatalf("--amend --discard-saved:\n%s", r)
+		}
+		if got := subjects(e); got != message+"\nInitial commit" {
+			t.Errorf("history:\n%s", got)
+		}
+		// The saved message is gone.
+		if r := e.fastcommit("--use-saved"); r.code == 0 {
+			t.Errorf("--use-saved after --discard-saved:\n%s", r)
+		}
+	})
+}
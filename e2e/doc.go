@@ -0,0 +1,11 @@
+// Package e2e holds the end-to-end tests of the fastcommit command: they run
+// the compiled binary in temporary repositories against a
+// fastcommittest.Server, and pin down the behavior scripts and packagers
+// rely on, such as exit codes and what goes to stdout and stderr. They are
+// built only with the e2e tag:
+//
+//	go test -tags e2e ./e2e/...
+//
+// FASTCOMMIT_BIN selects a binary to test, e.g. a packaged release, instead
+// of one built from this tree.
+package e2e
@@ -0,0 +1,107 @@
+package fastcommit
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// commitMessageFile is the name EditorConfig sections are matched against
+// for commit messages, as editors see it when git opens one.
+const commitMessageFile = "COMMIT_EDITMSG"
+
+// EditorConfigLineLength returns the max_line_length that .editorconfig files
+// apply to commit messages, looking in dir and its parents. As in
+// EditorConfig, nearer files take precedence and the search stops at a file
+// with root = true. ok is false when no usable value is set, including when
+// it is "off" or not a number.
+func EditorConfigLineLength(dir string) (n int, ok bool, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return 0, false, err
+	}
+	for {
+		value, found, root, err := readEditorConfig(filepath.Join(dir, ".editorconfig"), "max_line_length")
+		if err != nil {
+			return 0, false, err
+		}
+		if found {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return 0, false, nil
+			}
+			return n, true, nil
+		}
+		parent := filepath.Dir(dir)
+		if root || parent == dir {
+			return 0, false, nil
+		}
+		dir = parent
+	}
+}
+
+// readEditorConfig returns the value of key for commit messages in the
+// .editorconfig file at path, if the file exists and sets it. Later sections
+// override earlier ones.
+func readEditorConfig(path, key string) (value string, found, root bool, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, false, nil
+		}
+		return "", false, false, err
+	}
+	defer file.Close()
+
+	preamble, matches := true, false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[' && strings.HasSuffix(line, "]"):
+			preamble = false
+			matches = editorConfigSectionMatches(line[1 : len(line)-1])
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.ToLower(strings.TrimSpace(v))
+		switch {
+		case preamble && k == "root":
+			root = v == "true"
+		case matches && k == key:
+			value, found = v, true
+		}
+	}
+	return value, found, root, scanner.Err()
+}
+
+// editorConfigSectionMatches reports whether a section header applies to
+// commit messages. Only brace lists of alternatives are expanded, which is
+// all that matters for matching a single file name.
+func editorConfigSectionMatches(glob string) bool {
+	alternatives := []string{glob}
+	if i := strings.Index(glob, "{"); i >= 0 {
+		if j := strings.Index(glob[i:], "}"); j >= 0 {
+			alternatives = nil
+			for _, alt := range strings.Split(glob[i+1:i+j], ",") {
+				alternatives = append(alternatives, glob[:i]+alt+glob[i+j+1:])
+			}
+		}
+	}
+	for _, alt := range alternatives {
+		// "**" can match nothing, which is all a file name needs from it.
+		alt = strings.ReplaceAll(alt, "**/", "")
+		if MatchGlob(alt, commitMessageFile) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package fastcommit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lineEndingShare is the share of a file's changed lines that must differ
+// only in their line endings for DescribeLineEndingChanges to collapse it.
+const lineEndingShare = 0.95
+
+// DescribeLineEndingChanges rewrites the parts of diff whose changed lines
+// almost all differ only in their line endings, as after converting a file
+// from CRLF to LF or a core.autocrlf mixup, into a note saying so. Every line
+// of such a file shows as changed, which wastes tokens and reads as a
+// rewrite. The few lines that changed otherwise are kept after the note.
+// Other parts are returned unchanged.
+func DescribeLineEndingChanges(diff string) string {
+	if !strings.Contains(diff, "\r") {
+		return diff
+	}
+	files := SplitDiff(diff)
+	// Keep anything before the first file as is.
+	n := len(diff)
+	for _, f := range files {
+		n -= len(f.Diff)
+	}
+	var b strings.Builder
+	b.WriteString(diff[:n])
+	for _, f := range files {
+		b.WriteString(describeLineEndingChange(f))
+	}
+	return b.String()
+}
+
+// describeLineEndingChange returns f's diff, rewritten as described for
+// DescribeLineEndingChanges.
+func describeLineEndingChange(f FileDiff) string {
+	removed, added := changedLines(f.Diff)
+	if len(removed) == 0 || len(added) == 0 {
+		return f.Diff
+	}
+
+	// Pair each added line with a removed line that is the same but for
+	// its line ending.
+	unpaired := map[string][]int{}
+	for i, line := range removed {
+		if strings.HasSuffix(line, "\r") {
+			key := strings.TrimSuffix(line, "\r")
+			unpaired[key] = append(unpaired[key], i)
+		}
+	}
+	pairedRemoved := make([]bool, len(removed))
+	pairedAdded := make([]bool, len(added))
+	toLF := 0
+	for i, line := range added {
+		if strings.HasSuffix(line, "\r") {
+			continue
+		}
+		if idx := unpaired[line]; len(idx) > 0 {
+			pairedRemoved[idx[0]], pairedAdded[i] = true, true
+			unpaired[line] = idx[1:]
+			toLF++
+		}
+	}
+	for key := range unpaired {
+		delete(unpaired, key)
+	}
+	for i, line := range removed {
+		if !pairedRemoved[i] && !strings.HasSuffix(line, "\r") {
+			unpaired[line] = append(unpaired[line], i)
+		}
+	}
+	toCRLF := 0
+	for i, line := range added {
+		if pairedAdded[i] || !strings.HasSuffix(line, "\r") {
+			continue
+		}
+		key := strings.TrimSuffix(line, "\r")
+		if idx := unpaired[key]; len(idx) > 0 {
+			pairedRemoved[idx[0]], pairedAdded[i] = true, true
+			unpaired[key] = idx[1:]
+			toCRLF++
+		}
+	}
+
+	pairs := toLF + toCRLF
+	if float64(2*pairs) < lineEndingShare*float64(len(removed)+len(added)) {
+		return f.Diff
+	}
+
+	var what string
+	switch {
+	case toCRLF == 0:
+		what = fmt.Sprintf("%s converted from CRLF to LF", countNoun(pairs, "line"))
+	case toLF == 0:
+		what = fmt.Sprintf("%s converted from LF to CRLF", countNoun(pairs, "line"))
+	default:
+		what = fmt.Sprintf("%s converted from CRLF to LF and %s from LF to CRLF",
+			countNoun(toLF, "line"), countNoun(toCRLF, "line"))
+	}
+	header, _, _ := strings.Cut(f.Diff, "\n")
+	var b strings.Builder
+	b.WriteString(header + "\n")
+	var rest []string
+	for i, line := range removed {
+		if !pairedRemoved[i] {
+			rest = append(rest, "-"+line)
+		}
+	}
+	for i, line := range added {
+		if !pairedAdded[i] {
+			rest = append(rest, "+"+line)
+		}
+	}
+	if len(rest) == 0 {
+		fmt.Fprintf(&b, "Line-ending normalization only: %s, with no other changes. "+
+			"Describe it as a line-ending normalization.\n", what)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Line-ending normalization: %s. The only other changes are:\n", what)
+	b.WriteString(strings.Join(rest, "\n") + "\n")
+	return b.String()
+}
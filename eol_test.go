@@ -0,0 +1,86 @@
+package fastcommit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns n lines of text ending in eol.
+func numberedLines(n int, format, eol string) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, format+eol, i)
+	}
+	return b.String()
+}
+
+func TestLineEndingFixtures(t *testing.T) {
+	dir := newRepo(t)
+	gitT(t, dir, "config", "core.autocrlf", "false")
+	writeFile(t, dir, "docs/guide.txt", numberedLines(40, "Guide line %d", "\r\n"))
+	writeFile(t, dir, "src/app.cs", numberedLines(40, "var x%d = 1;", "\r\n"))
+	writeFile(t, dir, "src/rewrite.cs", numberedLines(40, "var old%d = 1;", "\r\n"))
+	writeFile(t, dir, "notes.txt", "first\nsecond\n")
+	commitAll(t, dir, "Add CRLF files")
+
+	// Converted only.
+	writeFile(t, dir, "docs/guide.txt", numberedLines(40, "Guide line %d", "\n"))
+	// Converted, with one real change.
+	app := strings.Replace(numberedLines(40, "var x%d = 1;", "\n"), "var x7 = 1;", "var x7 = 2;", 1)
+	writeFile(t, dir, "src/app.cs", app)
+	// Converted while rewritten: no line is the same but for its ending.
+	writeFile(t, dir, "src/rewrite.cs", numberedLines(40, "let renamed%d = 1;", "\n"))
+	// A real change in a file that always had LF.
+	writeFile(t, dir, "notes.txt", "first\nsecond\nthird\n")
+	gitT(t, dir, "add", "-A")
+
+	msgs, err := BuildPromptWithOptions(io.Discard, dir, PromptOptions{MaxTokens: 128000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := promptText(msgs)
+	for _, want := range []string{
+		"diff --git a/docs/guide.txt b/docs/guide.txt\nLine-ending normalization only: 40 lines converted from CRLF to LF, with no other changes.",
+		"diff --git a/src/app.cs b/src/app.cs\nLine-ending normalization: 39 lines converted from CRLF to LF. The only other changes are:\n-var x7 = 1;\r\n+var x7 = 2;\n",
+		"+let renamed0 = 1;",
+		"-var old39 = 1;",
+		"+third",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("the prompt lacks %q:\n%s", want, text)
+		}
+	}
+	for _, unwanted := range []string{"+Guide line 3", "-Guide line 3", "+var x3 = 1;"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("the prompt shows line-ending changes as %q:\n%s", unwanted, text)
+		}
+	}
+}
+
+func TestDescribeLineEndingChangesShare(t *testing.T) {
+	// 18 of 20 lines converted and two rewritten: under 95%, so the diff
+	// is left as it is.
+	var diff strings.Builder
+	diff.WriteString("diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,20 +1,20 @@\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&diff, "-line %d\r\n", i)
+	}
+	for i := 0; i < 20; i++ {
+		if i < 2 {
+			fmt.Fprintf(&diff, "+changed %d\n", i)
+		} else {
+			fmt.Fprintf(&diff, "+line %d\n", i)
+		}
+	}
+	if got := DescribeLineEndingChanges(diff.String()); got != diff.String() {
+		t.Errorf("a diff under the share was collapsed:\n%s", got)
+	}
+
+	// LF to CRLF is recognized too.
+	lf := "diff --git a/b.txt b/b.txt\n--- a/b.txt\n+++ b/b.txt\n@@ -1,2 +1,2 @@\n-one\n-two\n+one\r\n+two\r\n"
+	if got, want := DescribeLineEndingChanges(lf), "2 lines converted from LF to CRLF"; !strings.Contains(got, want) {
+		t.Errorf("DescribeLineEndingChanges = %q, want %q", got, want)
+	}
+}
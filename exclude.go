@@ -0,0 +1,120 @@
+package fastcommit
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultExcludes are the files whose diffs are left out of the prompt unless
+// PromptOptions.NoExclude is set: lockfiles and generated code, whose diffs
+// are large and say little about the change. Files that .gitattributes marks
+// linguist-generated are left out too, and vendored files are summarized
+// separately.
+var DefaultExcludes = []string{
+	"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "bun.lockb",
+	"go.sum", "go.work.sum", "Cargo.lock", "poetry.lock", "Pipfile.lock", "uv.lock",
+	"Gemfile.lock", "composer.lock", "mix.lock", "pubspec.lock", "Podfile.lock", "flake.lock",
+	"*.pb.go", "*.pb.gw.go", "*_pb2.py", "*_pb2_grpc.py", "*.pb.cc", "*.pb.h",
+	"*.min.js", "*.min.css", "*.js.map", "*.css.map",
+}
+
+// ExcludedFile is a file whose diff was left out of the prompt.
+type ExcludedFile struct {
+	Path    string
+	Added   int
+	Deleted int
+}
+
+func (e ExcludedFile) String() string {
+	return fmt.Sprintf("%s: %s, %s (excluded from diff)", e.Path,
+		countNoun(e.Added, "insertion"), countNoun(e.Deleted, "deletion"))
+}
+
+// countNoun formats n with thousands separators followed by noun, plural
+// unless n is 1.
+func countNoun(n int, noun string) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	if n != 1 {
+		noun += "s"
+	}
+	return s + " " + noun
+}
+
+// ExcludeFiles removes the files matching any of patterns, or for which
+// generated reports true, from diff and returns them with their line counts
+// instead. Patterns match as in MatchGlob. generated may be nil.
+func ExcludeFiles(diff string, patterns []string, generated func(string) bool) (rest string, excluded []ExcludedFile) {
+	var b strings.Builder
+	files := SplitDiff(diff)
+	// Keep anything before the first file as is.
+	n := len(diff)
+	for _, f := range files {
+		n -= len(f.Diff)
+	}
+	b.WriteString(diff[:n])
+	for _, f := range files {
+		if !matchesAny(patterns, f.Path) && (generated == nil || !generated(f.Path)) {
+			b.WriteString(f.Diff)
+			continue
+		}
+		removed, added := changedLines(f.Diff)
+		excluded = append(excluded, ExcludedFile{Path: f.Path, Added: len(added), Deleted: len(removed)})
+	}
+	return b.String(), excluded
+}
+
+// linguistGenerated returns the paths, relative to the root of the repository
+// containing dir, that .gitattributes marks linguist-generated.
+func linguistGenerated(dir string, paths []string) map[string]bool {
+	root, err := findGitRoot(dir)
+	if err != nil || len(paths) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	args := append([]string{"check-attr", "-z", "linguist-generated", "--"}, paths...)
+	if err := runGit(&buf, root, args...); err != nil {
+		return nil
+	}
+	generated := map[string]bool{}
+	// Records are path, attribute and value, each NUL-terminated.
+	fields := strings.Split(buf.String(), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		if v := fields[i+2]; v == "set" || v == "true" {
+			generated[fields[i]] = true
+		}
+	}
+	return generated
+}
+
+// excludedNote replaces the excluded files of diff with a line each, so that
+// the model can still mention them, e.g. as a dependency update.
+func excludedNote(dir, diff string, opts PromptOptions) string {
+	if opts.NoExclude {
+		return diff
+	}
+	patterns := append(DefaultExcludes[:len(DefaultExcludes):len(DefaultExcludes)], opts.Exclude...)
+	var generated map[string]bool
+	if !opts.Minimal {
+		var paths []string
+		for _, f := range SplitDiff(diff) {
+			paths = append(paths, f.Path)
+		}
+		generated = linguistGenerated(dir, paths)
+	}
+	rest, excluded := ExcludeFiles(diff, patterns, func(p string) bool { return generated[p] })
+	if len(excluded) == 0 {
+		return diff
+	}
+	var b strings.Builder
+	b.WriteString("These files changed too, but their diffs are left out, e.g. as lockfiles or generated code. " +
+		"Mention them only for what they are, such as a dependency update:\n")
+	for _, e := range excluded {
+		b.WriteString(e.String() + "\n")
+	}
+	return b.String() + "\n" + rest
+}
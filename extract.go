@@ -0,0 +1,260 @@
+package fastcommit
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"slices"
+)
+
+// FileChange is a changed file as an IdentifierExtractor sees it. Its
+// content is only read locally.
+type FileChange struct {
+	Path string
+	// Diff is the file's part of the diff.
+	Diff string
+	load func(after bool) []byte
+}
+
+func newFileChange(dir string, f FileDiff, oldPath string, before, after treeSide) FileChange {
+	if oldPath == "" {
+		oldPath = f.Path
+	}
+	return FileChange{
+		Path: f.Path,
+		Diff: f.Diff,
+		load: func(isAfter bool) []byte {
+			if isAfter {
+				return after.read(dir, f.Path)
+			}
+			return before.read(dir, oldPath)
+		},
+	}
+}
+
+// Source returns the whole file before or after the change, or nil if it
+// does not exist on that side.
+func (c FileChange) Source(after bool) []byte {
+	if c.load == nil {
+		return nil
+	}
+	return c.load(after)
+}
+
+// IdentifierExtractor finds the names of the functions, types and similar
+// that a change touches.
+type IdentifierExtractor struct {
+	Name string
+	// Extensions are the file extensions handled, such as ".go". An
+	// extractor without extensions handles any file.
+	Extensions []string
+	Fn         func(FileChange) []string
+}
+
+// IdentifierExtractors are tried in order; the first that handles a file
+// extracts its identifiers.
+type IdentifierExtractors []IdentifierExtractor
+
+// DefaultIdentifierExtractors returns the extractors Digest uses by default:
+// Go's own parser for Go, patterns for other common languages and a generic
+// pattern for anything else. The returned slice is a fresh copy that
+// embedders may extend; put more specific extractors first.
+func DefaultIdentifierExtractors() IdentifierExtractors {
+	return IdentifierExtractors{
+		GoExtractor,
+		RegexExtractor("Python", []string{".py"},
+			`^\s*(?:async\s+)?(?:def|class)\s+(\w+)`),
+		RegexExtractor("JavaScript", []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx"},
+			`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?`+
+				`(?:function\s*\*?\s*(\w+)|class\s+(\w+)|interface\s+(\w+)|type\s+(\w+)\s*=|`+
+				`(?:const|let|var)\s+(\w+)\s*=\s*(?:async\s*)?(?:\([^)]*\)|\w+)\s*=>)`),
+		RegexExtractor("Rust", []string{".rs"},
+			`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:unsafe\s+)?`+
+				`(?:fn|struct|enum|trait|mod|type|const|static|impl(?:<[^>]*>)?)\s+(\w+)`),
+		RegexExtractor("Ruby", []string{".rb"},
+			`^\s*(?:def\s+(?:self\.)?|class\s+|module\s+)(\w+[?!]?)`),
+		RegexExtractor("Java", []string{".java", ".kt", ".cs", ".scala"},
+			`^\s*(?:(?:public|private|protected|internal|static|final|abstract|override|open|data|sealed|async)\s+)*`+
+				`(?:(?:class|interface|enum|record|object|fun|def)\s+(\w+)|[\w<>\[\],.?]+\s+(\w+)\s*\([^;]*$)`),
+		RegexExtractor("C", []string{".c", ".h", ".cc", ".cpp", ".cxx", ".hpp"},
+			`^\s*(?:(?:struct|class|enum|union)\s+(\w+)\s*[{:]|(?:[\w:*&<>]+\s+)+\**(\w+)\s*\([^;]*$)`),
+		RegexExtractor("generic", nil,
+			`^\s*(?:export\s+)?(?:func|function|def|class|struct|interface|enum|fn|type|module|sub|proc)\s+(\w+)`),
+	}
+}
+
+// Extract returns the identifiers of c according to the first extractor
+// that handles it.
+func (e IdentifierExtractors) Extract(c FileChange) []string {
+	ext := path.Ext(c.Path)
+	for _, x := range e {
+		if len(x.Extensions) == 0 || slices.Contains(x.Extensions, ext) {
+			return x.Fn(c)
+		}
+	}
+	return nil
+}
+
+// RegexExtractor returns an extractor that matches pattern against the
+// changed lines and the function context of each hunk header. The first
+// non-empty group of a match is the identifier.
+func RegexExtractor(name string, extensions []string, pattern string) IdentifierExtractor {
+	re := regexp.MustCompile(pattern)
+	return IdentifierExtractor{
+		Name:       name,
+		Extensions: extensions,
+		Fn: func(c FileChange) []string {
+			return regexIdentifiers(re, c)
+		},
+	}
+}
+
+func regexIdentifiers(re *regexp.Regexp, c FileChange) []string {
+	removed, added := changedLines(c.Diff)
+	_, _, contexts := hunkLines(c.Diff)
+	var ids []string
+	lines := append(append(contexts, added...), removed...)
+	for _, line := range lines {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, g := range m[1:] {
+			if g != "" {
+				if !slices.Contains(ids, g) {
+					ids = append(ids, g)
+				}
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// GoExtractor finds the changed declarations of Go files by parsing both
+// versions of the file, so that a change inside a function body is
+// attributed to the function. Declarations that only exist on one side are
+// marked as added or removed. Files that do not parse, e.g. halfway through
+// a rebase conflict, fall back to a pattern for func and type lines.
+var GoExtractor = IdentifierExtractor{
+	Name:       "Go",
+	Extensions: []string{".go"},
+	Fn:         goIdentifiers,
+}
+
+// goDecl is a top-level declaration and the lines it spans.
+type goDecl struct {
+	name       string
+	start, end int
+}
+
+func goIdentifiers(c FileChange) []string {
+	oldDecls, okOld := goDecls(c.Source(false))
+	newDecls, okNew := goDecls(c.Source(true))
+	if !okOld || !okNew {
+		return regexIdentifiers(goFallbackRe, c)
+	}
+	removedLines, addedLines, _ := hunkLines(c.Diff)
+	touched := func(decls []goDecl, lines []int) []string {
+		var names []string
+		for _, d := range decls {
+			if slices.ContainsFunc(lines, func(l int) bool { return l >= d.start && l <= d.end }) {
+				names = append(names, d.name)
+			}
+		}
+		return names
+	}
+	declared := func(decls []goDecl, name string) bool {
+		return slices.ContainsFunc(decls, func(d goDecl) bool { return d.name == name })
+	}
+
+	var ids []string
+	add := func(id string) {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	for _, name := range touched(newDecls, addedLines) {
+		if declared(oldDecls, name) {
+			add(name)
+		} else {
+			add(name + " (added)")
+		}
+	}
+	for _, name := range touched(oldDecls, removedLines) {
+		if declared(newDecls, name) {
+			add(name)
+		} else {
+			add(name + " (removed)")
+		}
+	}
+	return ids
+}
+
+var goFallbackRe = regexp.MustCompile(`^\s*(?:func(?:\s*\([^)]*\))?|type)\s+(\w+)`)
+
+// goDecls returns the top-level declarations of src, named like "func F",
+// "method T.M" or "type T". ok is false if src does not parse; a missing
+// file has no declarations.
+func goDecls(src []byte) (decls []goDecl, ok bool) {
+	if src == nil {
+		return nil, true
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, false
+	}
+	add := func(name string, node ast.Node) {
+		decls = append(decls, goDecl{
+			name:  name,
+			start: fset.Position(node.Pos()).Line,
+			end:   fset.Position(node.End()).Line,
+		})
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add("method "+receiverName(d.Recv.List[0].Type)+"."+d.Name.Name, d)
+			} else {
+				add("func "+d.Name.Name, d)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add("type "+s.Name.Name, s)
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						if n.Name != "_" {
+							add(d.Tok.String()+" "+n.Name, s)
+						}
+					}
+				}
+			}
+		}
+	}
+	return decls, true
+}
+
+// receiverName returns the type name of a method receiver such as *T or
+// T[K].
+func receiverName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return "?"
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package fastcommit
+
+import (
+	"slices"
+	"testing"
+)
+
+// fileChange returns a change of name whose hunk adds lines, with ctx as
+// the hunk header's function context.
+func fileChange(name, ctx string, lines ...string) FileChange {
+	diff := "diff --git a/" + name + " b/" + name + "\n--- a/" + name + "\n+++ b/" + name + "\n@@ -1,0 +1,9 @@ " + ctx + "\n"
+	for _, l := range lines {
+		diff += "+" + l + "\n"
+	}
+	return FileChange{Path: name, Diff: diff}
+}
+
+func TestIdentifierExtractors(t *testing.T) {
+	tests := []struct {
+		name string
+		c    FileChange
+		want []string
+	}{
+		{"Python", fileChange("app/models.py", "class User:",
+			"    def save(self):", "async def fetch(url):", "x = 1"), []string{"User", "save", "fetch"}},
+		{"JavaScript", fileChange("src/api.ts", "",
+			"export default async function load() {", "export class Store {", "interface Props {",
+			"type Id = string", "const handle = async (e) => {", "const n = 1"), []string{"load", "Store", "Props", "Id", "handle"}},
+		{"Rust", fileChange("src/lib.rs", "",
+			"pub(crate) async fn run() {", "struct Config {", "impl<T> Parser<T> {", "let x = 1;"), []string{"run", "Config", "Parser"}},
+		{"Ruby", fileChange("lib/user.rb", "",
+			"module Accounts", "  def self.find!(id)", "  def admin?"), []string{"Accounts", "find!", "admin?"}},
+		{"Java", fileChange("src/Main.java", "",
+			"public final class Main {", "  private static List<String> names(int n) {", "  return x;"), []string{"Main", "names"}},
+		{"C", fileChange("src/buf.c", "",
+			"struct buffer {", "static int buf_grow(struct buffer *b, size_t n)", "int x;"), []string{"buffer", "buf_grow"}},
+		{"generic", fileChange("build.zig", "", "pub fn main() void {", "fn helper() void {"), []string{"helper"}},
+		{"no identifiers", fileChange("README.md", "", "Some text"), nil},
+		// Without the sources, a Go file that does not parse falls back to a
+		// pattern.
+		{"Go fallback", FileChange{Path: "x.go", Diff: fileChange("x.go", "func Old() {", "func (s *Server) Start() {", "type Conf struct {").Diff,
+			load: func(bool) []byte { return []byte("package x\nfunc {") }}, []string{"Old", "Start", "Conf"}},
+	}
+	for _, tt := range tests {
+		if got := DefaultIdentifierExtractors().Extract(tt.c); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Extract = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGoExtractorParsesBothSides(t *testing.T) {
+	before := "package x\n\nfunc A() {\n\treturn\n}\n\nfunc B() {}\n\ntype T struct{}\n"
+	after := "package x\n\nfunc A() {\n\tprintln()\n\treturn\n}\n\ntype T struct{}\n\nfunc (t *T) M() {}\n"
+	// A change inside A's body, B removed and T.M added.
+	diff := "diff --git a/x.go b/x.go\n--- a/x.go\n+++ b/x.go\n" +
+		"@@ -3,0 +4 @@ func A() {\n+\tprintln()\n" +
+		"@@ -7,2 +7,0 @@\n-func B() {}\n-\n" +
+		"@@ -9,0 +10,2 @@\n+\n+func (t *T) M() {}\n"
+	c := FileChange{Path: "x.go", Diff: diff, load: func(isAfter bool) []byte {
+		if isAfter {
+			return []byte(after)
+		}
+		return []byte(before)
+	}}
+	want := []string{"func A", "method T.M (added)", "func B (removed)"}
+	if got := GoExtractor.Fn(c); !slices.Equal(got, want) {
+		t.Errorf("GoExtractor = %q, want %q", got, want)
+	}
+}
+
+func TestIdentifierExtractorsOrder(t *testing.T) {
+	custom := IdentifierExtractor{Name: "SQL", Extensions: []string{".py"}, Fn: func(FileChange) []string {
+		return []string{"custom"}
+	}}
+	c := fileChange("a.py", "", "def f():")
+	if got := append(IdentifierExtractors{custom}, DefaultIdentifierExtractors()...).Extract(c); !slices.Equal(got, []string{"custom"}) {
+		t.Errorf("an extractor put first was not used: %q", got)
+	}
+	if got := append(DefaultIdentifierExtractors(), custom).Extract(c); !slices.Equal(got, []string{"f"}) {
+		t.Errorf("an extractor put last took over: %q", got)
+	}
+}
+
+func TestHunkLines(t *testing.T) {
+	diff := "@@ -10,3 +10,4 @@ func F() {\n a\n-b\n+c\n+d\n e\n\\ No newline at end of file\n@@ -40 +41 @@\n-x\n+y\n"
+	removed, added, contexts := hunkLines(diff)
+	if !slices.Equal(removed, []int{11, 40}) || !slices.Equal(added, []int{11, 12, 41}) || !slices.Equal(contexts, []string{"func F() {"}) {
+		t.Errorf("hunkLines = %v, %v, %q", removed, added, contexts)
+	}
+}
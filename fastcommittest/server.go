@@ -0,0 +1,150 @@
+// Package fastcommittest provides a fake OpenAI-compatible server for
+// exercising fastcommit, or tools built on it, without a real backend.
+package fastcommittest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Model is the only model the server lists.
+const Model = "gpt-4o-2024-08-06"
+
+// Server is a fake OpenAI API answering every chat completion with the same
+// message, streamed or not. Point fastcommit at it with --openai-base-url
+// URL and any key.
+type Server struct {
+	// URL is the base URL of the API, ending in /v1.
+	URL string
+
+	srv      *httptest.Server
+	mu       sync.Mutex
+	message  string
+	failures []int
+	requests []openai.ChatCompletionRequest
+}
+
+// NewServer starts a server answering with message. Close it when done.
+func NewServer(message string) *Server {
+	s := &Server{message: message}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/models", s.models)
+	mux.HandleFunc("/v1/chat/completions", s.completions)
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL + "/v1"
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// SetMessage changes the message later requests are answered with.
+func (s *Server) SetMessage(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.message = message
+}
+
+// FailNext makes the next completion request fail with an error response
+// of the given HTTP status, e.g. 401 or 500. Calls add up, failing as many
+// requests in turn.
+func (s *Server) FailNext(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, status)
+}
+
+// Requests returns the completion requests received so far, in order.
+func (s *Server) Requests() []openai.ChatCompletionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), s.requests...)
+}
+
+func (s *Server) models(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"object": "list",
+		"data":   []map[string]string{{"id": Model, "object": "model"}},
+	})
+}
+
+func (s *Server) completions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	message := s.message
+	status := 0
+	if len(s.failures) > 0 {
+		status, s.failures = s.failures[0], s.failures[1:]
+	}
+	s.mu.Unlock()
+	if status != 0 {
+		writeError(w, status, http.StatusText(status))
+		return
+	}
+
+	usage := openai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	if !req.Stream {
+		resp := openai.ChatCompletionResponse{ID: "fake", Object: "chat.completion", Model: req.Model, Usage: usage}
+		for i := 0; i < max(req.N, 1); i++ {
+			resp.Choices = append(resp.Choices, openai.ChatCompletionChoice{
+				Index:        i,
+				Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: message},
+				FinishReason: openai.FinishReasonStop,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	send := func(chunk openai.ChatCompletionStreamResponse) {
+		b, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", b)
+	}
+	// A few words per chunk, as real servers send them.
+	words := strings.SplitAfter(message, " ")
+	for i := 0; i < len(words); i += 3 {
+		send(openai.ChatCompletionStreamResponse{
+			ID: "fake", Object: "chat.completion.chunk", Model: req.Model,
+			Choices: []openai.ChatCompletionStreamChoice{{
+				Delta: openai.ChatCompletionStreamChoiceDelta{Content: strings.Join(words[i:min(i+3, len(words))], "")},
+			}},
+		})
+	}
+	send(openai.ChatCompletionStreamResponse{
+		ID: "fake", Object: "chat.completion.chunk", Model: req.Model,
+		Choices: []openai.ChatCompletionStreamChoice{{FinishReason: openai.FinishReasonStop}},
+	})
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		send(openai.ChatCompletionStreamResponse{ID: "fake", Object: "chat.completion.chunk", Model: req.Model, Usage: &usage})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// writeError writes an error response the way the OpenAI API does.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": message, "type": "invalid_request_error"},
+	})
+}
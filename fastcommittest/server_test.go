@@ -0,0 +1,131 @@
+package fastcommittest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func newClient(s *Server) *openai.Client {
+	config := openai.DefaultConfig("sk-test")
+	config.BaseURL = s.URL
+	return openai.NewClientWithConfig(config)
+}
+
+var request = openai.ChatCompletionRequest{
+	Model:    Model,
+	Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "diff"}},
+}
+
+func TestCompletion(t *testing.T) {
+	s := NewServer("Fix the build")
+	defer s.Close()
+
+	req := request
+	req.N = 2
+	resp, err := newClient(s).CreateChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Choices) != 2 {
+		t.Fatalf("%d choices, want 2", len(resp.Choices))
+	}
+	for _, c := range resp.Choices {
+		if c.Message.Content != "Fix the build" {
+			t.Errorf("choice %d = %q", c.Index, c.Message.Content)
+		}
+	}
+	if resp.Usage.TotalTokens == 0 {
+		t.Error("no usage reported")
+	}
+}
+
+func TestStream(t *testing.T) {
+	s := NewServer("Fix the build on Windows and macOS")
+	defer s.Close()
+
+	req := request
+	req.Stream = true
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := newClient(s).CreateChatCompletionStream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var text strings.Builder
+	var chunks int
+	var usage *openai.Usage
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Choices) > 0 {
+			text.WriteString(resp.Choices[0].Delta.Content)
+			chunks++
+		}
+		if resp.Usage != nil {
+			usage = resp.Usage
+		}
+	}
+	if text.String() != "Fix the build on Windows and macOS" {
+		t.Errorf("streamed %q", text.String())
+	}
+	if chunks < 3 {
+		t.Errorf("message streamed in %d chunks, want it split up", chunks)
+	}
+	if usage == nil {
+		t.Error("no usage reported despite IncludeUsage")
+	}
+}
+
+func TestFailNextAndRequests(t *testing.T) {
+	s := NewServer("first")
+	defer s.Close()
+	client := newClient(s)
+
+	s.FailNext(http.StatusUnauthorized)
+	s.FailNext(http.StatusInternalServerError)
+	for _, want := range []int{http.StatusUnauthorized, http.StatusInternalServerError} {
+		_, err := client.CreateChatCompletion(context.Background(), request)
+		var apiErr *openai.APIError
+		if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != want {
+			t.Errorf("error = %v, want status %d", err, want)
+		}
+	}
+
+	s.SetMessage("second")
+	resp, err := client.CreateChatCompletion(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Choices[0].Message.Content; got != "second" {
+		t.Errorf("message = %q, want %q", got, "second")
+	}
+	if n := len(s.Requests()); n != 3 {
+		t.Errorf("%d requests recorded, want 3", n)
+	}
+	if got := s.Requests()[0].Messages[0].Content; got != "diff" {
+		t.Errorf("recorded message = %q", got)
+	}
+}
+
+func TestModels(t *testing.T) {
+	s := NewServer("")
+	defer s.Close()
+	models, err := newClient(s).ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models.Models) != 1 || models.Models[0].ID != Model {
+		t.Errorf("models = %+v", models.Models)
+	}
+}
@@ -0,0 +1,142 @@
+package fastcommit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fixtures are repositories of typical shapes with staged changes, built
+// the same way every time, for tests and benchmarks of whole prompts.
+var fixtures = []struct {
+	name  string
+	build func(t testing.TB, dir string)
+}{
+	{"small", buildSmallFixture},
+	{"medium", buildMediumFixture},
+	{"monorepo", buildMonorepoFixture},
+}
+
+// newFixture returns a new repository built by the named fixture.
+func newFixture(t testing.TB, name string) string {
+	t.Helper()
+	for _, f := range fixtures {
+		if f.name == name {
+			dir := newRepo(t)
+			f.build(t, dir)
+			return dir
+		}
+	}
+	t.Fatalf("no fixture %q", name)
+	return ""
+}
+
+// commitAll commits everything in dir with msg.
+func commitAll(t testing.TB, dir, msg string) {
+	t.Helper()
+	gitT(t, dir, "add", "-A")
+	gitT(t, dir, "commit", "-q", "-m", msg)
+}
+
+// goFile returns a Go source file of package pkg with n functions, the
+// version-th revision of which differ in their bodies.
+func goFile(pkg string, n, version int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s\n\nimport \"fmt\"\n", pkg)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "\n// Step%d performs step %d of the %s pipeline.\n", i, i, pkg)
+		fmt.Fprintf(&b, "func Step%d(input []string) ([]string, error) {\n", i)
+		fmt.Fprintf(&b, "\tout := make([]string, 0, len(input))\n")
+		fmt.Fprintf(&b, "\tfor _, s := range input {\n")
+		if version > 0 && i%3 == 0 {
+			fmt.Fprintf(&b, "\t\tif s == \"\" {\n\t\t\treturn nil, fmt.Errorf(\"step %d: empty input (v%d)\")\n\t\t}\n", i, version)
+		}
+		fmt.Fprintf(&b, "\t\tout = append(out, fmt.Sprintf(\"%%s-%d\", s))\n", i)
+		fmt.Fprintf(&b, "\t}\n\treturn out, nil\n}\n")
+	}
+	return b.String()
+}
+
+// buildSmallFixture makes a one-package project with a short history and a
+// staged fix to one function plus a README note.
+func buildSmallFixture(t testing.TB, dir string) {
+	writeFile(t, dir, "go.mod", "module example.com/small\n\ngo 1.21\n")
+	writeFile(t, dir, "parse/parse.go", goFile("parse", 3, 0))
+	commitAll(t, dir, "Add the parse package\n\nIt splits the input into steps.")
+	writeFile(t, dir, "README.md", "# small\n\nParses input in steps.\n")
+	commitAll(t, dir, "Document what the project does")
+
+	writeFile(t, dir, "parse/parse.go", goFile("parse", 3, 1))
+	writeFile(t, dir, "README.md", "# small\n\nParses input in steps. Empty input is an error.\n")
+	gitT(t, dir, "add", "-A")
+}
+
+// buildMediumFixture makes a project of a few packages with tests and a
+// history of a few dozen commits, and stages changes across several of them.
+func buildMediumFixture(t testing.TB, dir string) {
+	pkgs := []string{"api", "auth", "cache", "config", "db", "jobs", "mail", "web"}
+	writeFile(t, dir, "go.mod", "module example.com/medium\n\ngo 1.21\n")
+	for i, pkg := range pkgs {
+		writeFile(t, dir, pkg+"/"+pkg+".go", goFile(pkg, 6, 0))
+		writeFile(t, dir, pkg+"/"+pkg+"_test.go", fmt.Sprintf("package %s\n\nimport \"testing\"\n\nfunc TestStep0(t *testing.T) {\n\tif _, err := Step0(nil); err != nil {\n\t\tt.Fatal(err)\n\t}\n}\n", pkg))
+		commitAll(t, dir, fmt.Sprintf("Add the %s package\n\nThe %s package is step %d of the service.", pkg, pkg, i))
+	}
+	for i := 0; i < 24; i++ {
+		pkg := pkgs[i%len(pkgs)]
+		writeFile(t, dir, pkg+"/notes.txt", fmt.Sprintf("revision %d of the %s notes\n", i, pkg))
+		commitAll(t, dir, fmt.Sprintf("Update the %s notes for revision %d", pkg, i))
+	}
+
+	for _, pkg := range pkgs[:5] {
+		writeFile(t, dir, pkg+"/"+pkg+".go", goFile(pkg, 6, 1))
+	}
+	writeFile(t, dir, "docs/errors.md", "# Errors\n\nEvery step rejects empty input.\n")
+	gitT(t, dir, "add", "-A")
+}
+
+// buildMonorepoFixture makes services and shared packages in several
+// languages, with a lockfile and vendored code, and stages a change that
+// touches most of them.
+func buildMonorepoFixture(t testing.TB, dir string) {
+	services := []string{"billing", "catalog", "checkout", "search", "users", "notify"}
+	for _, svc := range services {
+		writeFile(t, dir, "services/"+svc+"/main.go", goFile("main", 4, 0))
+		writeFile(t, dir, "services/"+svc+"/handler.go", goFile("main", 40, 0))
+		writeFile(t, dir, "services/"+svc+"/README.md", "# "+svc+"\n\nThe "+svc+" service.\n")
+	}
+	writeFile(t, dir, "packages/ui/package.json", "{\n  \"name\": \"@corp/ui\",\n  \"version\": \"1.0.0\"\n}\n")
+	writeFile(t, dir, "packages/ui/button.ts", "export function button(label: string): string {\n  return `<button>${label}</button>`;\n}\n")
+	writeFile(t, dir, "package-lock.json", lockfile(0))
+	writeFile(t, dir, "vendor/github.com/lib/retry/retry.go", goFile("retry", 5, 0))
+	writeFile(t, dir, "vendor/modules.txt", "# github.com/lib/retry v1.0.0\n")
+	commitAll(t, dir, "Import the services into one repository")
+	for i := 0; i < 40; i++ {
+		svc := services[i%len(services)]
+		writeFile(t, dir, "services/"+svc+"/CHANGELOG", fmt.Sprintf("%d: maintenance\n", i))
+		commitAll(t, dir, fmt.Sprintf("%s: record maintenance %d in the changelog", svc, i))
+	}
+
+	for _, svc := range services {
+		writeFile(t, dir, "services/"+svc+"/handler.go", goFile("main", 40, 1))
+	}
+	writeFile(t, dir, "packages/ui/button.ts", "export function button(label: string, disabled = false): string {\n  return `<button${disabled ? \" disabled\" : \"\"}>${label}</button>`;\n}\n")
+	writeFile(t, dir, "package-lock.json", lockfile(1))
+	writeFile(t, dir, "vendor/github.com/lib/retry/retry.go", goFile("retry", 5, 1))
+	writeFile(t, dir, "vendor/modules.txt", "# github.com/lib/retry v1.1.0\n")
+	gitT(t, dir, "add", "-A")
+}
+
+// lockfile returns a package-lock.json with many entries, the version-th
+// revision of which pins different versions.
+func lockfile(version int) string {
+	var b strings.Builder
+	b.WriteString("{\n  \"lockfileVersion\": 3,\n  \"packages\": {\n")
+	for i := 0; i < 200; i++ {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(&b, "    \"node_modules/dep%d\": {\n      \"version\": \"1.%d.%d\",\n      \"integrity\": \"sha512-%064x\"\n    }", i, i, version, i*7919+version)
+	}
+	b.WriteString("\n  }\n}\n")
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package fastcommit
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var globCache sync.Map // pattern -> *regexp.Regexp
+
+// MatchGlob reports whether the slash-separated path matches pattern. As in
+// .gitignore, a pattern without a slash matches the base name in any
+// directory, "*" matches within a path segment and "**" matches any number of
+// segments.
+func MatchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	re, ok := globCache.Load(pattern)
+	if !ok {
+		re, _ = globCache.LoadOrStore(pattern, globRegexp(pattern))
+	}
+	return re.(*regexp.Regexp).MatchString(name)
+}
+
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// A pattern naming a directory also matches everything inside it.
+	b.WriteString("(?:/.*)?$")
+	return regexp.MustCompile(b.String())
+}
@@ -2,6 +2,11 @@ module github.com/AkhilSharma90/GenAI-Code-Committer
 
 go 1.21.4
 
+require (
+	github.com/pelletier/go-toml/v2 v2.2.2
+	golang.org/x/term v0.23.0
+)
+
 require (
 	dario.cat/mergo v1.0.0 // indirect
 	github.com/Microsoft/go-winio v0.6.1 // indirect
@@ -32,7 +37,7 @@ require (
 	al.essio.dev/pkg/shellescape v1.5.0
 	github.com/go-git/go-git/v5 v5.12.0
 	github.com/sashabaranov/go-openai v1.29.0
-	github.com/tiktoken-go/tokenizer v0.1.1
+	github.com/tiktoken-go/tokenizer v0.2.1
 	golang.org/x/crypto v0.26.0 // indirect
-	golang.org/x/sys v0.24.0 // indirect
+	golang.org/x/sys v0.24.0
 )
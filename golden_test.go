@@ -0,0 +1,201 @@
+package fastcommit
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+var updateGoldens = flag.Bool("update-goldens", false, "rewrite the golden files in testdata with the current output")
+
+// defaultMaxTokens is the prompt budget of the fastcommit command.
+const defaultMaxTokens = 128000
+
+// maxPromptGrowth is how much larger than its golden token count the
+// default prompt of a fixture may get before TestPromptTokens fails, so
+// that a new prompt section cannot silently double what every commit costs.
+const maxPromptGrowth = 0.10
+
+// promptConfigs are the configurations whose prompt sizes are tracked. The
+// first is the default.
+var promptConfigs = []struct {
+	name string
+	opts PromptOptions
+}{
+	{"default", PromptOptions{MaxTokens: defaultMaxTokens}},
+	{"minimal", PromptOptions{MaxTokens: defaultMaxTokens, Minimal: true}},
+	{"digest", PromptOptions{MaxTokens: defaultMaxTokens, DigestOnly: true}},
+	{"conventional", PromptOptions{MaxTokens: defaultMaxTokens, Conventional: &ConventionalRules{}}},
+	{"narrative", PromptOptions{MaxTokens: defaultMaxTokens, Narrative: 5}},
+	{"small-budget", PromptOptions{MaxTokens: 8000}},
+}
+
+// formatPrompt renders msgs for a golden file, one "--- role" header per
+// message.
+func formatPrompt(msgs []openai.ChatCompletionMessage) string {
+	var b strings.Builder
+	for _, m := range msgs {
+		fmt.Fprintf(&b, "--- %s\n%s\n", m.Role, m.Content)
+	}
+	return b.String()
+}
+
+// golden compares got with the golden file name in testdata, or rewrites
+// it with -update-goldens.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGoldens {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run make update-goldens to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the output; if the change is intended, run make update-goldens and review the diff\n%s",
+			path, firstDifference(string(want), got))
+	}
+}
+
+// firstDifference describes the first line where want and got differ.
+func firstDifference(want, got string) string {
+	wl, gl := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(wl) || i < len(gl); i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want %q\n  got  %q", i+1, w, g)
+		}
+	}
+	return ""
+}
+
+func TestGoldenPrompt(t *testing.T) {
+	dir := newFixture(t, "small")
+	msgs, err := BuildPromptWithOptions(io.Discard, dir, promptConfigs[0].opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, filepath.Join("prompts", "small.golden"), formatPrompt(msgs))
+}
+
+// readTokenGoldens reads lines of "fixture config tokens".
+func readTokenGoldens(path string) (map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	counts := map[string]int{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s: bad line %q", path, line)
+		}
+		n, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		counts[fields[0]+" "+fields[1]] = n
+	}
+	return counts, s.Err()
+}
+
+// TestPromptTokens measures the prompt of every fixture in every tracked
+// configuration against testdata/prompts/tokens.golden.
+func TestPromptTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds every fixture")
+	}
+	path := filepath.Join("testdata", "prompts", "tokens.golden")
+	var want map[string]int
+	if !*updateGoldens {
+		var err error
+		if want, err = readTokenGoldens(path); err != nil {
+			t.Fatalf("%v; run make update-goldens to create it", err)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("# Prompt tokens per fixture and configuration, checked by TestPromptTokens.\n")
+	for _, f := range fixtures {
+		dir := newFixture(t, f.name)
+		for i, c := range promptConfigs {
+			msgs, err := BuildPromptWithOptions(io.Discard, dir, c.opts)
+			if err != nil {
+				t.Fatalf("%s %s: %v", f.name, c.name, err)
+			}
+			got := CountTokens(msgs...)
+			key := f.name + " " + c.name
+			fmt.Fprintf(&out, "%s %d\n", key, got)
+			if *updateGoldens {
+				continue
+			}
+			w, ok := want[key]
+			switch {
+			case !ok:
+				t.Errorf("%s: no golden count; run make update-goldens", key)
+			case i == 0 && float64(got) > float64(w)*(1+maxPromptGrowth):
+				t.Errorf("%s: the prompt grew from %d to %d tokens, more than %.0f%%",
+					key, w, got, maxPromptGrowth*100)
+			case got != w:
+				t.Logf("%s: %d tokens, golden %d; run make update-goldens to record it", key, got, w)
+			}
+		}
+	}
+	if *updateGoldens {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBuildPrompt measures building the prompt of every fixture in
+// every tracked configuration, reporting its size in tokens.
+func BenchmarkBuildPrompt(b *testing.B) {
+	for _, f := range fixtures {
+		dir := newFixture(b, f.name)
+		for _, c := range promptConfigs {
+			b.Run(f.name+"/"+c.name, func(b *testing.B) {
+				var tokens int
+				for i := 0; i < b.N; i++ {
+					msgs, err := BuildPromptWithOptions(io.Discard, dir, c.opts)
+					if err != nil {
+						b.Fatal(err)
+					}
+					tokens = CountTokens(msgs...)
+				}
+				b.ReportMetric(float64(tokens), "tokens")
+			})
+		}
+	}
+}
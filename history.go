@@ -0,0 +1,170 @@
+package fastcommit
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Defaults for the recent commits a prompt shows as examples of the
+// repository's style.
+const (
+	// DefaultHistory is the most commits shown.
+	DefaultHistory = 300
+	// DefaultHistoryTokens caps the tokens of the commit messages shown,
+	// so that long messages do not take the diff's budget.
+	DefaultHistoryTokens = 4000
+	// DefaultHistoryMinLength is the length in characters below which a
+	// message, such as "wip" or "fix", says nothing about the style.
+	DefaultHistoryMinLength = 10
+	// DefaultHistoryBotPattern matches the authors of automated commits,
+	// as "Name <email>".
+	DefaultHistoryBotPattern = `(?i)\[bot\]|^(dependabot|renovate|github-actions|greenkeeper)\b`
+)
+
+// historyScan is the fewest commits looked at for examples.
+const historyScan = 300
+
+// HistoryExample is a commit whose message a prompt shows as an example.
+type HistoryExample struct {
+	Hash    string
+	Subject string
+	Tokens  int
+	// SameFiles is set when the commit touched files the change touches.
+	SameFiles bool
+}
+
+// HistorySelection is how the examples of a prompt were chosen.
+type HistorySelection struct {
+	// Examples are the commits shown, oldest first as in the prompt.
+	Examples []HistoryExample
+	// Scanned is the number of commits looked at.
+	Scanned int
+	// Merges, Bots and Short count the commits skipped as merges, as
+	// authored by bots and for messages shorter than the minimum length.
+	Merges, Bots, Short int
+	// OverBudget counts the eligible commits left out by the count or
+	// the token cap.
+	OverBudget int
+	// Tokens is the total of the examples' tokens.
+	Tokens int
+}
+
+// selectHistory chooses the recent commits reachable from head whose
+// messages the prompt shows, as opts configures, leaving out skip. Commits
+// touching paths, the files of the change, are preferred over more recent
+// ones; the rest are taken newest first.
+func selectHistory(repo *git.Repository, gitRoot string, head plumbing.Hash, skip string, paths []string, opts PromptOptions) (HistorySelection, []string, error) {
+	var sel HistorySelection
+	count := opts.History
+	if count == 0 {
+		count = DefaultHistory
+	}
+	maxTokens := opts.HistoryTokens
+	if maxTokens == 0 {
+		maxTokens = DefaultHistoryTokens
+	}
+	minLength := opts.HistoryMinLength
+	if minLength == 0 {
+		minLength = DefaultHistoryMinLength
+	}
+	pattern := opts.HistoryBotPattern
+	if pattern == "" {
+		pattern = DefaultHistoryBotPattern
+	}
+	bots, err := regexp.Compile(pattern)
+	if err != nil {
+		return sel, nil, fmt.Errorf("invalid history bot pattern: %w", err)
+	}
+
+	scan := max(historyScan, 3*count)
+	commits, err := recentCommits(repo, head, skip, scan)
+	if err != nil {
+		return sel, nil, err
+	}
+	sel.Scanned = len(commits)
+	sameFiles := touchingCommits(gitRoot, head, paths, scan)
+
+	type candidate struct {
+		commit *object.Commit
+		msg    string
+		ex     HistoryExample
+		order  int
+	}
+	var preferred, others []candidate
+	for i, commit := range commits {
+		switch {
+		case commit.NumParents() > 1:
+			sel.Merges++
+			continue
+		case bots.MatchString(commit.Author.Name + " <" + commit.Author.Email + ">"):
+			sel.Bots++
+			continue
+		case utf8.RuneCountInString(strings.TrimSpace(commit.Message)) < minLength:
+			sel.Short++
+			continue
+		}
+		msg := Ellipse(commit.Message, 1000)
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		c := candidate{commit: commit, msg: msg, order: i, ex: HistoryExample{
+			Hash:      commit.Hash.String(),
+			Subject:   subject,
+			Tokens:    CountTokens(openai.ChatCompletionMessage{Content: msg}),
+			SameFiles: sameFiles[commit.Hash.String()],
+		}}
+		if c.ex.SameFiles {
+			preferred = append(preferred, c)
+		} else {
+			others = append(others, c)
+		}
+	}
+
+	var chosen []candidate
+	for _, c := range append(preferred, others...) {
+		if len(chosen) >= count || (maxTokens > 0 && sel.Tokens+c.ex.Tokens > maxTokens) {
+			sel.OverBudget++
+			continue
+		}
+		chosen = append(chosen, c)
+		sel.Tokens += c.ex.Tokens
+	}
+
+	// The most recent commit is the last or "most recent" in the chat.
+	slices.SortFunc(chosen, func(a, b candidate) int { return b.order - a.order })
+	var msgs []string
+	for _, c := range chosen {
+		msgs = append(msgs, c.msg)
+		sel.Examples = append(sel.Examples, c.ex)
+	}
+	return sel, msgs, nil
+}
+
+// touchingCommits returns the hashes of the last n commits reachable from
+// head that touched any of paths. Without paths, or if git fails, it
+// returns none, which only loses the preference for them.
+func touchingCommits(gitRoot string, head plumbing.Hash, paths []string, n int) map[string]bool {
+	if len(paths) == 0 {
+		return nil
+	}
+	// Keep the command line short for changes touching many files.
+	paths = paths[:min(len(paths), 100)]
+	var buf bytes.Buffer
+	args := append([]string{"--literal-pathspecs", "log", "--format=%H", "--no-merges",
+		fmt.Sprintf("-n%d", n), head.String(), "--"}, paths...)
+	if err := runGit(&buf, gitRoot, args...); err != nil {
+		return nil
+	}
+	hashes := map[string]bool{}
+	for _, h := range strings.Fields(buf.String()) {
+		hashes[h] = true
+	}
+	return hashes
+}
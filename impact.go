@@ -0,0 +1,82 @@
+package fastcommit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Impact is whether a change is worth a changelog entry.
+type Impact struct {
+	// UserFacing is set when end users of the software notice the change.
+	UserFacing bool `json:"user_facing"`
+	// Changelog describes a user-facing change to end users in one line.
+	// It is empty for internal changes.
+	Changelog string `json:"changelog"`
+}
+
+// impactSchema is the structured output ClassifyImpact asks for.
+var impactSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"user_facing": {"type": "boolean"},
+		"changelog": {"type": "string"}
+	},
+	"required": ["user_facing", "changelog"],
+	"additionalProperties": false
+}`)
+
+// ClassifyImpact asks model whether the change described by msgs, the prompt
+// message was generated from, is user-facing or internal. The answer is
+// requested as structured output, so that it parses reliably.
+func ClassifyImpact(
+	ctx context.Context,
+	p Provider,
+	model string,
+	msgs []openai.ChatCompletionMessage,
+	message string,
+) (Impact, error) {
+	var im Impact
+	resp, err := p.CreateCompletion(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: append(msgs[:len(msgs):len(msgs)],
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: message},
+			openai.ChatCompletionMessage{
+				Role: openai.ChatMessageRoleSystem,
+				Content: "Classify the change you described. It is user-facing if end users of the software " +
+					"notice it: new features, changed behavior, fixed bugs, performance they feel. Refactoring, " +
+					"tests, CI, build and development tooling are internal. For a user-facing change, set " +
+					"changelog to one line describing it to end users, without code identifiers or file names; " +
+					"for an internal one, leave it empty.",
+			},
+		),
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "impact",
+				Schema: impactSchema,
+				Strict: true,
+			},
+		},
+	})
+	if err != nil {
+		return im, err
+	}
+	if len(resp.Choices) == 0 {
+		return im, errors.New("no choices returned")
+	}
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &im); err != nil {
+		return im, fmt.Errorf("parse classification: %w", err)
+	}
+	im.Changelog, _, _ = strings.Cut(strings.TrimSpace(im.Changelog), "\n")
+	if !im.UserFacing {
+		im.Changelog = ""
+	} else if im.Changelog == "" {
+		return im, errors.New("user-facing change classified without a changelog line")
+	}
+	return im, nil
+}
@@ -0,0 +1,122 @@
+package fastcommit
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// PathFix is a file reference LinkFiles changed: Old was not a changed path
+// and was replaced by New, or dropped when New is empty.
+type PathFix struct {
+	Old string
+	New string
+}
+
+var (
+	// pathTokenRe matches what may be a file path: a word with an
+	// extension, optionally in directories, optionally in backticks.
+	pathTokenRe = regexp.MustCompile("`?[\\w.\\-/]*[\\w\\-]\\.[A-Za-z][A-Za-z0-9]{0,9}`?")
+	// droppedRefRe matches a dropped reference with the words that only
+	// introduced it, e.g. "in ", " and in " or "(see )".
+	droppedRefRe = regexp.MustCompile(`(?i)(\s*,?\s+(?:and|or)\s+(?:(?:in|into|from|to|of|at|under)\s+)?|` +
+		`\s+(?:in|into|from|to|of|at|under)\s+|\s*\(\s*(?:in|see)?\s*)?\x00(\s*\))?`)
+	innerSpacesRe = regexp.MustCompile(`(\S) {2,}`)
+)
+
+// LinkFiles checks the file paths the body of msg mentions against paths,
+// the files the change touches. A mention of a file that is not among them,
+// usually one the model made up, is replaced by the closest changed path, or
+// dropped if none is close. Only words with an extension that one of paths
+// has, or with a directory, are taken for paths.
+func LinkFiles(msg string, paths []string) (string, []PathFix) {
+	subject, body, ok := strings.Cut(msg, "\n")
+	if !ok {
+		return msg, nil
+	}
+	exts := map[string]bool{}
+	for _, p := range paths {
+		if ext := path.Ext(p); ext != "" {
+			exts[ext] = true
+		}
+	}
+	var fixes []PathFix
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		dropped := false
+		line = pathTokenRe.ReplaceAllStringFunc(line, func(tok string) string {
+			ref := strings.Trim(tok, "`")
+			if strings.Contains(ref, "://") || (!strings.Contains(ref, "/") && !exts[path.Ext(ref)]) {
+				return tok
+			}
+			fixed, ok := matchPath(ref, paths)
+			switch {
+			case ok && fixed == ref:
+				return tok
+			case ok:
+				fixes = append(fixes, PathFix{Old: ref, New: fixed})
+				return strings.Replace(tok, ref, fixed, 1)
+			}
+			fixes = append(fixes, PathFix{Old: ref})
+			dropped = true
+			return "\x00"
+		})
+		if dropped {
+			line = droppedRefRe.ReplaceAllString(line, "")
+			line = innerSpacesRe.ReplaceAllString(line, "$1 ")
+			line = strings.NewReplacer(" ,", ",", " .", ".", ", and ", " and ").Replace(line)
+		}
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return subject + "\n" + strings.Join(lines, "\n"), fixes
+}
+
+// matchPath returns the changed path ref stands for: ref itself when it is
+// one, or the base name of a changed path, or else the closest changed path
+// by edit distance. A bare file name is matched against base names and
+// stays one.
+func matchPath(ref string, paths []string) (string, bool) {
+	bare := !strings.Contains(ref, "/")
+	best, bestDist := "", -1
+	for _, p := range paths {
+		candidate := p
+		if bare {
+			candidate = path.Base(p)
+		}
+		if candidate == ref {
+			return ref, true
+		}
+		if !bare && strings.HasSuffix(p, "/"+ref) {
+			return ref, true
+		}
+		if d := editDistance(ref, candidate); bestDist < 0 || d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+	// Allow a typo or two, more in long paths.
+	if bestDist >= 0 && bestDist <= max(2, len(ref)/5) {
+		return best, true
+	}
+	return "", false
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,262 @@
+package fastcommit
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The rules of LintRules, by the names LintRules.Disable and LintViolation
+// use.
+const (
+	LintSubjectLength  = "subject-length"
+	LintImperative     = "imperative"
+	LintTrailingPeriod = "trailing-period"
+	LintCase           = "case"
+	LintBodyWidth      = "body-width"
+	LintUselessSubject = "useless-subject"
+)
+
+// LintRuleNames are the names of all lint rules.
+var LintRuleNames = []string{
+	LintSubjectLength, LintImperative, LintTrailingPeriod, LintCase, LintBodyWidth, LintUselessSubject,
+}
+
+// LintSubjectLength and LintBodyWidth default to these limits. They are the
+// hard limits reviewers hold messages to, looser than what is asked of the
+// model.
+const (
+	DefaultLintSubjectLength = 72
+	DefaultLintBodyWidth     = 72
+)
+
+// DefaultUselessSubjects are subjects that say nothing about the change.
+var DefaultUselessSubjects = []string{
+	"update", "updates", "change", "changes", "fix", "fixes", "fix bug", "fix bugs", "fix the bug",
+	"fix issue", "fix stuff", "fix things", "minor fix", "minor fixes", "minor changes", "small changes",
+	"more changes", "misc", "cleanup", "clean up", "wip", "work in progress", "stuff", "tweaks",
+	"update code", "update files", "refactor", "refactor code",
+}
+
+// LintRules are the standards a generated message is held to before it is
+// committed. The zero value checks every rule with the defaults.
+type LintRules struct {
+	// Disable lists the rules not checked.
+	Disable []string
+	// SubjectCase is "upper" or "lower" to require the subject to start
+	// with an uppercase or lowercase letter, or "any". Empty means "upper",
+	// except for Conventional Commits subjects, which ConventionalRules
+	// checks.
+	SubjectCase string
+	// MaxSubjectLength limits the subject line. Zero means
+	// DefaultLintSubjectLength.
+	MaxSubjectLength int
+	// BodyWidth limits the lines of the body. Zero means
+	// DefaultLintBodyWidth.
+	BodyWidth int
+	// UselessSubjects replaces DefaultUselessSubjects. They are compared
+	// case-insensitively, without a trailing period or a Conventional
+	// Commits type.
+	UselessSubjects []string
+}
+
+// LintViolation is a rule a message breaks.
+type LintViolation struct {
+	// Rule is the name of the rule.
+	Rule string
+	// Problem describes the violation as an instruction to fix it.
+	Problem string
+}
+
+func (v LintViolation) String() string {
+	return v.Problem + " (" + v.Rule + ")"
+}
+
+// Validate reports rule names and settings that do not exist.
+func (r LintRules) Validate() error {
+	for _, name := range r.Disable {
+		if !slices.Contains(LintRuleNames, name) {
+			return fmt.Errorf("unknown lint rule %q; the rules are %s", name, strings.Join(LintRuleNames, ", "))
+		}
+	}
+	switch r.SubjectCase {
+	case "", "upper", "lower", "any":
+	default:
+		return fmt.Errorf("invalid subject case %q; use upper, lower or any", r.SubjectCase)
+	}
+	if r.MaxSubjectLength < 0 || r.BodyWidth < 0 {
+		return fmt.Errorf("lint limits must not be negative")
+	}
+	return nil
+}
+
+func (r LintRules) enabled(rule string) bool {
+	return !slices.Contains(r.Disable, rule)
+}
+
+// Lint returns the rules msg breaks, or nil if it follows them all.
+func Lint(msg string, rules LintRules) []LintViolation {
+	var found []LintViolation
+	add := func(rule, format string, args ...any) {
+		if rules.enabled(rule) {
+			found = append(found, LintViolation{Rule: rule, Problem: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	subject, body, _ := strings.Cut(strings.TrimSpace(msg), "\n")
+	subject = strings.TrimSpace(subject)
+	limit := rules.MaxSubjectLength
+	if limit == 0 {
+		limit = DefaultLintSubjectLength
+	}
+	if n := utf8.RuneCountInString(subject); n > limit {
+		add(LintSubjectLength, "the subject line is %d characters long; shorten it to at most %d", n, limit)
+	}
+	if strings.HasSuffix(subject, ".") && !strings.HasSuffix(subject, "...") {
+		add(LintTrailingPeriod, "the subject line ends with a period; remove it")
+	}
+
+	// The checks of the wording apply to the description of a Conventional
+	// Commits subject.
+	description := subject
+	conventional := false
+	if m := conventionalSubjectRe.FindStringSubmatch(subject); m != nil {
+		description, conventional = m[5], true
+	}
+	words := strings.Fields(description)
+	if len(words) > 0 {
+		first := words[0]
+		if base, ok := nonImperative[strings.ToLower(first)]; ok {
+			add(LintImperative, "the subject starts with %q; use the imperative mood, as in %q", first, capitalizeLike(base, first))
+		}
+		sc := rules.SubjectCase
+		if sc == "" && !conventional {
+			sc = "upper"
+		}
+		switch {
+		case sc == "upper" && isLowerWord(first):
+			add(LintCase, "the subject starts with a lowercase letter; capitalize it")
+		case sc == "lower" && isCapitalizedWord(first):
+			add(LintCase, "the subject starts with an uppercase letter; start it lowercase")
+		}
+	}
+	if uselessSubject(description, rules.UselessSubjects) {
+		add(LintUselessSubject, "the subject %q says nothing about the change; say what changed and why", subject)
+	} else if len(words) > 1 && slices.Contains(vagueVerbs, strings.ToLower(words[0])) &&
+		!slices.ContainsFunc(words[1:], func(w string) bool { return !looksLikePath(w) }) {
+		add(LintUselessSubject, "the subject only names the files changed; say what changed in them")
+	}
+
+	width := rules.BodyWidth
+	if width == 0 {
+		width = DefaultLintBodyWidth
+	}
+	for i, line := range strings.Split(body, "\n") {
+		// Indented lines are code or output, and lines without spaces, such
+		// as URLs, cannot be wrapped.
+		if n := utf8.RuneCountInString(line); n > width && !strings.HasPrefix(line, " ") &&
+			!strings.HasPrefix(line, "\t") && strings.Contains(strings.TrimSpace(line), " ") {
+			add(LintBodyWidth, "line %d of the message is %d characters long; wrap the body at %d", i+2, n, width)
+			break
+		}
+	}
+	return found
+}
+
+// uselessSubject reports whether description is one of the useless subjects.
+func uselessSubject(description string, useless []string) bool {
+	if useless == nil {
+		useless = DefaultUselessSubjects
+	}
+	s := strings.ToLower(strings.Join(strings.Fields(strings.TrimRight(description, ".!")), " "))
+	return slices.ContainsFunc(useless, func(u string) bool {
+		return strings.EqualFold(strings.TrimSpace(u), s)
+	})
+}
+
+// vagueVerbs are the verbs that, followed by nothing but file names, leave
+// the change unexplained, as in "Update main.go".
+var vagueVerbs = []string{"update", "change", "modify", "edit", "fix", "refactor", "tweak", "touch", "clean"}
+
+var pathWordRe = regexp.MustCompile(`^[\w@.-]*(?:/[\w@.-]+)+/?$|^[\w-]+\.[A-Za-z][A-Za-z0-9]{0,7}$`)
+
+// looksLikePath reports whether word names a file, such as main.go or
+// cmd/app, ignoring punctuation around it.
+func looksLikePath(word string) bool {
+	w := strings.Trim(word, "`'\",;:.()")
+	return w != "" && pathWordRe.MatchString(w)
+}
+
+func isLowerWord(w string) bool {
+	return w != "" && !strings.ContainsFunc(w, func(r rune) bool { return !unicode.IsLower(r) })
+}
+
+// isCapitalizedWord reports whether w is a capitalized word such as "Add",
+// rather than an acronym or a name such as "API" or "GitHub".
+func isCapitalizedWord(w string) bool {
+	first, size := utf8.DecodeRuneInString(w)
+	return unicode.IsUpper(first) && size < len(w) && isLowerWord(w[size:])
+}
+
+func capitalizeLike(s, like string) string {
+	if first, _ := utf8.DecodeRuneInString(like); unicode.IsUpper(first) {
+		r, size := utf8.DecodeRuneInString(s)
+		return string(unicode.ToUpper(r)) + s[size:]
+	}
+	return s
+}
+
+// nonImperative maps the past tense, third person and gerund forms of verbs
+// common in subjects to the imperative.
+var nonImperative = func() map[string]string {
+	verbs := []string{
+		"add", "adjust", "allow", "apply", "avoid", "bump", "cache", "change", "check", "clean", "configure",
+		"convert", "correct", "create", "delete", "disable", "document", "drop", "enable", "ensure",
+		"expose", "extract", "fix", "format", "handle", "hide", "implement", "improve", "increase",
+		"install", "introduce", "merge", "migrate", "move", "optimize", "parse", "pass", "prevent",
+		"reduce", "refactor", "release", "remove", "rename", "replace", "resolve", "return", "revert",
+		"show", "simplify", "skip", "split", "stop", "support", "switch", "tweak", "update",
+		"upgrade", "use", "validate", "wrap",
+	}
+	irregular := map[string][]string{
+		"build":   {"built", "builds", "building"},
+		"make":    {"made", "makes", "making"},
+		"rewrite": {"rewrote", "rewritten", "rewrites", "rewriting"},
+		"set":     {"sets", "setting"},
+		"write":   {"wrote", "written", "writes", "writing"},
+	}
+	forms := map[string]string{}
+	for v, fs := range irregular {
+		for _, f := range fs {
+			forms[f] = v
+		}
+	}
+	for _, v := range verbs {
+		stem := v
+		switch {
+		case slices.Contains([]string{"drop", "skip", "split", "stop", "wrap"}, v):
+			stem = v + v[len(v)-1:]
+		case strings.HasSuffix(v, "e"):
+			stem = v[:len(v)-1]
+		}
+		third := v + "s"
+		switch {
+		case strings.HasSuffix(v, "y") && !strings.ContainsAny(v[len(v)-2:len(v)-1], "aeiou"):
+			forms[v[:len(v)-1]+"ied"], forms[v[:len(v)-1]+"ies"] = v, v
+			forms[v+"ing"] = v
+			continue
+		case strings.HasSuffix(v, "x") || strings.HasSuffix(v, "s") || strings.HasSuffix(v, "sh") ||
+			strings.HasSuffix(v, "ch"):
+			third = v + "es"
+		}
+		if v != "split" {
+			forms[stem+"ed"] = v
+		}
+		forms[third] = v
+		forms[stem+"ing"] = v
+	}
+	return forms
+}()
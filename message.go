@@ -0,0 +1,47 @@
+package fastcommit
+
+import (
+	"io"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// PromptMessage is one message of a prompt, for embedders whose client is
+// not go-openai, such as the Anthropic or Gemini SDKs. Role is "system",
+// "user" or "assistant"; clients without a system role usually take the
+// system messages as a separate instruction.
+//
+// It is not named Message, which is the type sanitizers work on.
+type PromptMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// BuildPromptMessages is BuildPromptWithOptions returning PromptMessages.
+func BuildPromptMessages(log io.Writer, dir string, opts PromptOptions) ([]PromptMessage, error) {
+	msgs, err := BuildPromptWithOptions(log, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return FromOpenAIMessages(msgs), nil
+}
+
+// FromOpenAIMessages converts go-openai messages to PromptMessages, keeping
+// only their role and content.
+func FromOpenAIMessages(msgs []openai.ChatCompletionMessage) []PromptMessage {
+	out := make([]PromptMessage, len(msgs))
+	for i, m := range msgs {
+		out[i] = PromptMessage{Role: m.Role, Content: m.Content}
+	}
+	return out
+}
+
+// ToOpenAIMessages converts PromptMessages to go-openai messages, e.g. to
+// count their tokens with CountTokens or send them through a Provider.
+func ToOpenAIMessages(msgs []PromptMessage) []openai.ChatCompletionMessage {
+	out := make([]openai.ChatCompletionMessage, len(msgs))
+	for i, m := range msgs {
+		out[i] = openai.ChatCompletionMessage{Role: m.Role, Content: m.Content}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package fastcommit
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestPromptMessageRoundTrip(t *testing.T) {
+	msgs := []PromptMessage{
+		{Role: "system", Content: "You write commit messages."},
+		{Role: "user", Content: "diff --git a/a b/a\n+ünïcode\r\n"},
+		{Role: "assistant", Content: ""},
+	}
+	if got := FromOpenAIMessages(ToOpenAIMessages(msgs)); !reflect.DeepEqual(got, msgs) {
+		t.Errorf("round trip through go-openai = %+v, want %+v", got, msgs)
+	}
+
+	// Fields other than role and content are not carried over.
+	oai := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "hello", Name: "alice"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "hi", ToolCallID: "call_1"},
+	}
+	want := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "hello"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "hi"},
+	}
+	if got := ToOpenAIMessages(FromOpenAIMessages(oai)); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip from go-openai = %+v, want %+v", got, want)
+	}
+
+	if got := ToOpenAIMessages(nil); len(got) != 0 {
+		t.Errorf("ToOpenAIMessages(nil) = %v", got)
+	}
+	if got := FromOpenAIMessages(nil); len(got) != 0 {
+		t.Errorf("FromOpenAIMessages(nil) = %v", got)
+	}
+}
+
+func TestPromptMessageJSON(t *testing.T) {
+	b, err := json.Marshal(PromptMessage{Role: "user", Content: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"role":"user","content":"x"}` {
+		t.Errorf("JSON = %s", b)
+	}
+}
+
+func TestBuildPromptMessages(t *testing.T) {
+	dir := newFixture(t, "small")
+	opts := PromptOptions{MaxTokens: defaultMaxTokens}
+	msgs, err := BuildPromptMessages(io.Discard, dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oai, err := BuildPromptWithOptions(io.Discard, dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ToOpenAIMessages(msgs), oai) {
+		t.Errorf("BuildPromptMessages differs from BuildPromptWithOptions")
+	}
+}
@@ -0,0 +1,93 @@
+package fastcommit
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	modeRegular    = "100644"
+	modeExecutable = "100755"
+	modeSymlink    = "120000"
+)
+
+// DescribeModeChanges rewrites the parts of diff that show a change only
+// through git's mode lines: symlinks that were created, removed or retargeted,
+// and files whose executable bit was flipped. Such a part becomes a plain
+// sentence, like "symlink config.yml now points to configs/prod.yml instead
+// of configs/dev.yml", since the raw diff gives the model next to nothing to
+// go on. Other parts are returned unchanged.
+func DescribeModeChanges(diff string) string {
+	files := SplitDiff(diff)
+	// Keep anything before the first file as is.
+	n := len(diff)
+	for _, f := range files {
+		n -= len(f.Diff)
+	}
+	var b strings.Builder
+	b.WriteString(diff[:n])
+	for _, f := range files {
+		b.WriteString(describeModeChange(f))
+	}
+	return b.String()
+}
+
+// describeModeChange returns f's diff, rewritten as described for
+// DescribeModeChanges.
+func describeModeChange(f FileDiff) string {
+	var oldMode, newMode string
+	var rest []string
+	for _, line := range strings.SplitAfter(f.Diff, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "old mode "):
+			oldMode = strings.TrimPrefix(trimmed, "old mode ")
+		case strings.HasPrefix(trimmed, "new mode "):
+			newMode = strings.TrimPrefix(trimmed, "new mode ")
+		case strings.HasPrefix(trimmed, "deleted file mode "):
+			oldMode = strings.TrimPrefix(trimmed, "deleted file mode ")
+			rest = append(rest, line)
+		case strings.HasPrefix(trimmed, "new file mode "):
+			newMode = strings.TrimPrefix(trimmed, "new file mode ")
+			rest = append(rest, line)
+		case strings.HasPrefix(trimmed, "index ") && strings.HasSuffix(trimmed, " "+modeSymlink):
+			oldMode, newMode = modeSymlink, modeSymlink
+			rest = append(rest, line)
+		default:
+			rest = append(rest, line)
+		}
+	}
+
+	removed, added := changedLines(f.Diff)
+	target := func(lines []string) string {
+		if len(lines) == 0 {
+			return ""
+		}
+		return lines[0]
+	}
+	switch {
+	case oldMode == modeSymlink && newMode == modeSymlink:
+		return fmt.Sprintf("symlink %s now points to %s instead of %s\n", f.Path, target(added), target(removed))
+	case newMode == modeSymlink && oldMode == "":
+		return fmt.Sprintf("new symlink %s pointing to %s\n", f.Path, target(added))
+	case oldMode == modeSymlink && newMode == "":
+		return fmt.Sprintf("symlink %s, which pointed to %s, removed\n", f.Path, target(removed))
+	case oldMode == "" || newMode == "" || oldMode == newMode:
+		return f.Diff
+	}
+
+	var what string
+	switch {
+	case newMode == modeExecutable:
+		what = fmt.Sprintf("%s made executable", f.Path)
+	case oldMode == modeExecutable && newMode == modeRegular:
+		what = fmt.Sprintf("%s no longer executable", f.Path)
+	default:
+		what = fmt.Sprintf("%s mode changed from %s to %s", f.Path, oldMode, newMode)
+	}
+	if len(removed) == 0 && len(added) == 0 {
+		return what + "\n"
+	}
+	// The content changed too, so keep the diff without the mode lines.
+	return what + ", and its content changed:\n" + strings.Join(rest, "")
+}
@@ -0,0 +1,123 @@
+package fastcommit
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestModeChangesInPrompt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks and executable bits")
+	}
+	tests := []struct {
+		name   string
+		before func(t *testing.T, dir string)
+		change func(t *testing.T, dir string)
+		want   string
+	}{
+		{
+			name: "symlink retargeted",
+			before: func(t *testing.T, dir string) {
+				symlink(t, "configs/dev.yml", filepath.Join(dir, "config.yml"))
+			},
+			change: func(t *testing.T, dir string) {
+				os.Remove(filepath.Join(dir, "config.yml"))
+				symlink(t, "configs/prod.yml", filepath.Join(dir, "config.yml"))
+			},
+			want: "symlink config.yml now points to configs/prod.yml instead of configs/dev.yml\n",
+		},
+		{
+			name: "new symlink",
+			change: func(t *testing.T, dir string) {
+				symlink(t, "README.md", filepath.Join(dir, "docs.md"))
+			},
+			want: "new symlink docs.md pointing to README.md\n",
+		},
+		{
+			name: "symlink removed",
+			before: func(t *testing.T, dir string) {
+				symlink(t, "README.md", filepath.Join(dir, "docs.md"))
+			},
+			change: func(t *testing.T, dir string) {
+				os.Remove(filepath.Join(dir, "docs.md"))
+			},
+			want: "symlink docs.md, which pointed to README.md, removed\n",
+		},
+		{
+			name: "made executable",
+			before: func(t *testing.T, dir string) {
+				writeFile(t, dir, "scripts/deploy.sh", "#!/bin/sh\necho deploy\n")
+			},
+			change: func(t *testing.T, dir string) {
+				chmod(t, filepath.Join(dir, "scripts/deploy.sh"), 0o755)
+			},
+			want: "scripts/deploy.sh made executable\n",
+		},
+		{
+			name: "no longer executable",
+			before: func(t *testing.T, dir string) {
+				writeFile(t, dir, "run.sh", "#!/bin/sh\n")
+				chmod(t, filepath.Join(dir, "run.sh"), 0o755)
+			},
+			change: func(t *testing.T, dir string) {
+				chmod(t, filepath.Join(dir, "run.sh"), 0o644)
+			},
+			want: "run.sh no longer executable\n",
+		},
+		{
+			name: "made executable and edited",
+			before: func(t *testing.T, dir string) {
+				writeFile(t, dir, "build.sh", "#!/bin/sh\n")
+			},
+			change: func(t *testing.T, dir string) {
+				writeFile(t, dir, "build.sh", "#!/bin/sh\nmake\n")
+				chmod(t, filepath.Join(dir, "build.sh"), 0o755)
+			},
+			want: "build.sh made executable, and its content changed:\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newRepo(t)
+			if tt.before != nil {
+				tt.before(t, dir)
+				gitT(t, dir, "add", "-A")
+				gitT(t, dir, "commit", "-q", "-m", "Before")
+			}
+			tt.change(t, dir)
+			gitT(t, dir, "add", "-A")
+
+			msgs, err := BuildPromptWithOptions(io.Discard, dir, PromptOptions{MaxTokens: 128000})
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := promptText(msgs)
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("the prompt lacks %q:\n%s", tt.want, text)
+			}
+			for _, raw := range []string{"old mode", "new mode", "120000"} {
+				if strings.Contains(text, raw) {
+					t.Errorf("the prompt shows the raw mode line %q:\n%s", raw, text)
+				}
+			}
+		})
+	}
+}
+
+func symlink(t *testing.T, target, name string) {
+	t.Helper()
+	if err := os.Symlink(target, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func chmod(t *testing.T, name string, mode os.FileMode) {
+	t.Helper()
+	if err := os.Chmod(name, mode); err != nil {
+		t.Fatal(err)
+	}
+}
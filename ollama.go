@@ -0,0 +1,269 @@
+package fastcommit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultOllamaURL is where the Ollama daemon listens by default.
+	DefaultOllamaURL = "http://localhost:11434"
+	// DefaultOllamaModel is a model that runs on most machines and writes
+	// usable commit messages.
+	DefaultOllamaModel = "llama3.1"
+)
+
+// OllamaProvider talks to Ollama's native API, which unlike its
+// OpenAI-compatible endpoint needs no stream options and reports usage in
+// its own way.
+type OllamaProvider struct {
+	// BaseURL is the daemon's URL, e.g. DefaultOllamaURL.
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewOllamaProvider returns a provider for the daemon at baseURL, or at
+// DefaultOllamaURL if it is empty.
+func NewOllamaProvider(baseURL string) OllamaProvider {
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+	if !strings.Contains(baseURL, "://") {
+		// $OLLAMA_HOST is often just host:port.
+		baseURL = "http://" + baseURL
+	}
+	return OllamaProvider{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  map[string]any  `json:"options,omitempty"`
+	// Format is "json" or a JSON schema the response must follow.
+	Format any `json:"format,omitempty"`
+}
+
+// ollamaChatResponse is a response of /api/chat, or one line of it when
+// streaming.
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (r ollamaChatResponse) usage() openai.Usage {
+	return openai.Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+func (r ollamaChatResponse) finishReason() openai.FinishReason {
+	if r.DoneReason == "length" {
+		return openai.FinishReasonLength
+	}
+	return openai.FinishReasonStop
+}
+
+// post sends a chat request and returns the response body.
+func (p OllamaProvider) post(ctx context.Context, req openai.ChatCompletionRequest, stream bool) (io.ReadCloser, error) {
+	body := ollamaChatRequest{
+		Model:   req.Model,
+		Stream:  stream,
+		Options: map[string]any{"temperature": req.Temperature},
+	}
+	if rf := req.ResponseFormat; rf != nil {
+		switch {
+		case rf.JSONSchema != nil:
+			body.Format = rf.JSONSchema.Schema
+		case rf.Type == openai.ChatCompletionResponseFormatTypeJSONObject:
+			body.Format = "json"
+		}
+	}
+	for _, m := range req.Messages {
+		body.Messages = append(body.Messages, ollamaMessage{Role: m.Role, Content: m.Content})
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/api/chat", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := p.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do sends req, turning a refused connection and error statuses into
+// errors that say what to do about them.
+func (p OllamaProvider) do(req *http.Request) (*http.Response, error) {
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return nil, fmt.Errorf("connect to Ollama at %s: %w; is the daemon running? Start it with \"ollama serve\"",
+			p.BaseURL, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var r ollamaChatResponse
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(b, &r) == nil && r.Error != "" {
+			return nil, fmt.Errorf("ollama: %s", r.Error)
+		}
+		return nil, fmt.Errorf("ollama: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return resp, nil
+}
+
+func (p OllamaProvider) StreamCompletion(
+	ctx context.Context,
+	req openai.ChatCompletionRequest,
+) (CompletionStream, error) {
+	body, err := p.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	return &ollamaStream{body: body, scanner: bufio.NewScanner(body)}, nil
+}
+
+// CreateCompletion asks for each of req.N choices separately, since Ollama
+// generates one per request.
+func (p OllamaProvider) CreateCompletion(
+	ctx context.Context,
+	req openai.ChatCompletionRequest,
+) (openai.ChatCompletionResponse, error) {
+	var resp openai.ChatCompletionResponse
+	for i := 0; i < max(req.N, 1); i++ {
+		body, err := p.post(ctx, req, false)
+		if err != nil {
+			return resp, err
+		}
+		var r ollamaChatResponse
+		err = json.NewDecoder(body).Decode(&r)
+		body.Close()
+		if err != nil {
+			return resp, fmt.Errorf("ollama: decode response: %w", err)
+		}
+		resp.Model = r.Model
+		resp.Choices = append(resp.Choices, openai.ChatCompletionChoice{
+			Index:        i,
+			Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: r.Message.Content},
+			FinishReason: r.finishReason(),
+		})
+		u := r.usage()
+		resp.Usage.PromptTokens += u.PromptTokens
+		resp.Usage.CompletionTokens += u.CompletionTokens
+		resp.Usage.TotalTokens += u.TotalTokens
+	}
+	return resp, nil
+}
+
+func (p OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("ollama: decode models: %w", err)
+	}
+	var ids []string
+	for _, m := range tags.Models {
+		ids = append(ids, m.Name)
+	}
+	return ids, nil
+}
+
+// ollamaStream reads the newline-delimited JSON objects Ollama streams.
+type ollamaStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	done    bool
+}
+
+func (s *ollamaStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	var resp openai.ChatCompletionStreamResponse
+	for !s.done {
+		if !s.scanner.Scan() {
+			if err := s.scanner.Err(); err != nil {
+				return resp, err
+			}
+			// The daemon went away before saying it was done.
+			return resp, io.ErrUnexpectedEOF
+		}
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var r ollamaChatResponse
+		if err := json.Unmarshal(line, &r); err != nil {
+			return resp, fmt.Errorf("ollama: decode chunk: %w", err)
+		}
+		if r.Error != "" {
+			return resp, fmt.Errorf("ollama: %s", r.Error)
+		}
+		resp.Model = r.Model
+		choice := openai.ChatCompletionStreamChoice{
+			Delta: openai.ChatCompletionStreamChoiceDelta{Content: r.Message.Content},
+		}
+		if r.Done {
+			s.done = true
+			choice.FinishReason = r.finishReason()
+			u := r.usage()
+			resp.Usage = &u
+		}
+		resp.Choices = []openai.ChatCompletionStreamChoice{choice}
+		return resp, nil
+	}
+	return resp, io.EOF
+}
+
+func (s *ollamaStream) Close() error {
+	return s.body.Close()
+}
+
+// GetRateLimitHeaders returns nothing; a local daemon has no rate limits.
+func (s *ollamaStream) GetRateLimitHeaders() openai.RateLimitHeaders {
+	return openai.RateLimitHeaders{}
+}
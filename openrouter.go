@@ -0,0 +1,206 @@
+package fastcommit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenRouterOptions are the OpenRouter-specific request settings.
+type OpenRouterOptions struct {
+	// ProviderOrder lists the upstream providers to try first, e.g.
+	// ["Anthropic", "Together"].
+	ProviderOrder []string `toml:"provider_order"`
+	// AllowFallbacks lets OpenRouter use providers not in ProviderOrder when
+	// those fail; unset leaves OpenRouter's default, which allows them.
+	AllowFallbacks *bool `toml:"allow_fallbacks"`
+	// Transforms are the prompt transforms to apply, e.g. ["middle-out"].
+	Transforms []string `toml:"transforms"`
+	// Referer and Title attribute the requests to an app in OpenRouter's
+	// rankings, sent as the HTTP-Referer and X-Title headers.
+	Referer string `toml:"referer"`
+	Title   string `toml:"title"`
+}
+
+// OpenRouterUsage is what OpenRouter reports about serving a request beyond
+// what the OpenAI API does.
+type OpenRouterUsage struct {
+	// Model is the model that served the request, which differs from the
+	// requested one with routers such as openrouter/auto.
+	Model string
+	// Provider is the upstream provider that served it.
+	Provider string
+	// Cost is what the request was billed, in credits (US dollars).
+	Cost             float64
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// IsOpenRouter reports whether baseURL is OpenRouter's API.
+func IsOpenRouter(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == "openrouter.ai" || strings.HasSuffix(host, ".openrouter.ai")
+}
+
+type openRouterUsageKey struct{}
+
+// WithOpenRouterUsage returns a context that makes requests to OpenRouter
+// made with it call fn with the usage OpenRouter reports, once per response.
+func WithOpenRouterUsage(ctx context.Context, fn func(OpenRouterUsage)) context.Context {
+	return context.WithValue(ctx, openRouterUsageKey{}, fn)
+}
+
+// NewOpenRouterProvider returns a provider for OpenRouter at baseURL that
+// sends opts with every completion request and asks for usage accounting,
+// which WithOpenRouterUsage passes on.
+func NewOpenRouterProvider(key, baseURL string, opts OpenRouterOptions) OpenAIProvider {
+	config := openai.DefaultConfig(key)
+	config.BaseURL = baseURL
+	config.HTTPClient = &http.Client{
+		Transport: openRouterTransport{base: retryAfterTransport{base: http.DefaultTransport}, opts: opts},
+	}
+	return OpenAIProvider{Client: openai.NewClientWithConfig(config)}
+}
+
+// openRouterTransport adds the OpenRouter fields go-openai does not know
+// to completion requests and picks the usage out of their responses.
+type openRouterTransport struct {
+	base http.RoundTripper
+	opts OpenRouterOptions
+}
+
+func (t openRouterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.opts.Referer != "" {
+		req.Header.Set("HTTP-Referer", t.opts.Referer)
+	}
+	if t.opts.Title != "" {
+		req.Header.Set("X-Title", t.opts.Title)
+	}
+	if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/chat/completions") || req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if body, err = t.extend(body); err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if fn, ok := req.Context().Value(openRouterUsageKey{}).(func(OpenRouterUsage)); ok {
+		resp.Body = &openRouterUsageReader{body: resp.Body, report: fn}
+	}
+	return resp, nil
+}
+
+// extend adds the options to a JSON request body.
+func (t openRouterTransport) extend(body []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	set := func(key string, v any) error {
+		b, err := json.Marshal(v)
+		fields[key] = b
+		return err
+	}
+	if err := set("usage", map[string]bool{"include": true}); err != nil {
+		return nil, err
+	}
+	if len(t.opts.ProviderOrder) > 0 || t.opts.AllowFallbacks != nil {
+		provider := map[string]any{}
+		if len(t.opts.ProviderOrder) > 0 {
+			provider["order"] = t.opts.ProviderOrder
+		}
+		if t.opts.AllowFallbacks != nil {
+			provider["allow_fallbacks"] = *t.opts.AllowFallbacks
+		}
+		if err := set("provider", provider); err != nil {
+			return nil, err
+		}
+	}
+	if t.opts.Transforms != nil {
+		if err := set("transforms", t.opts.Transforms); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// openRouterUsageReader passes a response body through, reporting the
+// usage in it: in the final event of a stream, or in a whole response.
+type openRouterUsageReader struct {
+	body   io.ReadCloser
+	report func(OpenRouterUsage)
+	line   []byte
+	done   bool
+}
+
+func (r *openRouterUsageReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	for _, c := range p[:n] {
+		if c != '\n' {
+			r.line = append(r.line, c)
+			continue
+		}
+		r.parse(r.line)
+		r.line = r.line[:0]
+	}
+	if err == io.EOF {
+		r.parse(r.line)
+		r.line = nil
+	}
+	return n, err
+}
+
+func (r *openRouterUsageReader) parse(line []byte) {
+	line = bytes.TrimSpace(line)
+	line = bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+	if r.done || len(line) == 0 || line[0] != '{' {
+		// Keep-alive comments, [DONE] and such.
+		return
+	}
+	var resp struct {
+		Model    string `json:"model"`
+		Provider string `json:"provider"`
+		Usage    *struct {
+			Cost             float64 `json:"cost"`
+			PromptTokens     int     `json:"prompt_tokens"`
+			CompletionTokens int     `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if json.Unmarshal(line, &resp) != nil || resp.Usage == nil {
+		return
+	}
+	r.done = true
+	r.report(OpenRouterUsage{
+		Model:            resp.Model,
+		Provider:         resp.Provider,
+		Cost:             resp.Usage.Cost,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	})
+}
+
+func (r *openRouterUsageReader) Close() error {
+	return r.body.Close()
+}
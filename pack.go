@@ -0,0 +1,118 @@
+package fastcommit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DiffPacking is how PackDiff fitted a diff into its token budget.
+type DiffPacking struct {
+	// Verbatim are the files included whole, and Summarized those reduced
+	// to their header and a summary line. Both are empty when the diff fit
+	// as it was.
+	Verbatim   []string
+	Summarized []string
+}
+
+// maxTouchedNames caps the function names listed in a file summary.
+const maxTouchedNames = 10
+
+// PackDiff fits diff into maxTokens tokens without cutting through a file.
+// Small files are included whole, smallest first, as long as they fit; the
+// others are reduced to their header and a line such as "+120/-30 lines,
+// functions touched: func Parse(s string) error", taken from the hunk
+// headers. Text before the first file, such as the notes about vendored and
+// excluded files, is kept. A diff that fits is returned unchanged, one whose
+// summaries alone do not fit is cut short like Ellipse does, and nothing is
+// left of any diff for a budget of zero or less.
+func PackDiff(diff string, maxTokens int) (string, DiffPacking) {
+	var packing DiffPacking
+	if maxTokens <= 0 {
+		return "", packing
+	}
+	count := func(s string) int {
+		return CountTokens(openai.ChatCompletionMessage{Content: s})
+	}
+	files := SplitDiff(diff)
+	if len(files) == 0 || count(diff) <= maxTokens {
+		return diff, packing
+	}
+	n := len(diff)
+	for _, f := range files {
+		n -= len(f.Diff)
+	}
+	prefix := diff[:n]
+
+	summaries := make([]string, len(files))
+	full := make([]int, len(files))
+	remaining := maxTokens - count(prefix)
+	for i, f := range files {
+		summaries[i] = summarizeFileDiff(f)
+		full[i] = count(f.Diff)
+		remaining -= count(summaries[i])
+	}
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return full[a] - full[b] })
+	verbatim := make([]bool, len(files))
+	for _, i := range order {
+		extra := full[i] - count(summaries[i])
+		if extra > remaining {
+			break
+		}
+		verbatim[i] = true
+		remaining -= extra
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	for i, f := range files {
+		if verbatim[i] {
+			b.WriteString(f.Diff)
+			packing.Verbatim = append(packing.Verbatim, f.Path)
+		} else {
+			b.WriteString(summaries[i])
+			packing.Summarized = append(packing.Summarized, f.Path)
+		}
+	}
+	return Ellipse(b.String(), maxTokens), packing
+}
+
+// summarizeFileDiff returns the header of a file's diff followed by a line
+// summarizing its hunks.
+func summarizeFileDiff(f FileDiff) string {
+	var header, touched []string
+	inHunks := false
+	for _, line := range strings.Split(strings.TrimSuffix(f.Diff, "\n"), "\n") {
+		if !strings.HasPrefix(line, "@@") {
+			if !inHunks {
+				header = append(header, line)
+			}
+			continue
+		}
+		inHunks = true
+		// git names the enclosing function after the hunk range, as in
+		// "@@ -10,6 +10,8 @@ func Parse(s string) error".
+		if i := strings.Index(line[2:], "@@"); i >= 0 {
+			name := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line[i+4:]), "{"))
+			if name != "" && !slices.Contains(touched, name) {
+				touched = append(touched, name)
+			}
+		}
+	}
+
+	removed, added := changedLines(f.Diff)
+	summary := fmt.Sprintf("(diff left out to fit the token budget: +%d/-%d lines", len(added), len(removed))
+	if len(touched) > maxTouchedNames {
+		touched = append(touched[:maxTouchedNames], fmt.Sprintf("and %d more", len(touched)-maxTouchedNames))
+	}
+	if len(touched) > 0 {
+		summary += ", functions touched: " + strings.Join(touched, "; ")
+	}
+	return strings.Join(header, "\n") + "\n" + summary + ")\n"
+}
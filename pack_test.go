@@ -0,0 +1,139 @@
+package fastcommit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// fileDiff returns the diff of a new file name with lines added lines, each
+// hunk of ten lines naming a function of its own.
+func fileDiff(name string, lines int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\nnew file mode 100644\n--- /dev/null\n+++ b/%s\n", name, name, name)
+	for i := 0; i < lines; i++ {
+		if i%10 == 0 {
+			fmt.Fprintf(&b, "@@ -0,0 +%d,10 @@ func F%d() {\n", i+1, i/10)
+		}
+		fmt.Fprintf(&b, "+\tvalue%d := compute(%d) // line %d of %s\n", i, i*7, i, name)
+	}
+	return b.String()
+}
+
+func textTokens(s string) int {
+	return CountTokens(openai.ChatCompletionMessage{Content: s})
+}
+
+func TestPackDiffFits(t *testing.T) {
+	diff := fileDiff("a.go", 5)
+	got, packing := PackDiff(diff, 10000)
+	if got != diff || packing.Verbatim != nil || packing.Summarized != nil {
+		t.Errorf("a diff within the budget was changed: %+v\n%s", packing, got)
+	}
+}
+
+func TestPackDiffSingleFileOverBudget(t *testing.T) {
+	diff := fileDiff("big.go", 2000)
+	const budget = 500
+	if textTokens(diff) <= budget {
+		t.Fatal("the file fits the budget")
+	}
+	got, packing := PackDiff(diff, budget)
+	if !slices.Equal(packing.Summarized, []string{"big.go"}) || len(packing.Verbatim) != 0 {
+		t.Errorf("packing = %+v, want big.go summarized", packing)
+	}
+	if n := textTokens(got); n > budget {
+		t.Errorf("packed diff has %d tokens, over the budget of %d", n, budget)
+	}
+	if !strings.HasPrefix(got, "diff --git a/big.go b/big.go\nnew file mode 100644\n") {
+		t.Errorf("the file header is gone:\n%s", got)
+	}
+	want := "(diff left out to fit the token budget: +2000/-0 lines, functions touched: func F0(); func F1(); "
+	if !strings.Contains(got, want) || !strings.Contains(got, "and 190 more)") {
+		t.Errorf("the summary is missing:\n%s", got)
+	}
+	if strings.Contains(got, "value1 :=") {
+		t.Errorf("a hunk of the file was kept:\n%s", got)
+	}
+}
+
+func TestPackDiffSmallFilesFirst(t *testing.T) {
+	prefix := "Vendored dependencies changed.\n\n"
+	diff := prefix + fileDiff("big.go", 2000) + fileDiff("small.go", 5) + fileDiff("medium.go", 40)
+	got, packing := PackDiff(diff, 1500)
+	if !slices.Equal(packing.Verbatim, []string{"small.go", "medium.go"}) || !slices.Equal(packing.Summarized, []string{"big.go"}) {
+		t.Errorf("packing = %+v", packing)
+	}
+	if !strings.HasPrefix(got, prefix) || !strings.Contains(got, fileDiff("small.go", 5)) || !strings.Contains(got, fileDiff("medium.go", 40)) {
+		t.Errorf("the small files or the prefix are not whole:\n%s", got)
+	}
+	if n := textTokens(got); n > 1500 {
+		t.Errorf("packed diff has %d tokens", n)
+	}
+}
+
+func TestPackDiffSummariesOverBudget(t *testing.T) {
+	var diff string
+	for i := 0; i < 50; i++ {
+		diff += fileDiff(fmt.Sprintf("pkg%d/file.go", i), 30)
+	}
+	got, packing := PackDiff(diff, 100)
+	if len(packing.Verbatim) != 0 || len(packing.Summarized) != 50 {
+		t.Errorf("packing = %+v", packing)
+	}
+	if !strings.HasSuffix(got, "...") || textTokens(strings.TrimSuffix(got, "...")) > 100 {
+		t.Errorf("the summaries were not cut at the budget:\n%s", got)
+	}
+}
+
+func TestBuildPromptSingleFileOverBudget(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "big.go", goFile("big", 600, 0))
+	gitT(t, dir, "add", "-A")
+
+	const budget = 8000
+	msgs, err := BuildPromptWithOptions(io.Discard, dir, PromptOptions{MaxTokens: budget})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := CountTokens(msgs...); n > budget {
+		t.Errorf("the prompt has %d tokens, over the budget of %d", n, budget)
+	}
+	text := promptText(msgs)
+	if !strings.Contains(text, "diff --git a/big.go b/big.go") || !strings.Contains(text, "(diff left out to fit the token budget: +") {
+		t.Errorf("the prompt does not summarize big.go:\n%s", text)
+	}
+}
+
+func TestPackDiffNoBudget(t *testing.T) {
+	diff := fileDiff("a.go", 50)
+	for _, budget := range []int{0, -7063} {
+		if got, packing := PackDiff(diff, budget); got != "" || packing.Verbatim != nil || packing.Summarized != nil {
+			t.Errorf("PackDiff with a budget of %d = %q, %+v; want nothing", budget, got, packing)
+		}
+		if got := Ellipse(diff, budget); got != "" {
+			t.Errorf("Ellipse with a budget of %d = %q, want nothing", budget, got)
+		}
+	}
+}
+
+// A style guide larger than the whole budget used to panic when the diff's
+// share of it went negative.
+func TestBuildPromptGuidanceOverBudget(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "COMMITS.md", strings.Repeat("Write subjects in the imperative mood and explain why in the body.\n", 1000))
+	gitT(t, dir, "add", "-A")
+	gitT(t, dir, "commit", "-q", "-m", "Add a style guide")
+	writeFile(t, dir, "a.go", goFile("a", 20, 0))
+	gitT(t, dir, "add", "-A")
+
+	_, err := BuildPromptWithOptions(io.Discard, dir, PromptOptions{MaxTokens: 5000})
+	if !errors.Is(err, ErrGuidanceTooLong) {
+		t.Errorf("BuildPromptWithOptions = %v, want ErrGuidanceTooLong", err)
+	}
+}
@@ -2,15 +2,21 @@ package fastcommit
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/sashabaranov/go-openai"
 	"github.com/tiktoken-go/tokenizer"
@@ -24,40 +30,77 @@ func CountTokens(msgs ...openai.ChatCompletionMessage) int {
 
 	var tokens int
 	for _, msg := range msgs {
-		ts, _, _ := enc.Encode(msg.Content)
-		tokens += len(ts)
+		tokens += countText(enc, msg.Content)
 
 		for _, call := range msg.ToolCalls {
-			ts, _, _ = enc.Encode(call.Function.Arguments)
-			tokens += len(ts)
+			tokens += countText(enc, call.Function.Arguments)
 		}
 	}
 	return tokens
 }
 
-// Ellipse returns a string that is truncated to the maximum number of tokens.
+// tokenMemo remembers the token counts of long texts, such as diffs, whose
+// encoding dominates the time it takes to build a prompt and which are
+// counted again and again. It is keyed by a hash of the encoding and text.
+var tokenMemo sync.Map
+
+// minMemoLength is the length from which texts are memoized.
+const minMemoLength = 1024
+
+func countText(enc tokenizer.Codec, s string) int {
+	if len(s) < minMemoLength {
+		ts, _, _ := enc.Encode(s)
+		return len(ts)
+	}
+	key := memoKey(enc.GetName(), s)
+	if n, ok := tokenMemo.Load(key); ok {
+		return n.(int)
+	}
+	ts, _, _ := enc.Encode(s)
+	tokenMemo.Store(key, len(ts))
+	return len(ts)
+}
+
+func memoKey(encoding, s string) [sha256.Size]byte {
+	h := sha256.New()
+	io.WriteString(h, encoding)
+	h.Write([]byte{0})
+	io.WriteString(h, s)
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// RememberTokens records that content counts tokens tokens with CountTokens,
+// as counted before, e.g. by an earlier run that cached its prompt, so that
+// it is not encoded again.
+func RememberTokens(content string, tokens int) {
+	if len(content) >= minMemoLength {
+		tokenMemo.Store(memoKey(string(tokenizer.Cl100kBase), content), tokens)
+	}
+}
+
+// Ellipse returns a string that is truncated to the maximum number of tokens,
+// or empty when that is zero or less.
 func Ellipse(s string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
 	enc, err := tokenizer.Get(tokenizer.Cl100kBase)
 	if err != nil {
 		panic("failed to get tokenizer")
 	}
-
-	tokens, _, _ := enc.Encode(s)
-	if len(tokens) <= maxTokens {
+	// Texts that fit, the usual case, are counted through the memo.
+	if countText(enc, s) <= maxTokens {
 		return s
 	}
+	tokens, _, _ := enc.Encode(s)
 
 	// Decode the truncated tokens back to a string
 	truncated, _ := enc.Decode(tokens[:maxTokens])
 	return truncated + "..."
 }
 
-func reverseSlice[S ~[]E, E any](s S) {
-	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-		s[i], s[j] = s[j], s[i]
-	}
-}
-
 func mustJSON(v any) string {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -84,15 +127,32 @@ func findGitRoot(dir string) (string, error) {
 	}
 }
 
+// historyIntro introduces the recent commit messages of a prompt.
+const historyIntro = "Here are recent commit messages in the same repository:\n"
+
+// HistoryCommits returns the number of recent commit messages included in a
+// prompt built by BuildPromptWithOptions.
+func HistoryCommits(msgs []openai.ChatCompletionMessage) int {
+	for _, m := range msgs {
+		if list, ok := strings.CutPrefix(m.Content, historyIntro); ok && m.Role == openai.ChatMessageRoleSystem {
+			var commits []string
+			if json.Unmarshal([]byte(list), &commits) == nil {
+				return len(commits)
+			}
+		}
+	}
+	return 0
+}
+
 const styleGuideFilename = "COMMITS.md"
 const defaultUserStyleGuide = `
-1. Limit the subject line to 50 characters.
+1. Limit the subject line to %[1]d characters.
 2. Use the imperative mood in the subject line.
 3. Capitalize the subject line such as "Fix Issue 886" and don't end it with a period.
 4. The subject line should summarize the main change concisely.
 5. Only include a body if absolutely necessary for complex changes.
 6. If a body is needed, separate it from the subject with a blank line.
-7. Wrap the body at 72 characters.
+7. Wrap the body at %[2]d characters.
 8. In the body, explain the why, not the what (the diff shows the what).
 9. Use bullet points in the body only for truly distinct changes.
 10. Be extremely concise. Assume the reader can understand the diff.
@@ -135,6 +195,163 @@ func findUserStyleGuide() (string, error) {
 	return strings.TrimSpace(string(styleGuide)), nil
 }
 
+// PromptOptions configures BuildPromptWithOptions.
+type PromptOptions struct {
+	// CommitHash is the commit to describe. When empty, the staged changes
+	// are described.
+	CommitHash string
+	// Amend describes CommitHash together with the staged changes.
+	Amend bool
+	// WorkingTree describes all changes to tracked files in the working
+	// tree, staged or not, instead of only the staged changes.
+	WorkingTree bool
+	// IncludeUntracked adds untracked files to a WorkingTree diff.
+	IncludeUntracked bool
+	// MaxTokens is the token budget for the whole prompt.
+	MaxTokens int
+	// MaxDiffBytes bounds the memory the diff takes while the prompt is
+	// built. Past it, files are only named. Zero means DefaultMaxDiffBytes.
+	MaxDiffBytes int
+	// MaxLineLength caps the length of each diff line in characters. Zero
+	// means DefaultMaxLineLength; a negative value disables the cap.
+	MaxLineLength int
+	// Description is the user's own account of the change. When there is no
+	// diff, the message is generated from it instead. Otherwise it is given
+	// to the model as additional context.
+	Description string
+	// Overview stands in for the diff in the prompt when the diff is too
+	// large to show, e.g. summaries of each component it touches. With an
+	// Overview, an empty diff is not an error.
+	Overview string
+	// SubjectLength and BodyWidth are the line lengths asked of the model.
+	// Zero means DefaultSubjectLength and DefaultBodyWidth.
+	SubjectLength int
+	BodyWidth     int
+	// Paths limits the changes to these files, given relative to the
+	// repository root. All changed files are included when it is empty.
+	Paths []string
+	// SkipHistory leaves recent commit messages out of the prompt, e.g. in
+	// a shallow clone where there are too few of them to show the style.
+	SkipHistory bool
+	// History is the most recent commits whose messages are shown as
+	// examples. Zero means DefaultHistory.
+	History int
+	// HistoryTokens caps the tokens of those messages, apart from the
+	// diff's budget. Zero means DefaultHistoryTokens; a negative value
+	// disables the cap.
+	HistoryTokens int
+	// HistoryMinLength leaves out messages shorter than this many
+	// characters. Zero means DefaultHistoryMinLength; a negative value
+	// keeps them all.
+	HistoryMinLength int
+	// HistoryBotPattern is a regular expression matching the authors, as
+	// "Name <email>", of commits left out as automated. Empty means
+	// DefaultHistoryBotPattern.
+	HistoryBotPattern string
+	// HistorySelected is called with the examples chosen and why others
+	// were left out. Merge commits are always left out, and commits
+	// touching the same files as the change are preferred.
+	HistorySelected func(HistorySelection) `json:"-"`
+	// Narrative lists the subjects of this many commits before the change,
+	// newest first, so that the message may read as their continuation.
+	// Unlike the examples of History, they are never repeated in the
+	// message. Range and Since, which list their own commits, ignore it.
+	Narrative int
+	// StyleExamples are messages the user edited after they were generated.
+	// They are the highest-priority style guidance in the prompt.
+	StyleExamples []StyleExample
+	// Range describes a range of commits such as "main..HEAD" as a single
+	// change, as when squashing them. It takes precedence over CommitHash.
+	Range string
+	// Since describes all work since the branch forked off this ref, e.g.
+	// "origin/main": the branch's commits and the staged changes, as one
+	// change for a squash merge. It takes precedence over CommitHash, and
+	// Range over it.
+	Since string
+	// Conventions are the commit conventions the repository documents, such
+	// as the commit section of its CONTRIBUTING.md. They take priority over
+	// the style guides, but not over SubjectLength and BodyWidth.
+	Conventions string
+	// VendorDirs are the directories holding vendored dependencies. Their
+	// files are summarized instead of shown. Nil means DefaultVendorDirs;
+	// an empty list shows them like any other files.
+	VendorDirs []string
+	// Exclude lists files whose diffs are left out of the prompt, each
+	// replaced by a line with its line counts, in addition to
+	// DefaultExcludes and the files .gitattributes marks
+	// linguist-generated. Patterns match as in MatchGlob.
+	Exclude []string
+	// NoExclude shows the diffs of all files, ignoring Exclude.
+	NoExclude bool
+	// DigestOnly sends a digest of the diff instead of the diff: the changed
+	// files with their line counts and the names of the declarations changed
+	// in them, but no content. It takes precedence over Overview.
+	DigestOnly bool
+	// DigestExtractors extract the names for DigestOnly. Nil means
+	// DefaultIdentifierExtractors.
+	DigestExtractors IdentifierExtractors `json:"-"`
+	// Conventional requires Conventional Commits subjects following these
+	// rules. They take priority over all style guidance.
+	Conventional *ConventionalRules
+	// Packed is called when the diff does not fit MaxTokens, with how
+	// PackDiff fitted it.
+	Packed func(DiffPacking) `json:"-"`
+	// Minimal sends only the system message and the diff, without recent
+	// commits, style guides or the branch, and does not open the
+	// repository at all. It trades quality for latency.
+	Minimal bool
+}
+
+// Default line lengths of generated messages, following the common git
+// convention.
+const (
+	DefaultSubjectLength = 50
+	DefaultBodyWidth     = 72
+)
+
+// StyleExample is a generated message and what the user changed it to.
+type StyleExample struct {
+	Generated string `json:"generated"`
+	Edited    string `json:"edited"`
+}
+
+// maxRangeMessageTokens caps the messages of squashed commits in the prompt.
+const maxRangeMessageTokens = 4000
+
+// DefaultMaxLineLength is the default cap on the length of a diff line.
+const DefaultMaxLineLength = 500
+
+// truncatedLine ends a line that was cut short, with its full length.
+const truncatedLine = "... [line truncated, %d characters]"
+
+var truncatedLineRe = regexp.MustCompile(`\.\.\. \[line truncated, (\d+) characters\]$`)
+
+// truncateLongLines shortens lines longer than maxLen characters, such as
+// minified code, noting their original length. A line the diff limiter cut
+// already keeps the length it noted.
+func truncateLongLines(diff string, maxLen int) string {
+	if maxLen <= 0 {
+		return diff
+	}
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		if len(line) <= maxLen {
+			continue
+		}
+		n := utf8.RuneCountInString(line)
+		if n <= maxLen {
+			continue
+		}
+		if m := truncatedLineRe.FindStringSubmatchIndex(line); m != nil {
+			n, _ = strconv.Atoi(line[m[2]:m[3]])
+			line = line[:m[0]]
+		}
+		r := []rune(line)
+		lines[i] = string(r[:min(maxLen, len(r))]) + fmt.Sprintf(truncatedLine, n)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func BuildPrompt(
 	log io.Writer,
 	dir string,
@@ -142,33 +359,41 @@ func BuildPrompt(
 	amend bool,
 	maxTokens int,
 ) ([]openai.ChatCompletionMessage, error) {
-	resp := []openai.ChatCompletionMessage{
-		{
-			Role: openai.ChatMessageRoleSystem,
-			Content: strings.Join([]string{
-				"You are a tool called `fastcommit` that generates high quality commit messages for git diffs.",
-				"Generate only the commit message, without any additional text.",
-			}, "\n"),
-		},
-	}
+	return BuildPromptWithOptions(log, dir, PromptOptions{
+		CommitHash: commitHash,
+		Amend:      amend,
+		MaxTokens:  maxTokens,
+	})
+}
 
-	gitRoot, err := findGitRoot(dir)
-	if err != nil {
-		return nil, fmt.Errorf("find git root: %w", err)
-	}
+// BuildPromptWithOptions builds the chat messages used to generate a commit
+// message for the repository containing dir. The same repository state and
+// options always yield the same messages, with nothing taken from the clock,
+// temporary paths or map order. Guidance that rarely changes comes first and
+// the change itself last, so that providers can cache the common prefix; the
+// branch name, the one volatile detail, is only sent in the last message.
+func BuildPromptWithOptions(
+	log io.Writer,
+	dir string,
+	opts PromptOptions,
+) ([]openai.ChatCompletionMessage, error) {
+	commitHash, maxTokens := opts.CommitHash, opts.MaxTokens
 
-	repo, err := git.PlainOpen(gitRoot)
-	if err != nil {
-		return nil, fmt.Errorf("open repo %q: %w", dir, err)
-	}
+	resp := []openai.ChatCompletionMessage{systemMessage()}
 
 	var buf bytes.Buffer
 	// Get the working directory diff
-	if err := generateDiff(&buf, dir, commitHash, amend); err != nil {
+	if err := generateDiff(&buf, dir, opts); err != nil {
 		return nil, fmt.Errorf("generate working directory diff: %w", err)
 	}
 
-	if buf.Len() == 0 {
+	if buf.Len() == 0 && opts.Description == "" && opts.Overview == "" {
+		if opts.WorkingTree {
+			return nil, fmt.Errorf("no changes in the working tree")
+		}
+		if opts.Range != "" {
+			return nil, fmt.Errorf("no changes in %s", opts.Range)
+		}
 		if commitHash == "" {
 			return nil, fmt.Errorf("no staged changes, nothing to commit")
 		}
@@ -180,68 +405,80 @@ func BuildPrompt(
 		return nil, fmt.Errorf("maxTokens must be greater than %d", minTokens)
 	}
 
-	targetDiffString := buf.String()
+	maxLineLength := opts.MaxLineLength
+	if maxLineLength == 0 {
+		maxLineLength = DefaultMaxLineLength
+	}
+	// Truncate before any token counting so the budget reflects what is sent.
+	diff := DescribeLineEndingChanges(DescribeModeChanges(excludedNote(dir, vendoredNote(buf.String(), opts.VendorDirs), opts)))
+	targetDiffString := truncateLongLines(diff, maxLineLength)
+	if opts.Overview != "" {
+		targetDiffString = opts.Overview
+	}
+	if opts.DigestOnly && buf.Len() > 0 {
+		digest, err := digestPrompt(dir, opts, buf.String())
+		if err != nil {
+			return nil, fmt.Errorf("build digest: %w", err)
+		}
+		targetDiffString = digest
+	}
 
-	// Get the HEAD reference
-	head, err := repo.Head()
+	if opts.Minimal {
+		resp = append(resp, opts.Conventional.messages()...)
+		return appendTarget(resp, targetMessages(targetDiffString, opts.Description, ""), opts)
+	}
+
+	gitRoot, err := findGitRoot(dir)
 	if err != nil {
-		// No commits yet
-		fmt.Fprintln(log, "no commits yet")
-		resp = append(resp, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
-			Content: targetDiffString,
-		})
-		return resp, nil
+		return nil, fmt.Errorf("find git root: %w", err)
 	}
 
-	// Create a log options struct
-	logOptions := &git.LogOptions{
-		From:  head.Hash(),
-		Order: git.LogOrderCommitterTime,
+	repo, err := git.PlainOpen(gitRoot)
+	if err != nil {
+		return nil, fmt.Errorf("open repo %q: %w", dir, err)
 	}
 
-	// Get the commit iterator
-	commitIter, err := repo.Log(logOptions)
+	// Get the HEAD reference
+	head, err := repo.Head()
 	if err != nil {
-		return nil, fmt.Errorf("get commit iterator: %w", err)
+		// No commits yet
+		fmt.Fprintln(log, "no commits yet")
+		resp = append(resp, opts.Conventional.messages()...)
+		return appendTarget(resp, targetMessages(targetDiffString, opts.Description, ""), opts)
 	}
-	defer commitIter.Close()
 
-	// Collect the last N commits
-	var commits []*object.Commit
-	for i := 0; i < 300; i++ {
-		commit, err := commitIter.Next()
-		if err == io.EOF {
-			break
+	var commitMsgs []string
+	if !opts.SkipHistory {
+		var paths []string
+		for _, fd := range SplitDiff(buf.String()) {
+			paths = append(paths, fd.Path)
 		}
+		var sel HistorySelection
+		sel, commitMsgs, err = selectHistory(repo, gitRoot, head.Hash(), commitHash, paths, opts)
 		if err != nil {
-			return nil, fmt.Errorf("iterate commits: %w", err)
+			return nil, err
 		}
-		// Ignore if commit equals ref, because we are trying to recalculate
-		// that particular commit's message.
-		if commit.Hash.String() == commitHash {
-			continue
+		if opts.HistorySelected != nil {
+			opts.HistorySelected(sel)
 		}
-		commits = append(commits, commit)
-
-	}
-
-	// We want to reverse the commits so that the most recent commit is the
-	// last or "most recent" in the chat.
-	reverseSlice(commits)
-
-	var commitMsgs []string
-	for _, commit := range commits {
-		commitMsgs = append(commitMsgs, Ellipse(commit.Message, 1000))
 	}
 	// We provide the commit messages in case the actual commit diffs are cut
 	// off due to token limits.
-	resp = append(resp, openai.ChatCompletionMessage{
-		Role: openai.ChatMessageRoleSystem,
-		Content: "Here are recent commit messages in the same repository:\n" +
-			mustJSON(commitMsgs),
-	},
-	)
+	if len(commitMsgs) > 0 {
+		resp = append(resp, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: historyIntro + mustJSON(commitMsgs),
+		})
+	}
+
+	subjectLength, bodyWidth := opts.SubjectLength, opts.BodyWidth
+	if subjectLength == 0 {
+		subjectLength = DefaultSubjectLength
+	}
+	if bodyWidth == 0 {
+		bodyWidth = DefaultBodyWidth
+	}
+	lengthsCovered := false
 
 	// Add style guide after commit messages so it takes priority.
 	repoStyleGuide, err := findRepoStyleGuide(dir)
@@ -260,54 +497,300 @@ func BuildPrompt(
 			return nil, fmt.Errorf("find user style guide: %w", err)
 		}
 		if userStyleGuide == "" {
-			userStyleGuide = defaultUserStyleGuide
+			userStyleGuide = fmt.Sprintf(defaultUserStyleGuide, subjectLength, bodyWidth)
+			lengthsCovered = true
 		}
 		resp = append(resp, openai.ChatCompletionMessage{
 			Role:    openai.ChatMessageRoleSystem,
 			Content: "This user has a preferred style guide:\n" + userStyleGuide,
 		})
 	}
+	if opts.Conventions != "" {
+		resp = append(resp, conventionsMessage(opts.Conventions))
+		// Restate configured lengths so they win over the conventions.
+		lengthsCovered = false
+	}
+	if !lengthsCovered && (opts.SubjectLength != 0 || opts.BodyWidth != 0) {
+		// Explicitly configured lengths apply on top of a custom guide.
+		resp = append(resp, lengthsMessage(subjectLength, bodyWidth))
+	}
 
-	resp = append(resp, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
-		Content: Ellipse(targetDiffString, maxTokens-CountTokens(resp...)),
-	})
+	branch := ""
+	if head.Name().IsBranch() {
+		branch = head.Name().Short()
+	}
+	if len(opts.StyleExamples) > 0 {
+		// Last of the style guidance, so it takes priority.
+		resp = append(resp, openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleSystem,
+			Content: "The user edited these generated messages before committing them. " +
+				"The edits show the style the user wants; follow it above all other style guidance:\n" +
+				mustJSON(opts.StyleExamples),
+		})
+	}
+	// After the style examples, which cannot override what is checked.
+	resp = append(resp, opts.Conventional.messages()...)
 
-	return resp, nil
+	if opts.Narrative > 0 && opts.Range == "" && opts.Since == "" {
+		msg, ok, err := narrativeMessage(dir, commitHash, opts.Narrative)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			resp = append(resp, msg)
+		}
+	}
+	if opts.Range != "" {
+		squashed, err := RangeMessages(dir, opts.Range)
+		if err != nil {
+			return nil, fmt.Errorf("list commits in %s: %w", opts.Range, err)
+		}
+		resp = append(resp, openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleSystem,
+			Content: Ellipse("The following commits, oldest first, are being squashed into one. "+
+				"Write a single message for the combined change, based on the diff that follows:\n"+
+				mustJSON(squashed), maxRangeMessageTokens),
+		})
+	}
+	if opts.Since != "" && opts.Range == "" {
+		msg, ok, err := sinceMessage(dir, opts.Since)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			resp = append(resp, msg)
+		}
+	}
+	return appendTarget(resp, targetMessages(targetDiffString, opts.Description, branch), opts)
 }
 
-// generateDiff uses the git CLI to generate a diff for the given reference.
-// If refName is empty, it will generate a diff of staged changes for the working directory.
-func generateDiff(w io.Writer, dir string, refName string, amend bool) error {
-	// Use the git CLI instead of go-git for more accurate and complete diff generation
-	cmd := exec.Command("git", "-C", dir, "diff")
+// sinceMessage lists the subjects of the branch's commits since it forked off
+// ref, if it has any.
+func sinceMessage(dir, ref string) (openai.ChatCompletionMessage, bool, error) {
+	base, err := MergeBase(dir, ref)
+	if err != nil {
+		return openai.ChatCompletionMessage{}, false, err
+	}
+	msgs, err := RangeMessages(dir, base+"..HEAD")
+	if err != nil || len(msgs) == 0 {
+		return openai.ChatCompletionMessage{}, false, err
+	}
+	subjects := make([]string, len(msgs))
+	for i, m := range msgs {
+		subjects[i], _, _ = strings.Cut(m, "\n")
+	}
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: Ellipse("The branch is being squash-merged into "+ref+". These are the subjects of its commits, "+
+			"oldest first. Write a single message for the combined change, based on the diff that follows, "+
+			"which also includes any staged changes:\n"+mustJSON(subjects), maxRangeMessageTokens),
+	}, true, nil
+}
 
-	if refName == "" {
-		// Case 1: No specific commit reference provided
-		// Generate diff for staged changes in the working directory
-		cmd.Args = append(cmd.Args, "--cached")
-	} else {
-		// Case 2: A specific commit reference is provided
-		if amend {
-			// Case 2a: Amending the specified commit
-			// Show diff of the commit being amended plus any staged changes
-			cmd.Args = append(cmd.Args, "--cached", refName+"^")
-		} else {
-			// Case 2b: Show changes introduced by the specific commit
-			cmd.Args = append(cmd.Args, refName+"^", refName)
+// maxNarrativeTokens caps the subjects listed for PromptOptions.Narrative.
+const maxNarrativeTokens = 1000
+
+// narrativeMessage lists the subjects of the n commits on the first-parent
+// line before the change: before HEAD for staged changes, or before
+// commitHash when it is described or amended. It reports false when there
+// are none, as for the first commit.
+func narrativeMessage(dir, commitHash string, n int) (openai.ChatCompletionMessage, bool, error) {
+	start := "HEAD"
+	if commitHash != "" {
+		start = commitHash + "^"
+	}
+	if err := runGit(io.Discard, dir, "rev-parse", "--verify", "-q", start+"^{commit}"); err != nil {
+		return openai.ChatCompletionMessage{}, false, nil
+	}
+	var buf bytes.Buffer
+	if err := runGit(&buf, dir, "log", "--first-parent", "-n", strconv.Itoa(n), "--format=%s%x00", start, "--"); err != nil {
+		return openai.ChatCompletionMessage{}, false, err
+	}
+	var lines []string
+	for _, s := range splitNUL(buf.String()) {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		pos := "previous commit"
+		if i := len(lines) + 1; i > 1 {
+			pos = fmt.Sprintf("%d commits back", i)
 		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", pos, s))
+	}
+	if len(lines) == 0 {
+		return openai.ChatCompletionMessage{}, false, nil
 	}
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: Ellipse("These are the subjects of the commits just before this change on the branch, newest "+
+			"first. If the change continues their work, the message may say so, e.g. as a follow-up to the "+
+			"previous commit. Never repeat them or describe their changes again:\n"+strings.Join(lines, "\n"),
+			maxNarrativeTokens),
+	}, true, nil
+}
 
-	var errBuf bytes.Buffer
-	cmd.Stdout = w
-	cmd.Stderr = &errBuf
+// ErrGuidanceTooLong is returned when the messages around the diff, such as
+// style guides and recent commits, leave no room for it in MaxTokens.
+var ErrGuidanceTooLong = errors.New("the guidance alone exceeds the token budget")
+
+// appendTarget appends the target messages to resp, packing only the last
+// one, the diff, so that the prompt fits in opts.MaxTokens.
+func appendTarget(resp, target []openai.ChatCompletionMessage, opts PromptOptions) ([]openai.ChatCompletionMessage, error) {
+	last := &target[len(target)-1]
+	others := CountTokens(resp...) + CountTokens(target[:len(target)-1]...)
+	budget := opts.MaxTokens - others
+	if budget <= 0 {
+		return nil, fmt.Errorf("%d tokens for a budget of %d: %w", others, opts.MaxTokens, ErrGuidanceTooLong)
+	}
+	var packing DiffPacking
+	last.Content, packing = PackDiff(last.Content, budget)
+	if len(packing.Summarized) > 0 && opts.Packed != nil {
+		opts.Packed(packing)
+	}
+	last.Content = Ellipse(last.Content, budget)
+	return append(resp, target...), nil
+}
 
-	// Run the git command and return any execution errors
-	err := cmd.Run()
+// recentCommits returns up to n commits reachable from head, newest first,
+// leaving out skip. History cut off by a shallow clone ends the walk early.
+func recentCommits(repo *git.Repository, head plumbing.Hash, skip string, n int) ([]*object.Commit, error) {
+	commitIter, err := repo.Log(&git.LogOptions{
+		From:  head,
+		Order: git.LogOrderCommitterTime,
+	})
 	if err != nil {
-		return fmt.Errorf("running %s %s: %w\n%s",
-			cmd.Args[0], strings.Join(cmd.Args[1:], " "), err, errBuf.String())
+		return nil, fmt.Errorf("get commit iterator: %w", err)
 	}
+	defer commitIter.Close()
 
-	return nil
+	// Collect the last N commits
+	var commits []*object.Commit
+	for len(commits) < n {
+		commit, err := commitIter.Next()
+		if err == io.EOF || errors.Is(err, plumbing.ErrObjectNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("iterate commits: %w", err)
+		}
+		// Ignore if commit equals ref, because we are trying to recalculate
+		// that particular commit's message.
+		if commit.Hash.String() == skip {
+			continue
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+func systemMessage() openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: strings.Join([]string{
+			"You are a tool called `fastcommit` that generates high quality commit messages for git diffs.",
+			"Generate only the commit message, without any additional text.",
+		}, "\n"),
+	}
+}
+
+// conventionsMessage asks the model to follow the conventions a repository
+// documents.
+func conventionsMessage(conventions string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: "This repository documents its commit conventions as follows. They are " +
+			"authoritative; follow them over the style guide above:\n" + conventions,
+	}
+}
+
+// lengthsMessage asks for these line lengths.
+func lengthsMessage(subjectLength, bodyWidth int) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: fmt.Sprintf("Limit the subject line to %d characters and wrap the body at %d characters.",
+			subjectLength, bodyWidth),
+	}
+}
+
+// BuildIncrementalPrompt builds a prompt that updates a previously generated
+// message after a small follow-up change, such as amending a commit while
+// polishing it. delta is the diff between the state prevMessage described and
+// the new state.
+func BuildIncrementalPrompt(prevMessage, delta string, maxTokens int) []openai.ChatCompletionMessage {
+	return BuildIncrementalPromptWithOptions(prevMessage, delta, PromptOptions{MaxTokens: maxTokens})
+}
+
+// BuildIncrementalPromptWithOptions is BuildIncrementalPrompt with the rules
+// of opts that the message must follow whatever it said before: Conventions,
+// explicit SubjectLength and BodyWidth, and Conventional. The options
+// selecting the changes are ignored, since delta is given.
+func BuildIncrementalPromptWithOptions(prevMessage, delta string, opts PromptOptions) []openai.ChatCompletionMessage {
+	resp := []openai.ChatCompletionMessage{
+		systemMessage(),
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "The commit is being amended. Its current message is:\n" + prevMessage,
+		},
+		{
+			Role: openai.ChatMessageRoleSystem,
+			Content: "The next message is the diff of the changes made since that message was written. " +
+				"Update the message minimally to account for them, keeping its wording and style " +
+				"wherever it is still accurate. If nothing needs to change, repeat it unchanged.",
+		},
+	}
+	// After the request to keep the message, which they override.
+	if opts.Conventions != "" {
+		resp = append(resp, conventionsMessage(opts.Conventions))
+	}
+	if opts.SubjectLength != 0 || opts.BodyWidth != 0 {
+		subjectLength, bodyWidth := opts.SubjectLength, opts.BodyWidth
+		if subjectLength == 0 {
+			subjectLength = DefaultSubjectLength
+		}
+		if bodyWidth == 0 {
+			bodyWidth = DefaultBodyWidth
+		}
+		resp = append(resp, lengthsMessage(subjectLength, bodyWidth))
+	}
+	resp = append(resp, opts.Conventional.messages()...)
+
+	if delta == "" {
+		delta = "(no changes)"
+	}
+	delta, _ = PackDiff(delta, opts.MaxTokens-CountTokens(resp...))
+	resp = append(resp, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: Ellipse(delta, opts.MaxTokens-CountTokens(resp...)),
+	})
+	return resp
+}
+
+// targetMessages returns the messages describing the change itself: the diff,
+// optionally preceded by the user's description, or only the description when
+// there is no diff.
+func targetMessages(diff, description, branch string) []openai.ChatCompletionMessage {
+	if diff == "" {
+		content := "There is no diff for this change yet. " +
+			"Write the commit message from the user's description of it:\n" +
+			description
+		if branch != "" {
+			content = "Current branch: " + branch + "\n" + content
+		}
+		return []openai.ChatCompletionMessage{{
+			Role:    openai.ChatMessageRoleUser,
+			Content: content,
+		}}
+	}
+
+	var msgs []openai.ChatCompletionMessage
+	if description != "" {
+		msgs = append(msgs, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "The user describes the change as follows:\n" + description,
+		})
+	}
+	return append(msgs, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: diff,
+	})
 }
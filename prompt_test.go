@@ -0,0 +1,156 @@
+package fastcommit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// promptText joins the contents of msgs for tests that look for text
+// anywhere in a prompt.
+func promptText(msgs []openai.ChatCompletionMessage) string {
+	var b strings.Builder
+	for _, m := range msgs {
+		b.WriteString(m.Content)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestBuildPromptTemporaryIndex(t *testing.T) {
+	dir := newRepo(t)
+	index := filepath.Join(t.TempDir(), "index")
+	real, err := os.ReadFile(filepath.Join(dir, ".git", "index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(index, real, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, dir, "file.txt", "staged in the temporary index\n")
+	t.Setenv("GIT_INDEX_FILE", index)
+	gitT(t, dir, "add", "file.txt")
+	os.Unsetenv("GIT_INDEX_FILE")
+	writeFile(t, dir, "file.txt", "staged in the real index\n")
+	gitT(t, dir, "add", "file.txt")
+
+	t.Setenv("GIT_INDEX_FILE", index)
+	msgs, err := BuildPromptWithOptions(io.Discard, dir, PromptOptions{MaxTokens: 8192})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := promptText(msgs)
+	if !strings.Contains(text, "+staged in the temporary index") {
+		t.Errorf("prompt lacks the change in the temporary index:\n%s", text)
+	}
+	if strings.Contains(text, "staged in the real index") {
+		t.Errorf("prompt describes the real index:\n%s", text)
+	}
+}
+
+// minifiedBundle returns a single line of minified JavaScript of about n
+// bytes.
+func minifiedBundle(n int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < n; i++ {
+		fmt.Fprintf(&b, "function f%d(a,b){return a*%d+b};", i, i)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func TestBuildPromptMinifiedBundle(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "static/bundle.js", minifiedBundle(200_000))
+	gitT(t, dir, "add", "-A")
+
+	msgs, err := BuildPromptWithOptions(io.Discard, dir, PromptOptions{MaxTokens: 128000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := CountTokens(msgs...); n > 2000 {
+		t.Errorf("the prompt for a one-line bundle has %d tokens", n)
+	}
+	text := promptText(msgs)
+	if !strings.Contains(text, "... [line truncated, 2000") {
+		t.Errorf("prompt does not say the line was truncated:\n%s", text)
+	}
+
+	// The cap is configurable.
+	msgs, err = BuildPromptWithOptions(io.Discard, dir, PromptOptions{MaxTokens: 128000, MaxLineLength: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "+function f0(a,b){return a*0+b};function f1(a,b){r... [line truncated"
+	if text := promptText(msgs); !strings.Contains(text, want) {
+		t.Errorf("prompt lacks %q:\n%s", want, text)
+	}
+}
+
+// TestPromptHelperProcess is run by TestPromptDeterministic in another
+// process.
+func TestPromptHelperProcess(t *testing.T) {
+	dir, out := os.Getenv("FASTCOMMIT_PROMPT_DIR"), os.Getenv("FASTCOMMIT_PROMPT_OUT")
+	if dir == "" || out == "" {
+		t.Skip("helper process")
+	}
+	msgs, err := BuildPromptWithOptions(io.Discard, dir, deterministicOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, []byte(formatPrompt(msgs)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// deterministicOptions include the untracked files, whose order comes from
+// the file system.
+var deterministicOptions = PromptOptions{MaxTokens: defaultMaxTokens, WorkingTree: true, IncludeUntracked: true}
+
+func TestPromptDeterministic(t *testing.T) {
+	dir := newFixture(t, "monorepo")
+	for _, name := range []string{"zeta.txt", "alpha.txt", "mid/beta.txt", "mid/alpha.txt", "mid/café.txt"} {
+		writeFile(t, dir, name, "new "+name+"\n")
+	}
+
+	build := func() string {
+		msgs, err := BuildPromptWithOptions(io.Discard, dir, deterministicOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return formatPrompt(msgs)
+	}
+	first := build()
+	if second := build(); second != first {
+		t.Fatalf("two builds in one process differ:\n%s", firstDifference(first, second))
+	}
+
+	// Another process, started elsewhere, builds the same prompt.
+	out := filepath.Join(t.TempDir(), "prompt")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPromptHelperProcess$")
+	cmd.Dir = t.TempDir()
+	cmd.Env = append(os.Environ(), "FASTCOMMIT_PROMPT_DIR="+dir, "FASTCOMMIT_PROMPT_OUT="+out)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("helper process: %v\n%s", err, b)
+	}
+	other, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(other) != first {
+		t.Errorf("builds in two processes differ:\n%s", firstDifference(first, string(other)))
+	}
+
+	// Nor does the user's configuration change it.
+	gitT(t, dir, "config", "--global", "core.quotePath", "false")
+	if configured := build(); configured != first {
+		t.Errorf("core.quotePath changed the prompt:\n%s", firstDifference(first, configured))
+	}
+}
@@ -0,0 +1,105 @@
+package fastcommit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Provider is a model backend. Requests and responses use go-openai's types
+// whatever the backend speaks; fields a backend does not support, such as
+// StreamOptions, are ignored by it.
+type Provider interface {
+	// StreamCompletion streams the completion of req.
+	StreamCompletion(ctx context.Context, req openai.ChatCompletionRequest) (CompletionStream, error)
+	// CreateCompletion returns the whole completion of req at once,
+	// including req.N choices.
+	CreateCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+	// ListModels returns the IDs of the models the backend serves.
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// CompletionStream is a completion arriving in chunks. Recv returns io.EOF
+// after the last one.
+type CompletionStream interface {
+	Recv() (openai.ChatCompletionStreamResponse, error)
+	Close() error
+	// GetRateLimitHeaders returns the rate limits the backend reported, if
+	// any.
+	GetRateLimitHeaders() openai.RateLimitHeaders
+}
+
+// OpenAIProvider talks to the OpenAI API or any server compatible with it.
+type OpenAIProvider struct {
+	Client *openai.Client
+}
+
+// NewOpenAIProvider returns a provider for the API at baseURL. Its errors
+// carry the delay the server asks for, as returned by RetryAfter.
+func NewOpenAIProvider(key, baseURL string) OpenAIProvider {
+	config := openai.DefaultConfig(key)
+	config.BaseURL = baseURL
+	config.HTTPClient = &http.Client{Transport: retryAfterTransport{base: http.DefaultTransport}}
+	return OpenAIProvider{Client: openai.NewClientWithConfig(config)}
+}
+
+func (p OpenAIProvider) StreamCompletion(
+	ctx context.Context,
+	req openai.ChatCompletionRequest,
+) (CompletionStream, error) {
+	ctx, withDelay := withRetryAfter(ctx)
+	stream, err := p.Client.CreateChatCompletionStream(ctx, req)
+	if err != nil && req.StreamOptions != nil && rejectsStreamOptions(err) {
+		// Some compatible servers do not know stream_options; usage is
+		// only reported for debugging anyway.
+		req.StreamOptions = nil
+		stream, err = p.Client.CreateChatCompletionStream(ctx, req)
+	}
+	if err != nil {
+		return nil, withDelay(err)
+	}
+	return stream, nil
+}
+
+func (p OpenAIProvider) CreateCompletion(
+	ctx context.Context,
+	req openai.ChatCompletionRequest,
+) (openai.ChatCompletionResponse, error) {
+	ctx, withDelay := withRetryAfter(ctx)
+	resp, err := p.Client.CreateChatCompletion(ctx, req)
+	return resp, withDelay(err)
+}
+
+func (p OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	models, err := p.Client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(models.Models))
+	for i, m := range models.Models {
+		ids[i] = m.ID
+	}
+	return ids, nil
+}
+
+// rejectsStreamOptions reports whether err is a server refusing the
+// stream_options field of a request.
+func rejectsStreamOptions(err error) bool {
+	status := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+	if status != http.StatusBadRequest && status != http.StatusUnprocessableEntity {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "stream_options") || strings.Contains(msg, "include_usage")
+}
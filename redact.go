@@ -0,0 +1,141 @@
+package fastcommit
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// minRedactedComponentLength is the shortest single path component that is
+// redacted on its own. Shorter names such as "a" or "db" would mangle
+// unrelated text; they are still redacted as part of full paths.
+const minRedactedComponentLength = 3
+
+// PathRedactor replaces file paths with stable placeholders such as
+// "dir_01/file_02.go" for repositories whose path names are confidential.
+// File extensions are kept so the model still knows the languages involved.
+type PathRedactor struct {
+	replacer     *strings.Replacer
+	components   map[string]string
+	placeholders *regexp.Regexp
+}
+
+// NewPathRedactor returns a redactor for paths. Placeholders are numbered in
+// sorted path order so the same set of paths always yields the same prompt.
+func NewPathRedactor(paths []string) *PathRedactor {
+	paths = slices.Clone(paths)
+	slices.Sort(paths)
+
+	dirs := map[string]string{}
+	files := map[string]string{}
+	components := map[string]string{}
+	var pairs []string
+
+	redactDir := func(dir string) string {
+		if dir == "." || dir == "" {
+			return ""
+		}
+		if p, ok := dirs[dir]; ok {
+			return p
+		}
+		p := fmt.Sprintf("dir_%02d", len(dirs)+1)
+		dirs[dir] = p
+		components[path.Base(dir)] = p
+		return p
+	}
+
+	for _, p := range paths {
+		// Number every directory on the way down before the file itself.
+		var redacted []string
+		parts := strings.Split(p, "/")
+		for i := range parts[:len(parts)-1] {
+			redacted = append(redacted, redactDir(strings.Join(parts[:i+1], "/")))
+		}
+		if _, ok := files[p]; !ok {
+			placeholder := fmt.Sprintf("file_%02d%s", len(files)+1, path.Ext(p))
+			files[p] = placeholder
+			components[path.Base(p)] = placeholder
+		}
+		redacted = append(redacted, files[p])
+		pairs = append(pairs, p, strings.Join(redacted, "/"))
+	}
+	for dir := range dirs {
+		var redacted []string
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			redacted = append(redacted, dirs[strings.Join(parts[:i+1], "/")])
+		}
+		pairs = append(pairs, dir+"/", strings.Join(redacted, "/")+"/")
+	}
+
+	// strings.Replacer prefers earlier pairs at the same position, so list
+	// the longest originals first.
+	type pair struct{ old, new string }
+	var sorted []pair
+	for i := 0; i < len(pairs); i += 2 {
+		sorted = append(sorted, pair{pairs[i], pairs[i+1]})
+	}
+	slices.SortFunc(sorted, func(a, b pair) int {
+		if d := len(b.old) - len(a.old); d != 0 {
+			return d
+		}
+		return strings.Compare(a.old, b.old)
+	})
+	var args []string
+	for _, p := range sorted {
+		args = append(args, p.old, p.new)
+	}
+
+	return &PathRedactor{
+		replacer:     strings.NewReplacer(args...),
+		components:   components,
+		placeholders: regexp.MustCompile(`(?:dir_\d{2}/)*(?:file_\d{2}(?:\.\w+)?|dir_\d{2}/?)`),
+	}
+}
+
+var wordRe = regexp.MustCompile(`[\w.\-]+`)
+
+// Redact replaces every known path, directory and sufficiently long path
+// component in s with its placeholder.
+func (r *PathRedactor) Redact(s string) string {
+	s = r.replacer.Replace(s)
+	return wordRe.ReplaceAllStringFunc(s, func(word string) string {
+		if len(word) < minRedactedComponentLength {
+			return word
+		}
+		if p, ok := r.components[word]; ok {
+			return p
+		}
+		return word
+	})
+}
+
+// RedactMessages returns copies of msgs with Redact applied to their content.
+func (r *PathRedactor) RedactMessages(msgs []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	out := make([]openai.ChatCompletionMessage, len(msgs))
+	for i, msg := range msgs {
+		msg.Content = r.Redact(msg.Content)
+		out[i] = msg
+	}
+	return out
+}
+
+var spacesRe = regexp.MustCompile(`[ \t]{2,}`)
+
+// StripPlaceholders removes placeholders from a generated message so that
+// neither real nor redacted paths appear in it.
+func (r *PathRedactor) StripPlaceholders(msg string) string {
+	lines := strings.Split(msg, "\n")
+	for i, line := range lines {
+		line = r.placeholders.ReplaceAllString(line, "")
+		line = strings.ReplaceAll(line, "``", "")
+		line = spacesRe.ReplaceAllString(line, " ")
+		line = strings.ReplaceAll(line, " ,", ",")
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
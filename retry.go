@@ -0,0 +1,81 @@
+package fastcommit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryAfter returns how long the server asked to wait before retrying the
+// request that failed with err, from its Retry-After header. Only the errors
+// of providers made by NewOpenAIProvider, NewOpenRouterProvider and
+// NewAzureProvider carry it, since go-openai's errors leave out the response
+// headers.
+func RetryAfter(err error) (time.Duration, bool) {
+	var e *retryAfterError
+	if errors.As(err, &e) {
+		return e.after, true
+	}
+	return 0, false
+}
+
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+type retryAfterKey struct{}
+
+// withRetryAfter returns a context that makes retryAfterTransport record the
+// Retry-After header of a failed request, and a function that attaches the
+// recorded delay to the request's error.
+func withRetryAfter(ctx context.Context) (context.Context, func(error) error) {
+	after := time.Duration(-1)
+	ctx = context.WithValue(ctx, retryAfterKey{}, &after)
+	return ctx, func(err error) error {
+		if err == nil || after < 0 {
+			return err
+		}
+		return &retryAfterError{err: err, after: after}
+	}
+}
+
+// retryAfterTransport records the Retry-After header of error responses in
+// the delay withRetryAfter put into the request's context.
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	if after, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+		if d, ok := parseRetryAfter(resp.Header, time.Now()); ok {
+			*after = d
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads the delay from the Retry-After header, in seconds or
+// as an HTTP date, or from the Retry-After-Ms header OpenAI sends as well.
+func parseRetryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	if ms, err := strconv.Atoi(h.Get("Retry-After-Ms")); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond, true
+	}
+	v := h.Get("Retry-After")
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
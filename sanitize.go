@@ -0,0 +1,253 @@
+package fastcommit
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Message is a generated commit message on its way through the sanitizers.
+type Message string
+
+// Sanitizer is a named step of the cleanup applied to generated messages.
+type Sanitizer struct {
+	Name string
+	Fn   func(Message) Message
+}
+
+// Sanitizers is an ordered pipeline of cleanup steps.
+type Sanitizers []Sanitizer
+
+// DefaultSanitizers returns the pipeline the fastcommit CLI applies to every
+// generated message. The returned slice is a fresh copy that embedders may
+// reorder, shorten or extend with their own steps.
+func DefaultSanitizers() Sanitizers {
+	return Sanitizers{StripControl, StripFences, TrimSpace, StripMarkdown}
+}
+
+// Apply runs msg through each step in order.
+func (s Sanitizers) Apply(msg string) string {
+	m := Message(msg)
+	for _, step := range s {
+		m = step.Fn(m)
+	}
+	return string(m)
+}
+
+// Without returns the pipeline without the steps with the given names.
+func (s Sanitizers) Without(names ...string) Sanitizers {
+	return slices.DeleteFunc(slices.Clone(s), func(step Sanitizer) bool {
+		return slices.Contains(names, step.Name)
+	})
+}
+
+var (
+	// StripFences removes the backticks of a message the model wrapped in a
+	// code fence.
+	StripFences = Sanitizer{"StripFences", func(m Message) Message {
+		s := string(m)
+		if strings.HasPrefix(s, "```") {
+			s = strings.TrimSuffix(s, "```")
+			s = strings.TrimPrefix(s, "```")
+		}
+		return Message(s)
+	}}
+
+	// StripControl removes control characters other than newlines and tabs,
+	// such as terminal escape sequences the model copied from a crafted
+	// file name or diff.
+	StripControl = Sanitizer{"StripControl", func(m Message) Message {
+		return Message(strings.Map(func(r rune) rune {
+			if r != '\n' && r != '\t' && unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, string(m)))
+	}}
+
+	// TrimSpace removes leading and trailing whitespace.
+	TrimSpace = Sanitizer{"TrimSpace", func(m Message) Message {
+		return Message(strings.TrimSpace(string(m)))
+	}}
+
+	// StripMarkdown undoes the Markdown the model formatted the message
+	// with: emphasis markers, enclosing backticks and heading markers in the
+	// subject, and heading markers and "*" bullets in the body. Headings would
+	// otherwise start with git's comment character and be dropped. Asterisks
+	// that are not emphasis, as in "Ignore *.log files", and backticks around
+	// identifiers in the body are kept.
+	StripMarkdown = Sanitizer{"StripMarkdown", func(m Message) Message {
+		subject, body, ok := strings.Cut(string(m), "\n")
+		subject = headingRe.ReplaceAllString(subject, "")
+		subject = stripEmphasis(stripEmphasis(stripEmphasis(subject, "***"), "**"), "*")
+		if len(subject) > 2 && strings.HasPrefix(subject, "`") && strings.HasSuffix(subject, "`") &&
+			!strings.Contains(subject[1:len(subject)-1], "`") {
+			subject = subject[1 : len(subject)-1]
+		}
+		subject = strings.TrimSpace(subject)
+		if !ok {
+			return Message(subject)
+		}
+		body = headingRe.ReplaceAllString(body, "")
+		body = starBulletRe.ReplaceAllString(body, "${1}- ")
+		return Message(subject + "\n" + body)
+	}}
+
+	// TrimLabels removes a label the model put before the message, such as
+	// "Commit message:".
+	TrimLabels = Sanitizer{"TrimLabels", func(m Message) Message {
+		return Message(labelRe.ReplaceAllString(string(m), ""))
+	}}
+
+	// NormalizeWhitespace removes trailing whitespace from every line and
+	// collapses runs of blank lines into one.
+	NormalizeWhitespace = Sanitizer{"NormalizeWhitespace", func(m Message) Message {
+		lines := strings.Split(strings.TrimSpace(string(m)), "\n")
+		var out []string
+		for _, line := range lines {
+			line = strings.TrimRight(line, " \t\r")
+			if line == "" && len(out) > 0 && out[len(out)-1] == "" {
+				continue
+			}
+			out = append(out, line)
+		}
+		return Message(strings.Join(out, "\n"))
+	}}
+
+	// PlainBullets turns the "*", "+" and "•" list markers the model may
+	// use in the body into plain "- " bullets.
+	PlainBullets = Sanitizer{"PlainBullets", func(m Message) Message {
+		subject, body, ok := strings.Cut(string(m), "\n")
+		if !ok {
+			return m
+		}
+		return Message(subject + "\n" + bulletRe.ReplaceAllString(body, "${1}- "))
+	}}
+
+	// DedupTrailers removes repeated lines from the trailer block at the end
+	// of the message, such as a "Fixes #1" the model wrote twice.
+	DedupTrailers = Sanitizer{"DedupTrailers", func(m Message) Message {
+		s := strings.TrimRight(string(m), "\n")
+		i := strings.LastIndex(s, "\n\n")
+		if i < 0 {
+			return m
+		}
+		lines := strings.Split(s[i+2:], "\n")
+		if !slices.ContainsFunc(lines, trailerRe.MatchString) {
+			return m
+		}
+		var kept []string
+		for _, line := range lines {
+			if !trailerRe.MatchString(line) || !slices.Contains(kept, line) {
+				kept = append(kept, line)
+			}
+		}
+		return Message(s[:i+2] + strings.Join(kept, "\n"))
+	}}
+)
+
+var (
+	labelRe      = regexp.MustCompile(`^(?i)(?:commit message|message|subject)\s*:\s*`)
+	bulletRe     = regexp.MustCompile(`(?m)^([ \t]*)(?:[-*+]|•)[ \t]+`)
+	headingRe    = regexp.MustCompile(`(?m)^#{1,6}[ \t]+`)
+	starBulletRe = regexp.MustCompile(`(?m)^([ \t]*)\*[ \t]+`)
+	trailerRe    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*:\s|^(?i:close[sd]?|fix(?:e[sd])?|resolve[sd]?) `)
+)
+
+// stripEmphasis removes the pairs of marker around text in s. As in
+// Markdown, emphasis opens at the start of a word and closes at the end of
+// one, so that the asterisks of globs such as *.log or src/*/x stay. "_" is
+// left alone for identifiers such as __init__.
+func stripEmphasis(s, marker string) string {
+	opens := func(i int) bool {
+		before, after := s[:i], s[i+len(marker):]
+		return (before == "" || strings.ContainsAny(before[len(before)-1:], " \t(\"'")) &&
+			after != "" && !strings.ContainsAny(after[:1], " \t*")
+	}
+	closes := func(i int) bool {
+		before, after := s[:i], s[i+len(marker):]
+		return before != "" && !strings.ContainsAny(before[len(before)-1:], " \t*") &&
+			(after == "" || strings.ContainsAny(after[:1], " \t).,:;!?\"'"))
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, marker)
+		for i >= 0 && !opens(i) {
+			next := strings.Index(s[i+len(marker):], marker)
+			if next < 0 {
+				i = -1
+				break
+			}
+			i += len(marker) + next
+		}
+		if i < 0 {
+			break
+		}
+		j := -1
+		for k := i + len(marker); k < len(s); {
+			next := strings.Index(s[k:], marker)
+			if next < 0 {
+				break
+			}
+			if closes(k + next) {
+				j = k + next
+				break
+			}
+			k += next + len(marker)
+		}
+		if j < 0 {
+			break
+		}
+		b.WriteString(s[:i])
+		b.WriteString(s[i+len(marker) : j])
+		s = s[j+len(marker):]
+	}
+	return b.String() + s
+}
+
+// WrapBody returns a step that wraps the paragraphs and list items of the
+// body at width columns. The subject, indented lines and lines that cannot be
+// broken, such as long URLs, are left alone.
+func WrapBody(width int) Sanitizer {
+	return Sanitizer{"WrapBody", func(m Message) Message {
+		subject, body, ok := strings.Cut(string(m), "\n")
+		if !ok || width <= 0 {
+			return m
+		}
+		var out []string
+		for _, line := range strings.Split(body, "\n") {
+			out = append(out, wrapLine(line, width)...)
+		}
+		return Message(subject + "\n" + strings.Join(out, "\n"))
+	}}
+}
+
+// wrapLine breaks line at spaces so that no piece is wider than width. The
+// pieces of a list item are indented to line up with its text.
+func wrapLine(line string, width int) []string {
+	if utf8.RuneCountInString(line) <= width || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+		return []string{line}
+	}
+	indent := ""
+	if m := listItemRe.FindString(line); m != "" {
+		indent = strings.Repeat(" ", len(m))
+	}
+	var out []string
+	cur := ""
+	for _, word := range strings.Fields(line) {
+		switch {
+		case cur == "":
+			cur = word
+		case utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) > width:
+			out = append(out, cur)
+			cur = indent + word
+		default:
+			cur += " " + word
+		}
+	}
+	return append(out, cur)
+}
+
+var listItemRe = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+`)
@@ -0,0 +1,143 @@
+package fastcommit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestSanitizerSteps(t *testing.T) {
+	tests := []struct {
+		step    Sanitizer
+		in, out string
+	}{
+		{StripFences, "```\nAdd x\n```", "\nAdd x\n"},
+		{StripFences, "Use ``` fences in docs", "Use ``` fences in docs"},
+		{StripControl, "Fix \x1b[1mbold\x1b[0m\x00 text\n\n\tindented", "Fix [1mbold[0m text\n\n\tindented"},
+		{StripControl, "Keep é and 日本\r\n", "Keep é and 日本\n"},
+		{TrimSpace, "\n\n  Add x \n\n", "Add x"},
+		{TrimLabels, "Commit message: Add x", "Add x"},
+		{TrimLabels, "subject:Add x", "Add x"},
+		{TrimLabels, "Add message: field", "Add message: field"},
+		{NormalizeWhitespace, "Add x  \n\n\n\nBody \t\n\n", "Add x\n\nBody"},
+		{PlainBullets, "Add x\n\n* one\n  + two\n• three", "Add x\n\n- one\n  - two\n- three"},
+		{PlainBullets, "* Add x", "* Add x"},
+		{DedupTrailers, "Add x\n\nFixes #1\nSigned-off-by: A <a@b>\nFixes #1\n", "Add x\n\nFixes #1\nSigned-off-by: A <a@b>"},
+		{DedupTrailers, "Add x\n\nSame line\nSame line", "Add x\n\nSame line\nSame line"},
+		{DedupTrailers, "Fixes #1", "Fixes #1"},
+		{WrapBody(20), "A subject longer than twenty columns\n\nwrap this paragraph at twenty columns\n- and this list item as well", "A subject longer than twenty columns\n\nwrap this paragraph\nat twenty columns\n- and this list item\n  as well"},
+		{WrapBody(20), "Add x\n\n    indented code stays as it is\nhttps://example.com/a/very/long/url", "Add x\n\n    indented code stays as it is\nhttps://example.com/a/very/long/url"},
+		{WrapBody(0), "Add x\n\nno width, no wrapping at all", "Add x\n\nno width, no wrapping at all"},
+	}
+	for _, tt := range tests {
+		if got := string(tt.step.Fn(Message(tt.in))); got != tt.out {
+			t.Errorf("%s(%q) = %q, want %q", tt.step.Name, tt.in, got, tt.out)
+		}
+	}
+}
+
+func TestStripMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		in, out string
+	}{
+		{"bold subject", "**Add caching**", "Add caching"},
+		{"italic subject", "*Add caching*", "Add caching"},
+		{"backticked subject", "`Add caching`", "Add caching"},
+		{"bold word", "Add **response** caching", "Add response caching"},
+		{"nested emphasis", "***Add caching***", "Add caching"},
+		{"emphasis in parentheses", "Fix parser (*again*)", "Fix parser (again)"},
+		{"heading subject", "## Fix the parser", "Fix the parser"},
+		{"glob", "Ignore *.log files", "Ignore *.log files"},
+		{"two globs", "Match *.go and *_test.go", "Match *.go and *_test.go"},
+		{"glob in a path", "Clean src/*/tmp and build/*", "Clean src/*/tmp and build/*"},
+		{"bare glob", "Ignore * in paths", "Ignore * in paths"},
+		{"multiplication", "Compute 2*3 inline", "Compute 2*3 inline"},
+		{"spaced multiplication", "Simplify a * b * c", "Simplify a * b * c"},
+		{"pointer", "Return *Config from load", "Return *Config from load"},
+		{"underscores", "Rename __init__ hooks", "Rename __init__ hooks"},
+		{"unbalanced bold", "Fix **unterminated emphasis", "Fix **unterminated emphasis"},
+		{"identifiers in backticks", "Use `Parse` in `Load`", "Use `Parse` in `Load`"},
+		{"body bullets", "Add x\n\n* one\n  * two\n*not a bullet*", "Add x\n\n- one\n  - two\n*not a bullet*"},
+		{"body heading", "Add x\n\n# Details\nText", "Add x\n\nDetails\nText"},
+		{"body keeps code", "Add x\n\nCall `Parse` with **opts**.", "Add x\n\nCall `Parse` with **opts**."},
+		{"issue reference", "Fix #12 in parser", "Fix #12 in parser"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(StripMarkdown.Fn(Message(tt.in))); got != tt.out {
+				t.Errorf("StripMarkdown(%q) = %q, want %q", tt.in, got, tt.out)
+			}
+		})
+	}
+}
+
+func TestSanitizersWithout(t *testing.T) {
+	s := DefaultSanitizers()
+	without := s.Without("StripFences", "NoSuchStep")
+	var names []string
+	for _, step := range without {
+		names = append(names, step.Name)
+	}
+	if want := []string{"StripControl", "TrimSpace", "StripMarkdown"}; !slices.Equal(names, want) {
+		t.Errorf("Without(StripFences) = %q, want %q", names, want)
+	}
+	if len(s) != 4 || s[0].Name != "StripControl" {
+		t.Errorf("Without changed the pipeline it was called on: %v", s)
+	}
+
+	// Embedders may add their own steps.
+	upper := Sanitizer{"Upper", func(m Message) Message { return Message(strings.ToUpper(string(m))) }}
+	if got := append(without, upper).Apply("```\nadd x\n```"); got != "```\nADD X\n```" {
+		t.Errorf("Apply with a custom step = %q", got)
+	}
+}
+
+// TestGoldenSanitize runs every message in testdata/sanitize through the
+// default pipeline, comparing the result with its .golden file, so that the
+// CLI's cleanup does not change by accident.
+func TestGoldenSanitize(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "sanitize", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no messages in testdata/sanitize")
+	}
+	for _, in := range inputs {
+		b, err := os.ReadFile(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimSuffix(filepath.Base(in), ".txt")
+		golden(t, filepath.Join("sanitize", name+".golden"), DefaultSanitizers().Apply(string(b))+"\n")
+	}
+}
+
+func FuzzSanitizers(f *testing.F) {
+	for _, seed := range []string{
+		"```\nAdd x\n```",
+		"**Fix** the *parser*\n\n* one\n+ two\n• three\n\nFixes #1\nFixes #1",
+		"Update \x1b[31mbanner\x1b[0m\x07\r\n",
+		"Commit message: ``` `x` ```",
+		"# \n\n**\n*a*b*c**d**",
+		"\xff\xfe invalid * utf-8 *",
+	} {
+		f.Add(seed, 20)
+	}
+	all := append(DefaultSanitizers(), TrimLabels, NormalizeWhitespace, PlainBullets, DedupTrailers)
+	f.Fuzz(func(t *testing.T, msg string, width int) {
+		out := append(all, WrapBody(width%200)).Apply(msg)
+		for _, r := range out {
+			if r != '\n' && r != '\t' && unicode.IsControl(r) {
+				t.Fatalf("control character %U left in %q", r, out)
+			}
+		}
+		for _, step := range all {
+			step.Fn(Message(msg))
+		}
+	})
+}
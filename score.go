@@ -0,0 +1,191 @@
+package fastcommit
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ScoreWeights weighs the parts of a candidate message's score.
+type ScoreWeights struct {
+	Length   float64
+	Subject  float64
+	Coverage float64
+	Denylist float64
+}
+
+// DefaultScoreWeights favors messages that follow the subject line rules and
+// mention what the diff touches.
+var DefaultScoreWeights = ScoreWeights{Length: 1, Subject: 2, Coverage: 2, Denylist: 3}
+
+// DefaultDenylist holds phrases typical of vague or boilerplate messages.
+var DefaultDenylist = []string{
+	"various changes", "minor changes", "some changes", "update code", "updated code",
+	"misc", "this commit", "as an ai", "code changes", "several improvements",
+}
+
+// CandidateScore is the score of one candidate message. Each part is between
+// 0 and 1; Total is their weighted sum.
+type CandidateScore struct {
+	Length   float64
+	Subject  float64
+	Coverage float64
+	Denylist float64
+	Total    float64
+}
+
+// ScoreLength rates the length of msg: 1 for a subject of 10 to subjectLength
+// characters and a body of at most 15 lines, less the further it strays.
+func ScoreLength(msg string, subjectLength int) float64 {
+	if subjectLength <= 0 {
+		subjectLength = DefaultSubjectLength
+	}
+	subject, body, _ := strings.Cut(strings.TrimSpace(msg), "\n")
+	n := utf8.RuneCountInString(strings.TrimSpace(subject))
+	score := 1.0
+	switch {
+	case n == 0:
+		return 0
+	case n < 10:
+		score -= float64(10-n) / 10
+	case n > subjectLength:
+		score -= min(1, float64(n-subjectLength)/float64(subjectLength))
+	}
+	if lines := strings.Count(strings.TrimSpace(body), "\n") + 1; body != "" && lines > 15 {
+		score -= min(0.5, float64(lines-15)/30)
+	}
+	return max(0, score)
+}
+
+// ScoreSubject rates how many of the usual subject line rules msg follows: a
+// single line separated from the body by a blank line, no trailing period,
+// no leading or trailing whitespace, and an imperative first word.
+func ScoreSubject(msg string) float64 {
+	subject, body, hasBody := strings.Cut(msg, "\n")
+	rules := []bool{
+		strings.TrimSpace(subject) == subject && subject != "",
+		!strings.HasSuffix(subject, "."),
+		!hasBody || body == "" || strings.HasPrefix(body, "\n"),
+		imperative(subject),
+	}
+	ok := 0
+	for _, r := range rules {
+		if r {
+			ok++
+		}
+	}
+	return float64(ok) / float64(len(rules))
+}
+
+var conventionalPrefixRe = regexp.MustCompile(`^[a-z]+(\([^)]*\))?!?: `)
+
+// imperative guesses whether the first word of subject, after any
+// Conventional Commits type or bracketed tag, is an imperative verb.
+func imperative(subject string) bool {
+	subject = conventionalPrefixRe.ReplaceAllString(subject, "")
+	for strings.HasPrefix(subject, "[") {
+		_, rest, ok := strings.Cut(subject, "] ")
+		if !ok {
+			break
+		}
+		subject = rest
+	}
+	first, _, _ := strings.Cut(strings.ToLower(subject), " ")
+	first = strings.TrimRightFunc(first, func(r rune) bool { return !unicode.IsLetter(r) })
+	return first != "" &&
+		!strings.HasSuffix(first, "ed") &&
+		!strings.HasSuffix(first, "ing") &&
+		!(strings.HasSuffix(first, "s") && !strings.HasSuffix(first, "ss"))
+}
+
+// ScoreCoverage returns the fraction of terms that msg mentions, ignoring
+// case. It is 1 when there are no terms.
+func ScoreCoverage(msg string, terms []string) float64 {
+	if len(terms) == 0 {
+		return 1
+	}
+	lower := strings.ToLower(msg)
+	n := 0
+	for _, t := range terms {
+		if strings.Contains(lower, strings.ToLower(t)) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(terms))
+}
+
+// ScoreDenylist returns 1 for a message containing none of the denied
+// phrases, and half as much for each one it contains.
+func ScoreDenylist(msg string, denylist []string) float64 {
+	lower := strings.ToLower(msg)
+	score := 1.0
+	for _, phrase := range denylist {
+		if phrase != "" && strings.Contains(lower, strings.ToLower(phrase)) {
+			score /= 2
+		}
+	}
+	return score
+}
+
+// declarationRe matches the names declared on a diff line in common
+// languages.
+var declarationRe = regexp.MustCompile(
+	`^[-+]\s*(?:func(?:\s*\([^)]*\))?|type|class|def|struct|interface|enum|fn)\s+([A-Za-z_][A-Za-z0-9_]*)`)
+
+// DiffTerms extracts what a good message for diff is likely to mention: the
+// base names of the changed files without extension and the names declared
+// on changed lines. At most max terms are returned.
+func DiffTerms(diff string, max int) []string {
+	var terms []string
+	add := func(t string) {
+		if len(t) >= 3 && len(terms) < max && !slices.Contains(terms, t) {
+			terms = append(terms, t)
+		}
+	}
+	files := SplitDiff(diff)
+	for _, f := range files {
+		base := path.Base(f.Path)
+		add(strings.TrimSuffix(base, path.Ext(base)))
+	}
+	for _, f := range files {
+		removed, added := changedLines(f.Diff)
+		for _, line := range append(added, removed...) {
+			if m := declarationRe.FindStringSubmatch("+" + line); m != nil {
+				add(m[1])
+			}
+		}
+	}
+	return terms
+}
+
+// ScoreCandidates scores each candidate message for a change described by
+// terms, as returned by DiffTerms.
+func ScoreCandidates(msgs, terms []string, subjectLength int, w ScoreWeights) []CandidateScore {
+	scores := make([]CandidateScore, len(msgs))
+	for i, msg := range msgs {
+		s := CandidateScore{
+			Length:   ScoreLength(msg, subjectLength),
+			Subject:  ScoreSubject(msg),
+			Coverage: ScoreCoverage(msg, terms),
+			Denylist: ScoreDenylist(msg, DefaultDenylist),
+		}
+		s.Total = w.Length*s.Length + w.Subject*s.Subject + w.Coverage*s.Coverage + w.Denylist*s.Denylist
+		scores[i] = s
+	}
+	return scores
+}
+
+// BestCandidate returns the index of the highest total score. Ties go to the
+// earliest candidate.
+func BestCandidate(scores []CandidateScore) int {
+	best := 0
+	for i, s := range scores {
+		if s.Total > scores[best].Total {
+			best = i
+		}
+	}
+	return best
+}
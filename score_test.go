@@ -0,0 +1,95 @@
+package fastcommit
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const scoreDiff = `diff --git a/uploader/retry.go b/uploader/retry.go
+index 1111111..2222222 100644
+--- a/uploader/retry.go
++++ b/uploader/retry.go
+@@ -1,3 +1,8 @@
+ package uploader
++
++// RetryChunk uploads a chunk again after a failure.
++func RetryChunk(c Chunk) error {
++	return upload(c)
++}
+`
+
+func TestDiffTerms(t *testing.T) {
+	if got, want := DiffTerms(scoreDiff, 10), []string{"retry", "RetryChunk"}; !slices.Equal(got, want) {
+		t.Errorf("DiffTerms = %q, want %q", got, want)
+	}
+	if got := DiffTerms(scoreDiff, 1); len(got) != 1 {
+		t.Errorf("DiffTerms with max 1 = %q", got)
+	}
+}
+
+func TestBestCandidateNotLongest(t *testing.T) {
+	msgs := []string{
+		"Updated code with various changes to the uploader and some other files.\n" +
+			strings.Repeat("This commit makes several improvements to how things work.\n", 20),
+		"Add RetryChunk to retry failed uploads",
+		"Fix stuff",
+	}
+	scores := ScoreCandidates(msgs, DiffTerms(scoreDiff, 10), 0, DefaultScoreWeights)
+	longest := 0
+	for i, m := range msgs {
+		if len(m) > len(msgs[longest]) {
+			longest = i
+		}
+	}
+	if got := BestCandidate(scores); got != 1 || got == longest {
+		t.Errorf("BestCandidate = %d, want 1; scores %+v", got, scores)
+	}
+}
+
+func TestBestCandidateTies(t *testing.T) {
+	msgs := []string{"Add the retry logic", "Add the retry logic", "Add the retry logic"}
+	scores := ScoreCandidates(msgs, nil, 0, DefaultScoreWeights)
+	if got := BestCandidate(scores); got != 0 {
+		t.Errorf("BestCandidate of equal scores = %d, want 0", got)
+	}
+}
+
+func TestScoreCandidatesWeights(t *testing.T) {
+	// A well-formed message that misses the terms and a sloppy one that
+	// names them: the weights decide.
+	msgs := []string{"Add retry logic", "added RetryChunk."}
+	terms := []string{"RetryChunk"}
+	if got := BestCandidate(ScoreCandidates(msgs, terms, 0, ScoreWeights{Subject: 1})); got != 0 {
+		t.Errorf("subject rules only: picked %d", got)
+	}
+	if got := BestCandidate(ScoreCandidates(msgs, terms, 0, ScoreWeights{Coverage: 1})); got != 1 {
+		t.Errorf("coverage only: picked %d", got)
+	}
+}
+
+func TestScoreParts(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"length of a good subject", ScoreLength("Add retry logic to uploads", 50), 1},
+		{"length of an empty message", ScoreLength("  ", 50), 0},
+		{"length of a short subject", ScoreLength("Fix stuff", 50), 0.9},
+		{"length of a subject twice too long", ScoreLength(strings.Repeat("x", 100), 50), 0},
+		{"all subject rules", ScoreSubject("Add retry\n\nBody."), 1},
+		{"past tense with a period", ScoreSubject("Added retry."), 0.5},
+		{"conventional prefix", ScoreSubject("feat(upload): add retry"), 1},
+		{"body without a blank line", ScoreSubject("Add retry\nBody"), 0.75},
+		{"coverage without terms", ScoreCoverage("Add retry", nil), 1},
+		{"coverage ignoring case", ScoreCoverage("add retrychunk", []string{"RetryChunk", "upload"}), 0.5},
+		{"no denied phrases", ScoreDenylist("Add retry", DefaultDenylist), 1},
+		{"two denied phrases", ScoreDenylist("Misc: various changes", DefaultDenylist), 0.25},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
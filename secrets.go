@@ -0,0 +1,231 @@
+package fastcommit
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// The kinds of secrets RedactSecrets finds.
+const (
+	SecretAWSAccessKey  = "aws-access-key"
+	SecretGitHubToken   = "github-token"
+	SecretPrivateKey    = "private-key"
+	SecretAssignment    = "generic-secret"
+	SecretSensitiveFile = "sensitive-file"
+)
+
+// SensitiveFiles are the files, as MatchGlob patterns, whose changes are
+// never sent, since they usually hold credentials.
+var SensitiveFiles = []string{
+	".env", ".env.*", "*.env",
+	"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+	"*.pem", "*.p12", "*.pfx",
+	"credentials", ".netrc",
+}
+
+// Secret is a likely credential found in a prompt.
+type Secret struct {
+	// Kind is what was found, e.g. SecretAWSAccessKey.
+	Kind string
+	// File and Line locate the secret in the diff: Line is in the new
+	// version of File, or in the old one for a removed line, and zero for a
+	// sensitive file as a whole. Outside the diff, e.g. in --context, File
+	// is empty and Line counts the lines of the message.
+	File string
+	Line int
+	// Message is the index of the prompt message holding the secret.
+	Message int
+}
+
+func (s Secret) String() string {
+	switch {
+	case s.File == "":
+		return fmt.Sprintf("message %d, line %d: %s", s.Message+1, s.Line, s.Kind)
+	case s.Line == 0:
+		return fmt.Sprintf("%s: %s", s.File, s.Kind)
+	}
+	return fmt.Sprintf("%s:%d: %s", s.File, s.Line, s.Kind)
+}
+
+var (
+	awsAccessKeyRe = regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)
+	githubTokenRe  = regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)
+	privateKeyRe   = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----`)
+	privateKeyEnd  = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY( BLOCK)?-----`)
+	// assignmentRe matches a value assigned to a name that suggests a
+	// credential, e.g. API_KEY=... or "client_secret": "...".
+	assignmentRe = regexp.MustCompile(`(?i)[\w.-]*(?:api[_-]?key|secret|token|passw(?:or)?d|access[_-]?key)[\w.-]*["']?\s*(?::=|=|:)\s*["']?([^\s"'` + "`" + `,;]+)`)
+	hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)`)
+)
+
+// minSecretLength and minSecretEntropy are what a value assigned to a name
+// such as API_KEY must reach to count as a secret: random keys are long and
+// varied, while placeholders, test fixtures and code are not.
+const (
+	minSecretLength  = 16
+	minSecretEntropy = 3.5
+)
+
+// IsSensitiveFile reports whether path matches SensitiveFiles.
+func IsSensitiveFile(path string) bool {
+	for _, p := range SensitiveFiles {
+		if MatchGlob(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactSecrets returns copies of msgs with every line holding a likely
+// secret replaced by "[REDACTED: kind]", along with the secrets found. The
+// changed lines of sensitive files are all redacted, since what they hold
+// cannot be told apart from a secret. Lines of a diff keep their +, - or
+// space prefix, so that it still reads as a diff.
+func RedactSecrets(msgs []openai.ChatCompletionMessage) ([]openai.ChatCompletionMessage, []Secret) {
+	out := make([]openai.ChatCompletionMessage, len(msgs))
+	var found []Secret
+	for i, msg := range msgs {
+		var secrets []Secret
+		msg.Content, secrets = redactSecrets(msg.Content, i)
+		found = append(found, secrets...)
+		out[i] = msg
+	}
+	return out, found
+}
+
+func redactSecrets(content string, message int) (string, []Secret) {
+	var found []Secret
+	var (
+		file                     string
+		sensitive, inHunk, inKey bool
+		oldLine, newLine         int
+	)
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file, inHunk, inKey = "", false, false
+			if _, b, ok := strings.Cut(line, " b/"); ok {
+				file = b
+			}
+			sensitive = IsSensitiveFile(file)
+			if sensitive {
+				found = append(found, Secret{Kind: SecretSensitiveFile, File: file, Message: message})
+			}
+			continue
+		case strings.HasPrefix(line, "@@ "):
+			if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+				oldLine, _ = strconv.Atoi(m[1])
+				newLine, _ = strconv.Atoi(m[2])
+				inHunk = true
+			}
+			continue
+		}
+
+		prefix, text, at, where := "", line, i+1, ""
+		if inHunk && line != "" && strings.ContainsRune("+- ", rune(line[0])) {
+			prefix, text, where = line[:1], line[1:], file
+			if prefix == "-" {
+				at = oldLine
+				oldLine++
+			} else {
+				at = newLine
+				newLine++
+				if prefix == " " {
+					oldLine++
+				}
+			}
+		} else if inHunk && !strings.HasPrefix(line, `\`) {
+			// The diff ended, e.g. before the rest of the prompt.
+			inHunk, sensitive, file = false, false, ""
+		}
+
+		kind := ""
+		switch {
+		case sensitive && where != "":
+			kind = SecretSensitiveFile
+		case inKey:
+			kind = SecretPrivateKey
+			inKey = !privateKeyEnd.MatchString(text)
+		default:
+			if kind = findSecret(text); kind == SecretPrivateKey {
+				inKey = !privateKeyEnd.MatchString(text)
+			}
+			if kind != "" {
+				found = append(found, Secret{Kind: kind, File: where, Line: at, Message: message})
+			}
+		}
+		if kind != "" {
+			lines[i] = prefix + "[REDACTED: " + kind + "]"
+		}
+	}
+	if len(found) == 0 {
+		return content, nil
+	}
+	return strings.Join(lines, "\n"), found
+}
+
+// findSecret returns the kind of the first secret in line, or "".
+func findSecret(line string) string {
+	for _, m := range awsAccessKeyRe.FindAllString(line, -1) {
+		// AWS documents its example keys this way.
+		if !strings.HasSuffix(m, "EXAMPLE") {
+			return SecretAWSAccessKey
+		}
+	}
+	if githubTokenRe.MatchString(line) {
+		return SecretGitHubToken
+	}
+	if privateKeyRe.MatchString(line) {
+		return SecretPrivateKey
+	}
+	for _, m := range assignmentRe.FindAllStringSubmatch(line, -1) {
+		if looksRandom(m[1]) {
+			return SecretAssignment
+		}
+	}
+	return ""
+}
+
+// looksRandom reports whether v could be a generated key rather than a
+// placeholder, a word or code such as os.Getenv("API_KEY").
+func looksRandom(v string) bool {
+	if len(v) < minSecretLength || strings.ContainsAny(v, "()[]{}<>$") {
+		return false
+	}
+	lower := strings.ToLower(v)
+	for _, s := range []string{"example", "xxxx", "your", "changeme", "dummy", "redacted"} {
+		if strings.Contains(lower, s) {
+			return false
+		}
+	}
+	if !strings.ContainsAny(v, "0123456789") || strings.IndexFunc(v, isLetter) < 0 {
+		return false
+	}
+	return entropy(v) >= minSecretEntropy
+}
+
+func isLetter(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
+
+// entropy returns the Shannon entropy of s in bits per byte.
+func entropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var h float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
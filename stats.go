@@ -0,0 +1,77 @@
+package fastcommit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileStat is the size of the change to a single file.
+type FileStat struct {
+	Path    string
+	Added   int
+	Deleted int
+	// Binary is set for binary files, for which git reports no line counts.
+	Binary bool
+}
+
+// Lines returns the number of changed lines.
+func (s FileStat) Lines() int {
+	return s.Added + s.Deleted
+}
+
+// DiffStats returns per-file change sizes for the changes described by opts.
+func DiffStats(dir string, opts PromptOptions) ([]FileStat, error) {
+	args, err := diffArgs(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	args = append([]string{"diff", "--numstat", "--no-renames", "-z"}, args...)
+	if err := runGit(&buf, dir, args...); err != nil {
+		return nil, err
+	}
+
+	var stats []FileStat
+	for _, rec := range strings.Split(buf.String(), "\x00") {
+		fields := strings.SplitN(rec, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		s := FileStat{Path: fields[2]}
+		if fields[0] == "-" && fields[1] == "-" {
+			s.Binary = true
+		} else {
+			s.Added, _ = strconv.Atoi(fields[0])
+			s.Deleted, _ = strconv.Atoi(fields[1])
+		}
+		stats = append(stats, s)
+	}
+
+	if opts.WorkingTree && opts.IncludeUntracked {
+		root, err := findGitRoot(dir)
+		if err != nil {
+			return nil, err
+		}
+		untracked, err := untrackedFiles(dir, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range untracked {
+			b, err := os.ReadFile(filepath.Join(root, path))
+			if err != nil {
+				return nil, err
+			}
+			s := FileStat{Path: path}
+			if bytes.IndexByte(b, 0) >= 0 {
+				s.Binary = true
+			} else {
+				s.Added = bytes.Count(b, []byte("\n"))
+			}
+			stats = append(stats, s)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,72 @@
+package fastcommit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// WorkingTreeStatus lists changes in the working tree that are not staged.
+type WorkingTreeStatus struct {
+	// Unstaged holds tracked files with unstaged changes.
+	Unstaged []string
+	// Untracked holds untracked files. Untracked directories are listed once,
+	// with a trailing slash.
+	Untracked []string
+}
+
+// Status returns the unstaged and untracked files of the repository at dir.
+// Paths are relative to the repository root.
+func Status(dir string) (WorkingTreeStatus, error) {
+	var st WorkingTreeStatus
+	var buf bytes.Buffer
+	if err := runGit(&buf, dir, "status", "--porcelain=v1", "-z"); err != nil {
+		return st, err
+	}
+	entries := splitNUL(buf.String())
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		if len(e) < 4 {
+			continue
+		}
+		x, y, path := e[0], e[1], e[3:]
+		if x == 'R' || x == 'C' {
+			// Renames and copies are followed by the original path.
+			i++
+		}
+		switch {
+		case x == '?':
+			st.Untracked = append(st.Untracked, path)
+		case y != ' ':
+			st.Unstaged = append(st.Unstaged, path)
+		}
+	}
+	return st, nil
+}
+
+// Empty reports whether there are neither unstaged nor untracked files.
+func (st WorkingTreeStatus) Empty() bool {
+	return len(st.Unstaged) == 0 && len(st.Untracked) == 0
+}
+
+// Summary describes st in a few lines, naming at most maxNames files per
+// group. File contents are never included.
+func (st WorkingTreeStatus) Summary(maxNames int) string {
+	var sb strings.Builder
+	group := func(label string, paths []string) {
+		if len(paths) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "- %d %s", len(paths), label)
+		if n := min(maxNames, len(paths)); n > 0 {
+			sb.WriteString(": " + strings.Join(paths[:n], ", "))
+			if n < len(paths) {
+				fmt.Fprintf(&sb, " and %d more", len(paths)-n)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	group("file(s) with unstaged changes", st.Unstaged)
+	group("untracked file(s)", st.Untracked)
+	return sb.String()
+}
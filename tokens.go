@@ -0,0 +1,73 @@
+package fastcommit
+
+import (
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+// Encoding returns the name of the tokenizer encoding used for model. Models
+// the tokenizer does not know, such as those of other OpenAI-compatible
+// providers, are counted with cl100k_base.
+func Encoding(model string) string {
+	return codecForModel(model).GetName()
+}
+
+func codecForModel(model string) tokenizer.Codec {
+	if enc, err := tokenizer.ForModel(tokenizer.Model(model)); err == nil {
+		return enc
+	}
+	enc, err := tokenizer.Get(tokenizer.Cl100kBase)
+	if err != nil {
+		panic("failed to get tokenizer")
+	}
+	return enc
+}
+
+// CountTokensForModel is like CountTokens but uses the encoding of model.
+func CountTokensForModel(model string, msgs ...openai.ChatCompletionMessage) int {
+	enc := codecForModel(model)
+
+	var tokens int
+	for _, msg := range msgs {
+		tokens += countText(enc, msg.Content)
+
+		for _, call := range msg.ToolCalls {
+			tokens += countText(enc, call.Function.Arguments)
+		}
+	}
+	return tokens
+}
+
+// contextWindows maps model name prefixes to their context window in tokens.
+// Longer prefixes are listed first so the most specific one wins.
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-1106", 128000},
+	{"gpt-4-0125", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo-instruct", 4096},
+	{"gpt-3.5-turbo", 16385},
+	{"o1", 128000},
+}
+
+// DefaultContextWindow is assumed for models whose context window is not
+// known.
+const DefaultContextWindow = 128000
+
+// ContextWindow returns the context window of model in tokens, and whether
+// the model is known.
+func ContextWindow(model string) (int, bool) {
+	for _, w := range contextWindows {
+		if strings.HasPrefix(model, w.prefix) {
+			return w.tokens, true
+		}
+	}
+	return DefaultContextWindow, false
+}
@@ -0,0 +1,151 @@
+package fastcommit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultVendorDirs are the directories whose files are vendored
+// dependencies, wherever they are in the repository.
+var DefaultVendorDirs = []string{"vendor", "third_party", "node_modules"}
+
+// VendorSummary describes the changes to one vendor directory.
+type VendorSummary struct {
+	// Dir is the vendor directory, e.g. "vendor" or "web/node_modules".
+	Dir   string
+	Files int
+	// Modules are the module changes recorded in Dir/modules.txt, for Go
+	// vendor directories.
+	Modules []ModuleChange
+}
+
+// ModuleChange is a vendored module that was added, removed or updated.
+// Old is empty for an added module, New for a removed one.
+type ModuleChange struct {
+	Path string
+	Old  string
+	New  string
+}
+
+func (m ModuleChange) String() string {
+	switch {
+	case m.Old == "":
+		return fmt.Sprintf("%s added %s", m.Path, m.New)
+	case m.New == "":
+		return fmt.Sprintf("%s removed", m.Path)
+	default:
+		return fmt.Sprintf("%s updated %s→%s", m.Path, m.Old, m.New)
+	}
+}
+
+func (s VendorSummary) String() string {
+	var parts []string
+	for _, m := range s.Modules {
+		parts = append(parts, m.String())
+	}
+	files := "files"
+	if s.Files == 1 {
+		files = "file"
+	}
+	parts = append(parts, fmt.Sprintf("%d %s", s.Files, files))
+	return s.Dir + ": " + strings.Join(parts, ", ")
+}
+
+// vendorRoot returns the vendor directory p is in, or "" if it is not
+// vendored.
+func vendorRoot(p string, dirs []string) string {
+	for _, d := range dirs {
+		d = strings.Trim(d, "/")
+		if d == "" {
+			continue
+		}
+		if strings.HasPrefix(p, d+"/") {
+			return d
+		}
+		if i := strings.Index(p, "/"+d+"/"); i >= 0 {
+			return p[:i+1+len(d)]
+		}
+	}
+	return ""
+}
+
+// SummarizeVendored removes the files in vendor directories from diff and
+// summarizes them instead, so that their contents are never sent to the
+// model. Module versions are taken from the changes to vendor/modules.txt.
+func SummarizeVendored(diff string, dirs []string) (rest string, summaries []VendorSummary) {
+	var b strings.Builder
+	byDir := map[string]int{} // index into summaries
+	files := SplitDiff(diff)
+	// Keep anything before the first file as is.
+	n := len(diff)
+	for _, f := range files {
+		n -= len(f.Diff)
+	}
+	b.WriteString(diff[:n])
+	for _, f := range files {
+		root := vendorRoot(f.Path, dirs)
+		if root == "" {
+			b.WriteString(f.Diff)
+			continue
+		}
+		i, ok := byDir[root]
+		if !ok {
+			i = len(summaries)
+			byDir[root] = i
+			summaries = append(summaries, VendorSummary{Dir: root})
+		}
+		summaries[i].Files++
+		if f.Path == root+"/modules.txt" {
+			summaries[i].Modules = moduleChanges(f.Diff)
+		}
+	}
+	return b.String(), summaries
+}
+
+// moduleChanges parses the diff of a Go vendor/modules.txt.
+func moduleChanges(diff string) []ModuleChange {
+	removed, added := changedLines(diff)
+	versions := func(lines []string) map[string]string {
+		m := map[string]string{}
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && fields[0] == "#" {
+				m[fields[1]] = fields[2]
+			}
+		}
+		return m
+	}
+	before, after := versions(removed), versions(added)
+	var changes []ModuleChange
+	for path, v := range after {
+		if before[path] != v {
+			changes = append(changes, ModuleChange{Path: path, Old: before[path], New: v})
+		}
+	}
+	for path, v := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, ModuleChange{Path: path, Old: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// vendoredNote replaces the vendored files of diff with their summaries.
+func vendoredNote(diff string, dirs []string) string {
+	if dirs == nil {
+		dirs = DefaultVendorDirs
+	}
+	rest, summaries := SummarizeVendored(diff, dirs)
+	if len(summaries) == 0 {
+		return diff
+	}
+	var b strings.Builder
+	b.WriteString("Vendored dependencies changed. Describe the dependency change, not the files; " +
+		"their contents are left out:\n")
+	for _, s := range summaries {
+		b.WriteString(s.String() + "\n")
+	}
+	return b.String() + "\n" + rest
+}
@@ -0,0 +1,78 @@
+package fastcommit
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// buildVendorFixture makes a Go module vendoring two modules, then stages
+// an update of one, the removal of the other and the addition of a third,
+// along with a change to the module's own code.
+func buildVendorFixture(t *testing.T, dir string) {
+	writeFile(t, dir, "go.mod", "module example.com/app\n\ngo 1.21\n")
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, dir, "vendor/modules.txt", "# github.com/foo/bar v1.2.0\n## explicit\ngithub.com/foo/bar\n"+
+		"# github.com/old/dep v0.1.0\n## explicit\ngithub.com/old/dep\n")
+	writeFile(t, dir, "vendor/github.com/foo/bar/bar.go", "package bar\n\nconst vendoredBarV1 = 1\n")
+	writeFile(t, dir, "vendor/github.com/old/dep/dep.go", "package dep\n\nconst vendoredOldDep = 1\n")
+	commitAll(t, dir, "Vendor dependencies")
+
+	writeFile(t, dir, "main.go", "package main\n\nimport \"github.com/foo/bar\"\n\nfunc main() { bar.Run() }\n")
+	writeFile(t, dir, "vendor/modules.txt", "# github.com/foo/bar v1.3.1\n## explicit\ngithub.com/foo/bar\n"+
+		"# github.com/new/mod v0.2.0\n## explicit\ngithub.com/new/mod\n")
+	writeFile(t, dir, "vendor/github.com/foo/bar/bar.go", "package bar\n\nconst vendoredBarV2 = 2\n\nfunc Run() {}\n")
+	writeFile(t, dir, "vendor/github.com/foo/bar/run.go", "package bar\n\nconst vendoredBarRun = 1\n")
+	writeFile(t, dir, "vendor/github.com/new/mod/mod.go", "package mod\n\nconst vendoredNewMod = 1\n")
+	gitT(t, dir, "rm", "-q", "-r", "vendor/github.com/old")
+	gitT(t, dir, "add", "-A")
+}
+
+func TestVendoredPrompt(t *testing.T) {
+	dir := newRepo(t)
+	buildVendorFixture(t, dir)
+
+	msgs, err := BuildPromptWithOptions(io.Discard, dir, PromptOptions{MaxTokens: 128000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := promptText(msgs)
+	want := "vendor: github.com/foo/bar updated v1.2.0→v1.3.1, github.com/new/mod added v0.2.0, github.com/old/dep removed, 5 files\n"
+	if !strings.Contains(text, want) {
+		t.Errorf("the prompt lacks the summary %q:\n%s", want, text)
+	}
+	if !strings.Contains(text, "+func main() { bar.Run() }") {
+		t.Errorf("the prompt lacks the change to main.go:\n%s", text)
+	}
+	for _, vendored := range []string{"vendoredBar", "vendoredOldDep", "vendoredNewMod", "## explicit", "diff --git a/vendor/"} {
+		if strings.Contains(text, vendored) {
+			t.Errorf("the prompt contains vendored content %q:\n%s", vendored, text)
+		}
+	}
+}
+
+func TestSummarizeVendored(t *testing.T) {
+	dir := newRepo(t)
+	writeFile(t, dir, "web/node_modules/left-pad/index.js", "module.exports = 1\n")
+	writeFile(t, dir, "web/app.js", "require('left-pad')\n")
+	writeFile(t, dir, "deps/lib/lib.c", "int lib;\n")
+	gitT(t, dir, "add", "-A")
+	diff := gitT(t, dir, "diff", "--cached") + "\n"
+
+	rest, summaries := SummarizeVendored(diff, DefaultVendorDirs)
+	if len(summaries) != 1 || summaries[0].String() != "web/node_modules: 1 file" {
+		t.Errorf("summaries = %v", summaries)
+	}
+	if !strings.Contains(rest, "web/app.js") || !strings.Contains(rest, "deps/lib/lib.c") || strings.Contains(rest, "left-pad/index.js") {
+		t.Errorf("rest of the diff:\n%s", rest)
+	}
+
+	// A configured list replaces the defaults.
+	rest, summaries = SummarizeVendored(diff, []string{"deps/"})
+	if len(summaries) != 1 || summaries[0].String() != "deps: 1 file" {
+		t.Errorf("summaries with deps/ = %v", summaries)
+	}
+	if !strings.Contains(rest, "left-pad/index.js") || strings.Contains(rest, "lib.c") {
+		t.Errorf("rest of the diff with deps/:\n%s", rest)
+	}
+}
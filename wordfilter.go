@@ -0,0 +1,64 @@
+package fastcommit
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultProfanity is a mild list of words that have no place in a commit
+// message. Words such as "hell" are left out since they have technical uses,
+// as in "dependency hell".
+var DefaultProfanity = []string{
+	"asshole", "bastard", "bullshit", "crap", "crappy", "damn", "dammit",
+	"fuck", "fucked", "fucking", "piss", "pissed", "shit", "shitty", "wtf",
+}
+
+// WordFilter finds whole words from a list in text. Words only match on word
+// boundaries, and letters, digits and underscores all count as part of a
+// word, so identifiers such as "class" or "crap_count" are never touched.
+type WordFilter struct {
+	re *regexp.Regexp
+}
+
+// NewWordFilter returns a filter for words, matched case-insensitively. It
+// returns nil if words is empty.
+func NewWordFilter(words []string) *WordFilter {
+	var quoted []string
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	return &WordFilter{re: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+// Find returns the distinct words of the list found in s, in lower case.
+func (f *WordFilter) Find(s string) []string {
+	if f == nil {
+		return nil
+	}
+	var found []string
+	for _, m := range f.re.FindAllString(s, -1) {
+		if m = strings.ToLower(m); !slices.Contains(found, m) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// Mask replaces all but the first letter of each word of the list found in s
+// with asterisks.
+func (f *WordFilter) Mask(s string) string {
+	if f == nil {
+		return s
+	}
+	return f.re.ReplaceAllStringFunc(s, func(m string) string {
+		_, n := utf8.DecodeRuneInString(m)
+		return m[:n] + strings.Repeat("*", utf8.RuneCountInString(m[n:]))
+	})
+}
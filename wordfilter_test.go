@@ -0,0 +1,47 @@
+package fastcommit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWordFilterWordBoundaries(t *testing.T) {
+	f := NewWordFilter(append([]string{"ass", "hell", "cock"}, DefaultProfanity...))
+	tests := []struct {
+		in, masked string
+		found      []string
+	}{
+		// Words containing filtered substrings are left alone.
+		{in: "Add a base class for assertions", masked: "Add a base class for assertions"},
+		{in: "Bypass the shell when hello is passed", masked: "Bypass the shell when hello is passed"},
+		{in: "Rename crap_count and shitake_mode", masked: "Rename crap_count and shitake_mode"},
+		{in: "Fix the cocktail sort and scrapped jobs", masked: "Fix the cocktail sort and scrapped jobs"},
+		{in: "Bump damn2 to v2", masked: "Bump damn2 to v2"},
+		// Whole words are masked in any case, next to punctuation.
+		{in: "Remove the crap", masked: "Remove the c***", found: []string{"crap"}},
+		{in: "WTF: fix the Shitty, damn parser", masked: "W**: fix the S*****, d*** parser", found: []string{"wtf", "shitty", "damn"}},
+		{in: "Fix (hell) and \"hell\"", masked: "Fix (h***) and \"h***\"", found: []string{"hell"}},
+		{in: "ass-backwards", masked: "a**-backwards", found: []string{"ass"}},
+	}
+	for _, tt := range tests {
+		if got := f.Find(tt.in); !reflect.DeepEqual(got, tt.found) {
+			t.Errorf("Find(%q) = %q, want %q", tt.in, got, tt.found)
+		}
+		if got := f.Mask(tt.in); got != tt.masked {
+			t.Errorf("Mask(%q) = %q, want %q", tt.in, got, tt.masked)
+		}
+	}
+}
+
+func TestWordFilterEmpty(t *testing.T) {
+	f := NewWordFilter([]string{"", "  "})
+	if f != nil {
+		t.Fatalf("NewWordFilter of blank words = %v, want nil", f)
+	}
+	if got := f.Find("damn"); got != nil {
+		t.Errorf("Find on nil filter = %q", got)
+	}
+	if got := f.Mask("damn"); got != "damn" {
+		t.Errorf("Mask on nil filter = %q", got)
+	}
+}